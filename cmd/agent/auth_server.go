@@ -19,26 +19,56 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	stdlog "log"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/ettle/strcase"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/auth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
 	hubinformers "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/informers/externalversions"
 	"github.com/traefik/hub-agent-kubernetes/pkg/kube"
 	"github.com/traefik/hub-agent-kubernetes/pkg/logger"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
 	"github.com/traefik/hub-agent-kubernetes/pkg/version"
 	"github.com/urfave/cli/v2"
 	kinformers "k8s.io/client-go/informers"
 	kclientset "k8s.io/client-go/kubernetes"
 )
 
+const (
+	flagDecisionLogStdout             = "decision-log.stdout"
+	flagDecisionLogFile               = "decision-log.file"
+	flagDecisionLogFileMaxSize        = "decision-log.file-max-size"
+	flagDecisionLogFileMaxBackups     = "decision-log.file-max-backups"
+	flagDecisionLogWebhookURL         = "decision-log.webhook-url"
+	flagDecisionLogWebhookBatchSize   = "decision-log.webhook-batch-size"
+	flagDecisionLogWebhookFlushPeriod = "decision-log.webhook-flush-interval"
+	flagLockoutStore                  = "lockout.store"
+	flagRedisAddress                  = "redis.address"
+	flagRedisUsername                 = "redis.username"
+	flagRedisPassword                 = "redis.password"
+	flagRedisDB                       = "redis.db"
+	flagRedisTLS                      = "redis.tls"
+	flagSessionAdminToken             = "session.admin-token"
+)
+
+const (
+	storeMemory = "memory"
+	storeRedis  = "redis"
+)
+
 type authServerCmd struct {
 	flags []cli.Flag
 }
@@ -51,6 +81,81 @@ func newAuthServerCmd() authServerCmd {
 			EnvVars: []string{"AUTH_SERVER_LISTEN_ADDR"},
 			Value:   "0.0.0.0:80",
 		},
+		&cli.BoolFlag{
+			Name:    flagDecisionLogStdout,
+			Usage:   "Write authorization decisions to the standard output as JSON",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogStdout)},
+		},
+		&cli.StringFlag{
+			Name:    flagDecisionLogFile,
+			Usage:   "Path of the file authorization decisions are written to as JSON",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogFile)},
+		},
+		&cli.Int64Flag{
+			Name:    flagDecisionLogFileMaxSize,
+			Usage:   "Size in megabytes after which the decision log file is rotated",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogFileMaxSize)},
+			Value:   100,
+		},
+		&cli.IntFlag{
+			Name:    flagDecisionLogFileMaxBackups,
+			Usage:   "Number of rotated decision log files to keep",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogFileMaxBackups)},
+			Value:   5,
+		},
+		&cli.StringFlag{
+			Name:    flagDecisionLogWebhookURL,
+			Usage:   "URL authorization decisions are sent to in batches",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogWebhookURL)},
+		},
+		&cli.IntFlag{
+			Name:    flagDecisionLogWebhookBatchSize,
+			Usage:   "Maximum number of authorization decisions sent at once to the decision log webhook",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogWebhookBatchSize)},
+			Value:   decision.DefaultWebhookBatchSize,
+		},
+		&cli.DurationFlag{
+			Name:    flagDecisionLogWebhookFlushPeriod,
+			Usage:   "Maximum duration authorization decisions are kept before being sent to the decision log webhook",
+			EnvVars: []string{strcase.ToSNAKE(flagDecisionLogWebhookFlushPeriod)},
+			Value:   decision.DefaultWebhookFlushInterval,
+		},
+		&cli.StringFlag{
+			Name:    flagLockoutStore,
+			Usage:   "Store of failed authentication attempts and lockouts, either \"memory\" or \"redis\". Use \"redis\" to share lockouts between replicas",
+			EnvVars: []string{strcase.ToSNAKE(flagLockoutStore)},
+			Value:   storeMemory,
+		},
+		&cli.StringFlag{
+			Name:    flagRedisAddress,
+			Usage:   "Address of the Redis compatible server used to share state between replicas",
+			EnvVars: []string{strcase.ToSNAKE(flagRedisAddress)},
+		},
+		&cli.StringFlag{
+			Name:    flagRedisUsername,
+			Usage:   "Username used to authenticate to the Redis compatible server",
+			EnvVars: []string{strcase.ToSNAKE(flagRedisUsername)},
+		},
+		&cli.StringFlag{
+			Name:    flagRedisPassword,
+			Usage:   "Password used to authenticate to the Redis compatible server",
+			EnvVars: []string{strcase.ToSNAKE(flagRedisPassword)},
+		},
+		&cli.IntFlag{
+			Name:    flagRedisDB,
+			Usage:   "Database of the Redis compatible server",
+			EnvVars: []string{strcase.ToSNAKE(flagRedisDB)},
+		},
+		&cli.BoolFlag{
+			Name:    flagRedisTLS,
+			Usage:   "Connect to the Redis compatible server using TLS",
+			EnvVars: []string{strcase.ToSNAKE(flagRedisTLS)},
+		},
+		&cli.StringFlag{
+			Name:    flagSessionAdminToken,
+			Usage:   "Bearer token required to revoke OIDC sessions on the /_sessions/revoke endpoint. The endpoint is disabled if empty",
+			EnvVars: []string{strcase.ToSNAKE(flagSessionAdminToken)},
+		},
 	}
 
 	flgs = append(flgs, globalFlags()...)
@@ -89,13 +194,37 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 		return fmt.Errorf("create Kube client set: %w", err)
 	}
 
+	decisions, closeDecisions, err := newDecisionSink(cliCtx)
+	if err != nil {
+		return fmt.Errorf("create decision log sink: %w", err)
+	}
+	defer closeDecisions()
+
+	redisClient := newRedisClient(cliCtx)
+	if redisClient != nil {
+		defer func() { _ = redisClient.Close() }()
+	}
+
+	lockouts, err := newLockoutStore(cliCtx, redisClient)
+	if err != nil {
+		return fmt.Errorf("create lockout store: %w", err)
+	}
+
+	sessions := oidc.SessionBackends{Memory: oidc.NewMemorySessionBackend()}
+	if redisClient != nil {
+		sessions.Redis = oidc.NewRedisSessionBackend(redisClient)
+	}
+
 	switcher := auth.NewHandlerSwitcher()
 	kubeInformer := kinformers.NewSharedInformerFactory(kubeClientSet, 5*time.Minute)
 	hubInformer := hubinformers.NewSharedInformerFactory(hubClientSet, 5*time.Minute)
 	acpWatcher := auth.NewWatcher(
 		switcher,
 		hubInformer.Hub().V1alpha1().AccessControlPolicies().Lister(),
-		acp.NewKubeSecretValueGetter(kubeInformer.Core().V1().Secrets().Lister()),
+		acp.NewKubeSecretValueGetter(kubeInformer.Core().V1().Secrets().Lister(), kubeClientSet.CoreV1()),
+		decisions,
+		lockouts,
+		sessions,
 	)
 
 	if _, err = hubInformer.Hub().V1alpha1().AccessControlPolicies().Informer().AddEventHandler(acpWatcher); err != nil {
@@ -132,8 +261,31 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 		rw.WriteHeader(http.StatusOK)
 	}))
 	mux.Handle("/_ready", http.HandlerFunc(func(rw http.ResponseWriter, request *http.Request) {
+		if !acpWatcher.Ready() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
 		rw.WriteHeader(http.StatusOK)
 	}))
+	mux.Handle("/_status", http.HandlerFunc(func(rw http.ResponseWriter, request *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(rw).Encode(acpWatcher.Status()); err != nil {
+			log.Error().Err(err).Msg("Unable to write auth server status")
+		}
+	}))
+
+	registry := metrics.NewAuthServerRegistry()
+	registry.Register(metrics.NewGaugeFunc(
+		"hub_agent_auth_handlers",
+		"Number of ACP handlers currently loaded.",
+		func() []metrics.GaugeSample {
+			return []metrics.GaugeSample{{Value: float64(switcher.HandlerCount())}}
+		},
+	))
+	mux.Handle("/metrics", registry)
+	mux.Handle("/_sessions/revoke", oidc.NewRevocationHandler(sessions, cliCtx.String(flagSessionAdminToken)))
 
 	mux.Handle("/", switcher)
 
@@ -171,3 +323,97 @@ func (c authServerCmd) run(cliCtx *cli.Context) error {
 
 	return nil
 }
+
+// newLockoutStore creates the store of failed authentication attempts and lockouts according to the command flags.
+func newLockoutStore(cliCtx *cli.Context, redisClient *redis.Client) (lockout.Store, error) {
+	switch store := cliCtx.String(flagLockoutStore); store {
+	case storeMemory:
+		return lockout.NewMemoryStore(), nil
+	case storeRedis:
+		if redisClient == nil {
+			return nil, fmt.Errorf("%q must be set to use the %q store", flagRedisAddress, storeRedis)
+		}
+
+		return lockout.NewRedisStore(redisClient), nil
+	default:
+		return nil, fmt.Errorf("unsupported store %q, must be one of %q or %q", store, storeMemory, storeRedis)
+	}
+}
+
+// newRedisClient creates a client of the Redis compatible server configured by the command flags. It returns nil if no
+// server is configured.
+func newRedisClient(cliCtx *cli.Context) *redis.Client {
+	addr := cliCtx.String(flagRedisAddress)
+	if addr == "" {
+		return nil
+	}
+
+	return redis.NewClient(redis.Config{
+		Address:  addr,
+		Username: cliCtx.String(flagRedisUsername),
+		Password: cliCtx.String(flagRedisPassword),
+		DB:       cliCtx.Int(flagRedisDB),
+		TLS:      cliCtx.Bool(flagRedisTLS),
+	})
+}
+
+// newDecisionSink creates the sink authorization decisions are written to. Decisions are always reported as metrics and
+// logged according to the command flags. It also returns a function releasing the resources of the sink.
+func newDecisionSink(cliCtx *cli.Context) (decision.Sink, func(), error) {
+	sinks := decision.MultiSink{metrics.DecisionSink{}}
+	var closers []func()
+	closeAll := func() {
+		for _, c := range closers {
+			c()
+		}
+	}
+
+	if cliCtx.Bool(flagDecisionLogStdout) {
+		sinks = append(sinks, decision.NewWriterSink(os.Stdout))
+	}
+
+	if path := cliCtx.String(flagDecisionLogFile); path != "" {
+		fileSink, err := decision.NewFileSink(path, cliCtx.Int64(flagDecisionLogFileMaxSize)*1024*1024, cliCtx.Int(flagDecisionLogFileMaxBackups))
+		if err != nil {
+			return nil, nil, fmt.Errorf("create file sink: %w", err)
+		}
+
+		sinks = append(sinks, fileSink)
+		closers = append(closers, func() {
+			if err = fileSink.Close(); err != nil {
+				log.Error().Err(err).Msg("Unable to close decision log file")
+			}
+		})
+	}
+
+	if url := cliCtx.String(flagDecisionLogWebhookURL); url != "" {
+		webhookSink, err := decision.NewWebhookSink(decision.WebhookConfig{
+			URL:           url,
+			BatchSize:     cliCtx.Int(flagDecisionLogWebhookBatchSize),
+			FlushInterval: cliCtx.Duration(flagDecisionLogWebhookFlushPeriod),
+		})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("create webhook sink: %w", err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			webhookSink.Run(ctx)
+			close(done)
+		}()
+
+		sinks = append(sinks, webhookSink)
+		closers = append(closers, func() {
+			cancel()
+			<-done
+		})
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], closeAll, nil
+	}
+
+	return sinks, closeAll, nil
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -119,6 +120,9 @@ func headerToForward(cfg *acp.Config) ([]string, error) {
 			headerToFwd = append(headerToFwd, headerName)
 		}
 		headerToFwd = append(headerToFwd, "Authorization", "Cookie")
+		if h := cfg.OIDC.AccessTokenHeader; h != "" && !strings.EqualFold(h, "Authorization") {
+			headerToFwd = append(headerToFwd, h)
+		}
 
 	case cfg.OIDCGoogle != nil:
 		for headerName := range cfg.OIDCGoogle.ForwardHeaders {
@@ -131,10 +135,78 @@ func headerToForward(cfg *acp.Config) ([]string, error) {
 			headerToFwd = append(headerToFwd, headerName)
 		}
 
+	case cfg.MTLS != nil:
+		for headerName := range cfg.MTLS.ForwardHeaders {
+			headerToFwd = append(headerToFwd, headerName)
+		}
+
+	case cfg.IPAllowList != nil:
+		// IP allow lists don't forward any header.
+
+	case cfg.ExternalAuthz != nil:
+		headerToFwd = append(headerToFwd, cfg.ExternalAuthz.ForwardHeaders...)
+
+	case len(cfg.AnyOf) > 0:
+		methodHeaders, err := methodsHeaderToForward(cfg.AnyOf)
+		if err != nil {
+			return nil, err
+		}
+		headerToFwd = append(headerToFwd, methodHeaders...)
+
+	case len(cfg.AllOf) > 0:
+		methodHeaders, err := methodsHeaderToForward(cfg.AllOf)
+		if err != nil {
+			return nil, err
+		}
+		headerToFwd = append(headerToFwd, methodHeaders...)
+
 	default:
 		return nil, errors.New("unsupported ACP type")
 	}
 
+	if cfg.IssueToken != nil {
+		headerToFwd = append(headerToFwd, cfg.IssueToken.Header)
+	}
+
+	if cfg.UpstreamToken != nil && !containsHeader(headerToFwd, "Authorization") {
+		headerToFwd = append(headerToFwd, "Authorization")
+	}
+
+	return headerToFwd, nil
+}
+
+// containsHeader returns whether the given header is in the given headers.
+func containsHeader(headers []string, header string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// methodsHeaderToForward returns the headers forwarded by any of the given methods of a composite ACP.
+func methodsHeaderToForward(methods []acp.Config) ([]string, error) {
+	var headerToFwd []string
+
+	seen := make(map[string]struct{})
+	for i := range methods {
+		headers, err := headerToForward(&methods[i])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, header := range headers {
+			if _, ok := seen[header]; ok {
+				continue
+			}
+			seen[header] = struct{}{}
+
+			headerToFwd = append(headerToFwd, header)
+		}
+	}
+
 	return headerToFwd, nil
 }
 
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
 )
 
 const (
@@ -54,15 +55,35 @@ func genNginxAnnotations(polName string, polCfg *acp.Config, agentAddr, groups s
 	locSnip := generateLocationSnippet(headerToFwd)
 
 	if polCfg.OIDC == nil {
-		address := fmt.Sprintf("%s/%s", agentAddr, polName)
+		query := make(url.Values)
 		if groups != "" {
-			address += "?groups=" + url.QueryEscape(groups)
+			query.Set("groups", groups)
 		}
 
-		return map[string]string{
+		// The auth server must read the client certificate from the Nginx header rather than from the Traefik one,
+		// which may have been sent by the client.
+		if usesMTLS(polCfg) {
+			query.Set(mtls.ControllerParam, mtls.ControllerNginx)
+		}
+
+		address := fmt.Sprintf("%s/%s", agentAddr, polName)
+		if len(query) > 0 {
+			address += "?" + query.Encode()
+		}
+
+		anno := map[string]string{
 			authURL:              address,
 			configurationSnippet: wrapHubSnippet(locSnip),
-		}, nil
+		}
+
+		// The client certificate verified by Nginx (see auth-tls-* annotations) is not sent to the auth server by default.
+		// The Traefik header is cleared too, so that a client cannot provide its own certificate through it.
+		if usesMTLS(polCfg) {
+			anno[authSnippet] = wrapHubSnippet(`proxy_set_header ssl-client-cert $ssl_client_escaped_cert;
+proxy_set_header X-Forwarded-Tls-Client-Cert "";`)
+		}
+
+		return anno, nil
 	}
 
 	redirectPath, err := redirectPath(polCfg)
@@ -78,8 +99,13 @@ proxy_set_header X-Forwarded-Proto $scheme;
 proxy_set_header X-Forwarded-Method $request_method;`
 	authServerURL := fmt.Sprintf("%s/%s", agentAddr, polName)
 
+	address := authServerURL
+	if checksGroups(polCfg) && groups != "" {
+		address += "?groups=" + url.QueryEscape(groups)
+	}
+
 	return map[string]string{
-		authURL:              authServerURL,
+		authURL:              address,
 		authSignin:           "$url_redirect",
 		authSnippet:          wrapHubSnippet(headers),
 		configurationSnippet: wrapHubSnippet(locSnip + " auth_request_set $url_redirect $upstream_http_url_redirect;"),
@@ -87,6 +113,23 @@ proxy_set_header X-Forwarded-Method $request_method;`
 	}, nil
 }
 
+// usesMTLS returns whether the given ACP, or one of its composite methods, is an mTLS ACP.
+func usesMTLS(polCfg *acp.Config) bool {
+	if polCfg.MTLS != nil {
+		return true
+	}
+
+	for _, methods := range [][]acp.Config{polCfg.AnyOf, polCfg.AllOf} {
+		for i := range methods {
+			if usesMTLS(&methods[i]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func redirectPath(polCfg *acp.Config) (string, error) {
 	u, err := url.Parse(polCfg.OIDC.RedirectURL)
 	if err != nil {
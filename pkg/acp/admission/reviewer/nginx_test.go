@@ -29,7 +29,9 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/ingclass"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
 	admv1 "k8s.io/api/admission/v1"
@@ -260,6 +262,42 @@ func TestNginxIngress_Review(t *testing.T) {
 				"custom-annotation":                                 "foobar",
 			},
 		},
+		{
+			desc: "adds authentication and forwards the client certificate with an mTLS ACP",
+			config: &acp.Config{
+				MTLS: &mtls.Config{
+					ForwardHeaders: map[string]string{
+						"X-Client-Cn": "subject.commonName",
+					},
+				},
+			},
+			ingAnnotations: map[string]string{
+				"hub.traefik.io/access-control-policy": "my-policy",
+			},
+			wantPatch: map[string]string{
+				"hub.traefik.io/access-control-policy":              "my-policy",
+				"nginx.ingress.kubernetes.io/auth-url":              "http://hub-agent.default.svc.cluster.local/my-policy?controller=nginx",
+				"nginx.ingress.kubernetes.io/auth-snippet":          "##hub-snippet-start\nproxy_set_header ssl-client-cert $ssl_client_escaped_cert;\nproxy_set_header X-Forwarded-Tls-Client-Cert \"\";\n##hub-snippet-end",
+				"nginx.ingress.kubernetes.io/configuration-snippet": "##hub-snippet-start\nauth_request_set $value_0 $upstream_http_X_Client_Cn; proxy_set_header X-Client-Cn $value_0;\n##hub-snippet-end",
+			},
+		},
+		{
+			desc: "adds authentication with an IP allow list combined with a JWT ACP",
+			config: &acp.Config{
+				AllOf: []acp.Config{
+					{IPAllowList: &ipallowlist.Config{SourceRanges: []string{"10.0.0.0/8"}}},
+					{JWT: &jwt.Config{ForwardHeaders: map[string]string{"X-Sub": "sub"}}},
+				},
+			},
+			ingAnnotations: map[string]string{
+				"hub.traefik.io/access-control-policy": "my-policy",
+			},
+			wantPatch: map[string]string{
+				"hub.traefik.io/access-control-policy":              "my-policy",
+				"nginx.ingress.kubernetes.io/auth-url":              "http://hub-agent.default.svc.cluster.local/my-policy",
+				"nginx.ingress.kubernetes.io/configuration-snippet": "##hub-snippet-start\nauth_request_set $value_0 $upstream_http_X_Sub; proxy_set_header X-Sub $value_0;\n##hub-snippet-end",
+			},
+		},
 		{
 			desc: "adds authentication and strip Authorization header",
 			config: &acp.Config{
@@ -487,6 +525,27 @@ func TestNginxIngress_Review(t *testing.T) {
 				"nginx.ingress.kubernetes.io/server-snippet":        "##hub-snippet-start\nlocation /callback { proxy_pass http://hub-agent.default.svc.cluster.local/my-policy; \nproxy_set_header From nginx;\nproxy_set_header X-Forwarded-Uri $request_uri;\nproxy_set_header X-Forwarded-Host $host;\nproxy_set_header X-Forwarded-Proto $scheme;\nproxy_set_header X-Forwarded-Method $request_method;}\n##hub-snippet-end\n# Stuff after.",
 			},
 		},
+		{
+			desc: "oidc annotations with required groups",
+			config: &acp.Config{
+				OIDC: &oidc.Config{
+					GroupsClaim: "groups",
+				},
+			},
+			ingAnnotations: map[string]string{
+				"hub.traefik.io/access-control-policy": "my-policy",
+				AnnotationHubAuthGroup:                 "dev,ops",
+			},
+			wantPatch: map[string]string{
+				"hub.traefik.io/access-control-policy":              "my-policy",
+				AnnotationHubAuthGroup:                              "dev,ops",
+				"nginx.ingress.kubernetes.io/auth-signin":           "$url_redirect",
+				"nginx.ingress.kubernetes.io/auth-snippet":          "##hub-snippet-start\nproxy_set_header From nginx;\nproxy_set_header X-Forwarded-Uri $request_uri;\nproxy_set_header X-Forwarded-Host $host;\nproxy_set_header X-Forwarded-Proto $scheme;\nproxy_set_header X-Forwarded-Method $request_method;\n##hub-snippet-end",
+				"nginx.ingress.kubernetes.io/auth-url":              "http://hub-agent.default.svc.cluster.local/my-policy?groups=dev%2Cops",
+				"nginx.ingress.kubernetes.io/configuration-snippet": "##hub-snippet-start\nauth_request_set $value_0 $upstream_http_Authorization; proxy_set_header Authorization $value_0;\nauth_request_set $value_1 $upstream_http_Cookie; proxy_set_header Cookie $value_1;\n auth_request_set $url_redirect $upstream_http_url_redirect;\n##hub-snippet-end",
+				"nginx.ingress.kubernetes.io/server-snippet":        "##hub-snippet-start\nlocation /callback { proxy_pass http://hub-agent.default.svc.cluster.local/my-policy; \nproxy_set_header From nginx;\nproxy_set_header X-Forwarded-Uri $request_uri;\nproxy_set_header X-Forwarded-Host $host;\nproxy_set_header X-Forwarded-Proto $scheme;\nproxy_set_header X-Forwarded-Method $request_method;}\n##hub-snippet-end",
+			},
+		},
 		{
 			desc:   "fallback to forced 404 response snippet when ACP is not found",
 			config: nil,
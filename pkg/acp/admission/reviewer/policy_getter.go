@@ -55,5 +55,10 @@ func (p PolGetter) GetConfig(canonicalName string) (*acp.Config, error) {
 		return nil, fmt.Errorf("get ACP: %w", err)
 	}
 
-	return acp.ConfigFromPolicy(policy), nil
+	cfg, err := acp.ConfigFromPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("make ACP config: %w", err)
+	}
+
+	return cfg, nil
 }
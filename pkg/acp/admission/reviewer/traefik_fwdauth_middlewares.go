@@ -60,7 +60,6 @@ func (m FwdAuthMiddlewares) Setup(ctx context.Context, polName, namespace, group
 
 	logger := log.Ctx(ctx).With().
 		Str("acp_name", polName).
-		Str("middleware_name", name).
 		Logger()
 	ctx = logger.WithContext(ctx)
 
@@ -84,8 +83,6 @@ func (m FwdAuthMiddlewares) Setup(ctx context.Context, polName, namespace, group
 }
 
 func (m *FwdAuthMiddlewares) setupMiddleware(ctx context.Context, name, namespace, canonicalPolName, groups string, cfg *acp.Config) (string, error) {
-	logger := log.Ctx(ctx)
-
 	if groups != "" {
 		h, err := hash(groups)
 		if err != nil {
@@ -95,41 +92,94 @@ func (m *FwdAuthMiddlewares) setupMiddleware(ctx context.Context, name, namespac
 		name = name + "-" + fmt.Sprintf("%d", h)
 	}
 
-	currentMiddleware, err := m.findMiddleware(ctx, name, namespace)
+	spec, err := m.newMiddlewareSpec(canonicalPolName, groups, cfg)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("new middleware spec: %w", err)
 	}
 
-	if currentMiddleware == nil {
-		logger.Debug().Msg("No ForwardAuth middleware found, creating a new one")
-		if err = m.createMiddleware(ctx, name, namespace, canonicalPolName, groups, cfg); err != nil {
+	if !usesMTLS(cfg) {
+		if err = m.applyMiddleware(ctx, name, namespace, spec); err != nil {
 			return "", err
 		}
 
 		return name, nil
 	}
 
-	newSpec, err := m.newMiddlewareSpec(canonicalPolName, groups, cfg)
-	if err != nil {
+	// ForwardAuth sends the headers of the client to the auth server as-is. The client certificate header must be
+	// overwritten with the certificate verified by Traefik before, which the chain guarantees.
+	clientCertName := name + "-client-cert"
+	fwdAuthName := name + "-fwd-auth"
+
+	clientCertSpec := traefikv1alpha1.MiddlewareSpec{
+		PassTLSClientCert: &traefikv1alpha1.PassTLSClientCert{PEM: true},
+	}
+	if err = m.applyMiddleware(ctx, clientCertName, namespace, clientCertSpec); err != nil {
 		return "", err
 	}
 
-	if reflect.DeepEqual(currentMiddleware.Spec, newSpec) {
-		logger.Debug().Msg("Existing ForwardAuth middleware is up do date")
+	if err = m.applyMiddleware(ctx, fwdAuthName, namespace, spec); err != nil {
+		return "", err
+	}
 
-		return name, nil
+	chainSpec := traefikv1alpha1.MiddlewareSpec{
+		Chain: &traefikv1alpha1.Chain{
+			Middlewares: []traefikv1alpha1.MiddlewareRef{
+				{Name: clientCertName, Namespace: namespace},
+				{Name: fwdAuthName, Namespace: namespace},
+			},
+		},
+	}
+	if err = m.applyMiddleware(ctx, name, namespace, chainSpec); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// applyMiddleware creates the given middleware, or updates it if it exists with a different spec.
+func (m *FwdAuthMiddlewares) applyMiddleware(ctx context.Context, name, namespace string, spec traefikv1alpha1.MiddlewareSpec) error {
+	logger := log.Ctx(ctx).With().Str("middleware_name", name).Logger()
+
+	currentMiddleware, err := m.findMiddleware(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	if currentMiddleware == nil {
+		logger.Debug().Msg("No middleware found, creating a new one")
+
+		mdlwr := &traefikv1alpha1.Middleware{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: spec,
+		}
+
+		_, err = m.traefikClientSet.Middlewares(namespace).Create(ctx, mdlwr, metav1.CreateOptions{FieldManager: "hub-auth"})
+		if err != nil {
+			return fmt.Errorf("create middleware: %w", err)
+		}
+
+		return nil
+	}
+
+	if reflect.DeepEqual(currentMiddleware.Spec, spec) {
+		logger.Debug().Msg("Existing middleware is up do date")
+
+		return nil
 	}
 
-	logger.Debug().Msg("Existing ForwardAuth middleware is outdated, updating it")
+	logger.Debug().Msg("Existing middleware is outdated, updating it")
 
-	currentMiddleware.Spec = newSpec
+	currentMiddleware.Spec = spec
 
 	_, err = m.traefikClientSet.Middlewares(namespace).Update(ctx, currentMiddleware, metav1.UpdateOptions{FieldManager: "hub-auth"})
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return name, nil
+	return nil
 }
 
 func (m *FwdAuthMiddlewares) findMiddleware(ctx context.Context, name, namespace string) (*traefikv1alpha1.Middleware, error) {
@@ -152,7 +202,7 @@ func (m *FwdAuthMiddlewares) newMiddlewareSpec(canonicalPolName, groups string,
 	}
 
 	address := m.agentAddress + "/" + canonicalPolName
-	if cfg.APIKey != nil && groups != "" {
+	if checksGroups(cfg) && groups != "" {
 		address += "?groups=" + url.QueryEscape(groups)
 	}
 
@@ -164,26 +214,30 @@ func (m *FwdAuthMiddlewares) newMiddlewareSpec(canonicalPolName, groups string,
 	}, nil
 }
 
-func (m *FwdAuthMiddlewares) createMiddleware(ctx context.Context, name, namespace, canonicalPolName, groups string, cfg *acp.Config) error {
-	spec, err := m.newMiddlewareSpec(canonicalPolName, groups, cfg)
-	if err != nil {
-		return fmt.Errorf("new middleware spec: %w", err)
-	}
-
-	mdlwr := &traefikv1alpha1.Middleware{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-		},
-		Spec: spec,
-	}
-
-	_, err = m.traefikClientSet.Middlewares(namespace).Create(ctx, mdlwr, metav1.CreateOptions{FieldManager: "hub-auth"})
-	if err != nil {
-		return fmt.Errorf("create middleware: %w", err)
+// checksGroups returns whether the given ACP checks the groups required by requests, either directly or through one of
+// its composite methods. API keys always check them, while JWT, OIDC and OAuth introspection ACPs only check them when
+// they have a groups claim.
+func checksGroups(cfg *acp.Config) bool {
+	switch {
+	case cfg.APIKey != nil:
+		return true
+	case cfg.JWT != nil:
+		return cfg.JWT.GroupsClaim != ""
+	case cfg.OIDC != nil:
+		return cfg.OIDC.GroupsClaim != ""
+	case cfg.OAuthIntro != nil:
+		return cfg.OAuthIntro.GroupsClaim != ""
+	}
+
+	for _, methods := range [][]acp.Config{cfg.AnyOf, cfg.AllOf} {
+		for i := range methods {
+			if checksGroups(&methods[i]) {
+				return true
+			}
+		}
 	}
 
-	return nil
+	return false
 }
 
 func hash(name string) (uint32, error) {
@@ -30,9 +30,15 @@ import (
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/admission/ingclass"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/externalauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/issuer"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/upstreamtoken"
 	traefikv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/traefik/v1alpha1"
 	traefikcrdfake "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/traefik/clientset/versioned/fake"
 	admv1 "k8s.io/api/admission/v1"
@@ -408,6 +414,93 @@ func TestTraefikIngress_ReviewAddsAuthentication(t *testing.T) {
 			},
 			wantAuthResponseHeaders: []string{"fwdHeader", "Authorization", "Cookie"},
 		},
+		{
+			desc: "add OIDC authentication forwarding the access token in a custom header",
+			config: &acp.Config{OIDC: &oidc.Config{
+				AccessTokenHeader: "X-Access-Token",
+			}},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"Authorization", "Cookie", "X-Access-Token"},
+		},
+		{
+			desc: "add authentication with an IP allow list",
+			config: &acp.Config{
+				IPAllowList: &ipallowlist.Config{SourceRanges: []string{"10.0.0.0/8"}},
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy@kubernetescrd",
+			},
+		},
+		{
+			desc: "add authentication with an external authorization service after a JWT",
+			config: &acp.Config{
+				AllOf: []acp.Config{
+					{JWT: &jwt.Config{ForwardHeaders: map[string]string{"X-Sub": "sub"}}},
+					{ExternalAuthz: &externalauthz.Config{URL: "https://authz.example.com", ForwardHeaders: []string{"X-Entitlements"}}},
+				},
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"X-Sub", "X-Entitlements"},
+		},
+		{
+			desc: "add authentication issuing identity tokens",
+			config: &acp.Config{
+				JWT: &jwt.Config{
+					ForwardHeaders: map[string]string{
+						"fwdHeader": "claim",
+					},
+				},
+				IssueToken: &issuer.Config{Header: "X-Hub-Identity"},
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"fwdHeader", "X-Hub-Identity"},
+		},
+		{
+			desc: "add authentication forwarding upstream tokens",
+			config: &acp.Config{
+				JWT: &jwt.Config{
+					ForwardHeaders: map[string]string{
+						"fwdHeader": "claim",
+					},
+				},
+				UpstreamToken: &upstreamtoken.Config{TokenURL: "https://idp.example.com/token"},
+			},
+			oldIngAnno: map[string]string{},
+			ingAnno: map[string]string{
+				AnnotationHubAuth: "my-policy",
+			},
+			wantPatch: map[string]string{
+				AnnotationHubAuth: "my-policy",
+				"traefik.ingress.kubernetes.io/router.middlewares": "test-zz-my-policy@kubernetescrd",
+			},
+			wantAuthResponseHeaders: []string{"fwdHeader", "Authorization"},
+		},
 		{
 			desc: "add Google OIDC authentication",
 			config: &acp.Config{
@@ -633,3 +726,180 @@ func TestTraefikIngress_ReviewUpdatesExistingMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestTraefikIngress_ReviewPassesRequiredGroups(t *testing.T) {
+	tests := []struct {
+		desc        string
+		config      *acp.Config
+		wantAddress string
+	}{
+		{
+			desc:        "API key",
+			config:      &acp.Config{APIKey: &apikey.Config{}},
+			wantAddress: "auth.server.svc/my-policy?groups=dev%2Cops",
+		},
+		{
+			desc:        "JWT without groups claim",
+			config:      &acp.Config{JWT: &jwt.Config{}},
+			wantAddress: "auth.server.svc/my-policy",
+		},
+		{
+			desc:        "JWT with groups claim",
+			config:      &acp.Config{JWT: &jwt.Config{GroupsClaim: "groups"}},
+			wantAddress: "auth.server.svc/my-policy?groups=dev%2Cops",
+		},
+		{
+			desc:        "OIDC with groups claim",
+			config:      &acp.Config{OIDC: &oidc.Config{GroupsClaim: "groups"}},
+			wantAddress: "auth.server.svc/my-policy?groups=dev%2Cops",
+		},
+		{
+			desc:        "OAuth introspection with groups claim",
+			config:      &acp.Config{OAuthIntro: &oauthintro.Config{GroupsClaim: "groups"}},
+			wantAddress: "auth.server.svc/my-policy?groups=dev%2Cops",
+		},
+		{
+			desc: "composite method with groups claim",
+			config: &acp.Config{
+				AnyOf: []acp.Config{
+					{BasicAuth: &basicauth.Config{}},
+					{JWT: &jwt.Config{GroupsClaim: "groups"}},
+				},
+			},
+			wantAddress: "auth.server.svc/my-policy?groups=dev%2Cops",
+		},
+		{
+			desc:        "basic auth",
+			config:      &acp.Config{BasicAuth: &basicauth.Config{}},
+			wantAddress: "auth.server.svc/my-policy",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			traefikClientSet := traefikcrdfake.NewSimpleClientset()
+
+			policies := newPolicyGetterMock(t)
+			policies.OnGetConfig("my-policy").TypedReturns(test.config, nil).Once()
+
+			fwdAuthMdlwrs := NewFwdAuthMiddlewares("auth.server.svc", policies, traefikClientSet.TraefikV1alpha1())
+			rev := NewTraefikIngress(newIngressClassesMock(t), fwdAuthMdlwrs)
+
+			ing := struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			}{
+				Metadata: metav1.ObjectMeta{
+					Name:      "name",
+					Namespace: "test",
+					Annotations: map[string]string{
+						AnnotationHubAuth:      "my-policy",
+						AnnotationHubAuthGroup: "dev,ops",
+					},
+				},
+			}
+			b, err := json.Marshal(ing)
+			require.NoError(t, err)
+
+			ar := admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					Object: runtime.RawExtension{Raw: b},
+				},
+			}
+
+			_, err = rev.Review(context.Background(), ar)
+			require.NoError(t, err)
+
+			h, err := hash("dev,ops")
+			require.NoError(t, err)
+
+			m, err := traefikClientSet.TraefikV1alpha1().Middlewares("test").
+				Get(context.Background(), fmt.Sprintf("zz-my-policy-%d", h), metav1.GetOptions{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantAddress, m.Spec.ForwardAuth.Address)
+		})
+	}
+}
+
+func TestTraefikIngress_ReviewOverwritesClientCertificateHeader(t *testing.T) {
+	tests := []struct {
+		desc   string
+		config *acp.Config
+	}{
+		{
+			desc:   "mTLS",
+			config: &acp.Config{MTLS: &mtls.Config{}},
+		},
+		{
+			desc: "composite method with mTLS",
+			config: &acp.Config{
+				AnyOf: []acp.Config{
+					{JWT: &jwt.Config{}},
+					{MTLS: &mtls.Config{}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			traefikClientSet := traefikcrdfake.NewSimpleClientset()
+
+			policies := newPolicyGetterMock(t)
+			policies.OnGetConfig("my-policy").TypedReturns(test.config, nil).Once()
+
+			fwdAuthMdlwrs := NewFwdAuthMiddlewares("auth.server.svc", policies, traefikClientSet.TraefikV1alpha1())
+			rev := NewTraefikIngress(newIngressClassesMock(t), fwdAuthMdlwrs)
+
+			ing := struct {
+				Metadata metav1.ObjectMeta `json:"metadata"`
+			}{
+				Metadata: metav1.ObjectMeta{
+					Name:        "name",
+					Namespace:   "test",
+					Annotations: map[string]string{AnnotationHubAuth: "my-policy"},
+				},
+			}
+			b, err := json.Marshal(ing)
+			require.NoError(t, err)
+
+			ar := admv1.AdmissionReview{
+				Request: &admv1.AdmissionRequest{
+					Object: runtime.RawExtension{Raw: b},
+				},
+			}
+
+			patch, err := rev.Review(context.Background(), ar)
+			require.NoError(t, err)
+			assert.Equal(t, "test-zz-my-policy@kubernetescrd", patch["value"].(map[string]string)[annotationTraefikMiddlewares])
+
+			// A client certificate header sent by the client must be overwritten before reaching the auth server.
+			chain, err := traefikClientSet.TraefikV1alpha1().Middlewares("test").
+				Get(context.Background(), "zz-my-policy", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, &traefikv1alpha1.Chain{
+				Middlewares: []traefikv1alpha1.MiddlewareRef{
+					{Name: "zz-my-policy-client-cert", Namespace: "test"},
+					{Name: "zz-my-policy-fwd-auth", Namespace: "test"},
+				},
+			}, chain.Spec.Chain)
+			assert.Nil(t, chain.Spec.ForwardAuth)
+
+			clientCert, err := traefikClientSet.TraefikV1alpha1().Middlewares("test").
+				Get(context.Background(), "zz-my-policy-client-cert", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, &traefikv1alpha1.PassTLSClientCert{PEM: true}, clientCert.Spec.PassTLSClientCert)
+
+			fwdAuth, err := traefikClientSet.TraefikV1alpha1().Middlewares("test").
+				Get(context.Background(), "zz-my-policy-fwd-auth", metav1.GetOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, "auth.server.svc/my-policy", fwdAuth.Spec.ForwardAuth.Address)
+		})
+	}
+}
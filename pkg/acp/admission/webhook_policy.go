@@ -149,6 +149,10 @@ func (h ACPHandler) review(ctx context.Context, req *admv1.AdmissionRequest) ([]
 	case admv1.Create:
 		logger.Info().Msg("Creating AccessControlPolicy resource")
 
+		if err = acp.ValidatePolicy(newACP); err != nil {
+			return nil, fmt.Errorf("invalid ACP: %w", err)
+		}
+
 		var a *acp.ACP
 		a, err = h.backend.CreateACP(ctx, newACP)
 		if err != nil {
@@ -161,6 +165,10 @@ func (h ACPHandler) review(ctx context.Context, req *admv1.AdmissionRequest) ([]
 	case admv1.Update:
 		logger.Info().Msg("Updating AccessControlPolicy resource")
 
+		if err = acp.ValidatePolicy(newACP); err != nil {
+			return nil, fmt.Errorf("invalid ACP: %w", err)
+		}
+
 		var a *acp.ACP
 		a, err = h.backend.UpdateACP(ctx, oldACP.Status.Version, newACP)
 		if err != nil {
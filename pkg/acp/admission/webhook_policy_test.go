@@ -134,6 +134,69 @@ func TestWebhookPolicy_ServeHTTP_Create(t *testing.T) {
 	assert.Equal(t, &wantResp, gotAr.Response)
 }
 
+func TestWebhookPolicy_ServeHTTP_CreateInvalid(t *testing.T) {
+	policyCreate := &hubv1alpha1.AccessControlPolicy{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "AccessControlPolicy",
+			APIVersion: "hub.traefik.io/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "acp",
+			Namespace: "default",
+		},
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			AnyOf: []hubv1alpha1.AccessControlPolicyMethod{
+				{
+					JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "secret"},
+				},
+				{},
+			},
+		},
+	}
+
+	b := mustMarshal(t, admv1.AdmissionReview{
+		Request: &admv1.AdmissionRequest{
+			UID: "id",
+			Kind: metav1.GroupVersionKind{
+				Group:   "hub.traefik.io",
+				Version: "v1alpha1",
+				Kind:    "AccessControlPolicy",
+			},
+			Name:      "acp",
+			Namespace: "default",
+			Operation: admv1.Create,
+			Object: runtime.RawExtension{
+				Raw: mustMarshal(t, policyCreate),
+			},
+		},
+		Response: &admv1.AdmissionResponse{},
+	})
+
+	// The backend must not be called for invalid policies.
+	h := NewACPHandler(newBackendMock(t))
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "/", bytes.NewBuffer(b))
+	require.NoError(t, err)
+
+	h.ServeHTTP(rec, req)
+
+	var gotAr admv1.AdmissionReview
+	err = json.NewDecoder(rec.Body).Decode(&gotAr)
+	require.NoError(t, err)
+
+	wantResp := admv1.AdmissionResponse{
+		UID:     "id",
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  "Failure",
+			Message: `invalid ACP: anyOf: method 1: exactly one of "jwt", "basicAuth", "apiKey", "oAuthIntro", "mtls", "ipAllowList" or "externalAuthz" must be set`,
+		},
+	}
+
+	assert.Equal(t, &wantResp, gotAr.Response)
+}
+
 func TestWebhookPolicy_ServeHTTP_Update(t *testing.T) {
 	policyUpdate := &hubv1alpha1.AccessControlPolicy{
 		TypeMeta: metav1.TypeMeta{
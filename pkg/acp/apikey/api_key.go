@@ -23,24 +23,56 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
 	"golang.org/x/crypto/sha3"
 )
 
 // Config configures an API key ACP handler.
 type Config struct {
-	KeySource      token.Source      `json:"keySource"`
-	Keys           []Key             `json:"keys"`
-	ForwardHeaders map[string]string `json:"forwardHeaders"`
+	KeySource         token.Source      `json:"keySource"`
+	Keys              []Key             `json:"keys"`
+	KeySecrets        []SecretReference `json:"keySecrets,omitempty"`
+	KeySecretSelector *SecretSelector   `json:"keySecretSelector,omitempty"`
+	ForwardHeaders    map[string]string `json:"forwardHeaders"`
+	Lockout           *lockout.Config   `json:"lockout,omitempty"`
+
+	// SecretKeys holds the keys resolved from KeySecrets and KeySecretSelector.
+	SecretKeys []Key `json:"-"`
 }
 
 // Key defines an API key.
 type Key struct {
-	ID       string            `json:"id"`
-	Metadata map[string]string `json:"metadata"`
-	Value    string            `json:"value"`
+	ID        string            `json:"id"`
+	Metadata  map[string]string `json:"metadata"`
+	Value     string            `json:"value"`
+	NotBefore *time.Time        `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// SecretSelector selects Secrets within a namespace.
+type SecretSelector struct {
+	Namespace        string                `json:"namespace"`
+	MatchLabels      map[string]string     `json:"matchLabels,omitempty"`
+	MatchExpressions []SelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// SelectorRequirement is a label selector requirement.
+type SelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
 }
 
 // Handler is an API Key ACP Handler.
@@ -49,18 +81,26 @@ type Handler struct {
 	keySrc     token.Source
 	keys       map[string]Key
 	fwdHeaders map[string]string
+	// limiter locks out clients and keys after too many failed attempts. It is nil if lockouts are disabled.
+	limiter *lockout.Limiter
+
+	now func() time.Time
 }
 
-// NewHandler creates a new API key ACP Handler.
-func NewHandler(cfg *Config, name string) (*Handler, error) {
+// NewHandler creates a new API key ACP Handler. Lockouts, if configured, are stored in the given store.
+func NewHandler(cfg *Config, name string, lockouts lockout.Store) (*Handler, error) {
 	if cfg.KeySource.Header == "" && cfg.KeySource.Query == "" && cfg.KeySource.Cookie == "" {
 		return nil, errors.New(`at least one of "header", "query" or "cookie" must be set`)
 	}
 
-	keys := make(map[string]Key, len(cfg.Keys))
-	uniqIDs := make(map[string]struct{}, len(cfg.Keys))
-	uniqValues := make(map[string]struct{}, len(cfg.Keys))
-	for _, k := range cfg.Keys {
+	allKeys := make([]Key, 0, len(cfg.Keys)+len(cfg.SecretKeys))
+	allKeys = append(allKeys, cfg.Keys...)
+	allKeys = append(allKeys, cfg.SecretKeys...)
+
+	keys := make(map[string]Key, len(allKeys))
+	uniqIDs := make(map[string]struct{}, len(allKeys))
+	uniqValues := make(map[string]struct{}, len(allKeys))
+	for _, k := range allKeys {
 		if k.ID == "" || k.Value == "" {
 			return nil, errors.New("empty ID or value")
 		}
@@ -83,9 +123,20 @@ func NewHandler(cfg *Config, name string) (*Handler, error) {
 		md["_id"] = k.ID
 
 		keys[k.Value] = Key{
-			ID:       k.ID,
-			Metadata: md,
-			Value:    k.Value,
+			ID:        k.ID,
+			Metadata:  md,
+			Value:     k.Value,
+			NotBefore: k.NotBefore,
+			ExpiresAt: k.ExpiresAt,
+		}
+	}
+
+	var limiter *lockout.Limiter
+	if cfg.Lockout != nil {
+		var err error
+		limiter, err = lockout.NewLimiter(cfg.Lockout, lockouts, name)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -94,6 +145,8 @@ func NewHandler(cfg *Config, name string) (*Handler, error) {
 		keySrc:     cfg.KeySource,
 		keys:       keys,
 		fwdHeaders: cfg.ForwardHeaders,
+		limiter:    limiter,
+		now:        time.Now,
 	}, nil
 }
 
@@ -103,30 +156,55 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	apiKey, err := token.Extract(req, h.keySrc)
 	if err != nil {
 		l.Debug().Err(err).Msg("Getting API key")
+		decision.SetReason(req, decision.ReasonMissingCredentials)
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	hash := make([]byte, 64)
-	sha3.ShakeSum256(hash, []byte(apiKey))
-	k, ok := h.keys[fmt.Sprintf("%x", hash)]
+	// Unknown keys are only accounted to the client IP.
+	k, ok := h.keys[Hash(apiKey)]
+
+	if h.limiter != nil {
+		if lockedFor := h.limiter.LockedFor(req, k.ID); lockedFor > 0 {
+			l.Debug().Str("key_id", k.ID).Msg("Client or key is locked out")
+			lockout.Deny(rw, req, lockedFor)
+			return
+		}
+	}
+
 	if !ok {
+		h.fail(req, "")
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !k.validAt(h.now()) {
+		l.Debug().Str("key_id", k.ID).Msg("API key is not valid at this time")
+		h.fail(req, k.ID)
+		decision.SetReason(req, decision.ReasonExpiredCredentials)
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	if h.limiter != nil {
+		h.limiter.Succeed(req, k.ID)
+	}
+
 	queryParam := req.URL.Query()
 	if queryParam.Get("groups") != "" {
 		groups, err := url.QueryUnescape(queryParam.Get("groups"))
 		if err != nil {
 			log.Error().Err(err).Msg("Error while unescaping groups")
 
+			decision.SetReason(req, decision.ReasonInternalError)
 			rw.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		userGroupsRaw, ok := k.Metadata["groups"]
 		if !ok {
+			decision.SetReason(req, decision.ReasonGroupsMismatch)
 			rw.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -147,6 +225,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 				Strs("user_groups", userGroups).
 				Msg("User is not in the required groups")
 
+			decision.SetReason(req, decision.ReasonGroupsMismatch)
 			rw.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -158,9 +237,44 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	claims := make(map[string]interface{}, len(k.Metadata))
+	for name, value := range k.Metadata {
+		claims[name] = value
+	}
+
+	decision.SetSubject(req, k.ID)
+	decision.SetClaims(req, claims)
+	if groups := k.Metadata["groups"]; groups != "" {
+		decision.SetGroups(req, strings.Split(groups, ","))
+	}
+
 	rw.WriteHeader(http.StatusOK)
 }
 
+// fail records a failed attempt, if lockouts are enabled.
+func (h *Handler) fail(req *http.Request, keyID string) {
+	if h.limiter != nil {
+		h.limiter.Fail(req, keyID)
+	}
+}
+
+// Hash returns the SHAKE-256 hash (using 64 bytes) of the given API key, hex encoded.
+func Hash(apiKey string) string {
+	hash := make([]byte, 64)
+	sha3.ShakeSum256(hash, []byte(apiKey))
+
+	return fmt.Sprintf("%x", hash)
+}
+
+// validAt returns whether the key can be used at the given time.
+func (k Key) validAt(t time.Time) bool {
+	if k.NotBefore != nil && t.Before(*k.NotBefore) {
+		return false
+	}
+
+	return k.ExpiresAt == nil || t.Before(*k.ExpiresAt)
+}
+
 func search(needle string, stack []string) bool {
 	for _, s := range stack {
 		if s == needle {
@@ -21,9 +21,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
 )
 
@@ -98,6 +101,25 @@ func TestNewHandler(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			desc: "duplicated key ID between inline and secret keys",
+			cfg: Config{
+				KeySource: token.Source{Header: "Api-Key"},
+				Keys: []Key{
+					{
+						ID:    "id-1",
+						Value: "17fa993d5eecbd361f30baf0b9b2329ad053bb6d5fec2228eca55e9b4914fface3af69bcc9a6b5f7ff093aa9a0d00811d0b2a3ee67eac60c57e79d2fd99bbde0",
+					},
+				},
+				SecretKeys: []Key{
+					{
+						ID:    "id-1",
+						Value: "2f721b4773058cdaec7c09c325375b1b3c88a610e1faa429fb4bf0f1b40e334da308ef1d1c542afcdac87f2df7122be9eb353b0765e7d4a128c36ce044ff1f6d",
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			desc: "ok",
 			cfg: Config{
@@ -118,7 +140,7 @@ func TestNewHandler(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			_, err := NewHandler(&test.cfg, "api-key")
+			_, err := NewHandler(&test.cfg, "api-key", nil)
 
 			if test.wantErr {
 				assert.Error(t, err)
@@ -252,7 +274,7 @@ func TestServeHTTP(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			apiKey, err := NewHandler(&test.cfg, "api-key")
+			apiKey, err := NewHandler(&test.cfg, "api-key", nil)
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
@@ -285,6 +307,78 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestServeHTTP_keyValidity(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		desc        string
+		notBefore   *time.Time
+		expiresAt   *time.Time
+		wantStatus  int
+		wantSubject string
+	}{
+		{
+			desc:        "no validity bounds",
+			wantStatus:  http.StatusOK,
+			wantSubject: "id-1",
+		},
+		{
+			desc:        "within validity bounds",
+			notBefore:   &before,
+			expiresAt:   &after,
+			wantStatus:  http.StatusOK,
+			wantSubject: "id-1",
+		},
+		{
+			desc:       "not valid yet",
+			notBefore:  &after,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "expired",
+			expiresAt:  &before,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "expires now",
+			expiresAt:  &now,
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&Config{
+				KeySource: token.Source{Header: "Api-Key"},
+				SecretKeys: []Key{
+					{
+						ID:        "id-1",
+						Value:     Hash(validAPIKey),
+						NotBefore: test.notBefore,
+						ExpiresAt: test.expiresAt,
+					},
+				},
+			}, "api-key", nil)
+			require.NoError(t, err)
+			handler.now = func() time.Time { return now }
+
+			req := decision.Track(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			req.Header.Set("Api-Key", validAPIKey)
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantStatus, rw.Code)
+			assert.Equal(t, test.wantSubject, decision.IdentityOf(req).Subject)
+		})
+	}
+}
+
 func TestServeHTTP_handleGroups(t *testing.T) {
 	tests := []struct {
 		desc       string
@@ -361,7 +455,7 @@ func TestServeHTTP_handleGroups(t *testing.T) {
 				}},
 			}
 
-			apiKey, err := NewHandler(&cfg, "api-key")
+			apiKey, err := NewHandler(&cfg, "api-key", nil)
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
@@ -414,7 +508,7 @@ func TestServeHTTPForwardsHeader(t *testing.T) {
 		t.Run(test.desc, func(t *testing.T) {
 			t.Parallel()
 
-			apiKey, err := NewHandler(&test.cfg, "api-key")
+			apiKey, err := NewHandler(&test.cfg, "api-key", nil)
 			require.NoError(t, err)
 
 			rr := httptest.NewRecorder()
@@ -432,3 +526,36 @@ func TestServeHTTPForwardsHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestServeHTTP_lockout(t *testing.T) {
+	handler, err := NewHandler(&Config{
+		KeySource:  token.Source{Header: "Api-Key"},
+		SecretKeys: []Key{{ID: "id-1", Value: Hash(validAPIKey)}},
+		Lockout:    &lockout.Config{MaxFailuresPerIP: 2, LockoutSeconds: 60},
+	}, "api-key", lockout.NewMemoryStore())
+	require.NoError(t, err)
+
+	tests := []struct {
+		ip             string
+		key            string
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{ip: "10.0.0.1", key: invalidAPIKey, wantStatus: http.StatusUnauthorized},
+		{ip: "10.0.0.1", key: invalidAPIKey, wantStatus: http.StatusUnauthorized},
+		{ip: "10.0.0.1", key: validAPIKey, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "60"},
+		{ip: "10.0.0.2", key: validAPIKey, wantStatus: http.StatusOK},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.RemoteAddr = test.ip + ":1234"
+		req.Header.Set("Api-Key", test.key)
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		assert.Equal(t, test.wantStatus, rw.Code, i)
+		assert.Equal(t, test.wantRetryAfter, rw.Header().Get("Retry-After"), i)
+	}
+}
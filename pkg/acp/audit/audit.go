@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Handler evaluates requests with an ACP handler but always grants access. It logs the decisions
+// the ACP handler would have made so their impact can be checked before enforcing them.
+// Decisions are exported by the hub_agent_auth_decisions_total metric, with the audit enforcement mode.
+type Handler struct {
+	name string
+	next http.Handler
+}
+
+// NewHandler creates a new audit Handler for the given ACP handler.
+func NewHandler(next http.Handler, name string) *Handler {
+	return &Handler{
+		name: name,
+		next: next,
+	}
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	resp := &response{header: make(http.Header)}
+	h.next.ServeHTTP(resp, req)
+
+	// Like net/http, consider a handler that did not write anything granted the request.
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+
+	granted := resp.code >= 200 && resp.code < 300
+
+	log.Info().
+		Str("handler_type", "Audit").
+		Str("handler_name", h.name).
+		Str("method", req.Header.Get("X-Forwarded-Method")).
+		Str("host", req.Header.Get("X-Forwarded-Host")).
+		Str("uri", req.Header.Get("X-Forwarded-Uri")).
+		Bool("granted", granted).
+		Int("status", resp.code).
+		Msg("Access control decision")
+
+	// Headers of a granted request are forwarded as usual. Those of a denied request, like a redirection
+	// to an identity provider, are dropped as access is granted anyway.
+	if granted {
+		for name, values := range resp.header {
+			rw.Header()[name] = values
+		}
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// response records the response of an ACP handler.
+type response struct {
+	header http.Header
+	code   int
+}
+
+// Header implements http.ResponseWriter.
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+// Write implements http.ResponseWriter. The body is discarded.
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return len(b), nil
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *response) WriteHeader(code int) {
+	if r.code != 0 {
+		return
+	}
+
+	r.code = code
+}
@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		next       http.Handler
+		wantHeader http.Header
+	}{
+		{
+			desc: "granted request forwards headers",
+			next: http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("User", "bruce")
+				rw.WriteHeader(http.StatusOK)
+			}),
+			wantHeader: http.Header{"User": []string{"bruce"}},
+		},
+		{
+			desc:       "handler writing nothing grants the request",
+			next:       http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+			wantHeader: http.Header{},
+		},
+		{
+			desc: "denied request is granted without its headers",
+			next: http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Www-Authenticate", "Basic")
+				rw.WriteHeader(http.StatusUnauthorized)
+			}),
+			wantHeader: http.Header{},
+		},
+		{
+			desc: "redirection is granted without its headers",
+			next: http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				http.Redirect(rw, req, "https://idp.example.com", http.StatusFound)
+			}),
+			wantHeader: http.Header{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			h := NewHandler(test.next, "acp")
+
+			rw := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			h.ServeHTTP(rw, req)
+
+			assert.Equal(t, http.StatusOK, rw.Code)
+			assert.Equal(t, test.wantHeader, rw.Header())
+			assert.Empty(t, rw.Body.String())
+		})
+	}
+}
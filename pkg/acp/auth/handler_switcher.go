@@ -26,6 +26,8 @@ import (
 type HTTPHandlerSwitcher struct {
 	handlerMu sync.RWMutex
 	handler   http.Handler
+	// handlerCount is the number of ACP handlers served by handler.
+	handlerCount int
 }
 
 // NewHandlerSwitcher builds a new instance of HTTPHandlerSwitcher.
@@ -43,13 +45,22 @@ func (h *HTTPHandlerSwitcher) ServeHTTP(rw http.ResponseWriter, req *http.Reques
 	handler.ServeHTTP(rw, req)
 }
 
-// UpdateHandler safely updates the current http.ServeMux with a new one.
-func (h *HTTPHandlerSwitcher) UpdateHandler(handler http.Handler) {
+// UpdateHandler safely updates the current http.ServeMux with a new one serving the given number of ACP handlers.
+func (h *HTTPHandlerSwitcher) UpdateHandler(handler http.Handler, handlerCount int) {
 	if handler == nil {
 		return
 	}
 
 	h.handlerMu.Lock()
 	h.handler = handler
+	h.handlerCount = handlerCount
 	h.handlerMu.Unlock()
 }
+
+// HandlerCount returns the number of ACP handlers currently served.
+func (h *HTTPHandlerSwitcher) HandlerCount() int {
+	h.handlerMu.RLock()
+	defer h.handlerMu.RUnlock()
+
+	return h.handlerCount
+}
@@ -21,19 +21,33 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/audit"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/composite"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/externalauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/issuer"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/upstreamtoken"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 	hublistersv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/listers/hub/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
@@ -53,41 +67,111 @@ type Watcher struct {
 	secrets            acp.SecretGetter
 	secretRefCounterMu sync.RWMutex
 	secretRefCounter   map[string]int
+	// secretSelectors holds the secret selectors of each ACP, indexed by ACP name.
+	secretSelectors map[string][]secretSelector
+	// configMapRefCounter counts the references of ACPs to ConfigMaps. It is guarded by secretRefCounterMu.
+	configMapRefCounter map[string]int
+	// configMapResync is the interval at which handlers are refreshed while ACPs reference ConfigMaps. ConfigMaps are
+	// read when building configurations rather than watched, which would require listing all the ConfigMaps.
+	configMapResync time.Duration
 
 	refresh chan struct{}
 
+	statusMu   sync.RWMutex
+	syncErr    error
+	configErrs map[string]error
+	buildErrs  map[string]error
+	lastBuild  time.Time
+	// lastSuccessfulBuild is the last time handlers have been built from all the ACPs, which were listed successfully.
+	lastSuccessfulBuild time.Time
+
 	switcher *HTTPHandlerSwitcher
+	// decisions receives the decisions made by ACP handlers. Decisions are not logged if it is nil.
+	decisions decision.Sink
+	// lockouts stores the failed attempts and lockouts of ACP handlers. It outlives handlers, which are rebuilt
+	// whenever an ACP changes.
+	lockouts lockout.Store
+	// sessions stores the server-side sessions of OIDC handlers. Like lockouts, they outlive handlers.
+	sessions oidc.SessionBackends
 }
 
 // NewWatcher returns a new watcher to track ACP resources. It calls the given Updater when an ACP is modified at most
 // once every throttle.
-func NewWatcher(switcher *HTTPHandlerSwitcher, acps hublistersv1alpha1.AccessControlPolicyLister, secrets acp.SecretGetter) *Watcher {
+// Decisions made by ACP handlers are written to the given sink, if any. Lockouts are stored in the given store, or in
+// memory if it is nil. Server-side OIDC sessions are stored in the given backends, the in-memory one being created if
+// missing.
+func NewWatcher(switcher *HTTPHandlerSwitcher, acps hublistersv1alpha1.AccessControlPolicyLister, secrets acp.SecretGetter, decisions decision.Sink, lockouts lockout.Store, sessions oidc.SessionBackends) *Watcher {
+	if lockouts == nil {
+		lockouts = lockout.NewMemoryStore()
+	}
+	if sessions.Memory == nil {
+		sessions.Memory = oidc.NewMemorySessionBackend()
+	}
+
 	return &Watcher{
-		configs:          make(map[string]*acp.Config),
-		acps:             acps,
-		secrets:          secrets,
-		secretRefCounter: make(map[string]int),
-		refresh:          make(chan struct{}, 1),
-		switcher:         switcher,
+		configs:             make(map[string]*acp.Config),
+		acps:                acps,
+		secrets:             secrets,
+		secretRefCounter:    make(map[string]int),
+		secretSelectors:     make(map[string][]secretSelector),
+		configMapRefCounter: make(map[string]int),
+		configMapResync:     time.Minute,
+		refresh:             make(chan struct{}, 1),
+		switcher:            switcher,
+		decisions:           decisions,
+		lockouts:            lockouts,
+		sessions:            sessions,
 	}
 }
 
 // Run launches listener if the watcher is dirty.
 func (w *Watcher) Run(ctx context.Context) {
+	// Build handlers right away, even if there is no ACP, so the watcher becomes ready.
+	select {
+	case w.refresh <- struct{}{}:
+	default:
+	}
+
+	resync := time.NewTicker(w.configMapResync)
+	defer resync.Stop()
+
 	for {
 		select {
+		case <-resync.C:
+			if !w.watchesConfigMaps() {
+				continue
+			}
+
+			select {
+			case w.refresh <- struct{}{}:
+			default:
+			}
+
 		case <-w.refresh:
-			configs, err := w.makeConfigs()
-			if err != nil {
-				log.Error().Err(err).Msg("Could not build ACP configs")
+			configs, configErrs, syncErr := w.makeConfigs()
+			if syncErr != nil {
+				log.Error().Err(syncErr).Msg("Could not build ACP configs")
 			}
 
+			w.statusMu.Lock()
+			w.syncErr = syncErr
+			w.configErrs = configErrs
+			w.statusMu.Unlock()
+
 			hash, err := hashstructure.Hash(configs, hashstructure.FormatV2, nil)
 			if err != nil {
 				log.Error().Err(err).Msg("Could not to compute ACP configs hash")
 			}
 
 			if err == nil && w.previous == hash {
+				// Handlers have already been built from these configs, which were successfully listed this time.
+				if syncErr == nil {
+					w.statusMu.Lock()
+					if w.lastSuccessfulBuild.IsZero() {
+						w.lastSuccessfulBuild = w.lastBuild
+					}
+					w.statusMu.Unlock()
+				}
 				continue
 			}
 
@@ -99,7 +183,21 @@ func (w *Watcher) Run(ctx context.Context) {
 
 			log.Debug().Msg("Refreshing ACP handlers")
 
-			w.switcher.UpdateHandler(w.buildRoutes(ctx))
+			handler, buildErrs := w.buildRoutes(ctx)
+			w.switcher.UpdateHandler(handler, len(configs)-len(buildErrs))
+
+			w.statusMu.Lock()
+			w.buildErrs = buildErrs
+			w.lastBuild = time.Now()
+			if syncErr == nil {
+				w.lastSuccessfulBuild = w.lastBuild
+			}
+			w.statusMu.Unlock()
+
+			// ACPs which could not be listed may still exist, so their series are kept until they can be listed.
+			if syncErr == nil {
+				deleteStaleMetrics(configs, configErrs)
+			}
 
 		case <-ctx.Done():
 			return
@@ -112,17 +210,19 @@ func (w *Watcher) OnAdd(obj interface{}) {
 	switch v := obj.(type) {
 	case *hubv1alpha1.AccessControlPolicy:
 		refs := secretReferences(v)
+		cmRefs := configMapReferences(v)
 		w.secretRefCounterMu.Lock()
 		for _, ref := range refs {
 			w.secretRefCounter[ref]++
 		}
+		for _, ref := range cmRefs {
+			w.configMapRefCounter[ref]++
+		}
+		w.setSecretSelectors(v)
 		w.secretRefCounterMu.Unlock()
 
 	case *corev1.Secret:
-		w.secretRefCounterMu.RLock()
-		c := w.secretRefCounter[secretKey(v.Name, v.Namespace)]
-		w.secretRefCounterMu.RUnlock()
-		if c == 0 {
+		if !w.isSecretWatched(v) {
 			return
 		}
 
@@ -143,26 +243,30 @@ func (w *Watcher) OnAdd(obj interface{}) {
 func (w *Watcher) OnUpdate(oldObj, newObj interface{}) {
 	switch v := newObj.(type) {
 	case *hubv1alpha1.AccessControlPolicy:
-		oldRefs := secretReferences(oldObj.(*hubv1alpha1.AccessControlPolicy))
+		oldPolicy := oldObj.(*hubv1alpha1.AccessControlPolicy)
+		oldRefs := secretReferences(oldPolicy)
 		newRefs := secretReferences(v)
+		oldCMRefs := configMapReferences(oldPolicy)
+		newCMRefs := configMapReferences(v)
 		w.secretRefCounterMu.Lock()
 		for _, ref := range oldRefs {
-			if w.secretRefCounter[ref] > 1 {
-				w.secretRefCounter[ref]--
-			} else {
-				delete(w.secretRefCounter, ref)
-			}
+			decrementRef(w.secretRefCounter, ref)
 		}
 		for _, ref := range newRefs {
 			w.secretRefCounter[ref]++
 		}
+		for _, ref := range oldCMRefs {
+			decrementRef(w.configMapRefCounter, ref)
+		}
+		for _, ref := range newCMRefs {
+			w.configMapRefCounter[ref]++
+		}
+		w.setSecretSelectors(v)
 		w.secretRefCounterMu.Unlock()
 
 	case *corev1.Secret:
-		w.secretRefCounterMu.RLock()
-		c := w.secretRefCounter[secretKey(v.Name, v.Namespace)]
-		w.secretRefCounterMu.RUnlock()
-		if c == 0 {
+		// Labels may have changed, making the secret selected or unselected.
+		if !w.isSecretWatched(v) && !w.isSecretWatched(oldObj.(*corev1.Secret)) {
 			return
 		}
 
@@ -184,21 +288,19 @@ func (w *Watcher) OnDelete(obj interface{}) {
 	switch v := obj.(type) {
 	case *hubv1alpha1.AccessControlPolicy:
 		refs := secretReferences(v)
+		cmRefs := configMapReferences(v)
 		w.secretRefCounterMu.Lock()
 		for _, ref := range refs {
-			if w.secretRefCounter[ref] > 1 {
-				w.secretRefCounter[ref]--
-			} else {
-				delete(w.secretRefCounter, ref)
-			}
+			decrementRef(w.secretRefCounter, ref)
 		}
+		for _, ref := range cmRefs {
+			decrementRef(w.configMapRefCounter, ref)
+		}
+		delete(w.secretSelectors, v.Name)
 		w.secretRefCounterMu.Unlock()
 
 	case *corev1.Secret:
-		w.secretRefCounterMu.RLock()
-		c := w.secretRefCounter[secretKey(v.Name, v.Namespace)]
-		w.secretRefCounterMu.RUnlock()
-		if c == 0 {
+		if !w.isSecretWatched(v) {
 			return
 		}
 
@@ -215,13 +317,65 @@ func (w *Watcher) OnDelete(obj interface{}) {
 	}
 }
 
-func (w *Watcher) makeConfigs() (map[string]*acp.Config, error) {
+// decrementRef decrements the reference count of the given key, removing it once it is no longer referenced.
+func decrementRef(counter map[string]int, key string) {
+	if counter[key] > 1 {
+		counter[key]--
+		return
+	}
+
+	delete(counter, key)
+}
+
+// setSecretSelectors records the secret selectors of the given policy. It must be called with secretRefCounterMu locked.
+func (w *Watcher) setSecretSelectors(policy *hubv1alpha1.AccessControlPolicy) {
+	selectors := secretSelectors(policy)
+	if len(selectors) == 0 {
+		delete(w.secretSelectors, policy.Name)
+		return
+	}
+
+	w.secretSelectors[policy.Name] = selectors
+}
+
+// isSecretWatched returns whether the given secret is referenced or selected by an ACP.
+func (w *Watcher) isSecretWatched(secret *corev1.Secret) bool {
+	w.secretRefCounterMu.RLock()
+	defer w.secretRefCounterMu.RUnlock()
+
+	if w.secretRefCounter[secretKey(secret.Name, secret.Namespace)] > 0 {
+		return true
+	}
+
+	for _, selectors := range w.secretSelectors {
+		for _, selector := range selectors {
+			if selector.matches(secret) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// watchesConfigMaps returns whether a ConfigMap is referenced by an ACP.
+func (w *Watcher) watchesConfigMaps() bool {
+	w.secretRefCounterMu.RLock()
+	defer w.secretRefCounterMu.RUnlock()
+
+	return len(w.configMapRefCounter) > 0
+}
+
+// makeConfigs returns the configurations of the ACPs, along with the errors preventing the configurations of some ACPs
+// to be created, indexed by ACP name.
+func (w *Watcher) makeConfigs() (map[string]*acp.Config, map[string]error, error) {
 	policies, err := w.acps.List(labels.Everything())
 	if err != nil {
-		return nil, fmt.Errorf("listing ACPs: %w", err)
+		return nil, nil, fmt.Errorf("listing ACPs: %w", err)
 	}
 
 	configs := make(map[string]*acp.Config)
+	errs := make(map[string]error)
 	for _, policy := range policies {
 		config, err := acp.ConfigFromPolicyWithSecret(policy, w.secrets)
 		if err != nil {
@@ -229,65 +383,247 @@ func (w *Watcher) makeConfigs() (map[string]*acp.Config, error) {
 				Err(err).
 				Str("acp_name", policy.Name).
 				Msg("Could not create ACP configuration")
+			errs[policy.Name] = err
 			continue
 		}
 
 		configs[policy.Name] = config
 	}
 
-	return configs, nil
+	return configs, errs, nil
 }
 
-func (w *Watcher) buildRoutes(ctx context.Context) http.Handler {
+// subRouter is implemented by ACP handlers serving additional endpoints under their path, like the OIDC back-channel
+// logout endpoint.
+type subRouter interface {
+	Routes() map[string]http.Handler
+}
+
+// buildRoutes returns a handler serving the ACP handlers, along with the errors preventing some ACP handlers to be
+// built, indexed by ACP name.
+func (w *Watcher) buildRoutes(ctx context.Context) (http.Handler, map[string]error) {
 	w.configsMu.RLock()
 	defer w.configsMu.RUnlock()
 
 	mux := http.NewServeMux()
 
+	errs := make(map[string]error)
+	var signingKeys []string
 	for name, cfg := range w.configs {
 		path := "/" + name
 
 		logger := log.With().Str("acp_name", name).Str("acp_type", getACPType(cfg)).Logger()
 
-		route, err := buildRoute(ctx, name, cfg)
+		route, err := w.buildRoute(ctx, name, cfg)
 		if err != nil {
 			logger.Error().Err(err).Msg("Could not Create ACP handler")
+			errs[name] = err
 			continue
 		}
 
+		var subRoutes map[string]http.Handler
+		if r, ok := route.(subRouter); ok {
+			subRoutes = r.Routes()
+		}
+
+		if cfg.IssueToken != nil {
+			route, err = issuer.NewHandler(route, cfg.IssueToken, name)
+			if err != nil {
+				logger.Error().Err(err).Msg("Could not Create ACP handler")
+				errs[name] = err
+				continue
+			}
+
+			signingKeys = append(signingKeys, cfg.IssueToken.SigningKey)
+		}
+
+		if cfg.UpstreamToken != nil {
+			route, err = upstreamtoken.NewHandler(route, cfg.UpstreamToken, name)
+			if err != nil {
+				logger.Error().Err(err).Msg("Could not Create ACP handler")
+				errs[name] = err
+				continue
+			}
+		}
+
+		if cfg.DenialResponse != nil {
+			route, err = denial.NewHandler(route, cfg.DenialResponse, name)
+			if err != nil {
+				logger.Error().Err(err).Msg("Could not Create ACP handler")
+				errs[name] = err
+				continue
+			}
+		}
+
+		for subPath, handler := range subRoutes {
+			mux.Handle(path+subPath, handler)
+		}
+
+		if w.decisions != nil {
+			enforcementMode := cfg.EnforcementMode
+			if enforcementMode == "" {
+				enforcementMode = acp.EnforcementModeEnforce
+			}
+
+			route = decision.NewHandler(route, w.decisions, name, getACPType(cfg), enforcementMode)
+		}
+
+		if cfg.EnforcementMode == acp.EnforcementModeAudit {
+			logger.Info().Msg("ACP decisions are audited but not enforced")
+			route = audit.NewHandler(route, name)
+		}
+
 		logger.Debug().Msg("Registering ACP handler")
 
 		mux.Handle(path, route)
 	}
 
-	return mux
+	jwks, err := issuer.NewJWKSHandler(signingKeys)
+	if err != nil {
+		log.Error().Err(err).Msg("Could not create JWKS handler")
+	} else {
+		mux.Handle(issuer.JWKSPath, jwks)
+	}
+
+	return mux, errs
 }
 
-func buildRoute(ctx context.Context, name string, cfg *acp.Config) (http.Handler, error) {
+// Status is the status of the ACP handlers.
+type Status struct {
+	// Ready is true once ACP handlers have been built at least once from successfully listed ACPs.
+	Ready               bool       `json:"ready"`
+	LastBuild           *time.Time `json:"lastBuild,omitempty"`
+	LastSuccessfulBuild *time.Time `json:"lastSuccessfulBuild,omitempty"`
+	// SyncError is the error preventing ACPs to be listed, if any.
+	SyncError       string          `json:"syncError,omitempty"`
+	Handlers        int             `json:"handlers"`
+	FailingPolicies []FailingPolicy `json:"failingPolicies"`
+}
+
+// FailingPolicy is an ACP which cannot be served.
+type FailingPolicy struct {
+	Name string `json:"name"`
+	// Stage is either "config", if the configuration of the ACP cannot be created, for instance because of a missing
+	// secret, or "build" if its handler cannot be built.
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+// Ready returns whether ACP handlers have been built at least once from successfully listed ACPs.
+// An ACP failing to be built does not make the watcher unready, as other ACPs can still be served.
+func (w *Watcher) Ready() bool {
+	w.statusMu.RLock()
+	defer w.statusMu.RUnlock()
+
+	return !w.lastSuccessfulBuild.IsZero()
+}
+
+// Status returns the status of the ACP handlers.
+func (w *Watcher) Status() Status {
+	w.statusMu.RLock()
+	defer w.statusMu.RUnlock()
+
+	status := Status{
+		Ready:           !w.lastSuccessfulBuild.IsZero(),
+		Handlers:        w.switcher.HandlerCount(),
+		FailingPolicies: make([]FailingPolicy, 0, len(w.configErrs)+len(w.buildErrs)),
+	}
+
+	if !w.lastBuild.IsZero() {
+		lastBuild := w.lastBuild
+		status.LastBuild = &lastBuild
+	}
+
+	if status.Ready {
+		lastSuccessfulBuild := w.lastSuccessfulBuild
+		status.LastSuccessfulBuild = &lastSuccessfulBuild
+	}
+
+	if w.syncErr != nil {
+		status.SyncError = w.syncErr.Error()
+	}
+
+	for name, err := range w.configErrs {
+		status.FailingPolicies = append(status.FailingPolicies, FailingPolicy{Name: name, Stage: "config", Error: err.Error()})
+	}
+	for name, err := range w.buildErrs {
+		status.FailingPolicies = append(status.FailingPolicies, FailingPolicy{Name: name, Stage: "build", Error: err.Error()})
+	}
+
+	sort.Slice(status.FailingPolicies, func(i, j int) bool {
+		return status.FailingPolicies[i].Name < status.FailingPolicies[j].Name
+	})
+
+	return status
+}
+
+func (w *Watcher) buildRoute(ctx context.Context, name string, cfg *acp.Config) (http.Handler, error) {
 	switch {
 	case cfg.JWT != nil:
 		return jwt.NewHandler(cfg.JWT, name)
 
 	case cfg.BasicAuth != nil:
-		return basicauth.NewHandler(cfg.BasicAuth, name)
+		return basicauth.NewHandler(cfg.BasicAuth, name, w.lockouts)
 
 	case cfg.APIKey != nil:
-		return apikey.NewHandler(cfg.APIKey, name)
+		return apikey.NewHandler(cfg.APIKey, name, w.lockouts)
 
 	case cfg.OIDC != nil:
-		return oidc.NewHandler(ctx, cfg.OIDC, name)
+		return oidc.NewHandler(ctx, cfg.OIDC, name, w.sessions)
 
 	case cfg.OIDCGoogle != nil:
-		return oidc.NewHandler(ctx, &cfg.OIDCGoogle.Config, name)
+		return oidc.NewHandler(ctx, &cfg.OIDCGoogle.Config, name, w.sessions)
 
 	case cfg.OAuthIntro != nil:
 		return oauthintro.NewHandler(cfg.OAuthIntro, name)
 
+	case cfg.MTLS != nil:
+		return mtls.NewHandler(cfg.MTLS, name)
+
+	case cfg.IPAllowList != nil:
+		return ipallowlist.NewHandler(cfg.IPAllowList, name)
+
+	case cfg.ExternalAuthz != nil:
+		return externalauthz.NewHandler(cfg.ExternalAuthz, name)
+
+	case len(cfg.AnyOf) > 0:
+		return w.buildCompositeRoute(ctx, name, composite.AnyOf, cfg.AnyOf)
+
+	case len(cfg.AllOf) > 0:
+		return w.buildCompositeRoute(ctx, name, composite.AllOf, cfg.AllOf)
+
 	default:
 		return nil, fmt.Errorf("unknown handler type for ACP %s", name)
 	}
 }
 
+func (w *Watcher) buildCompositeRoute(ctx context.Context, name string, mode composite.Mode, methods []acp.Config) (http.Handler, error) {
+	handlers := make([]http.Handler, 0, len(methods))
+	for i := range methods {
+		handler, err := w.buildRoute(ctx, name, &methods[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s method %d: %w", mode, i, err)
+		}
+
+		handlers = append(handlers, handler)
+	}
+
+	return composite.NewHandler(mode, handlers, name)
+}
+
+// deleteStaleMetrics deletes the metric series of the ACPs which no longer exist.
+func deleteStaleMetrics(configs map[string]*acp.Config, configErrs map[string]error) {
+	acps := make(map[string]struct{}, len(configs)+len(configErrs))
+	for name := range configs {
+		acps[name] = struct{}{}
+	}
+	for name := range configErrs {
+		acps[name] = struct{}{}
+	}
+
+	metrics.DeleteStaleSeries(acps)
+}
+
 func getACPType(cfg *acp.Config) string {
 	switch {
 	case cfg.JWT != nil:
@@ -308,6 +644,21 @@ func getACPType(cfg *acp.Config) string {
 	case cfg.OAuthIntro != nil:
 		return "OAuth Introspection"
 
+	case cfg.MTLS != nil:
+		return "mTLS"
+
+	case cfg.IPAllowList != nil:
+		return "IP Allow List"
+
+	case cfg.ExternalAuthz != nil:
+		return "External Authz"
+
+	case len(cfg.AnyOf) > 0:
+		return "AnyOf"
+
+	case len(cfg.AllOf) > 0:
+		return "AllOf"
+
 	default:
 		return "unknown"
 	}
@@ -321,19 +672,149 @@ func secretReferences(policy *hubv1alpha1.AccessControlPolicy) []string {
 	var refs []string
 
 	switch {
+	case policy.Spec.JWT != nil:
+		refs = append(refs, jwtSecretReferences(policy.Spec.JWT)...)
+
+	case policy.Spec.BasicAuth != nil:
+		refs = append(refs, basicAuthSecretReferences(policy.Spec.BasicAuth)...)
+
 	case policy.Spec.OIDC != nil:
 		if policy.Spec.OIDC.Secret != nil {
 			refs = append(refs, secretKey(policy.Spec.OIDC.Secret.Name, policy.Spec.OIDC.Secret.Namespace))
 		}
+		if policy.Spec.OIDC.Session != nil && policy.Spec.OIDC.Session.KeysSecret != nil {
+			refs = append(refs, secretKey(policy.Spec.OIDC.Session.KeysSecret.Name, policy.Spec.OIDC.Session.KeysSecret.Namespace))
+		}
 
 	case policy.Spec.OIDCGoogle != nil:
 		if policy.Spec.OIDCGoogle.Secret != nil {
 			refs = append(refs, secretKey(policy.Spec.OIDCGoogle.Secret.Name, policy.Spec.OIDCGoogle.Secret.Namespace))
 		}
+		if policy.Spec.OIDCGoogle.Session != nil && policy.Spec.OIDCGoogle.Session.KeysSecret != nil {
+			refs = append(refs, secretKey(policy.Spec.OIDCGoogle.Session.KeysSecret.Name, policy.Spec.OIDCGoogle.Session.KeysSecret.Namespace))
+		}
 
 	case policy.Spec.OAuthIntro != nil:
 		refs = append(refs, secretKey(policy.Spec.OAuthIntro.ClientConfig.Auth.Secret.Name, policy.Spec.OAuthIntro.ClientConfig.Auth.Secret.Namespace))
+
+	case policy.Spec.MTLS != nil:
+		refs = append(refs, secretKey(policy.Spec.MTLS.CASecret.Name, policy.Spec.MTLS.CASecret.Namespace))
+
+	case policy.Spec.APIKey != nil:
+		refs = append(refs, apiKeySecretReferences(policy.Spec.APIKey)...)
+
+	case len(policy.Spec.AnyOf) > 0:
+		refs = append(refs, methodSecretReferences(policy.Spec.AnyOf)...)
+
+	case len(policy.Spec.AllOf) > 0:
+		refs = append(refs, methodSecretReferences(policy.Spec.AllOf)...)
+	}
+
+	if policy.Spec.IssueToken != nil {
+		refs = append(refs, secretKey(policy.Spec.IssueToken.KeySecret.Name, policy.Spec.IssueToken.KeySecret.Namespace))
+	}
+
+	if policy.Spec.UpstreamToken != nil {
+		refs = append(refs, secretKey(policy.Spec.UpstreamToken.Secret.Name, policy.Spec.UpstreamToken.Secret.Namespace))
 	}
 
 	return refs
 }
+
+func configMapReferences(policy *hubv1alpha1.AccessControlPolicy) []string {
+	if policy.Spec.DenialResponse == nil || policy.Spec.DenialResponse.HTMLTemplate == nil {
+		return nil
+	}
+
+	ref := policy.Spec.DenialResponse.HTMLTemplate
+
+	return []string{secretKey(ref.Name, ref.Namespace)}
+}
+
+func methodSecretReferences(methods []hubv1alpha1.AccessControlPolicyMethod) []string {
+	var refs []string
+	for _, method := range methods {
+		switch {
+		case method.JWT != nil:
+			refs = append(refs, jwtSecretReferences(method.JWT)...)
+
+		case method.BasicAuth != nil:
+			refs = append(refs, basicAuthSecretReferences(method.BasicAuth)...)
+
+		case method.OAuthIntro != nil:
+			refs = append(refs, secretKey(method.OAuthIntro.ClientConfig.Auth.Secret.Name, method.OAuthIntro.ClientConfig.Auth.Secret.Namespace))
+
+		case method.MTLS != nil:
+			refs = append(refs, secretKey(method.MTLS.CASecret.Name, method.MTLS.CASecret.Namespace))
+
+		case method.APIKey != nil:
+			refs = append(refs, apiKeySecretReferences(method.APIKey)...)
+		}
+	}
+
+	return refs
+}
+
+func jwtSecretReferences(jwt *hubv1alpha1.AccessControlPolicyJWT) []string {
+	if jwt.DecryptionKeySecret == nil {
+		return nil
+	}
+
+	return []string{secretKey(jwt.DecryptionKeySecret.Name, jwt.DecryptionKeySecret.Namespace)}
+}
+
+func basicAuthSecretReferences(basicAuth *hubv1alpha1.AccessControlPolicyBasicAuth) []string {
+	if basicAuth.UsersSecret == nil {
+		return nil
+	}
+
+	return []string{secretKey(basicAuth.UsersSecret.Name, basicAuth.UsersSecret.Namespace)}
+}
+
+func apiKeySecretReferences(apiKey *hubv1alpha1.AccessControlPolicyAPIKey) []string {
+	refs := make([]string, 0, len(apiKey.KeySecrets))
+	for _, ref := range apiKey.KeySecrets {
+		refs = append(refs, secretKey(ref.Name, ref.Namespace))
+	}
+
+	return refs
+}
+
+// secretSelector selects secrets of a namespace.
+type secretSelector struct {
+	namespace string
+	selector  labels.Selector
+}
+
+func (s secretSelector) matches(secret *corev1.Secret) bool {
+	return secret.Namespace == s.namespace && s.selector.Matches(labels.Set(secret.Labels))
+}
+
+func secretSelectors(policy *hubv1alpha1.AccessControlPolicy) []secretSelector {
+	apiKeys := []*hubv1alpha1.AccessControlPolicyAPIKey{policy.Spec.APIKey}
+	for _, methods := range [][]hubv1alpha1.AccessControlPolicyMethod{policy.Spec.AnyOf, policy.Spec.AllOf} {
+		for _, method := range methods {
+			apiKeys = append(apiKeys, method.APIKey)
+		}
+	}
+
+	var selectors []secretSelector
+	for _, apiKey := range apiKeys {
+		if apiKey == nil || apiKey.KeySecretSelector == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(&apiKey.KeySecretSelector.Selector)
+		if err != nil {
+			log.Error().Err(err).Str("acp_name", policy.Name).Msg("Invalid key secret selector")
+			continue
+		}
+
+		selectors = append(selectors, secretSelector{
+			namespace: apiKey.KeySecretSelector.Namespace,
+			selector:  selector,
+		})
+	}
+
+	return selectors
+}
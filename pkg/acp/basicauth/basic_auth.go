@@ -24,6 +24,8 @@ import (
 
 	goauth "github.com/abbot/go-http-auth"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
 )
 
 const defaultRealm = "hub"
@@ -33,10 +35,22 @@ type Users []string
 
 // Config configures a basic auth ACP handler.
 type Config struct {
-	Users                    Users  `json:"users,omitempty"`
-	Realm                    string `json:"realm,omitempty"`
-	StripAuthorizationHeader bool   `json:"stripAuthorizationHeader,omitempty"`
-	ForwardUsernameHeader    string `json:"forwardUsernameHeader,omitempty"`
+	Users                    Users            `json:"users,omitempty"`
+	Realm                    string           `json:"realm,omitempty"`
+	StripAuthorizationHeader bool             `json:"stripAuthorizationHeader,omitempty"`
+	ForwardUsernameHeader    string           `json:"forwardUsernameHeader,omitempty"`
+	UsersSecret              *SecretReference `json:"usersSecret,omitempty"`
+	Lockout                  *lockout.Config  `json:"lockout,omitempty"`
+
+	// SecretUsers holds the users resolved from UsersSecret.
+	SecretUsers Users `json:"-"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
 }
 
 // Handler is a basic auth ACP Handler.
@@ -46,15 +60,30 @@ type Handler struct {
 	forwardUsername    string
 	stripAuthorization bool
 	name               string
+	// limiter locks out clients and users after too many failed attempts. It is nil if lockouts are disabled.
+	limiter *lockout.Limiter
 }
 
-// NewHandler creates a new basic auth ACP Handler.
-func NewHandler(cfg *Config, name string) (*Handler, error) {
-	users, err := getUsers(cfg.Users, basicUserParser)
+// NewHandler creates a new basic auth ACP Handler. Lockouts, if configured, are stored in the given store.
+func NewHandler(cfg *Config, name string, lockouts lockout.Store) (*Handler, error) {
+	users, err := getUsers(cfg.Users, basicUserParser, name)
+	if err != nil {
+		return nil, err
+	}
+
+	secretUsers, err := getUsers(cfg.SecretUsers, basicUserParser, name)
 	if err != nil {
 		return nil, err
 	}
 
+	// A user defined both inline and in the users Secret is ambiguous, as none of its definitions is more recent.
+	for userName, userHash := range secretUsers {
+		if _, ok := users[userName]; ok {
+			return nil, fmt.Errorf("duplicated user %q", userName)
+		}
+		users[userName] = userHash
+	}
+
 	h := &Handler{
 		users:              users,
 		forwardUsername:    cfg.ForwardUsernameHeader,
@@ -62,6 +91,13 @@ func NewHandler(cfg *Config, name string) (*Handler, error) {
 		name:               name,
 	}
 
+	if cfg.Lockout != nil {
+		h.limiter, err = lockout.NewLimiter(cfg.Lockout, lockouts, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	realm := defaultRealm
 	if len(cfg.Realm) > 0 {
 		realm = cfg.Realm
@@ -76,10 +112,26 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	l := log.With().Str("handler_type", "BasicAuth").Str("handler_name", h.name).Logger()
 
 	username, password, ok := req.BasicAuth()
-	if ok {
+
+	if h.limiter != nil {
+		if lockedFor := h.limiter.LockedFor(req, username); lockedFor > 0 {
+			l.Debug().Str("username", username).Msg("Client or user is locked out")
+			lockout.Deny(rw, req, lockedFor)
+			return
+		}
+	}
+
+	if !ok {
+		decision.SetReason(req, decision.ReasonMissingCredentials)
+	} else {
 		secret := h.auth.Secrets(username, h.auth.Realm)
 		if secret == "" || !goauth.CheckSecret(password, secret) {
+			decision.SetReason(req, decision.ReasonInvalidCredentials)
 			ok = false
+
+			if h.limiter != nil {
+				h.limiter.Fail(req, username)
+			}
 		}
 	}
 
@@ -90,6 +142,12 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if h.limiter != nil {
+		h.limiter.Succeed(req, username)
+	}
+
+	decision.SetSubject(req, username)
+
 	if h.forwardUsername != "" {
 		rw.Header().Set(h.forwardUsername, username)
 	}
@@ -109,6 +167,22 @@ func (h *Handler) secretBasic(user, _ string) string {
 	return ""
 }
 
+// ParseHtpasswd parses the content of an htpasswd file and returns the users it holds. Empty lines and comments are
+// ignored.
+func ParseHtpasswd(content []byte) Users {
+	var users Users
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		users = append(users, line)
+	}
+
+	return users
+}
+
 func basicUserParser(user string) (username, password string, err error) {
 	split := strings.Split(user, ":")
 	if len(split) != 2 {
@@ -120,13 +194,18 @@ func basicUserParser(user string) (username, password string, err error) {
 // userParser Parses a string and return a userName/userHash. An error if the format of the string is incorrect.
 type userParser func(user string) (username, password string, err error)
 
-func getUsers(users []string, parser userParser) (map[string]string, error) {
+// getUsers parses the given users. The last definition of a user defined several times is used.
+func getUsers(users []string, parser userParser, name string) (map[string]string, error) {
 	userMap := make(map[string]string)
 	for _, user := range users {
 		userName, userHash, err := parser(user)
 		if err != nil {
 			return nil, err
 		}
+
+		if _, ok := userMap[userName]; ok {
+			log.Warn().Str("acp_name", name).Str("username", userName).Msg("Duplicated user, using its last definition")
+		}
 		userMap[userName] = userHash
 	}
 
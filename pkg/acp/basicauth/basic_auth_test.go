@@ -24,19 +24,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestBasicAuthFail(t *testing.T) {
 	cfg := &Config{
 		Users: []string{"test"},
 	}
-	_, err := NewHandler(cfg, "authName")
+	_, err := NewHandler(cfg, "authName", nil)
 	require.Error(t, err)
 
 	auth2 := Config{
 		Users: []string{"test:test"},
 	}
-	handler, err := NewHandler(&auth2, "acp@my-ns")
+	handler, err := NewHandler(&auth2, "acp@my-ns", nil)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
@@ -53,7 +56,7 @@ func TestBasicAuthUserHeader(t *testing.T) {
 		Users:                 []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
 		ForwardUsernameHeader: "User",
 	}
-	handler, err := NewHandler(cfg, "acp@my-ns")
+	handler, err := NewHandler(cfg, "acp@my-ns", nil)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
@@ -65,3 +68,131 @@ func TestBasicAuthUserHeader(t *testing.T) {
 	assert.Equal(t, http.StatusOK, rec.Code)
 	assert.Equal(t, "test", rec.Header().Get("User"))
 }
+
+func TestBasicAuthSubject(t *testing.T) {
+	cfg := &Config{
+		Users: []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns", nil)
+	require.NoError(t, err)
+
+	req := decision.Track(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	req.SetBasicAuth("test", "wrong")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, decision.IdentityOf(req).Subject)
+
+	req = decision.Track(httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	req.SetBasicAuth("test", "test")
+	rec = httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "test", decision.IdentityOf(req).Subject)
+}
+
+func TestBasicAuthSecretUsers(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcrypt-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	cfg := &Config{
+		Users: []string{"inline:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		SecretUsers: ParseHtpasswd([]byte(`# Users managed by ops.
+bcrypt:` + string(bcryptHash) + `
+sha1:{SHA}qUqP5cyxm6YcTAhz05Hph5gvu9M=
+
+apr1:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/
+`)),
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		user     string
+		password string
+		wantCode int
+	}{
+		{user: "inline", password: "test", wantCode: http.StatusOK},
+		{user: "bcrypt", password: "bcrypt-password", wantCode: http.StatusOK},
+		{user: "bcrypt", password: "test", wantCode: http.StatusUnauthorized},
+		{user: "sha1", password: "test", wantCode: http.StatusOK},
+		{user: "sha1", password: "other", wantCode: http.StatusUnauthorized},
+		{user: "apr1", password: "test", wantCode: http.StatusOK},
+		{user: "unknown", password: "test", wantCode: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.SetBasicAuth(test.user, test.password)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, test.wantCode, rec.Code, test.user+":"+test.password)
+	}
+}
+
+func TestBasicAuthDuplicatedUsers(t *testing.T) {
+	cfg := &Config{
+		Users:       []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		SecretUsers: []string{"test:{SHA}qUqP5cyxm6YcTAhz05Hph5gvu9M="},
+	}
+	_, err := NewHandler(cfg, "acp@my-ns", nil)
+	assert.EqualError(t, err, `duplicated user "test"`)
+}
+
+func TestBasicAuthDuplicatedInlineUsers(t *testing.T) {
+	cfg := &Config{
+		// The last definition is used: the password of the first one is "test", the one of the last one is "password".
+		Users: []string{
+			"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/",
+			"test:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=",
+		},
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns", nil)
+	require.NoError(t, err)
+
+	for password, wantCode := range map[string]int{"test": http.StatusUnauthorized, "password": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.SetBasicAuth("test", password)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, wantCode, rec.Code, password)
+	}
+}
+
+func TestBasicAuthLockout(t *testing.T) {
+	cfg := &Config{
+		Users:   []string{"test:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+		Lockout: &lockout.Config{MaxFailuresPerUser: 2, LockoutSeconds: 60},
+	}
+	handler, err := NewHandler(cfg, "acp@my-ns", lockout.NewMemoryStore())
+	require.NoError(t, err)
+
+	tests := []struct {
+		password       string
+		wantCode       int
+		wantRetryAfter string
+	}{
+		{password: "wrong", wantCode: http.StatusUnauthorized},
+		{password: "wrong", wantCode: http.StatusUnauthorized},
+		{password: "test", wantCode: http.StatusTooManyRequests, wantRetryAfter: "60"},
+	}
+
+	for i, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.SetBasicAuth("test", test.password)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, test.wantCode, rec.Code, i)
+		assert.Equal(t, test.wantRetryAfter, rec.Header().Get("Retry-After"), i)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package composite
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+// Mode defines how the decisions of the methods of a composite ACP are combined.
+type Mode string
+
+// Supported composition modes.
+const (
+	// AnyOf grants access as soon as one method grants it. Methods are evaluated in order and only the headers
+	// of the first method granting access are forwarded. When no method grants access, the denial with the
+	// highest status code is returned, the first one in case of a tie.
+	AnyOf Mode = "anyOf"
+	// AllOf grants access only if all methods grant it. Methods are evaluated in order and the first denial is
+	// returned as is. Headers of all methods are forwarded and, when several methods set the same header, the
+	// values of the first method setting it are kept.
+	AllOf Mode = "allOf"
+)
+
+// Handler is a composite ACP Handler.
+type Handler struct {
+	name     string
+	mode     Mode
+	handlers []http.Handler
+}
+
+// NewHandler creates a new composite ACP Handler.
+func NewHandler(mode Mode, handlers []http.Handler, name string) (*Handler, error) {
+	if mode != AnyOf && mode != AllOf {
+		return nil, errors.New(`mode must be one of "anyOf" or "allOf"`)
+	}
+
+	if len(handlers) == 0 {
+		return nil, errors.New("at least one method is required")
+	}
+
+	return &Handler{
+		name:     name,
+		mode:     mode,
+		handlers: handlers,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// Track the request so methods can read the identity reported by the ones which granted it before them.
+	req = decision.Track(req)
+
+	var resp *response
+	switch h.mode {
+	case AnyOf:
+		resp = h.anyOf(req)
+	case AllOf:
+		resp = h.allOf(req)
+	}
+
+	if !resp.granted() {
+		log.Debug().
+			Str("handler_type", "Composite").
+			Str("handler_name", h.name).
+			Str("mode", string(h.mode)).
+			Int("status", resp.code).
+			Msg("Access denied")
+	}
+
+	resp.writeTo(rw)
+}
+
+func (h *Handler) anyOf(req *http.Request) *response {
+	var denial *response
+	for _, handler := range h.handlers {
+		resp := serve(handler, req)
+		if resp.granted() {
+			decision.Merge(req, resp.req)
+			return resp
+		}
+
+		if denial == nil || resp.code > denial.code {
+			denial = resp
+		}
+	}
+
+	decision.SetReason(req, decision.ReasonOf(denial.req))
+
+	return denial
+}
+
+func (h *Handler) allOf(req *http.Request) *response {
+	merged := newResponse()
+	for _, handler := range h.handlers {
+		resp := serve(handler, req)
+		if !resp.granted() {
+			decision.SetReason(req, decision.ReasonOf(resp.req))
+			return resp
+		}
+		decision.Merge(req, resp.req)
+
+		for name, values := range resp.header {
+			if _, ok := merged.header[name]; ok {
+				continue
+			}
+			merged.header[name] = values
+		}
+	}
+
+	merged.code = http.StatusOK
+
+	return merged
+}
+
+// serve serves the given request with the given handler. The handler reports the decision it made on its own fork of
+// the request, so that methods which did not grant the request cannot alter its identity.
+func serve(handler http.Handler, req *http.Request) *response {
+	resp := newResponse()
+	resp.req = decision.Fork(req)
+	handler.ServeHTTP(resp, resp.req)
+
+	// Like net/http, consider a handler that did not write anything granted the request.
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+
+	return resp
+}
+
+// response records the response of an ACP handler so that it can be combined with others.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+
+	// req is the fork of the request the handler served.
+	req *http.Request
+}
+
+func newResponse() *response {
+	return &response{header: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter.
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+// Write implements http.ResponseWriter.
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *response) WriteHeader(code int) {
+	if r.code != 0 {
+		return
+	}
+
+	r.code = code
+}
+
+func (r *response) granted() bool {
+	return r.code >= 200 && r.code < 300
+}
+
+func (r *response) writeTo(rw http.ResponseWriter) {
+	for name, values := range r.header {
+		rw.Header()[name] = values
+	}
+
+	rw.WriteHeader(r.code)
+	_, _ = rw.Write(r.body.Bytes())
+}
@@ -0,0 +1,205 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package composite
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler("oneOf", []http.Handler{grant(nil)}, "acp")
+	assert.Error(t, err)
+
+	_, err = NewHandler(AnyOf, nil, "acp")
+	assert.Error(t, err)
+
+	_, err = NewHandler(AllOf, []http.Handler{grant(nil)}, "acp")
+	assert.NoError(t, err)
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		mode       Mode
+		handlers   []http.Handler
+		wantStatus int
+		wantHeader http.Header
+	}{
+		{
+			desc: "anyOf: first method grants access",
+			mode: AnyOf,
+			handlers: []http.Handler{
+				grant(map[string]string{"User": "jwt-user"}),
+				grant(map[string]string{"User": "api-key-user"}),
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"User": []string{"jwt-user"}},
+		},
+		{
+			desc: "anyOf: second method grants access",
+			mode: AnyOf,
+			handlers: []http.Handler{
+				deny(http.StatusUnauthorized, map[string]string{"Www-Authenticate": "Basic"}),
+				grant(map[string]string{"User": "api-key-user"}),
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"User": []string{"api-key-user"}},
+		},
+		{
+			desc: "anyOf: no method grants access, first denial on tie",
+			mode: AnyOf,
+			handlers: []http.Handler{
+				deny(http.StatusUnauthorized, map[string]string{"Www-Authenticate": "Basic"}),
+				deny(http.StatusUnauthorized, nil),
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantHeader: http.Header{"Www-Authenticate": []string{"Basic"}},
+		},
+		{
+			desc: "anyOf: no method grants access, highest status code wins",
+			mode: AnyOf,
+			handlers: []http.Handler{
+				deny(http.StatusUnauthorized, nil),
+				deny(http.StatusForbidden, nil),
+			},
+			wantStatus: http.StatusForbidden,
+			wantHeader: http.Header{},
+		},
+		{
+			desc: "allOf: all methods grant access, first header wins",
+			mode: AllOf,
+			handlers: []http.Handler{
+				grant(map[string]string{"User": "jwt-user", "Group": "admin"}),
+				grant(map[string]string{"User": "api-key-user", "Key-Id": "key-1"}),
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{
+				"User":   []string{"jwt-user"},
+				"Group":  []string{"admin"},
+				"Key-Id": []string{"key-1"},
+			},
+		},
+		{
+			desc: "allOf: one method denies access",
+			mode: AllOf,
+			handlers: []http.Handler{
+				grant(map[string]string{"User": "jwt-user"}),
+				deny(http.StatusForbidden, nil),
+				deny(http.StatusUnauthorized, nil),
+			},
+			wantStatus: http.StatusForbidden,
+			wantHeader: http.Header{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := NewHandler(test.mode, test.handlers, "acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/acp", nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Equal(t, test.wantHeader, rec.Header())
+		})
+	}
+}
+
+func grant(headers map[string]string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		for name, value := range headers {
+			rw.Header().Set(name, value)
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func deny(code int, headers map[string]string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		for name, value := range headers {
+			rw.Header().Set(name, value)
+		}
+
+		rw.WriteHeader(code)
+	})
+}
+
+func TestHandler_ServeHTTP_sharesIdentity(t *testing.T) {
+	authenticate := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		decision.SetSubject(req, "alice")
+		decision.SetClaims(req, map[string]interface{}{"tenant": "acme"})
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	var got decision.Identity
+	authorize := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = decision.IdentityOf(req)
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	handler, err := NewHandler(AllOf, []http.Handler{authenticate, authorize}, "acp")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "alice", got.Subject)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, got.Claims)
+}
+
+func TestHandler_ServeHTTP_ignoresDeniedMethodIdentity(t *testing.T) {
+	impersonate := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		decision.SetSubject(req, "admin")
+		decision.SetClaims(req, map[string]interface{}{"role": "admin"})
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
+		rw.WriteHeader(http.StatusUnauthorized)
+	})
+
+	var got decision.Identity
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		got = decision.IdentityOf(req)
+		decision.SetReason(req, decision.ReasonIPNotAllowed)
+		rw.WriteHeader(http.StatusForbidden)
+	})
+
+	handler, err := NewHandler(AnyOf, []http.Handler{impersonate, next}, "acp")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	req := decision.Track(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, decision.Identity{}, got)
+	assert.Equal(t, decision.Identity{}, decision.IdentityOf(req))
+	assert.Equal(t, decision.ReasonIPNotAllowed, decision.ReasonOf(req))
+}
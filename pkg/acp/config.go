@@ -21,33 +21,73 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/externalauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/issuer"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/upstreamtoken"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
 	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 // Config is the configuration of an Access Control Policy. It is used to set up ACP handlers.
 type Config struct {
-	JWT        *jwt.Config        `json:"jwt,omitempty"`
-	BasicAuth  *basicauth.Config  `json:"basicAuth,omitempty"`
-	APIKey     *apikey.Config     `json:"apiKey,omitempty"`
-	OIDC       *oidc.Config       `json:"oidc,omitempty"`
-	OIDCGoogle *OIDCGoogle        `json:"oidcGoogle,omitempty"`
-	OAuthIntro *oauthintro.Config `json:"oAuthIntro,omitempty"`
+	JWT           *jwt.Config           `json:"jwt,omitempty"`
+	BasicAuth     *basicauth.Config     `json:"basicAuth,omitempty"`
+	APIKey        *apikey.Config        `json:"apiKey,omitempty"`
+	OIDC          *oidc.Config          `json:"oidc,omitempty"`
+	OIDCGoogle    *OIDCGoogle           `json:"oidcGoogle,omitempty"`
+	OAuthIntro    *oauthintro.Config    `json:"oAuthIntro,omitempty"`
+	MTLS          *mtls.Config          `json:"mtls,omitempty"`
+	IPAllowList   *ipallowlist.Config   `json:"ipAllowList,omitempty"`
+	ExternalAuthz *externalauthz.Config `json:"externalAuthz,omitempty"`
+
+	// AnyOf and AllOf hold the methods of a composite ACP. Each of them has exactly one method set.
+	AnyOf []Config `json:"anyOf,omitempty"`
+	AllOf []Config `json:"allOf,omitempty"`
+
+	EnforcementMode string `json:"enforcementMode,omitempty"`
+	// IssueToken configures the identity tokens issued for the requests granted by the ACP. It is only set on the
+	// top-level configuration.
+	IssueToken *issuer.Config `json:"issueToken,omitempty"`
+	// UpstreamToken configures the access tokens forwarded to upstream services for the requests granted by the ACP.
+	// It is only set on the top-level configuration.
+	UpstreamToken *upstreamtoken.Config `json:"upstreamToken,omitempty"`
+	// DenialResponse customizes the responses to the requests denied by the ACP. It is only set on the top-level
+	// configuration.
+	DenialResponse *denial.Config `json:"denialResponse,omitempty"`
 }
 
+// Supported enforcement modes.
+const (
+	// EnforcementModeEnforce grants or denies access according to the decision of the ACP. This is the default mode.
+	EnforcementModeEnforce = "enforce"
+	// EnforcementModeAudit always grants access but logs and counts the decisions the ACP would have made.
+	EnforcementModeAudit = "audit"
+)
+
 // OIDCGoogle is the Google OIDC configuration.
 type OIDCGoogle struct {
 	oidc.Config
@@ -55,29 +95,154 @@ type OIDCGoogle struct {
 	Emails []string `json:"emails,omitempty"`
 }
 
-// SecretGetter allows getting secrets.
+// SecretGetter allows getting secrets, and the ConfigMaps holding non-sensitive resources such as templates.
 type SecretGetter interface {
 	GetValue(secret *corev1.SecretReference, key string) ([]byte, error)
+	GetSecret(secret *corev1.SecretReference) (*corev1.Secret, error)
+	ListSecrets(namespace string, selector labels.Selector) ([]*corev1.Secret, error)
+	GetConfigMapValue(namespace, name, key string) (string, error)
 }
 
 // ConfigFromPolicy returns an ACP configuration for the given policy without resolving secret references.
-func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) *Config {
-	// This will never raise an error as we are not resolving secret references.
-	cfg, _ := ConfigFromPolicyWithSecret(policy, emptySecretGetter{})
-	return cfg
+func ConfigFromPolicy(policy *hubv1alpha1.AccessControlPolicy) (*Config, error) {
+	return ConfigFromPolicyWithSecret(policy, emptySecretGetter{})
 }
 
 // ConfigFromPolicyWithSecret returns an ACP configuration for the given policy and resolves its secret references.
 func ConfigFromPolicyWithSecret(policy *hubv1alpha1.AccessControlPolicy, secrets SecretGetter) (*Config, error) {
+	cfg, err := makeConfig(policy, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.EnforcementMode = policy.Spec.EnforcementMode
+
+	if policy.Spec.IssueToken != nil {
+		cfg.IssueToken, err = makeIssueTokenConfig(policy.Spec.IssueToken, secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.Spec.UpstreamToken != nil {
+		cfg.UpstreamToken, err = makeUpstreamTokenConfig(policy.Spec.UpstreamToken, secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if policy.Spec.DenialResponse != nil {
+		cfg.DenialResponse, err = makeDenialResponseConfig(policy.Spec.DenialResponse, secrets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+func makeIssueTokenConfig(policy *hubv1alpha1.AccessControlPolicyIssueToken, secrets SecretGetter) (*issuer.Config, error) {
+	issuerConfig := &issuer.Config{
+		KeySecret: issuer.SecretReference{
+			Name:      policy.KeySecret.Name,
+			Namespace: policy.KeySecret.Namespace,
+		},
+		Header:     policy.Header,
+		Issuer:     policy.Issuer,
+		Audience:   policy.Audience,
+		TTLSeconds: policy.TTLSeconds,
+		Claims:     policy.Claims,
+	}
+	issuerConfig.ApplyDefaultValues()
+
+	key, err := secrets.GetValue(&policy.KeySecret, "key")
+	if err != nil {
+		return nil, fmt.Errorf("getting token signing key: %w", err)
+	}
+
+	issuerConfig.SigningKey = string(key)
+
+	return issuerConfig, nil
+}
+
+func makeUpstreamTokenConfig(policy *hubv1alpha1.AccessControlPolicyUpstreamToken, secrets SecretGetter) (*upstreamtoken.Config, error) {
+	upstreamTokenConfig := &upstreamtoken.Config{
+		Config: httpclient.Config{
+			TimeoutSeconds: optional.NewInt(policy.TimeoutSeconds),
+			MaxRetries:     optional.NewInt(policy.MaxRetries),
+		},
+		TokenURL: policy.TokenURL,
+		Secret: upstreamtoken.SecretReference{
+			Name:      policy.Secret.Name,
+			Namespace: policy.Secret.Namespace,
+		},
+		Scopes:         policy.Scopes,
+		EndpointParams: policy.EndpointParams,
+	}
+
+	if policy.TLS != nil {
+		upstreamTokenConfig.TLS = &httpclient.ConfigTLS{
+			CABundle:           policy.TLS.CABundle,
+			InsecureSkipVerify: policy.TLS.InsecureSkipVerify,
+		}
+	}
+
+	clientID, err := secrets.GetValue(&policy.Secret, "clientId")
+	if err != nil {
+		return nil, fmt.Errorf("getting upstream token client ID: %w", err)
+	}
+
+	clientSecret, err := secrets.GetValue(&policy.Secret, "clientSecret")
+	if err != nil {
+		return nil, fmt.Errorf("getting upstream token client secret: %w", err)
+	}
+
+	upstreamTokenConfig.ClientID = string(clientID)
+	upstreamTokenConfig.ClientSecret = string(clientSecret)
+
+	return upstreamTokenConfig, nil
+}
+
+func makeDenialResponseConfig(policy *hubv1alpha1.AccessControlPolicyDenialResponse, secrets SecretGetter) (*denial.Config, error) {
+	denialConfig := &denial.Config{
+		ProblemDetails: policy.ProblemDetails,
+	}
+
+	if policy.HTMLTemplate == nil {
+		return denialConfig, nil
+	}
+
+	key := policy.HTMLTemplate.Key
+	if key == "" {
+		key = denial.DefaultTemplateKey
+	}
+
+	denialConfig.HTMLTemplate = &denial.ConfigMapKeyReference{
+		Name:      policy.HTMLTemplate.Name,
+		Namespace: policy.HTMLTemplate.Namespace,
+		Key:       key,
+	}
+
+	tmpl, err := secrets.GetConfigMapValue(policy.HTMLTemplate.Namespace, policy.HTMLTemplate.Name, key)
+	if err != nil {
+		return nil, fmt.Errorf("getting denial HTML template: %w", err)
+	}
+
+	denialConfig.Template = tmpl
+
+	return denialConfig, nil
+}
+
+func makeConfig(policy *hubv1alpha1.AccessControlPolicy, secrets SecretGetter) (*Config, error) {
 	switch {
 	case policy.Spec.JWT != nil:
-		return makeJWTConfig(policy.Spec.JWT), nil
+		return makeJWTConfig(policy.Spec.JWT, secrets)
 
 	case policy.Spec.BasicAuth != nil:
-		return makeBasicAuthConfig(policy.Spec.BasicAuth), nil
+		return makeBasicAuthConfig(policy.Spec.BasicAuth, secrets)
 
 	case policy.Spec.APIKey != nil:
-		return makeAPIKeyConfig(policy.Spec.APIKey), nil
+		return makeAPIKeyConfig(policy.Name, policy.Spec.APIKey, secrets)
 
 	case policy.Spec.OIDC != nil:
 		return makeOIDCConfig(policy.Spec.OIDC, secrets)
@@ -87,9 +252,75 @@ func ConfigFromPolicyWithSecret(policy *hubv1alpha1.AccessControlPolicy, secrets
 
 	case policy.Spec.OAuthIntro != nil:
 		return makeOAuthIntro(policy.Spec.OAuthIntro, secrets)
+
+	case policy.Spec.MTLS != nil:
+		return makeMTLSConfig(policy.Spec.MTLS, secrets)
+
+	case policy.Spec.IPAllowList != nil:
+		return makeIPAllowListConfig(policy.Spec.IPAllowList), nil
+
+	case policy.Spec.ExternalAuthz != nil:
+		return makeExternalAuthzConfig(policy.Spec.ExternalAuthz), nil
+
+	case len(policy.Spec.AnyOf) > 0:
+		methods, err := makeMethodConfigs(policy.Name, policy.Spec.AnyOf, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("anyOf: %w", err)
+		}
+		return &Config{AnyOf: methods}, nil
+
+	case len(policy.Spec.AllOf) > 0:
+		methods, err := makeMethodConfigs(policy.Name, policy.Spec.AllOf, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("allOf: %w", err)
+		}
+		return &Config{AllOf: methods}, nil
+	}
+
+	return nil, errors.New(`exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle", "oAuthIntro", "mtls", "ipAllowList", "externalAuthz", "anyOf" or "allOf" must be set`)
+}
+
+func makeMethodConfigs(acpName string, methods []hubv1alpha1.AccessControlPolicyMethod, secrets SecretGetter) ([]Config, error) {
+	configs := make([]Config, 0, len(methods))
+	for i, method := range methods {
+		cfg, err := makeMethodConfig(acpName, method, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("method %d: %w", i, err)
+		}
+
+		configs = append(configs, *cfg)
 	}
 
-	return nil, errors.New(`exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle" or "oAuthIntro" must be set`)
+	return configs, nil
+}
+
+func makeMethodConfig(acpName string, method hubv1alpha1.AccessControlPolicyMethod, secrets SecretGetter) (*Config, error) {
+	if err := validateMethod(method); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case method.JWT != nil:
+		return makeJWTConfig(method.JWT, secrets)
+
+	case method.BasicAuth != nil:
+		return makeBasicAuthConfig(method.BasicAuth, secrets)
+
+	case method.APIKey != nil:
+		return makeAPIKeyConfig(acpName, method.APIKey, secrets)
+
+	case method.MTLS != nil:
+		return makeMTLSConfig(method.MTLS, secrets)
+
+	case method.IPAllowList != nil:
+		return makeIPAllowListConfig(method.IPAllowList), nil
+
+	case method.ExternalAuthz != nil:
+		return makeExternalAuthzConfig(method.ExternalAuthz), nil
+
+	default:
+		return makeOAuthIntro(method.OAuthIntro, secrets)
+	}
 }
 
 // buildClaims builds the claims from the emails.
@@ -102,55 +333,250 @@ func buildClaims(emails []string) string {
 	return strings.Join(matchers, " || ")
 }
 
-func makeJWTConfig(policy *hubv1alpha1.AccessControlPolicyJWT) *Config {
-	return &Config{
-		JWT: &jwt.Config{
-			SigningSecret:              policy.SigningSecret,
-			SigningSecretBase64Encoded: policy.SigningSecretBase64Encoded,
-			PublicKey:                  policy.PublicKey,
-			JWKsFile:                   jwt.FileOrContent(policy.JWKsFile),
-			JWKsURL:                    policy.JWKsURL,
-			StripAuthorizationHeader:   policy.StripAuthorizationHeader,
-			ForwardHeaders:             policy.ForwardHeaders,
-			TokenQueryKey:              policy.TokenQueryKey,
-			Claims:                     policy.Claims,
-		},
+func makeJWTConfig(policy *hubv1alpha1.AccessControlPolicyJWT, secrets SecretGetter) (*Config, error) {
+	jwtConfig := &jwt.Config{
+		SigningSecret:              policy.SigningSecret,
+		SigningSecretBase64Encoded: policy.SigningSecretBase64Encoded,
+		PublicKey:                  policy.PublicKey,
+		JWKsFile:                   jwt.FileOrContent(policy.JWKsFile),
+		JWKsURL:                    policy.JWKsURL,
+		StripAuthorizationHeader:   policy.StripAuthorizationHeader,
+		ForwardHeaders:             policy.ForwardHeaders,
+		TokenQueryKey:              policy.TokenQueryKey,
+		Claims:                     policy.Claims,
+		GroupsClaim:                policy.GroupsClaim,
+		Issuer:                     policy.Issuer,
+		Audience:                   policy.Audience,
+		Algorithms:                 policy.Algorithms,
+		LeewaySeconds:              policy.LeewaySeconds,
 	}
+
+	if policy.DecryptionKeySecret != nil {
+		jwtConfig.DecryptionKeySecret = &jwt.SecretReference{
+			Name:      policy.DecryptionKeySecret.Name,
+			Namespace: policy.DecryptionKeySecret.Namespace,
+		}
+
+		secret, err := secrets.GetSecret(policy.DecryptionKeySecret)
+		if err != nil {
+			return nil, fmt.Errorf("getting decryption key secret: %w", err)
+		}
+
+		// The secret is nil when secret references are not resolved.
+		if secret != nil {
+			key, hasKey := secret.Data["key"]
+			jwks, hasJWKs := secret.Data["jwks"]
+			if hasKey == hasJWKs {
+				return nil, errors.New(`decryption key secret: exactly one of "key" or "jwks" must be set`)
+			}
+
+			jwtConfig.DecryptionKey = string(key)
+			jwtConfig.DecryptionJWKs = string(jwks)
+		}
+	}
+
+	return &Config{JWT: jwtConfig}, nil
 }
 
-func makeBasicAuthConfig(policy *hubv1alpha1.AccessControlPolicyBasicAuth) *Config {
-	return &Config{
-		BasicAuth: &basicauth.Config{
-			Users:                    policy.Users,
-			Realm:                    policy.Realm,
-			StripAuthorizationHeader: policy.StripAuthorizationHeader,
-			ForwardUsernameHeader:    policy.ForwardUsernameHeader,
-		},
+func makeBasicAuthConfig(policy *hubv1alpha1.AccessControlPolicyBasicAuth, secrets SecretGetter) (*Config, error) {
+	basicAuthConfig := &basicauth.Config{
+		Users:                    policy.Users,
+		Realm:                    policy.Realm,
+		StripAuthorizationHeader: policy.StripAuthorizationHeader,
+		ForwardUsernameHeader:    policy.ForwardUsernameHeader,
+		Lockout:                  makeLockoutConfig(policy.Lockout),
+	}
+
+	if policy.UsersSecret != nil {
+		basicAuthConfig.UsersSecret = &basicauth.SecretReference{
+			Name:      policy.UsersSecret.Name,
+			Namespace: policy.UsersSecret.Namespace,
+		}
+
+		users, err := secrets.GetValue(policy.UsersSecret, "users")
+		if err != nil {
+			return nil, fmt.Errorf("getting users secret: %w", err)
+		}
+
+		basicAuthConfig.SecretUsers = basicauth.ParseHtpasswd(users)
 	}
+
+	return &Config{BasicAuth: basicAuthConfig}, nil
 }
 
-func makeAPIKeyConfig(policy *hubv1alpha1.AccessControlPolicyAPIKey) *Config {
+func makeAPIKeyConfig(acpName string, policy *hubv1alpha1.AccessControlPolicyAPIKey, secrets SecretGetter) (*Config, error) {
 	keys := make([]apikey.Key, 0, len(policy.Keys))
 	for _, k := range policy.Keys {
-		keys = append(keys, apikey.Key{
+		key := apikey.Key{
 			ID:       k.ID,
 			Metadata: k.Metadata,
 			Value:    k.Value,
-		})
+		}
+		if k.NotBefore != nil {
+			key.NotBefore = &k.NotBefore.Time
+		}
+		if k.ExpiresAt != nil {
+			key.ExpiresAt = &k.ExpiresAt.Time
+		}
+
+		keys = append(keys, key)
 	}
 
-	return &Config{
-		APIKey: &apikey.Config{
-			KeySource: token.Source{
-				Header:           policy.KeySource.Header,
-				HeaderAuthScheme: policy.KeySource.HeaderAuthScheme,
-				Query:            policy.KeySource.Query,
-				Cookie:           policy.KeySource.Cookie,
-			},
-			Keys:           keys,
-			ForwardHeaders: policy.ForwardHeaders,
+	apiKeyConfig := &apikey.Config{
+		KeySource: token.Source{
+			Header:           policy.KeySource.Header,
+			HeaderAuthScheme: policy.KeySource.HeaderAuthScheme,
+			Query:            policy.KeySource.Query,
+			Cookie:           policy.KeySource.Cookie,
 		},
+		Keys:           keys,
+		ForwardHeaders: policy.ForwardHeaders,
+		Lockout:        makeLockoutConfig(policy.Lockout),
+	}
+
+	var keySecrets, selectedSecrets []*corev1.Secret
+	for _, ref := range policy.KeySecrets {
+		ref := ref
+		apiKeyConfig.KeySecrets = append(apiKeyConfig.KeySecrets, apikey.SecretReference{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+
+		secret, err := secrets.GetSecret(&ref)
+		if err != nil {
+			return nil, fmt.Errorf("getting key secret: %w", err)
+		}
+
+		// The secret is nil when secret references are not resolved.
+		if secret != nil {
+			keySecrets = append(keySecrets, secret)
+		}
+	}
+
+	if policy.KeySecretSelector != nil {
+		apiKeyConfig.KeySecretSelector = makeAPIKeySecretSelector(policy.KeySecretSelector)
+
+		selector, err := metav1.LabelSelectorAsSelector(&policy.KeySecretSelector.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key secret selector: %w", err)
+		}
+
+		selected, err := secrets.ListSecrets(policy.KeySecretSelector.Namespace, selector)
+		if err != nil {
+			return nil, fmt.Errorf("listing key secrets: %w", err)
+		}
+
+		// Sort selected secrets to get a stable configuration.
+		sort.Slice(selected, func(i, j int) bool {
+			return selected[i].Name < selected[j].Name
+		})
+
+		selectedSecrets = selected
+	}
+
+	for _, secret := range keySecrets {
+		key, err := parseAPIKeySecret(secret)
+		if err != nil {
+			return nil, fmt.Errorf("parsing key secret %q in namespace %q: %w", secret.Name, secret.Namespace, err)
+		}
+
+		apiKeyConfig.SecretKeys = append(apiKeyConfig.SecretKeys, key)
+	}
+
+	for _, secret := range selectedSecrets {
+		key, err := parseAPIKeySecret(secret)
+		if err != nil {
+			// A single invalid secret matched by the selector must not disable all the other keys of the ACP.
+			log.Warn().
+				Err(err).
+				Str("acp_name", acpName).
+				Str("secret_name", secret.Name).
+				Str("secret_namespace", secret.Namespace).
+				Msg("Skipping invalid selected key secret")
+			metrics.InvalidKeySecrets.Inc(acpName)
+			continue
+		}
+
+		apiKeyConfig.SecretKeys = append(apiKeyConfig.SecretKeys, key)
+	}
+
+	return &Config{APIKey: apiKeyConfig}, nil
+}
+
+func makeLockoutConfig(policy *hubv1alpha1.AccessControlPolicyLockout) *lockout.Config {
+	if policy == nil {
+		return nil
+	}
+
+	return &lockout.Config{
+		MaxFailuresPerIP:   policy.MaxFailuresPerIP,
+		MaxFailuresPerUser: policy.MaxFailuresPerUser,
+		WindowSeconds:      policy.WindowSeconds,
+		LockoutSeconds:     policy.LockoutSeconds,
+	}
+}
+
+func makeAPIKeySecretSelector(selector *hubv1alpha1.AccessControlPolicyAPIKeySecretSelector) *apikey.SecretSelector {
+	secretSelector := &apikey.SecretSelector{
+		Namespace:   selector.Namespace,
+		MatchLabels: selector.Selector.MatchLabels,
+	}
+
+	for _, expr := range selector.Selector.MatchExpressions {
+		secretSelector.MatchExpressions = append(secretSelector.MatchExpressions, apikey.SelectorRequirement{
+			Key:      expr.Key,
+			Operator: string(expr.Operator),
+			Values:   expr.Values,
+		})
+	}
+
+	return secretSelector
+}
+
+// parseAPIKeySecret parses a secret holding an API key.
+func parseAPIKeySecret(secret *corev1.Secret) (apikey.Key, error) {
+	key := apikey.Key{
+		ID:    secret.Name,
+		Value: string(secret.Data["value"]),
+	}
+
+	if id := secret.Data["id"]; len(id) > 0 {
+		key.ID = string(id)
+	}
+
+	rawKey, hasKey := secret.Data["key"]
+	switch {
+	case hasKey && key.Value != "":
+		return apikey.Key{}, errors.New(`only one of "key" or "value" must be set`)
+	case hasKey:
+		key.Value = apikey.Hash(string(rawKey))
+	case key.Value == "":
+		return apikey.Key{}, errors.New(`one of "key" or "value" must be set`)
+	}
+
+	if metadata := secret.Data["metadata"]; len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &key.Metadata); err != nil {
+			return apikey.Key{}, fmt.Errorf("parsing metadata: %w", err)
+		}
+	}
+
+	for name, dst := range map[string]**time.Time{
+		"notBefore": &key.NotBefore,
+		"expiresAt": &key.ExpiresAt,
+	} {
+		raw := secret.Data[name]
+		if len(raw) == 0 {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(string(raw)))
+		if err != nil {
+			return apikey.Key{}, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		*dst = &t
 	}
+
+	return key, nil
 }
 
 func makeOIDCConfig(policy *hubv1alpha1.AccessControlPolicyOIDC, secrets SecretGetter) (*Config, error) {
@@ -163,6 +589,14 @@ func makeOIDCConfig(policy *hubv1alpha1.AccessControlPolicyOIDC, secrets SecretG
 		AuthParams:     policy.AuthParams,
 		ForwardHeaders: policy.ForwardHeaders,
 		Claims:         policy.Claims,
+		GroupsClaim:    policy.GroupsClaim,
+
+		ProviderLogout:        policy.ProviderLogout,
+		PostLogoutRedirectURL: policy.PostLogoutRedirectURL,
+		FrontChannelLogoutURL: policy.FrontChannelLogoutURL,
+		ForwardAccessToken:    policy.ForwardAccessToken,
+		AccessTokenHeader:     policy.AccessTokenHeader,
+		FetchUserInfo:         policy.FetchUserInfo,
 	}
 
 	if policy.Secret != nil {
@@ -195,6 +629,23 @@ func makeOIDCConfig(policy *hubv1alpha1.AccessControlPolicyOIDC, secrets SecretG
 			SameSite: policy.Session.SameSite,
 			Secure:   policy.Session.Secure,
 			Refresh:  policy.Session.Refresh,
+			Store:    policy.Session.Store,
+
+			KeyGracePeriodSeconds: policy.Session.KeyGracePeriodSeconds,
+		}
+
+		if policy.Session.KeysSecret != nil {
+			oidcConfig.Session.KeysSecret = &oidc.SecretReference{
+				Name:      policy.Session.KeysSecret.Name,
+				Namespace: policy.Session.KeysSecret.Namespace,
+			}
+
+			sessionKeys, err := getSessionKeys(policy.Session.KeysSecret, secrets)
+			if err != nil {
+				return nil, err
+			}
+
+			oidcConfig.SessionKeys = sessionKeys
 		}
 	}
 
@@ -204,7 +655,7 @@ func makeOIDCConfig(policy *hubv1alpha1.AccessControlPolicyOIDC, secrets SecretG
 		return nil, fmt.Errorf("getting session key: %w", err)
 	}
 
-	oidcConfig.SessionKey = fmt.Sprintf("%x", sha256.Sum256(sessionKey))[:32]
+	oidcConfig.SessionKey = deriveSessionKey(sessionKey)
 	return &Config{OIDC: oidcConfig}, nil
 }
 
@@ -253,6 +704,23 @@ func makeOIDCGoogleConfig(policy *hubv1alpha1.AccessControlPolicyOIDCGoogle, sec
 			SameSite: policy.Session.SameSite,
 			Secure:   policy.Session.Secure,
 			Refresh:  policy.Session.Refresh,
+			Store:    policy.Session.Store,
+
+			KeyGracePeriodSeconds: policy.Session.KeyGracePeriodSeconds,
+		}
+
+		if policy.Session.KeysSecret != nil {
+			oidcGoogleConfig.Session.KeysSecret = &oidc.SecretReference{
+				Name:      policy.Session.KeysSecret.Name,
+				Namespace: policy.Session.KeysSecret.Namespace,
+			}
+
+			sessionKeys, err := getSessionKeys(policy.Session.KeysSecret, secrets)
+			if err != nil {
+				return nil, err
+			}
+
+			oidcGoogleConfig.SessionKeys = sessionKeys
 		}
 	}
 
@@ -262,16 +730,54 @@ func makeOIDCGoogleConfig(policy *hubv1alpha1.AccessControlPolicyOIDCGoogle, sec
 		return nil, fmt.Errorf("getting session key: %w", err)
 	}
 
-	oidcGoogleConfig.SessionKey = fmt.Sprintf("%x", sha256.Sum256(sessionKey))[:32]
+	oidcGoogleConfig.SessionKey = deriveSessionKey(sessionKey)
 	return &Config{OIDCGoogle: oidcGoogleConfig}, nil
 }
 
+// getSessionKeys returns the session keys of the given Secret, indexed by ID.
+func getSessionKeys(ref *corev1.SecretReference, secrets SecretGetter) (map[string]string, error) {
+	secret, err := secrets.GetSecret(ref)
+	if err != nil {
+		return nil, fmt.Errorf("getting session keys secret: %w", err)
+	}
+
+	// The secret is nil when secret references are not resolved.
+	if secret == nil {
+		return nil, nil
+	}
+
+	if len(secret.Data) == 0 {
+		return nil, errors.New("session keys secret: no key found")
+	}
+
+	keys := make(map[string]string, len(secret.Data))
+	for id, key := range secret.Data {
+		keys[id] = deriveSessionKey(key)
+	}
+
+	return keys, nil
+}
+
+// deriveSessionKey derives a 32 characters session key from the given secret.
+func deriveSessionKey(secret []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(secret))[:32]
+}
+
 func makeOAuthIntro(policy *hubv1alpha1.AccessControlOAuthIntro, secrets SecretGetter) (*Config, error) {
 	oauthIntroConfig := &oauthintro.Config{
 		Claims:         policy.Claims,
+		GroupsClaim:    policy.GroupsClaim,
 		ForwardHeaders: policy.ForwardHeaders,
 	}
 
+	if policy.Cache != nil {
+		oauthIntroConfig.Cache = &oauthintro.CacheConfig{
+			MaxSize:            optional.NewInt(policy.Cache.MaxSize),
+			MaxTTLSeconds:      optional.NewInt(policy.Cache.MaxTTLSeconds),
+			NegativeTTLSeconds: optional.NewInt(policy.Cache.NegativeTTLSeconds),
+		}
+	}
+
 	oauthIntroConfig.ClientConfig = oauthintro.ClientConfig{
 		Config: httpclient.Config{
 			TimeoutSeconds: optional.NewInt(policy.ClientConfig.TimeoutSeconds),
@@ -317,6 +823,63 @@ func makeOAuthIntro(policy *hubv1alpha1.AccessControlOAuthIntro, secrets SecretG
 	return &Config{OAuthIntro: oauthIntroConfig}, nil
 }
 
+func makeMTLSConfig(policy *hubv1alpha1.AccessControlPolicyMTLS, secrets SecretGetter) (*Config, error) {
+	caBundle, err := secrets.GetValue(&policy.CASecret, "ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("getting CA bundle: %w", err)
+	}
+
+	return &Config{
+		MTLS: &mtls.Config{
+			CASecret: mtls.SecretReference{
+				Name:      policy.CASecret.Name,
+				Namespace: policy.CASecret.Namespace,
+			},
+			CABundle:       string(caBundle),
+			Claims:         policy.Claims,
+			ForwardHeaders: policy.ForwardHeaders,
+		},
+	}, nil
+}
+
+func makeIPAllowListConfig(policy *hubv1alpha1.AccessControlPolicyIPAllowList) *Config {
+	return &Config{
+		IPAllowList: &ipallowlist.Config{
+			SourceRanges:      policy.SourceRanges,
+			DeniedRanges:      policy.DeniedRanges,
+			TrustedProxyDepth: policy.TrustedProxyDepth,
+		},
+	}
+}
+
+func makeExternalAuthzConfig(policy *hubv1alpha1.AccessControlPolicyExternalAuthz) *Config {
+	externalAuthzConfig := &externalauthz.Config{
+		Config: httpclient.Config{
+			TimeoutSeconds: optional.NewInt(policy.TimeoutSeconds),
+			MaxRetries:     optional.NewInt(policy.MaxRetries),
+		},
+		URL:            policy.URL,
+		RequestHeaders: policy.RequestHeaders,
+		ForwardHeaders: policy.ForwardHeaders,
+	}
+
+	if policy.TLS != nil {
+		externalAuthzConfig.TLS = &httpclient.ConfigTLS{
+			CABundle:           policy.TLS.CABundle,
+			InsecureSkipVerify: policy.TLS.InsecureSkipVerify,
+		}
+	}
+
+	if policy.Cache != nil {
+		externalAuthzConfig.Cache = &externalauthz.CacheConfig{
+			MaxSize:    optional.NewInt(policy.Cache.MaxSize),
+			TTLSeconds: optional.NewInt(policy.Cache.TTLSeconds),
+		}
+	}
+
+	return &Config{ExternalAuthz: externalAuthzConfig}
+}
+
 func parseOAuthIntroSecret(secrets SecretGetter, secret corev1.SecretReference, kind string) (key, value string, err error) {
 	switch kind {
 	case "Bearer":
@@ -19,8 +19,24 @@ package acp
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/issuer"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oidc"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/upstreamtoken"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestBuildClaims(t *testing.T) {
@@ -53,3 +69,374 @@ func TestBuildClaims(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAPIKeySecret(t *testing.T) {
+	expiresAt := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc    string
+		data    map[string]string
+		want    apikey.Key
+		wantErr bool
+	}{
+		{
+			desc: "raw key",
+			data: map[string]string{"key": "key"},
+			want: apikey.Key{
+				ID:    "my-secret",
+				Value: "17fa993d5eecbd361f30baf0b9b2329ad053bb6d5fec2228eca55e9b4914fface3af69bcc9a6b5f7ff093aa9a0d00811d0b2a3ee67eac60c57e79d2fd99bbde0",
+			},
+		},
+		{
+			desc: "hashed key with all fields",
+			data: map[string]string{
+				"id":        "user-1",
+				"value":     "17fa993d5eecbd361f30baf0b9b2329ad053bb6d5fec2228eca55e9b4914fface3af69bcc9a6b5f7ff093aa9a0d00811d0b2a3ee67eac60c57e79d2fd99bbde0",
+				"metadata":  `{"group":"dev"}`,
+				"expiresAt": "2030-01-01T00:00:00Z",
+			},
+			want: apikey.Key{
+				ID:        "user-1",
+				Value:     "17fa993d5eecbd361f30baf0b9b2329ad053bb6d5fec2228eca55e9b4914fface3af69bcc9a6b5f7ff093aa9a0d00811d0b2a3ee67eac60c57e79d2fd99bbde0",
+				Metadata:  map[string]string{"group": "dev"},
+				ExpiresAt: &expiresAt,
+			},
+		},
+		{
+			desc:    "missing key",
+			data:    map[string]string{"id": "user-1"},
+			wantErr: true,
+		},
+		{
+			desc:    "both raw and hashed key",
+			data:    map[string]string{"key": "key", "value": "value"},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid metadata",
+			data:    map[string]string{"key": "key", "metadata": "invalid"},
+			wantErr: true,
+		},
+		{
+			desc:    "invalid timestamp",
+			data:    map[string]string{"key": "key", "notBefore": "yesterday"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-secret", Namespace: "ns"},
+				Data:       make(map[string][]byte),
+			}
+			for k, v := range test.data {
+				secret.Data[k] = []byte(v)
+			}
+
+			got, err := parseAPIKeySecret(secret)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestConfigFromPolicyWithSecret_APIKeySecrets(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, secret := range []*corev1.Secret{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "referenced", Namespace: "ns"},
+			Data:       map[string][]byte{"value": []byte("referenced-hash")},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ns"},
+			Data:       map[string][]byte{},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "selected-invalid", Namespace: "ns", Labels: map[string]string{"app": "my-app"}},
+			Data:       map[string][]byte{"expiresAt": []byte("tomorrow"), "value": []byte("invalid-hash")},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "selected-valid", Namespace: "ns", Labels: map[string]string{"app": "my-app"}},
+			Data:       map[string][]byte{"value": []byte("selected-hash")},
+		},
+	} {
+		require.NoError(t, indexer.Add(secret))
+	}
+	secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubefake.NewSimpleClientset().CoreV1())
+
+	selector := &hubv1alpha1.AccessControlPolicyAPIKeySecretSelector{
+		Namespace: "ns",
+		Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "my-app"}},
+	}
+
+	// Invalid secrets matched by the selector are skipped.
+	got, err := ConfigFromPolicyWithSecret(&hubv1alpha1.AccessControlPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-api-key"},
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{
+				KeySource:         hubv1alpha1.TokenSource{Header: "Api-Key"},
+				KeySecrets:        []corev1.SecretReference{{Name: "referenced", Namespace: "ns"}},
+				KeySecretSelector: selector,
+			},
+		},
+	}, secrets)
+	require.NoError(t, err)
+	assert.Equal(t, []apikey.Key{
+		{ID: "referenced", Value: "referenced-hash"},
+		{ID: "selected-valid", Value: "selected-hash"},
+	}, got.APIKey.SecretKeys)
+
+	// Invalid referenced secrets still fail the ACP.
+	_, err = ConfigFromPolicyWithSecret(&hubv1alpha1.AccessControlPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-api-key"},
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{
+				KeySource:         hubv1alpha1.TokenSource{Header: "Api-Key"},
+				KeySecrets:        []corev1.SecretReference{{Name: "invalid", Namespace: "ns"}},
+				KeySecretSelector: selector,
+			},
+		},
+	}, secrets)
+	assert.EqualError(t, err, `parsing key secret "invalid" in namespace "ns": one of "key" or "value" must be set`)
+}
+
+func TestConfigFromPolicyWithSecret_JWTDecryptionKey(t *testing.T) {
+	tests := []struct {
+		desc    string
+		data    map[string]string
+		want    *jwt.Config
+		wantErr string
+	}{
+		{
+			desc: "PEM encoded private key",
+			data: map[string]string{"key": "private-key"},
+			want: &jwt.Config{
+				SigningSecret:       "secret",
+				DecryptionKeySecret: &jwt.SecretReference{Name: "my-key", Namespace: "default"},
+				DecryptionKey:       "private-key",
+			},
+		},
+		{
+			desc: "JWK set",
+			data: map[string]string{"jwks": `{"keys":[]}`},
+			want: &jwt.Config{
+				SigningSecret:       "secret",
+				DecryptionKeySecret: &jwt.SecretReference{Name: "my-key", Namespace: "default"},
+				DecryptionJWKs:      `{"keys":[]}`,
+			},
+		},
+		{
+			desc:    "both private key and JWK set",
+			data:    map[string]string{"key": "private-key", "jwks": `{"keys":[]}`},
+			wantErr: `decryption key secret: exactly one of "key" or "jwks" must be set`,
+		},
+		{
+			desc:    "no key",
+			data:    map[string]string{},
+			wantErr: `decryption key secret: exactly one of "key" or "jwks" must be set`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-key", Namespace: "default"},
+				Data:       make(map[string][]byte),
+			}
+			for k, v := range test.data {
+				secret.Data[k] = []byte(v)
+			}
+
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			require.NoError(t, indexer.Add(secret))
+			secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubefake.NewSimpleClientset().CoreV1())
+
+			policy := &hubv1alpha1.AccessControlPolicy{
+				Spec: hubv1alpha1.AccessControlPolicySpec{
+					JWT: &hubv1alpha1.AccessControlPolicyJWT{
+						SigningSecret:       "secret",
+						DecryptionKeySecret: &corev1.SecretReference{Name: "my-key", Namespace: "default"},
+					},
+				},
+			}
+
+			got, err := ConfigFromPolicyWithSecret(policy, secrets)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got.JWT)
+		})
+	}
+}
+
+func TestConfigFromPolicyWithSecret_OIDCSessionKeys(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hub-secret", Namespace: currentNamespace()},
+		Data:       map[string][]byte{"key": []byte("agent-key")},
+	}))
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-oidc", Namespace: "default"},
+		Data:       map[string][]byte{"clientSecret": []byte("client-secret")},
+	}))
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-session-keys", Namespace: "default"},
+		Data: map[string][]byte{
+			"2023-01": []byte("previous-key"),
+			"2023-02": []byte("current-key"),
+		},
+	}))
+	secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubefake.NewSimpleClientset().CoreV1())
+
+	policy := &hubv1alpha1.AccessControlPolicy{
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			OIDC: &hubv1alpha1.AccessControlPolicyOIDC{
+				Issuer:   "https://idp.example.com",
+				ClientID: "client-id",
+				Secret:   &corev1.SecretReference{Name: "my-oidc", Namespace: "default"},
+				Session: &hubv1alpha1.Session{
+					KeysSecret:            &corev1.SecretReference{Name: "my-session-keys", Namespace: "default"},
+					KeyGracePeriodSeconds: 3600,
+				},
+			},
+		},
+	}
+
+	got, err := ConfigFromPolicyWithSecret(policy, secrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, deriveSessionKey([]byte("agent-key")), got.OIDC.SessionKey)
+	assert.Equal(t, map[string]string{
+		"2023-01": deriveSessionKey([]byte("previous-key")),
+		"2023-02": deriveSessionKey([]byte("current-key")),
+	}, got.OIDC.SessionKeys)
+	assert.Equal(t, &oidc.SecretReference{Name: "my-session-keys", Namespace: "default"}, got.OIDC.Session.KeysSecret)
+	assert.Equal(t, 3600, got.OIDC.Session.KeyGracePeriodSeconds)
+}
+
+func TestConfigFromPolicyWithSecret_IssueToken(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-signing-key", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("private-key")},
+	}))
+	secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubefake.NewSimpleClientset().CoreV1())
+
+	policy := &hubv1alpha1.AccessControlPolicy{
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			JWT: &hubv1alpha1.AccessControlPolicyJWT{SigningSecret: "secret"},
+			IssueToken: &hubv1alpha1.AccessControlPolicyIssueToken{
+				KeySecret: corev1.SecretReference{Name: "my-signing-key", Namespace: "default"},
+				Audience:  []string{"internal"},
+				Claims:    []string{"email"},
+			},
+		},
+	}
+
+	got, err := ConfigFromPolicyWithSecret(policy, secrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, &issuer.Config{
+		KeySecret:  issuer.SecretReference{Name: "my-signing-key", Namespace: "default"},
+		Header:     issuer.DefaultHeader,
+		Issuer:     issuer.DefaultIssuer,
+		Audience:   []string{"internal"},
+		TTLSeconds: issuer.DefaultTTLSeconds,
+		Claims:     []string{"email"},
+		SigningKey: "private-key",
+	}, got.IssueToken)
+}
+
+func TestConfigFromPolicyWithSecret_UpstreamToken(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-client", Namespace: "default"},
+		Data: map[string][]byte{
+			"clientId":     []byte("client-id"),
+			"clientSecret": []byte("client-secret"),
+		},
+	}))
+	secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubefake.NewSimpleClientset().CoreV1())
+
+	policy := &hubv1alpha1.AccessControlPolicy{
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			JWT: &hubv1alpha1.AccessControlPolicyJWT{SigningSecret: "secret"},
+			UpstreamToken: &hubv1alpha1.AccessControlPolicyUpstreamToken{
+				HTTPClientConfig: hubv1alpha1.HTTPClientConfig{
+					TLS:            &hubv1alpha1.HTTPClientConfigTLS{CABundle: "<bundle>"},
+					TimeoutSeconds: 5,
+					MaxRetries:     3,
+				},
+				TokenURL:       "https://idp.example.com/token",
+				Secret:         corev1.SecretReference{Name: "my-client", Namespace: "default"},
+				Scopes:         []string{"read"},
+				EndpointParams: map[string]string{"audience": "legacy"},
+			},
+		},
+	}
+
+	got, err := ConfigFromPolicyWithSecret(policy, secrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, &upstreamtoken.Config{
+		Config: httpclient.Config{
+			TLS:            &httpclient.ConfigTLS{CABundle: "<bundle>"},
+			TimeoutSeconds: optional.NewInt(5),
+			MaxRetries:     optional.NewInt(3),
+		},
+		TokenURL:       "https://idp.example.com/token",
+		Secret:         upstreamtoken.SecretReference{Name: "my-client", Namespace: "default"},
+		Scopes:         []string{"read"},
+		EndpointParams: map[string]string{"audience": "legacy"},
+		ClientID:       "client-id",
+		ClientSecret:   "client-secret",
+	}, got.UpstreamToken)
+}
+
+func TestConfigFromPolicyWithSecret_DenialResponse(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	kubeClientSet := kubefake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-templates", Namespace: "default"},
+		Data:       map[string]string{"template.html": "<h1>{{ .Title }}</h1>"},
+	})
+	secrets := NewKubeSecretValueGetter(corev1lister.NewSecretLister(indexer), kubeClientSet.CoreV1())
+
+	policy := &hubv1alpha1.AccessControlPolicy{
+		Spec: hubv1alpha1.AccessControlPolicySpec{
+			JWT: &hubv1alpha1.AccessControlPolicyJWT{SigningSecret: "secret"},
+			DenialResponse: &hubv1alpha1.AccessControlPolicyDenialResponse{
+				ProblemDetails: true,
+				HTMLTemplate:   &hubv1alpha1.ConfigMapKeyReference{Name: "my-templates", Namespace: "default"},
+			},
+		},
+	}
+
+	got, err := ConfigFromPolicyWithSecret(policy, secrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, &denial.Config{
+		ProblemDetails: true,
+		HTMLTemplate:   &denial.ConfigMapKeyReference{Name: "my-templates", Namespace: "default", Key: "template.html"},
+		Template:       "<h1>{{ .Title }}</h1>",
+	}, got.DenialResponse)
+
+	policy.Spec.DenialResponse.HTMLTemplate.Key = "missing.html"
+
+	_, err = ConfigFromPolicyWithSecret(policy, secrets)
+	assert.EqualError(t, err, `getting denial HTML template: no key "missing.html" in ConfigMap "my-templates" in namespace "default"`)
+}
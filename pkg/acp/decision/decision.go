@@ -0,0 +1,338 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
+)
+
+// Decision is the outcome of the evaluation of a request by an ACP.
+type Decision string
+
+// Supported decisions.
+const (
+	Allow Decision = "allow"
+	Deny  Decision = "deny"
+)
+
+// Reason explains why an ACP denied a request.
+type Reason string
+
+// Supported reasons.
+const (
+	// ReasonMissingCredentials is used when the request does not hold any credentials.
+	ReasonMissingCredentials Reason = "missing_credentials"
+	// ReasonInvalidCredentials is used when the credentials of the request are unknown, malformed or cannot be verified.
+	ReasonInvalidCredentials Reason = "invalid_credentials"
+	// ReasonExpiredCredentials is used when the credentials of the request are not valid at this time.
+	ReasonExpiredCredentials Reason = "expired_credentials"
+	// ReasonClaimsMismatch is used when the claims of the request do not match the claims expression of the ACP.
+	ReasonClaimsMismatch Reason = "claims_mismatch"
+	// ReasonGroupsMismatch is used when the subject of the request is not in the required groups.
+	ReasonGroupsMismatch Reason = "groups_mismatch"
+	// ReasonAuthenticationRequired is used when the subject of the request must authenticate, for instance by being
+	// redirected to an identity provider.
+	ReasonAuthenticationRequired Reason = "authentication_required"
+	// ReasonIPNotAllowed is used when the client IP of the request is not allowed by the ACP.
+	ReasonIPNotAllowed Reason = "ip_not_allowed"
+	// ReasonExternallyDenied is used when an external authorization service denied the request.
+	ReasonExternallyDenied Reason = "externally_denied"
+	// ReasonLockedOut is used when the client or the user of the request is locked out after too many failed attempts.
+	ReasonLockedOut Reason = "locked_out"
+	// ReasonInternalError is used when the request could not be evaluated.
+	ReasonInternalError Reason = "internal_error"
+)
+
+// Entry is a decision log entry.
+type Entry struct {
+	Time            time.Time `json:"time"`
+	Policy          string    `json:"policy"`
+	HandlerType     string    `json:"handlerType"`
+	EnforcementMode string    `json:"enforcementMode"`
+	Decision        Decision  `json:"decision"`
+	Reason          Reason    `json:"reason,omitempty"`
+	Subject         string    `json:"subject,omitempty"`
+	Status          int       `json:"status"`
+	LatencyMS       float64   `json:"latencyMs"`
+	Request         Request   `json:"request"`
+}
+
+// Request holds the metadata of the request a decision has been made for.
+type Request struct {
+	Method    string `json:"method,omitempty"`
+	Host      string `json:"host,omitempty"`
+	URI       string `json:"uri,omitempty"`
+	SourceIP  string `json:"sourceIp,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+}
+
+// Sink receives decision log entries.
+type Sink interface {
+	Write(entry Entry) error
+}
+
+type recordKey struct{}
+
+// record holds the details ACP handlers report about the decision they made.
+type record struct {
+	reason  Reason
+	subject string
+	groups  []string
+	claims  map[string]interface{}
+}
+
+// Identity is the identity ACP handlers report for a request they granted.
+type Identity struct {
+	Subject string
+	Groups  []string
+	Claims  map[string]interface{}
+}
+
+// Track returns the given request with a record of the details ACP handlers report about it. Requests already tracked
+// are returned as is.
+func Track(req *http.Request) *http.Request {
+	if _, ok := req.Context().Value(recordKey{}).(*record); ok {
+		return req
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), recordKey{}, &record{}))
+}
+
+// Fork returns a copy of the given request tracked with its own record, starting with the identity reported so far.
+// It lets an ACP handler evaluate a request with other handlers, like the methods of a composite ACP, and only keep
+// what the handlers it relies on reported, using Merge.
+func Fork(req *http.Request) *http.Request {
+	rec := &record{}
+	if parent, ok := req.Context().Value(recordKey{}).(*record); ok {
+		rec.subject = parent.subject
+		rec.groups = parent.groups
+		if parent.claims != nil {
+			rec.claims = make(map[string]interface{}, len(parent.claims))
+			for name, value := range parent.claims {
+				rec.claims[name] = value
+			}
+		}
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), recordKey{}, rec))
+}
+
+// Merge reports on the given request what has been reported on the given fork of it.
+// It does nothing if the request is not tracked.
+func Merge(req, fork *http.Request) {
+	rec, ok := req.Context().Value(recordKey{}).(*record)
+	if !ok {
+		return
+	}
+
+	if forked, ok := fork.Context().Value(recordKey{}).(*record); ok {
+		*rec = *forked
+	}
+}
+
+// IdentityOf returns the identity reported for the given request. It is empty if the request is not tracked.
+func IdentityOf(req *http.Request) Identity {
+	rec, ok := req.Context().Value(recordKey{}).(*record)
+	if !ok {
+		return Identity{}
+	}
+
+	return Identity{
+		Subject: rec.subject,
+		Groups:  rec.groups,
+		Claims:  rec.claims,
+	}
+}
+
+// ReasonOf returns the reason reported for denying the given request. It is empty if the request is not tracked.
+func ReasonOf(req *http.Request) Reason {
+	if rec, ok := req.Context().Value(recordKey{}).(*record); ok {
+		return rec.reason
+	}
+
+	return ""
+}
+
+// SetReason reports why the ACP handler serving the given request denied it.
+// It does nothing if the request is not tracked.
+func SetReason(req *http.Request, reason Reason) {
+	if rec, ok := req.Context().Value(recordKey{}).(*record); ok {
+		rec.reason = reason
+	}
+}
+
+// SetSubject reports the subject, like a user name or an API key ID, of the given request. ACP handlers must only
+// report the identity of requests they granted, as it is trusted by other handlers.
+// It does nothing if the request is not tracked.
+func SetSubject(req *http.Request, subject string) {
+	if rec, ok := req.Context().Value(recordKey{}).(*record); ok {
+		rec.subject = subject
+	}
+}
+
+// SetGroups reports the groups of the subject of the given request.
+// It does nothing if the request is not tracked.
+func SetGroups(req *http.Request, groups []string) {
+	if rec, ok := req.Context().Value(recordKey{}).(*record); ok {
+		rec.groups = groups
+	}
+}
+
+// SetClaims reports the verified claims, like the claims of a JWT, of the given request. Claims reported by several
+// ACP handlers, like the methods of an allOf ACP, are merged.
+// It does nothing if the request is not tracked.
+func SetClaims(req *http.Request, claims map[string]interface{}) {
+	rec, ok := req.Context().Value(recordKey{}).(*record)
+	if !ok {
+		return
+	}
+
+	if rec.claims == nil {
+		rec.claims = make(map[string]interface{}, len(claims))
+	}
+	for name, value := range claims {
+		rec.claims[name] = value
+	}
+}
+
+// Handler logs the decisions made by an ACP handler.
+type Handler struct {
+	next            http.Handler
+	sink            Sink
+	name            string
+	handlerType     string
+	enforcementMode string
+
+	now func() time.Time
+}
+
+// NewHandler creates a new Handler logging the decisions made by the given ACP handler in the given sink.
+func NewHandler(next http.Handler, sink Sink, name, handlerType, enforcementMode string) *Handler {
+	return &Handler{
+		next:            next,
+		sink:            sink,
+		name:            name,
+		handlerType:     handlerType,
+		enforcementMode: enforcementMode,
+		now:             time.Now,
+	}
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	start := h.now()
+
+	req = Track(req)
+	rec := req.Context().Value(recordKey{}).(*record)
+
+	srw := &statusResponseWriter{ResponseWriter: rw}
+	h.next.ServeHTTP(srw, req)
+
+	// Like net/http, consider a handler that did not write anything granted the request.
+	if srw.code == 0 {
+		srw.code = http.StatusOK
+	}
+
+	entry := Entry{
+		Time:            start,
+		Policy:          h.name,
+		HandlerType:     h.handlerType,
+		EnforcementMode: h.enforcementMode,
+		Decision:        Allow,
+		Subject:         rec.subject,
+		Status:          srw.code,
+		LatencyMS:       float64(h.now().Sub(start)) / float64(time.Millisecond),
+		Request:         newRequest(req),
+	}
+
+	if srw.code < 200 || srw.code >= 300 {
+		entry.Decision = Deny
+		entry.Reason = rec.reason
+		if entry.Reason == "" {
+			entry.Reason = defaultReason(srw.code)
+		}
+	}
+
+	if err := h.sink.Write(entry); err != nil {
+		log.Error().Err(err).Str("acp_name", h.name).Msg("Unable to write decision log entry")
+	}
+}
+
+func newRequest(req *http.Request) Request {
+	r := Request{
+		Method:    expr.OriginalMethod(req),
+		Host:      req.Header.Get("X-Forwarded-Host"),
+		URI:       token.OriginalURI(req.Header),
+		UserAgent: req.Header.Get("User-Agent"),
+	}
+
+	if r.Host == "" {
+		r.Host = req.Host
+	}
+
+	if ip := expr.SourceIP(req); ip != nil {
+		r.SourceIP = ip.String()
+	}
+
+	return r
+}
+
+// defaultReason returns the reason of a denial when the ACP handler did not report any.
+func defaultReason(code int) Reason {
+	switch {
+	case code == http.StatusUnauthorized:
+		return ReasonInvalidCredentials
+	case code >= 300 && code < 400:
+		return ReasonAuthenticationRequired
+	case code >= 500:
+		return ReasonInternalError
+	default:
+		return ""
+	}
+}
+
+// statusResponseWriter records the status code written by an ACP handler.
+type statusResponseWriter struct {
+	http.ResponseWriter
+
+	code int
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,246 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		next       http.HandlerFunc
+		wantStatus int
+		want       Entry
+	}{
+		{
+			desc: "granted request",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				SetSubject(req, "bruce")
+				rw.Header().Set("User", "bruce")
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			want: Entry{
+				Decision: Allow,
+				Subject:  "bruce",
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			desc:       "handler writing nothing grants the request",
+			next:       func(http.ResponseWriter, *http.Request) {},
+			wantStatus: http.StatusOK,
+			want: Entry{
+				Decision: Allow,
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			desc: "granted request ignores the reason of a previous denial",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				SetReason(req, ReasonMissingCredentials)
+				SetSubject(req, "key-1")
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			want: Entry{
+				Decision: Allow,
+				Subject:  "key-1",
+				Status:   http.StatusOK,
+			},
+		},
+		{
+			desc: "denied request with a reason",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				SetSubject(req, "bruce")
+				SetReason(req, ReasonClaimsMismatch)
+				rw.WriteHeader(http.StatusForbidden)
+			},
+			wantStatus: http.StatusForbidden,
+			want: Entry{
+				Decision: Deny,
+				Reason:   ReasonClaimsMismatch,
+				Subject:  "bruce",
+				Status:   http.StatusForbidden,
+			},
+		},
+		{
+			desc: "denied request without reason",
+			next: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusUnauthorized)
+			},
+			wantStatus: http.StatusUnauthorized,
+			want: Entry{
+				Decision: Deny,
+				Reason:   ReasonInvalidCredentials,
+				Status:   http.StatusUnauthorized,
+			},
+		},
+		{
+			desc: "redirected request",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				http.Redirect(rw, req, "https://idp.example.com", http.StatusFound)
+			},
+			wantStatus: http.StatusFound,
+			want: Entry{
+				Decision: Deny,
+				Reason:   ReasonAuthenticationRequired,
+				Status:   http.StatusFound,
+			},
+		},
+		{
+			desc: "failing request",
+			next: func(rw http.ResponseWriter, _ *http.Request) {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			},
+			wantStatus: http.StatusInternalServerError,
+			want: Entry{
+				Decision: Deny,
+				Reason:   ReasonInternalError,
+				Status:   http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &sliceSink{}
+			h := NewHandler(test.next, sink, "my-acp", "JWT", "audit")
+
+			start := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+			calls := 0
+			h.now = func() time.Time {
+				calls++
+				return start.Add(time.Duration(calls-1) * 1500 * time.Microsecond)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://auth-server/my-acp", nil)
+			req.Header.Set("X-Forwarded-Method", http.MethodPost)
+			req.Header.Set("X-Forwarded-Host", "api.example.com")
+			req.Header.Set("X-Forwarded-Uri", "/users?page=2")
+			req.Header.Set("X-Real-Ip", "10.0.0.1")
+			req.Header.Set("User-Agent", "curl/7.88.1")
+
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantStatus, rw.Code)
+
+			want := test.want
+			want.Time = start
+			want.Policy = "my-acp"
+			want.HandlerType = "JWT"
+			want.EnforcementMode = "audit"
+			want.LatencyMS = 1.5
+			want.Request = Request{
+				Method:    http.MethodPost,
+				Host:      "api.example.com",
+				URI:       "/users?page=2",
+				SourceIP:  "10.0.0.1",
+				UserAgent: "curl/7.88.1",
+			}
+
+			require.Len(t, sink.entries, 1)
+			assert.Equal(t, want, sink.entries[0])
+		})
+	}
+}
+
+func TestSetReason_notLogged(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.NotPanics(t, func() {
+		SetReason(req, ReasonInvalidCredentials)
+		SetSubject(req, "bruce")
+	})
+}
+
+func TestIdentityOf(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, Identity{}, IdentityOf(req))
+
+	req = Track(req)
+	assert.Same(t, req, Track(req))
+
+	SetSubject(req, "bruce")
+	SetGroups(req, []string{"justice-league"})
+	SetClaims(req, map[string]interface{}{"city": "Gotham", "alias": "Batman"})
+	SetClaims(req, map[string]interface{}{"alias": "Dark Knight"})
+
+	assert.Equal(t, Identity{
+		Subject: "bruce",
+		Groups:  []string{"justice-league"},
+		Claims:  map[string]interface{}{"city": "Gotham", "alias": "Dark Knight"},
+	}, IdentityOf(req))
+}
+
+func TestFork(t *testing.T) {
+	req := Track(httptest.NewRequest(http.MethodGet, "/", nil))
+	SetSubject(req, "bruce")
+	SetClaims(req, map[string]interface{}{"city": "Gotham"})
+
+	fork := Fork(req)
+	assert.Equal(t, IdentityOf(req), IdentityOf(fork))
+
+	SetSubject(fork, "joker")
+	SetClaims(fork, map[string]interface{}{"city": "Arkham"})
+	SetReason(fork, ReasonInvalidCredentials)
+
+	assert.Equal(t, Identity{Subject: "bruce", Claims: map[string]interface{}{"city": "Gotham"}}, IdentityOf(req))
+	assert.Empty(t, ReasonOf(req))
+
+	Merge(req, fork)
+
+	assert.Equal(t, Identity{Subject: "joker", Claims: map[string]interface{}{"city": "Arkham"}}, IdentityOf(req))
+	assert.Equal(t, ReasonInvalidCredentials, ReasonOf(req))
+}
+
+func TestReasonOf(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	SetReason(req, ReasonInvalidCredentials)
+	assert.Empty(t, ReasonOf(req))
+
+	req = Track(req)
+	SetReason(req, ReasonInvalidCredentials)
+	assert.Equal(t, ReasonInvalidCredentials, ReasonOf(req))
+}
+
+type sliceSink struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (s *sliceSink) Write(entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, entry)
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// Decorate serves the given request with the given ACP handler, buffering its response so headers can be added once
+// the decision is known. When access is granted, decorate is called with the response header, typically to forward
+// a token to upstream services. If it fails, access is denied with an internal server error and its error is returned.
+func Decorate(rw http.ResponseWriter, req *http.Request, next http.Handler, decorate func(http.Header) error) error {
+	resp := &bufferedResponse{header: rw.Header()}
+	next.ServeHTTP(resp, req)
+
+	// Like net/http, consider a handler that did not write anything granted the request.
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+
+	if resp.code >= 200 && resp.code < 300 {
+		if err := decorate(rw.Header()); err != nil {
+			SetReason(req, ReasonInternalError)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return err
+		}
+	}
+
+	rw.WriteHeader(resp.code)
+	_, _ = rw.Write(resp.body.Bytes())
+
+	return nil
+}
+
+// bufferedResponse buffers the response of an ACP handler.
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+// Write implements http.ResponseWriter.
+func (r *bufferedResponse) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *bufferedResponse) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecorate(t *testing.T) {
+	tests := []struct {
+		desc       string
+		next       http.HandlerFunc
+		decorate   func(header http.Header) error
+		wantErr    bool
+		wantStatus int
+		wantHeader string
+		wantBody   string
+		wantReason Reason
+	}{
+		{
+			desc: "granted request is decorated",
+			next: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			},
+			decorate: func(header http.Header) error {
+				header.Set("X-Token", "token")
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: "token",
+		},
+		{
+			desc: "handler writing nothing grants the request",
+			next: func(http.ResponseWriter, *http.Request) {},
+			decorate: func(header http.Header) error {
+				header.Set("X-Token", "token")
+				return nil
+			},
+			wantStatus: http.StatusOK,
+			wantHeader: "token",
+		},
+		{
+			desc: "denied request is not decorated",
+			next: func(rw http.ResponseWriter, _ *http.Request) {
+				http.Error(rw, "denied", http.StatusForbidden)
+			},
+			decorate: func(header http.Header) error {
+				header.Set("X-Token", "token")
+				return nil
+			},
+			wantStatus: http.StatusForbidden,
+			wantBody:   "denied\n",
+		},
+		{
+			desc: "decoration failure denies access",
+			next: func(rw http.ResponseWriter, _ *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			},
+			decorate: func(http.Header) error {
+				return errors.New("boom")
+			},
+			wantErr:    true,
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Internal Server Error\n",
+			wantReason: ReasonInternalError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := Track(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			rec := httptest.NewRecorder()
+
+			err := Decorate(rec, req, test.next, test.decorate)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Equal(t, test.wantHeader, rec.Header().Get("X-Token"))
+			assert.Equal(t, test.wantBody, rec.Body.String())
+			assert.Equal(t, test.wantReason, ReasonOf(req))
+		})
+	}
+}
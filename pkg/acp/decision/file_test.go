@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	entry := Entry{Policy: "my-acp", Decision: Allow, Status: 200}
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+	entrySize := int64(len(b) + 1)
+
+	// Each file holds two entries.
+	sink, err := NewFileSink(path, 2*entrySize, 2)
+	require.NoError(t, err)
+
+	for i := 0; i < 7; i++ {
+		entry.Status = 200 + i
+		require.NoError(t, sink.Write(entry))
+	}
+	require.NoError(t, sink.Close())
+
+	assert.Equal(t, []int{206}, readStatuses(t, path))
+	assert.Equal(t, []int{204, 205}, readStatuses(t, path+".1"))
+	assert.Equal(t, []int{202, 203}, readStatuses(t, path+".2"))
+	assert.NoFileExists(t, path+".3")
+
+	err = sink.Write(entry)
+	assert.EqualError(t, err, "file sink closed")
+}
+
+func TestFileSink_WriteAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	sink, err := NewFileSink(path, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(Entry{Status: 200}))
+	require.NoError(t, sink.Close())
+
+	sink, err = NewFileSink(path, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(Entry{Status: 401}))
+	require.NoError(t, sink.Close())
+
+	assert.Equal(t, []int{200, 401}, readStatuses(t, path))
+}
+
+func TestFileSink_WriteWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.log")
+
+	sink, err := NewFileSink(path, 1, 0)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(Entry{Status: 200}))
+	require.NoError(t, sink.Write(Entry{Status: 401}))
+	require.NoError(t, sink.Close())
+
+	assert.Equal(t, []int{401}, readStatuses(t, path))
+	assert.NoFileExists(t, path+".1")
+}
+
+func readStatuses(t *testing.T, path string) []int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	var statuses []int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+
+		statuses = append(statuses, entry.Status)
+	}
+	require.NoError(t, scanner.Err())
+
+	return statuses
+}
@@ -0,0 +1,62 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterSink_Write(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	err := sink.Write(Entry{Policy: "my-acp", Decision: Allow, Status: 200})
+	require.NoError(t, err)
+	err = sink.Write(Entry{Policy: "my-acp", Decision: Deny, Reason: ReasonMissingCredentials, Status: 401})
+	require.NoError(t, err)
+
+	want := `{"time":"0001-01-01T00:00:00Z","policy":"my-acp","handlerType":"","enforcementMode":"","decision":"allow","status":200,"latencyMs":0,"request":{}}
+{"time":"0001-01-01T00:00:00Z","policy":"my-acp","handlerType":"","enforcementMode":"","decision":"deny","reason":"missing_credentials","status":401,"latencyMs":0,"request":{}}
+`
+	assert.Equal(t, want, buf.String())
+}
+
+func TestMultiSink_Write(t *testing.T) {
+	first := &sliceSink{}
+	second := &sliceSink{}
+
+	sink := MultiSink{first, failingSink{}, second}
+
+	entry := Entry{Policy: "my-acp", Decision: Allow, Status: 200}
+	err := sink.Write(entry)
+	assert.EqualError(t, err, "boom")
+
+	assert.Equal(t, []Entry{entry}, first.entries)
+	assert.Equal(t, []Entry{entry}, second.entries)
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(Entry) error {
+	return errors.New("boom")
+}
@@ -0,0 +1,180 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
+)
+
+// Webhook sink defaults.
+const (
+	DefaultWebhookBatchSize     = 100
+	DefaultWebhookFlushInterval = 5 * time.Second
+	DefaultWebhookQueueSize     = 10000
+)
+
+// ErrQueueFull is returned when an entry is dropped because the queue of a WebhookSink is full.
+var ErrQueueFull = errors.New("decision log queue is full")
+
+// WebhookConfig configures a WebhookSink.
+type WebhookConfig struct {
+	URL string
+	// BatchSize is the maximum number of entries sent at once.
+	BatchSize int
+	// FlushInterval is the maximum duration entries are kept before being sent.
+	FlushInterval time.Duration
+	// QueueSize is the maximum number of entries waiting to be sent. Entries are dropped once it is reached.
+	QueueSize int
+	Client    httpclient.Config
+}
+
+// WebhookSink sends decision log entries in batches to an HTTP endpoint. Each batch is sent as a JSON array in the
+// body of a POST request.
+type WebhookSink struct {
+	url           string
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+	entries       chan Entry
+}
+
+// NewWebhookSink creates a new WebhookSink. Entries are sent once Run is called.
+func NewWebhookSink(cfg WebhookConfig) (*WebhookSink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("URL is required")
+	}
+
+	client, err := httpclient.New(cfg.Client)
+	if err != nil {
+		return nil, fmt.Errorf("create HTTP client: %w", err)
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultWebhookBatchSize
+	}
+
+	flushInterval := cfg.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultWebhookFlushInterval
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultWebhookQueueSize
+	}
+
+	return &WebhookSink{
+		url:           cfg.URL,
+		client:        client,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		entries:       make(chan Entry, queueSize),
+	}, nil
+}
+
+// Write queues the given entry. It never blocks and returns ErrQueueFull if the entry has been dropped.
+func (s *WebhookSink) Write(entry Entry) error {
+	select {
+	case s.entries <- entry:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run sends queued entries until the given context is canceled. Remaining entries are sent before returning.
+func (s *WebhookSink) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Entry, 0, s.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+					if len(batch) >= s.batchSize {
+						batch = s.flush(context.Background(), batch)
+					}
+				default:
+					s.flush(context.Background(), batch)
+					return
+				}
+			}
+
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				batch = s.flush(ctx, batch)
+			}
+
+		case <-ticker.C:
+			batch = s.flush(ctx, batch)
+		}
+	}
+}
+
+// flush sends the given batch and returns an empty batch to fill. Batches that cannot be sent are dropped.
+func (s *WebhookSink) flush(ctx context.Context, batch []Entry) []Entry {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := s.send(ctx, batch); err != nil {
+		log.Error().Err(err).Int("entries", len(batch)).Msg("Unable to send decision log entries")
+	}
+
+	return batch[:0]
+}
+
+func (s *WebhookSink) send(ctx context.Context, batch []Entry) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("marshal entries: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
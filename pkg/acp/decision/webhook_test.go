@@ -0,0 +1,135 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package decision
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink_Run(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]Entry
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+		var batch []Entry
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	sink, err := NewWebhookSink(WebhookConfig{
+		URL:           srv.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, sink.Write(Entry{Status: 200 + i}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.Run(ctx)
+		close(done)
+	}()
+
+	// The first batch is sent as soon as it is full.
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(batches) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The remaining entries are sent on shutdown.
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, [][]Entry{
+		{{Status: 200}, {Status: 201}},
+		{{Status: 202}},
+	}, batches)
+}
+
+func TestWebhookSink_Run_flushInterval(t *testing.T) {
+	received := make(chan []Entry, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var batch []Entry
+		if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		received <- batch
+	}))
+	t.Cleanup(srv.Close)
+
+	sink, err := NewWebhookSink(WebhookConfig{
+		URL:           srv.URL,
+		BatchSize:     10,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go sink.Run(ctx)
+
+	require.NoError(t, sink.Write(Entry{Status: 401}))
+
+	select {
+	case batch := <-received:
+		assert.Equal(t, []Entry{{Status: 401}}, batch)
+	case <-time.After(time.Second):
+		t.Fatal("entries not sent")
+	}
+}
+
+func TestWebhookSink_Write_queueFull(t *testing.T) {
+	sink, err := NewWebhookSink(WebhookConfig{URL: "http://localhost", QueueSize: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Write(Entry{Status: 200}))
+	assert.ErrorIs(t, sink.Write(Entry{Status: 200}), ErrQueueFull)
+}
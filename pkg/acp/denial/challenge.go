@@ -0,0 +1,50 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package denial
+
+import (
+	"net/http"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+// Bearer token error codes, as defined by RFC 6750.
+const (
+	errorInvalidToken      = "invalid_token"
+	errorInsufficientScope = "insufficient_scope"
+)
+
+// SetBearerChallenge sets the WWW-Authenticate header challenging the client to authenticate with a bearer token, as
+// defined by RFC 6750. The challenge holds an error code and description matching the given reason, except if the
+// request does not hold any credentials.
+func SetBearerChallenge(rw http.ResponseWriter, reason decision.Reason) {
+	rw.Header().Set("WWW-Authenticate", bearerChallenge(reason))
+}
+
+func bearerChallenge(reason decision.Reason) string {
+	switch reason {
+	case decision.ReasonInvalidCredentials:
+		return `Bearer error="` + errorInvalidToken + `", error_description="The access token is invalid"`
+	case decision.ReasonExpiredCredentials:
+		return `Bearer error="` + errorInvalidToken + `", error_description="The access token is expired or not valid yet"`
+	case decision.ReasonClaimsMismatch, decision.ReasonGroupsMismatch:
+		return `Bearer error="` + errorInsufficientScope + `", error_description="The access token does not grant access to this resource"`
+	default:
+		return "Bearer"
+	}
+}
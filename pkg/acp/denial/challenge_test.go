@@ -0,0 +1,67 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package denial
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+func TestSetBearerChallenge(t *testing.T) {
+	tests := []struct {
+		reason decision.Reason
+		want   string
+	}{
+		{
+			reason: decision.ReasonMissingCredentials,
+			want:   "Bearer",
+		},
+		{
+			reason: decision.ReasonInvalidCredentials,
+			want:   `Bearer error="invalid_token", error_description="The access token is invalid"`,
+		},
+		{
+			reason: decision.ReasonExpiredCredentials,
+			want:   `Bearer error="invalid_token", error_description="The access token is expired or not valid yet"`,
+		},
+		{
+			reason: decision.ReasonClaimsMismatch,
+			want:   `Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`,
+		},
+		{
+			reason: decision.ReasonGroupsMismatch,
+			want:   `Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(string(test.reason), func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			SetBearerChallenge(rec, test.reason)
+
+			assert.Equal(t, test.want, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package denial
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+// DefaultTemplateKey is the key of the ConfigMap entry holding the HTML template if none is given.
+const DefaultTemplateKey = "template.html"
+
+// Config configures the responses of an ACP to the requests it denies.
+type Config struct {
+	// ProblemDetails makes denied requests answered with a JSON problem details body, as defined by RFC 7807.
+	ProblemDetails bool `json:"problemDetails,omitempty"`
+	// HTMLTemplate references the ConfigMap entry holding the HTML template rendered for denied browser requests.
+	HTMLTemplate *ConfigMapKeyReference `json:"htmlTemplate,omitempty"`
+
+	// Template holds the HTML template resolved from HTMLTemplate.
+	Template string `json:"-"`
+}
+
+// ConfigMapKeyReference references an entry of a ConfigMap in any namespace.
+type ConfigMapKeyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key,omitempty"`
+}
+
+// Problem describes why a request has been denied. It is the JSON problem details body of denied requests, and the
+// data of HTML templates.
+type Problem struct {
+	Type   string          `json:"type"`
+	Title  string          `json:"title"`
+	Status int             `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+	Reason decision.Reason `json:"reason,omitempty"`
+}
+
+// details are the descriptions of the reasons ACP handlers deny requests for, sent to clients.
+var details = map[decision.Reason]string{
+	decision.ReasonMissingCredentials:     "The request does not hold any credentials.",
+	decision.ReasonInvalidCredentials:     "The credentials of the request are invalid.",
+	decision.ReasonExpiredCredentials:     "The credentials of the request are expired or not valid yet.",
+	decision.ReasonClaimsMismatch:         "The credentials of the request don't grant access to this resource.",
+	decision.ReasonGroupsMismatch:         "The subject of the request is not in the required groups.",
+	decision.ReasonAuthenticationRequired: "Authentication is required to access this resource.",
+	decision.ReasonIPNotAllowed:           "The client IP is not allowed to access this resource.",
+	decision.ReasonExternallyDenied:       "The request has been denied by the authorization service.",
+	decision.ReasonLockedOut:              "Too many failed attempts, try again later.",
+}
+
+// Handler customizes the responses of an ACP handler to the requests it denies. Browser requests are answered with
+// the HTML template, if any, and other requests with a JSON problem details body, if enabled.
+type Handler struct {
+	next           http.Handler
+	name           string
+	problemDetails bool
+	tmpl           *template.Template
+}
+
+// NewHandler creates a new Handler customizing the denial responses of the given ACP handler.
+func NewHandler(next http.Handler, cfg *Config, name string) (*Handler, error) {
+	var tmpl *template.Template
+	if cfg.HTMLTemplate != nil {
+		if cfg.Template == "" {
+			return nil, errors.New("empty HTML template")
+		}
+
+		var err error
+		tmpl, err = template.New("denial").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parsing HTML template: %w", err)
+		}
+	}
+
+	return &Handler{
+		next:           next,
+		name:           name,
+		problemDetails: cfg.ProblemDetails,
+		tmpl:           tmpl,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	req = decision.Track(req)
+
+	resp := &response{header: rw.Header()}
+	h.next.ServeHTTP(resp, req)
+
+	// Like net/http, consider a handler that did not write anything granted the request.
+	if resp.code == 0 {
+		resp.code = http.StatusOK
+	}
+
+	// Only denials are customized, not internal errors.
+	if resp.code < 400 || resp.code >= 500 {
+		resp.writeTo(rw)
+		return
+	}
+
+	reason := decision.ReasonOf(req)
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(resp.code),
+		Status: resp.code,
+		Detail: details[reason],
+		Reason: reason,
+	}
+
+	if h.tmpl != nil && acceptsHTML(req) {
+		var body bytes.Buffer
+		err := h.tmpl.Execute(&body, problem)
+		if err == nil {
+			resp.header.Set("Content-Type", "text/html; charset=utf-8")
+			resp.body = body
+			resp.writeTo(rw)
+			return
+		}
+
+		log.Error().Err(err).Str("acp_name", h.name).Msg("Unable to render denial HTML template")
+	}
+
+	if h.problemDetails {
+		var body bytes.Buffer
+		if err := json.NewEncoder(&body).Encode(problem); err != nil {
+			log.Error().Err(err).Str("acp_name", h.name).Msg("Unable to encode problem details")
+			resp.writeTo(rw)
+			return
+		}
+
+		resp.header.Set("Content-Type", "application/problem+json")
+		resp.body = body
+	}
+
+	resp.writeTo(rw)
+}
+
+// acceptsHTML returns whether the given request, likely sent by a browser, accepts HTML responses.
+func acceptsHTML(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		if strings.Contains(strings.ToLower(accept), "text/html") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// response buffers the response of an ACP handler, so it can be replaced once the decision is known.
+type response struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (r *response) Header() http.Header {
+	return r.header
+}
+
+// Write implements http.ResponseWriter.
+func (r *response) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.body.Write(b)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (r *response) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+func (r *response) writeTo(rw http.ResponseWriter) {
+	// The length of the body may have changed.
+	rw.Header().Del("Content-Length")
+	rw.WriteHeader(r.code)
+	_, _ = rw.Write(r.body.Bytes())
+}
@@ -0,0 +1,184 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package denial
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+func TestNewHandler(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc: "problem details",
+			cfg:  Config{ProblemDetails: true},
+		},
+		{
+			desc: "HTML template",
+			cfg: Config{
+				HTMLTemplate: &ConfigMapKeyReference{Name: "my-templates", Namespace: "default", Key: DefaultTemplateKey},
+				Template:     "<h1>{{ .Title }}</h1>",
+			},
+		},
+		{
+			desc: "empty HTML template",
+			cfg: Config{
+				HTMLTemplate: &ConfigMapKeyReference{Name: "my-templates", Namespace: "default", Key: DefaultTemplateKey},
+			},
+			wantErr: "empty HTML template",
+		},
+		{
+			desc: "invalid HTML template",
+			cfg: Config{
+				HTMLTemplate: &ConfigMapKeyReference{Name: "my-templates", Namespace: "default", Key: DefaultTemplateKey},
+				Template:     "<h1>{{ .Title </h1>",
+			},
+			wantErr: `parsing HTML template: template: denial:1: unexpected "<" in operand`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {})
+
+			_, err := NewHandler(next, &test.cfg, "my-acp")
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	htmlTemplate := &ConfigMapKeyReference{Name: "my-templates", Namespace: "default", Key: DefaultTemplateKey}
+
+	tests := []struct {
+		desc            string
+		cfg             Config
+		accept          string
+		code            int
+		reason          decision.Reason
+		wantCode        int
+		wantContentType string
+		wantBody        string
+		wantChallenge   string
+	}{
+		{
+			desc:     "granted requests are not customized",
+			cfg:      Config{ProblemDetails: true},
+			code:     http.StatusOK,
+			wantCode: http.StatusOK,
+		},
+		{
+			desc:          "denied requests are left untouched by default",
+			code:          http.StatusUnauthorized,
+			reason:        decision.ReasonInvalidCredentials,
+			wantCode:      http.StatusUnauthorized,
+			wantChallenge: `Bearer error="invalid_token"`,
+		},
+		{
+			desc:            "denied requests get problem details",
+			cfg:             Config{ProblemDetails: true},
+			code:            http.StatusUnauthorized,
+			reason:          decision.ReasonInvalidCredentials,
+			wantCode:        http.StatusUnauthorized,
+			wantContentType: "application/problem+json",
+			wantBody:        `{"type":"about:blank","title":"Unauthorized","status":401,"detail":"The credentials of the request are invalid.","reason":"invalid_credentials"}` + "\n",
+			wantChallenge:   `Bearer error="invalid_token"`,
+		},
+		{
+			desc:            "denied browser requests get the HTML template",
+			cfg:             Config{ProblemDetails: true, HTMLTemplate: htmlTemplate, Template: "<h1>{{ .Title }}</h1><p>{{ .Detail }}</p>"},
+			accept:          "text/html,application/xhtml+xml,*/*;q=0.8",
+			code:            http.StatusForbidden,
+			reason:          decision.ReasonIPNotAllowed,
+			wantCode:        http.StatusForbidden,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        "<h1>Forbidden</h1><p>The client IP is not allowed to access this resource.</p>",
+			wantChallenge:   `Bearer error="invalid_token"`,
+		},
+		{
+			desc:            "denied API requests get problem details even with an HTML template",
+			cfg:             Config{ProblemDetails: true, HTMLTemplate: htmlTemplate, Template: "<h1>{{ .Title }}</h1>"},
+			accept:          "application/json",
+			code:            http.StatusForbidden,
+			reason:          decision.ReasonClaimsMismatch,
+			wantCode:        http.StatusForbidden,
+			wantContentType: "application/problem+json",
+			wantBody:        `{"type":"about:blank","title":"Forbidden","status":403,"detail":"The credentials of the request don't grant access to this resource.","reason":"claims_mismatch"}` + "\n",
+			wantChallenge:   `Bearer error="invalid_token"`,
+		},
+		{
+			desc:     "internal errors are not customized",
+			cfg:      Config{ProblemDetails: true},
+			code:     http.StatusInternalServerError,
+			reason:   decision.ReasonInternalError,
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if test.reason != "" {
+					decision.SetReason(req, test.reason)
+				}
+				if test.code >= 400 && test.code < 500 {
+					rw.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				}
+				rw.WriteHeader(test.code)
+			})
+
+			handler, err := NewHandler(next, &test.cfg, "my-acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantCode, rec.Code)
+			assert.Equal(t, test.wantContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, test.wantBody, rec.Body.String())
+			assert.Equal(t, test.wantChallenge, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
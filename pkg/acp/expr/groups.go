@@ -0,0 +1,67 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package expr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MatchGroups returns the groups held by the given claim, and whether one of them is among the groups required by the
+// ingress controller. Required groups are given as a comma separated list in the "groups" query parameter of the
+// request, like for API keys. Requests not requiring any group always match.
+func MatchGroups(req *http.Request, claim string, claims map[string]interface{}) ([]string, bool, error) {
+	groups, err := PluckClaim(claim, claims)
+	if err != nil {
+		return nil, false, fmt.Errorf("plucking groups claim %q: %w", claim, err)
+	}
+
+	required, err := requiredGroups(req)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(required) == 0 {
+		return groups, true, nil
+	}
+
+	for _, group := range groups {
+		for _, r := range required {
+			if group == r {
+				return groups, true, nil
+			}
+		}
+	}
+
+	return groups, false, nil
+}
+
+func requiredGroups(req *http.Request) ([]string, error) {
+	raw := req.URL.Query().Get("groups")
+	if raw == "" {
+		return nil, nil
+	}
+
+	groups, err := url.QueryUnescape(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unescaping groups: %w", err)
+	}
+
+	return strings.Split(groups, ","), nil
+}
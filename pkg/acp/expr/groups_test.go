@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package expr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGroups(t *testing.T) {
+	tests := []struct {
+		desc       string
+		target     string
+		claim      string
+		claims     map[string]interface{}
+		wantGroups []string
+		wantMatch  bool
+	}{
+		{
+			desc:       "no group required",
+			target:     "/",
+			claim:      "groups",
+			claims:     map[string]interface{}{"groups": []interface{}{"dev"}},
+			wantGroups: []string{"dev"},
+			wantMatch:  true,
+		},
+		{
+			desc:      "no group required and no groups claim",
+			target:    "/",
+			claim:     "groups",
+			claims:    map[string]interface{}{},
+			wantMatch: true,
+		},
+		{
+			desc:       "subject in one of the required groups",
+			target:     "/?groups=admin%2Cdev",
+			claim:      "groups",
+			claims:     map[string]interface{}{"groups": []interface{}{"dev", "ops"}},
+			wantGroups: []string{"dev", "ops"},
+			wantMatch:  true,
+		},
+		{
+			desc:       "subject in none of the required groups",
+			target:     "/?groups=admin",
+			claim:      "groups",
+			claims:     map[string]interface{}{"groups": []interface{}{"dev", "ops"}},
+			wantGroups: []string{"dev", "ops"},
+		},
+		{
+			desc:   "subject without groups claim",
+			target: "/?groups=admin",
+			claim:  "groups",
+			claims: map[string]interface{}{"sub": "alice"},
+		},
+		{
+			desc:       "nested groups claim",
+			target:     "/?groups=admin",
+			claim:      "realm_access.roles",
+			claims:     map[string]interface{}{"realm_access": map[string]interface{}{"roles": []interface{}{"admin"}}},
+			wantGroups: []string{"admin"},
+			wantMatch:  true,
+		},
+		{
+			desc:       "single group claim",
+			target:     "/?groups=admin,dev",
+			claim:      "group",
+			claims:     map[string]interface{}{"group": "dev"},
+			wantGroups: []string{"dev"},
+			wantMatch:  true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, test.target, http.NoBody)
+
+			groups, match, err := MatchGroups(req, test.claim, test.claims)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantGroups, groups)
+			assert.Equal(t, test.wantMatch, match)
+		})
+	}
+}
+
+func TestMatchGroups_unsupportedClaim(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?groups=admin", http.NoBody)
+
+	_, _, err := MatchGroups(req, "groups", map[string]interface{}{"groups": []interface{}{map[string]interface{}{"name": "admin"}}})
+	assert.Error(t, err)
+}
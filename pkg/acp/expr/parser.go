@@ -19,15 +19,21 @@ package expr
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vulcand/predicate"
 )
 
 // Predicate represents a function that can be evaluated to get the result of an expression.
-type Predicate func(a map[string]interface{}) bool
+// The request is the one received by the ACP handler. It can be nil, in which case request predicates are false.
+type Predicate func(claims map[string]interface{}, req *http.Request) bool
 
 // Parse returns a predicate from the given expression.
 func Parse(expr string) (Predicate, error) {
@@ -43,6 +49,17 @@ func Parse(expr string) (Predicate, error) {
 			"Contains":      contains,
 			"SplitContains": splitContains,
 			"Ohubf":         ohubf,
+			"In":            in,
+			"Matches":       matchesRegexp,
+			"GreaterThan":   greaterThan,
+			"LessThan":      lessThan,
+			"Before":        before,
+			"After":         after,
+			"Method":        method,
+			"Path":          path,
+			"PathPrefix":    pathPrefix,
+			"SourceIP":      sourceIP,
+			"Header":        header,
 		},
 	})
 	if err != nil {
@@ -51,32 +68,37 @@ func Parse(expr string) (Predicate, error) {
 
 	p, err := parser.Parse(expr)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse expression: %w", err)
+		return nil, fmt.Errorf("unable to parse expression %q: %w", expr, err)
 	}
 
-	return p.(Predicate), nil
+	pred, ok := p.(Predicate)
+	if !ok {
+		return nil, fmt.Errorf("unable to parse expression %q: not a predicate", expr)
+	}
+
+	return pred, nil
 }
 
 func andFunc(a, b Predicate) Predicate {
-	return func(v map[string]interface{}) bool {
-		return a(v) && b(v)
+	return func(v map[string]interface{}, req *http.Request) bool {
+		return a(v, req) && b(v, req)
 	}
 }
 
 func orFunc(a, b Predicate) Predicate {
-	return func(v map[string]interface{}) bool {
-		return a(v) || b(v)
+	return func(v map[string]interface{}, req *http.Request) bool {
+		return a(v, req) || b(v, req)
 	}
 }
 
 func notFunc(a Predicate) Predicate {
-	return func(v map[string]interface{}) bool {
-		return !a(v)
+	return func(v map[string]interface{}, req *http.Request) bool {
+		return !a(v, req)
 	}
 }
 
 func equals(claimName, expected string) Predicate {
-	return func(claims map[string]interface{}) bool {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
 		claim, ok := resolve(claimName, claims)
 		if !ok {
 			return false
@@ -87,7 +109,7 @@ func equals(claimName, expected string) Predicate {
 }
 
 func prefix(claimName, expected string) Predicate {
-	return func(claims map[string]interface{}) bool {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
 		claim, ok := resolve(claimName, claims)
 		if !ok {
 			return false
@@ -103,7 +125,7 @@ func prefix(claimName, expected string) Predicate {
 }
 
 func contains(claimName, expected string) Predicate {
-	return func(claims map[string]interface{}) bool {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
 		claim, ok := resolve(claimName, claims)
 		if !ok {
 			return false
@@ -128,7 +150,7 @@ func contains(claimName, expected string) Predicate {
 }
 
 func splitContains(claimName, sep, expected string) Predicate {
-	return func(claims map[string]interface{}) bool {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
 		claim, ok := resolve(claimName, claims)
 		if !ok {
 			return false
@@ -150,7 +172,7 @@ func splitContains(claimName, sep, expected string) Predicate {
 }
 
 func ohubf(claimName string, expected ...string) Predicate {
-	return func(claims map[string]interface{}) bool {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
 		claim, ok := resolve(claimName, claims)
 		if !ok {
 			return false
@@ -179,6 +201,169 @@ func ohubf(claimName string, expected ...string) Predicate {
 	}
 }
 
+// in matches claims equal to one of the expected values. If the claim is a list, one of its elements must be equal to
+// one of the expected values.
+func in(claimName string, expected ...string) Predicate {
+	return func(claims map[string]interface{}, _ *http.Request) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		values, ok := claim.([]interface{})
+		if !ok {
+			values = []interface{}{claim}
+		}
+
+		for _, v := range values {
+			for _, exp := range expected {
+				if matches(v, exp) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// matchesRegexp matches string claims against the given RE2 regular expression.
+func matchesRegexp(claimName, expr string) (Predicate, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %q given to Matches: %w", expr, err)
+	}
+
+	return func(claims map[string]interface{}, _ *http.Request) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		str, ok := claim.(string)
+		if !ok {
+			return false
+		}
+
+		return re.MatchString(str)
+	}, nil
+}
+
+func greaterThan(claimName, expected string) (Predicate, error) {
+	return compareNumber("GreaterThan", claimName, expected, func(claim, exp float64) bool {
+		return claim > exp
+	})
+}
+
+func lessThan(claimName, expected string) (Predicate, error) {
+	return compareNumber("LessThan", claimName, expected, func(claim, exp float64) bool {
+		return claim < exp
+	})
+}
+
+func compareNumber(fnName, claimName, expected string, cmp func(claim, exp float64) bool) (Predicate, error) {
+	exp, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q given to %s: %w", expected, fnName, err)
+	}
+
+	return func(claims map[string]interface{}, _ *http.Request) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		f, ok := toFloat(claim)
+		if !ok {
+			return false
+		}
+
+		return cmp(f, exp)
+	}, nil
+}
+
+// before matches timestamp claims before the given time.
+// See parseTime for the supported formats of the given time.
+func before(claimName, expected string) (Predicate, error) {
+	return compareTime("Before", claimName, expected, func(claim, exp time.Time) bool {
+		return claim.Before(exp)
+	})
+}
+
+// after matches timestamp claims after the given time.
+// See parseTime for the supported formats of the given time.
+func after(claimName, expected string) (Predicate, error) {
+	return compareTime("After", claimName, expected, func(claim, exp time.Time) bool {
+		return claim.After(exp)
+	})
+}
+
+func compareTime(fnName, claimName, expected string, cmp func(claim, exp time.Time) bool) (Predicate, error) {
+	exp, err := parseTime(expected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q given to %s: %w", expected, fnName, err)
+	}
+
+	return func(claims map[string]interface{}, _ *http.Request) bool {
+		claim, ok := resolve(claimName, claims)
+		if !ok {
+			return false
+		}
+
+		t, ok := toTime(claim)
+		if !ok {
+			return false
+		}
+
+		return cmp(t, exp())
+	}, nil
+}
+
+// parseTime parses either an RFC 3339 timestamp or a duration relative to the evaluation time, e.g. "-1h".
+func parseTime(s string) (func() time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return func() time.Time { return t }, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, errors.New("must be an RFC 3339 timestamp or a duration")
+	}
+
+	return func() time.Time { return time.Now().Add(d) }, nil
+}
+
+// toTime converts a timestamp claim to a time. Timestamps are either numeric dates (number of seconds since the Unix
+// epoch) or RFC 3339 strings.
+func toTime(v interface{}) (time.Time, bool) {
+	if str, ok := v.(string); ok {
+		t, err := time.Parse(time.RFC3339, str)
+		return t, err == nil
+	}
+
+	f, ok := toFloat(v)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case json.Number:
+		f, err := val.Float64()
+		return f, err == nil
+
+	case float64:
+		return val, true
+
+	default:
+		return 0, false
+	}
+}
+
 func matches(v interface{}, expected string) bool {
 	switch val := v.(type) {
 	case string:
@@ -213,6 +213,72 @@ func TestValidateCustomClaims(t *testing.T) {
 			expr:   "Equals(``, `bruce`)",
 			want:   false,
 		},
+		{
+			desc:   "in expression",
+			claims: `{"grp":"dev"}`,
+			expr:   "In(`grp`, `admin`, `dev`)",
+			want:   true,
+		},
+		{
+			desc:   "in expression over a list",
+			claims: `{"grps":["ops","dev"]}`,
+			expr:   "In(`grps`, `admin`, `dev`)",
+			want:   true,
+		},
+		{
+			desc:   "in expression over a list (false)",
+			claims: `{"grps":["ops","qa"]}`,
+			expr:   "In(`grps`, `admin`, `dev`)",
+			want:   false,
+		},
+		{
+			desc:   "matches expression",
+			claims: `{"email":"bruce@wayne-enterprises.com"}`,
+			expr:   "Matches(`email`, `^[a-z]+@wayne-enterprises\\.com$`)",
+			want:   true,
+		},
+		{
+			desc:   "matches expression on a non string claim",
+			claims: `{"level":42}`,
+			expr:   "Matches(`level`, `4`)",
+			want:   false,
+		},
+		{
+			desc:   "greaterThan expression",
+			claims: `{"level":42}`,
+			expr:   "GreaterThan(`level`, `41.5`) && !GreaterThan(`level`, `42`)",
+			want:   true,
+		},
+		{
+			desc:   "lessThan expression",
+			claims: `{"level":42}`,
+			expr:   "LessThan(`level`, `43`) && !LessThan(`level`, `42`)",
+			want:   true,
+		},
+		{
+			desc:   "lessThan expression on a non numeric claim",
+			claims: `{"level":"42"}`,
+			expr:   "LessThan(`level`, `43`)",
+			want:   false,
+		},
+		{
+			desc:   "before and after expressions with a timestamp",
+			claims: `{"auth_time":1672531200}`,
+			expr:   "After(`auth_time`, `2022-12-31T23:59:59Z`) && Before(`auth_time`, `2023-01-01T00:00:01Z`)",
+			want:   true,
+		},
+		{
+			desc:   "after expression with a duration",
+			claims: `{"auth_time":1672531200}`,
+			expr:   "After(`auth_time`, `-1h`)",
+			want:   false,
+		},
+		{
+			desc:   "before expression with an RFC 3339 claim",
+			claims: `{"updated_at":"2023-01-01T00:00:00Z"}`,
+			expr:   "Before(`updated_at`, `-1h`)",
+			want:   true,
+		},
 	}
 	for _, test := range tests {
 		test := test
@@ -228,7 +294,51 @@ func TestValidateCustomClaims(t *testing.T) {
 			err = dec.Decode(&claims)
 			require.NoError(t, err)
 
-			assert.Equal(t, test.want, pred(claims))
+			assert.Equal(t, test.want, pred(claims, nil))
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		expr    string
+		wantErr string
+	}{
+		{
+			desc:    "unknown function",
+			expr:    "Foo(`grp`)",
+			wantErr: "unable to parse expression \"Foo(`grp`)\": unsupported function: Foo",
+		},
+		{
+			desc:    "not a predicate",
+			expr:    "`grp`",
+			wantErr: "unable to parse expression \"`grp`\": not a predicate",
+		},
+		{
+			desc:    "invalid regular expression",
+			expr:    "Matches(`grp`, `(`)",
+			wantErr: "unable to parse expression \"Matches(`grp`, `(`)\": invalid regular expression \"(\" given to Matches: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			desc:    "invalid number",
+			expr:    "GreaterThan(`level`, `high`)",
+			wantErr: "unable to parse expression \"GreaterThan(`level`, `high`)\": invalid number \"high\" given to GreaterThan: strconv.ParseFloat: parsing \"high\": invalid syntax",
+		},
+		{
+			desc:    "invalid time",
+			expr:    "After(`auth_time`, `yesterday`)",
+			wantErr: "unable to parse expression \"After(`auth_time`, `yesterday`)\": invalid time \"yesterday\" given to After: must be an RFC 3339 timestamp or a duration",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(test.expr)
+			assert.EqualError(t, err, test.wantErr)
 		})
 	}
 }
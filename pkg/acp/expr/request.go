@@ -0,0 +1,235 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	pathpkg "path"
+	"strings"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
+)
+
+// method matches requests whose original HTTP method is one of the given methods.
+func method(methods ...string) (Predicate, error) {
+	if len(methods) == 0 {
+		return nil, errors.New("at least one method must be given to Method")
+	}
+
+	return func(_ map[string]interface{}, req *http.Request) bool {
+		if req == nil {
+			return false
+		}
+
+		m := OriginalMethod(req)
+		for _, expected := range methods {
+			if strings.EqualFold(m, expected) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// path matches requests whose original path is one of the given paths.
+func path(paths ...string) (Predicate, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one path must be given to Path")
+	}
+
+	return func(_ map[string]interface{}, req *http.Request) bool {
+		if req == nil {
+			return false
+		}
+
+		p := OriginalPath(req)
+		for _, expected := range paths {
+			if p == expected {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// pathPrefix matches requests whose original path starts with the given prefix.
+func pathPrefix(prefix string) Predicate {
+	return func(_ map[string]interface{}, req *http.Request) bool {
+		if req == nil {
+			return false
+		}
+
+		return strings.HasPrefix(OriginalPath(req), prefix)
+	}
+}
+
+// sourceIP matches requests whose source IP is one of the given IPs or is within one of the given CIDRs.
+func sourceIP(ranges ...string) (Predicate, error) {
+	if len(ranges) == 0 {
+		return nil, errors.New("at least one IP or CIDR must be given to SourceIP")
+	}
+
+	nets, err := ParseIPRanges(ranges)
+	if err != nil {
+		return nil, fmt.Errorf("SourceIP: %w", err)
+	}
+
+	return func(_ map[string]interface{}, req *http.Request) bool {
+		if req == nil {
+			return false
+		}
+
+		ip := SourceIP(req)
+		if ip == nil {
+			return false
+		}
+
+		for _, ipNet := range nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// ParseIPRanges parses the given IPs and CIDRs. An IP is parsed as a range holding only this IP.
+func ParseIPRanges(ranges []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(ranges))
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", r)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", r, err)
+		}
+
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// header matches requests having the given header set to one of the given values.
+func header(name string, values ...string) (Predicate, error) {
+	if len(values) == 0 {
+		return nil, errors.New("at least one value must be given to Header")
+	}
+
+	return func(_ map[string]interface{}, req *http.Request) bool {
+		if req == nil {
+			return false
+		}
+
+		for _, v := range req.Header.Values(name) {
+			for _, expected := range values {
+				if v == expected {
+					return true
+				}
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// OriginalMethod gets the HTTP method of the request sent to the ingress controller, regardless of its type.
+// It currently supports Traefik (X-Forwarded-Method) and Nginx Community (X-Original-Method).
+func OriginalMethod(req *http.Request) string {
+	if m := req.Header.Get("X-Forwarded-Method"); m != "" {
+		return m
+	}
+	if m := req.Header.Get("X-Original-Method"); m != "" {
+		return m
+	}
+
+	return req.Method
+}
+
+// OriginalPath gets the path of the request sent to the ingress controller, regardless of its type.
+// It falls back on the path of the given request if the original URI is unknown.
+// The returned path is decoded and cleaned of dot-segments, as the upstream service would resolve it.
+func OriginalPath(req *http.Request) string {
+	uri := token.OriginalURI(req.Header)
+	if uri == "" {
+		return cleanPath(req.URL.Path)
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+
+	return cleanPath(u.Path)
+}
+
+// cleanPath resolves the dot-segments and duplicated slashes of the given decoded path, keeping its trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return ""
+	}
+
+	cleaned := pathpkg.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// SourceIP gets the IP of the client of the ingress controller. It reads the X-Real-Ip header, set by both Traefik
+// and Nginx, and otherwise the closest entry of the X-Forwarded-For header, as farther entries can be forged by clients.
+func SourceIP(req *http.Request) net.IP {
+	if ip := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-Ip"))); ip != nil {
+		return ip
+	}
+
+	if xff := req.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		entries := strings.Split(xff[len(xff)-1], ",")
+		if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-1])); ip != nil {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return net.ParseIP(req.RemoteAddr)
+	}
+
+	return net.ParseIP(host)
+}
@@ -0,0 +1,199 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package expr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestPredicates(t *testing.T) {
+	tests := []struct {
+		desc    string
+		expr    string
+		claims  map[string]interface{}
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{
+			desc:    "method forwarded by Traefik",
+			expr:    "Method(`DELETE`)",
+			headers: map[string]string{"X-Forwarded-Method": "DELETE"},
+			want:    true,
+		},
+		{
+			desc:    "method forwarded by Nginx",
+			expr:    "Method(`get`, `HEAD`)",
+			headers: map[string]string{"X-Original-Method": "GET"},
+			want:    true,
+		},
+		{
+			desc:   "method of the request when not forwarded",
+			expr:   "Method(`DELETE`)",
+			method: http.MethodGet,
+			want:   false,
+		},
+		{
+			desc:    "path forwarded by Traefik",
+			expr:    "Path(`/api/users`)",
+			headers: map[string]string{"X-Forwarded-Uri": "/api/users?page=2"},
+			want:    true,
+		},
+		{
+			desc:    "path forwarded by Nginx",
+			expr:    "PathPrefix(`/api/`)",
+			headers: map[string]string{"X-Original-Url": "https://example.com/api/users"},
+			want:    true,
+		},
+		{
+			desc:    "path not matching",
+			expr:    "PathPrefix(`/admin`)",
+			headers: map[string]string{"X-Forwarded-Uri": "/api/users"},
+			want:    false,
+		},
+		{
+			desc:    "path prefix escaped with dot-segments",
+			expr:    "PathPrefix(`/public`)",
+			headers: map[string]string{"X-Forwarded-Uri": "/public/../admin"},
+			want:    false,
+		},
+		{
+			desc:    "path prefix escaped with encoded dot-segments",
+			expr:    "PathPrefix(`/public`)",
+			headers: map[string]string{"X-Forwarded-Uri": "/public/%2e%2e/admin"},
+			want:    false,
+		},
+		{
+			desc:    "path with encoded dot-segments",
+			expr:    "Path(`/admin`)",
+			headers: map[string]string{"X-Original-Url": "https://example.com/public/%2E%2E/admin"},
+			want:    true,
+		},
+		{
+			desc:    "path with dot-segments keeps its trailing slash",
+			expr:    "Path(`/api/`)",
+			headers: map[string]string{"X-Forwarded-Uri": "/api/users/./../"},
+			want:    true,
+		},
+		{
+			desc:    "source IP from X-Real-Ip",
+			expr:    "SourceIP(`10.0.0.0/8`)",
+			headers: map[string]string{"X-Real-Ip": "10.1.2.3", "X-Forwarded-For": "192.168.1.1"},
+			want:    true,
+		},
+		{
+			desc:    "source IP from the closest X-Forwarded-For entry",
+			expr:    "SourceIP(`192.168.1.1`)",
+			headers: map[string]string{"X-Forwarded-For": "10.1.2.3, 192.168.1.1"},
+			want:    true,
+		},
+		{
+			desc:    "forged X-Forwarded-For entry is ignored",
+			expr:    "SourceIP(`10.1.2.3`)",
+			headers: map[string]string{"X-Forwarded-For": "10.1.2.3, 192.168.1.1"},
+			want:    false,
+		},
+		{
+			desc: "source IP from the remote address",
+			expr: "SourceIP(`192.0.2.0/24`, `::1`)",
+			want: true,
+		},
+		{
+			desc:    "header",
+			expr:    "Header(`X-Tenant`, `acme`, `globex`)",
+			headers: map[string]string{"X-Tenant": "globex"},
+			want:    true,
+		},
+		{
+			desc: "missing header",
+			expr: "Header(`X-Tenant`, `acme`)",
+			want: false,
+		},
+		{
+			desc:    "admins for DELETE, anyone for GET",
+			expr:    "(Method(`DELETE`) && Equals(`grp`, `admin`)) || Method(`GET`)",
+			claims:  map[string]interface{}{"grp": "dev"},
+			headers: map[string]string{"X-Forwarded-Method": "DELETE"},
+			want:    false,
+		},
+		{
+			desc:    "admins for DELETE",
+			expr:    "(Method(`DELETE`) && Equals(`grp`, `admin`)) || Method(`GET`)",
+			claims:  map[string]interface{}{"grp": "admin"},
+			headers: map[string]string{"X-Forwarded-Method": "DELETE"},
+			want:    true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			pred, err := Parse(test.expr)
+			require.NoError(t, err)
+
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, "/", http.NoBody)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			assert.Equal(t, test.want, pred(test.claims, req))
+		})
+	}
+}
+
+func TestRequestPredicates_nilRequest(t *testing.T) {
+	pred, err := Parse("Method(`GET`) || Path(`/`) || PathPrefix(`/`) || SourceIP(`0.0.0.0/0`) || Header(`X`, `y`)")
+	require.NoError(t, err)
+
+	assert.False(t, pred(nil, nil))
+}
+
+func TestRequestPredicates_invalid(t *testing.T) {
+	tests := []struct {
+		desc string
+		expr string
+	}{
+		{desc: "method without argument", expr: "Method()"},
+		{desc: "path without argument", expr: "Path()"},
+		{desc: "invalid IP", expr: "SourceIP(`10.0.0`)"},
+		{desc: "invalid CIDR", expr: "SourceIP(`10.0.0.0/33`)"},
+		{desc: "header without value", expr: "Header(`X-Tenant`)"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(test.expr)
+			assert.Error(t, err)
+		})
+	}
+}
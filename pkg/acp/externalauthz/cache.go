@@ -0,0 +1,63 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package externalauthz
+
+import (
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lru"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+)
+
+// Default values of the cache configuration.
+const (
+	DefaultCacheMaxSize    = 1000
+	DefaultCacheTTLSeconds = 60
+)
+
+// CacheConfig configures the cache of authorization decisions.
+type CacheConfig struct {
+	MaxSize    *optional.Int `json:"maxSize,omitempty"`
+	TTLSeconds *optional.Int `json:"ttlSeconds,omitempty"`
+}
+
+// cache is a bounded LRU cache of authorization decisions, keyed by the hash of the fields deciding access.
+type cache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	entries *lru.Cache[result]
+}
+
+func newCache(cfg CacheConfig) *cache {
+	return &cache{
+		ttl:     time.Duration(cfg.TTLSeconds.IntOrDefault(DefaultCacheTTLSeconds)) * time.Second,
+		now:     time.Now,
+		entries: lru.New[result](cfg.MaxSize.IntOrDefault(DefaultCacheMaxSize)),
+	}
+}
+
+// Get returns the cached decision for the given key, if any.
+func (c *cache) Get(key string) (result, bool) {
+	return c.entries.Get(key, c.now())
+}
+
+// Set caches the decision for the given key.
+func (c *cache) Set(key string, res result) {
+	c.entries.Set(key, res, c.now().Add(c.ttl))
+}
@@ -0,0 +1,73 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package externalauthz
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+)
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Now()
+
+	c := newCache(CacheConfig{TTLSeconds: optional.NewInt(60)})
+	c.now = func() time.Time { return now }
+
+	granted := result{code: http.StatusOK, header: http.Header{"X-Entitlements": []string{"read"}}}
+	c.Set("granted", granted)
+	c.Set("denied", result{code: http.StatusForbidden})
+
+	c.now = func() time.Time { return now.Add(59 * time.Second) }
+
+	res, ok := c.Get("granted")
+	assert.True(t, ok)
+	assert.Equal(t, granted, res)
+
+	res, ok = c.Get("denied")
+	assert.True(t, ok)
+	assert.Equal(t, result{code: http.StatusForbidden}, res)
+
+	c.now = func() time.Time { return now.Add(60 * time.Second) }
+
+	_, ok = c.Get("granted")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.entries.Len())
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(CacheConfig{MaxSize: optional.NewInt(2)})
+
+	c.Set("key-1", result{code: http.StatusOK})
+	c.Set("key-2", result{code: http.StatusOK})
+
+	_, ok := c.Get("key-1")
+	assert.True(t, ok)
+
+	c.Set("key-3", result{code: http.StatusOK})
+
+	_, ok = c.Get("key-2")
+	assert.False(t, ok)
+	_, ok = c.Get("key-1")
+	assert.True(t, ok)
+	_, ok = c.Get("key-3")
+	assert.True(t, ok)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package externalauthz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
+)
+
+// Config configures an external authorization ACP handler.
+type Config struct {
+	httpclient.Config
+
+	URL string `json:"url"`
+	// RequestHeaders are the headers of the request sent to the external authorization service. No headers are sent
+	// if empty.
+	RequestHeaders []string `json:"requestHeaders,omitempty"`
+	// ForwardHeaders are the headers of the responses of the external authorization service forwarded to upstream
+	// services when access is granted.
+	ForwardHeaders []string     `json:"forwardHeaders,omitempty"`
+	Cache          *CacheConfig `json:"cache,omitempty"`
+}
+
+// Request is the description of a request sent to the external authorization service. Subject and claims are the
+// ones reported by the methods evaluated before, like the JWT method of an allOf ACP.
+type Request struct {
+	Method  string                 `json:"method"`
+	Host    string                 `json:"host,omitempty"`
+	Path    string                 `json:"path"`
+	Headers map[string][]string    `json:"headers,omitempty"`
+	Subject string                 `json:"subject,omitempty"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Handler is an external authorization ACP handler. It grants access to requests when the external authorization
+// service answers with a 2xx status code, and denies it when it answers with a 401 or 403 status code.
+type Handler struct {
+	name string
+
+	url            string
+	httpClient     *http.Client
+	requestHeaders []string
+	fwdHeaders     []string
+
+	cache *cache
+}
+
+// NewHandler creates a new external authorization ACP handler.
+func NewHandler(cfg *Config, name string) (*Handler, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("empty URL")
+	}
+
+	httpClient, err := httpclient.New(cfg.Config)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+
+	fwdHeaders := make([]string, 0, len(cfg.ForwardHeaders))
+	for _, header := range cfg.ForwardHeaders {
+		fwdHeaders = append(fwdHeaders, http.CanonicalHeaderKey(header))
+	}
+
+	var c *cache
+	if cfg.Cache != nil {
+		c = newCache(*cfg.Cache)
+	}
+
+	return &Handler{
+		name:           name,
+		url:            cfg.URL,
+		httpClient:     httpClient,
+		requestHeaders: cfg.RequestHeaders,
+		fwdHeaders:     fwdHeaders,
+		cache:          c,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "ExternalAuthz").Str("handler_name", h.name).Logger()
+
+	res, err := h.authorize(req)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to get authorization decision")
+		decision.SetReason(req, decision.ReasonInternalError)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if res.code != http.StatusOK {
+		l.Debug().Int("status", res.code).Msg("Access denied by the external authorization service")
+		decision.SetReason(req, decision.ReasonExternallyDenied)
+		rw.WriteHeader(res.code)
+		return
+	}
+
+	for name, values := range res.header {
+		for _, value := range values {
+			rw.Header().Add(name, value)
+		}
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// result is the decision of the external authorization service.
+type result struct {
+	// code is http.StatusOK when access is granted, and the status code returned by the service otherwise.
+	code   int
+	header http.Header
+}
+
+// authorize returns the decision of the external authorization service for the given request, from the cache if
+// possible.
+func (h *Handler) authorize(req *http.Request) (result, error) {
+	// The description only holds the fields deciding access: the original method, host and path, the allowed
+	// headers and the identity reported by the methods evaluated before. It is therefore used as the cache key.
+	body, err := json.Marshal(h.describe(req))
+	if err != nil {
+		return result{}, fmt.Errorf("encoding request description: %w", err)
+	}
+
+	if h.cache == nil {
+		return h.callService(req, body)
+	}
+
+	key := hashBody(body)
+	if res, ok := h.cache.Get(key); ok {
+		return res, nil
+	}
+
+	res, err := h.callService(req, body)
+	if err != nil {
+		return result{}, err
+	}
+
+	h.cache.Set(key, res)
+
+	return res, nil
+}
+
+// describe returns the description of the given request sent to the external authorization service.
+func (h *Handler) describe(req *http.Request) Request {
+	host := req.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = req.Host
+	}
+
+	// Only the allowed headers are sent, so credentials like cookies don't leak to the service unless configured.
+	var headers http.Header
+	for _, name := range h.requestHeaders {
+		values := req.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+
+		if headers == nil {
+			headers = make(http.Header, len(h.requestHeaders))
+		}
+		headers[http.CanonicalHeaderKey(name)] = values
+	}
+
+	// Only the methods which granted the request before this one, like in an allOf ACP, report an identity.
+	identity := decision.IdentityOf(req)
+
+	return Request{
+		Method:  expr.OriginalMethod(req),
+		Host:    host,
+		Path:    expr.OriginalPath(req),
+		Headers: headers,
+		Subject: identity.Subject,
+		Claims:  identity.Claims,
+	}
+}
+
+func (h *Handler) callService(originalReq *http.Request, body []byte) (result, error) {
+	req, err := http.NewRequestWithContext(originalReq.Context(), http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return result{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		header := make(http.Header)
+		for _, name := range h.fwdHeaders {
+			if values := resp.Header.Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+
+		return result{code: http.StatusOK, header: header}, nil
+
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return result{code: resp.StatusCode}, nil
+
+	default:
+		return result{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,268 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package externalauthz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/composite"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+)
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler(&Config{}, "my-acp")
+	assert.EqualError(t, err, "empty URL")
+
+	_, err = NewHandler(&Config{URL: "https://authz.example.com"}, "my-acp")
+	assert.NoError(t, err)
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		status     int
+		wantStatus int
+		wantReason decision.Reason
+		wantHeader http.Header
+	}{
+		{
+			desc:       "granted",
+			status:     http.StatusNoContent,
+			wantStatus: http.StatusOK,
+			wantHeader: http.Header{"X-Entitlements": []string{"read", "write"}},
+		},
+		{
+			desc:       "denied",
+			status:     http.StatusForbidden,
+			wantStatus: http.StatusForbidden,
+			wantReason: decision.ReasonExternallyDenied,
+			wantHeader: http.Header{},
+		},
+		{
+			desc:       "unauthenticated",
+			status:     http.StatusUnauthorized,
+			wantStatus: http.StatusUnauthorized,
+			wantReason: decision.ReasonExternallyDenied,
+			wantHeader: http.Header{},
+		},
+		{
+			desc:       "unexpected status code",
+			status:     http.StatusBadRequest,
+			wantStatus: http.StatusInternalServerError,
+			wantReason: decision.ReasonInternalError,
+			wantHeader: http.Header{},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var got Request
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+					rw.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+
+				if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				rw.Header().Add("X-Entitlements", "read")
+				rw.Header().Add("X-Entitlements", "write")
+				rw.Header().Set("X-Internal", "secret")
+				rw.WriteHeader(test.status)
+			}))
+			t.Cleanup(srv.Close)
+
+			handler, err := NewHandler(&Config{
+				Config:         noRetries(),
+				URL:            srv.URL,
+				RequestHeaders: []string{"authorization", "X-Tenant"},
+				ForwardHeaders: []string{"x-entitlements"},
+			}, "my-acp")
+			require.NoError(t, err)
+
+			req := decision.Track(httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			req.Header.Set("X-Forwarded-Method", http.MethodDelete)
+			req.Header.Set("X-Forwarded-Host", "api.example.com")
+			req.Header.Set("X-Forwarded-Uri", "/orders/42?force=true")
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("X-Tenant", "acme")
+			req.Header.Set("Cookie", "session=secret")
+
+			// Identity reported by a method evaluated before.
+			decision.SetSubject(req, "alice")
+			decision.SetClaims(req, map[string]interface{}{"plan": "gold"})
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Equal(t, test.wantHeader, rec.Header())
+			assert.Equal(t, test.wantReason, decision.ReasonOf(req))
+			assert.Equal(t, Request{
+				Method: http.MethodDelete,
+				Host:   "api.example.com",
+				Path:   "/orders/42",
+				Headers: map[string][]string{
+					"Authorization": {"Bearer token"},
+					"X-Tenant":      {"acme"},
+				},
+				Subject: "alice",
+				Claims:  map[string]interface{}{"plan": "gold"},
+			}, got)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_cachesDecisions(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+
+		var got Request
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if got.Headers["X-Tenant"][0] != "acme" {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		rw.Header().Set("X-Entitlements", "read")
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	handler, err := NewHandler(&Config{
+		Config:         noRetries(),
+		URL:            srv.URL,
+		RequestHeaders: []string{"X-Tenant"},
+		ForwardHeaders: []string{"X-Entitlements"},
+		Cache:          &CacheConfig{},
+	}, "my-acp")
+	require.NoError(t, err)
+
+	for i, tenant := range []string{"acme", "acme", "globex", "globex"} {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set("X-Tenant", tenant)
+		// Headers which are not sent to the service don't prevent cache hits.
+		req.Header.Set("X-Request-Id", strconv.Itoa(i))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if tenant == "acme" {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "read", rec.Header().Get("X-Entitlements"))
+			continue
+		}
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("X-Entitlements"))
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestHandler_ServeHTTP_sendsNoHeadersByDefault(t *testing.T) {
+	var got Request
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	handler, err := NewHandler(&Config{Config: noRetries(), URL: srv.URL}, "my-acp")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Tenant", "acme")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Request{Method: http.MethodGet, Host: "example.com", Path: "/"}, got)
+}
+
+func TestHandler_ServeHTTP_ignoresDeniedMethodIdentity(t *testing.T) {
+	var got Request
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if got.Subject != "admin" {
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	basicAuth, err := basicauth.NewHandler(&basicauth.Config{
+		Users: []string{"admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+	}, "my-acp", nil)
+	require.NoError(t, err)
+
+	externalAuthz, err := NewHandler(&Config{Config: noRetries(), URL: srv.URL}, "my-acp")
+	require.NoError(t, err)
+
+	anyOf, err := composite.NewHandler(composite.AnyOf, []http.Handler{basicAuth, externalAuthz}, "my-acp")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.SetBasicAuth("admin", "wrong-password")
+
+	rec := httptest.NewRecorder()
+	anyOf.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Empty(t, got.Subject)
+	assert.Empty(t, got.Claims)
+}
+
+func noRetries() httpclient.Config {
+	return httpclient.Config{MaxRetries: optional.NewInt(0)}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ipallowlist
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+)
+
+// Config configures an IP allow list ACP handler.
+type Config struct {
+	// SourceRanges are the IPs and CIDRs allowed to access resources. All IPs are allowed if empty.
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+	// DeniedRanges are the IPs and CIDRs denied access to resources, even if they are in SourceRanges.
+	DeniedRanges []string `json:"deniedRanges,omitempty"`
+	// TrustedProxyDepth is the number of trusted proxies in front of the ingress controller. When set, the client IP
+	// is read from the X-Forwarded-For header, skipping the entries added by these proxies.
+	TrustedProxyDepth int `json:"trustedProxyDepth,omitempty"`
+}
+
+// Handler is an IP allow list ACP Handler.
+type Handler struct {
+	name string
+
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+	depth   int
+}
+
+// NewHandler creates a new IP allow list ACP Handler.
+func NewHandler(cfg *Config, name string) (*Handler, error) {
+	if len(cfg.SourceRanges) == 0 && len(cfg.DeniedRanges) == 0 {
+		return nil, errors.New(`at least one of "sourceRanges" or "deniedRanges" must be set`)
+	}
+
+	if cfg.TrustedProxyDepth < 0 {
+		return nil, errors.New("trusted proxy depth must not be negative")
+	}
+
+	allowed, err := expr.ParseIPRanges(cfg.SourceRanges)
+	if err != nil {
+		return nil, fmt.Errorf("parsing source ranges: %w", err)
+	}
+
+	denied, err := expr.ParseIPRanges(cfg.DeniedRanges)
+	if err != nil {
+		return nil, fmt.Errorf("parsing denied ranges: %w", err)
+	}
+
+	return &Handler{
+		name:    name,
+		allowed: allowed,
+		denied:  denied,
+		depth:   cfg.TrustedProxyDepth,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "IPAllowList").Str("handler_name", h.name).Logger()
+
+	ip := clientIP(req, h.depth)
+	if ip == nil {
+		l.Debug().Msg("Unable to get client IP")
+		decision.SetReason(req, decision.ReasonIPNotAllowed)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if contains(h.denied, ip) || (len(h.allowed) > 0 && !contains(h.allowed, ip)) {
+		l.Debug().Str("client_ip", ip.String()).Msg("Client IP is not allowed")
+		decision.SetReason(req, decision.ReasonIPNotAllowed)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// clientIP returns the IP of the client of the request. Without trusted proxies, it is the source IP of the request
+// received by the ingress controller. Otherwise, it is the entry of the X-Forwarded-For header preceding the ones added
+// by the given number of trusted proxies and by the ingress controller. It returns nil if the header doesn't have
+// enough entries, as the client IP cannot be trusted.
+func clientIP(req *http.Request, depth int) net.IP {
+	if depth == 0 {
+		return expr.SourceIP(req)
+	}
+
+	var entries []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		entries = append(entries, strings.Split(value, ",")...)
+	}
+
+	i := len(entries) - 1 - depth
+	if i < 0 {
+		return nil
+	}
+
+	return net.ParseIP(strings.TrimSpace(entries[i]))
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
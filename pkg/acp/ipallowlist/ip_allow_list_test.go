@@ -0,0 +1,193 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package ipallowlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			desc: "valid configuration",
+			cfg: Config{
+				SourceRanges:      []string{"10.0.0.0/8", "192.168.1.1"},
+				DeniedRanges:      []string{"10.0.0.1"},
+				TrustedProxyDepth: 1,
+			},
+		},
+		{
+			desc:    "no ranges",
+			cfg:     Config{},
+			wantErr: `at least one of "sourceRanges" or "deniedRanges" must be set`,
+		},
+		{
+			desc: "invalid source range",
+			cfg: Config{
+				SourceRanges: []string{"10.0.0.0/33"},
+			},
+			wantErr: `parsing source ranges: invalid CIDR "10.0.0.0/33": invalid CIDR address: 10.0.0.0/33`,
+		},
+		{
+			desc: "invalid denied range",
+			cfg: Config{
+				DeniedRanges: []string{"10.0.0"},
+			},
+			wantErr: `parsing denied ranges: invalid IP "10.0.0"`,
+		},
+		{
+			desc: "negative trusted proxy depth",
+			cfg: Config{
+				SourceRanges:      []string{"10.0.0.0/8"},
+				TrustedProxyDepth: -1,
+			},
+			wantErr: "trusted proxy depth must not be negative",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewHandler(&test.cfg, "my-acp")
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		cfg        Config
+		headers    map[string][]string
+		remoteAddr string
+		wantStatus int
+	}{
+		{
+			desc:       "allowed X-Real-Ip",
+			cfg:        Config{SourceRanges: []string{"10.0.0.0/8"}},
+			headers:    map[string][]string{"X-Real-Ip": {"10.1.2.3"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "not allowed X-Real-Ip",
+			cfg:        Config{SourceRanges: []string{"10.0.0.0/8"}},
+			headers:    map[string][]string{"X-Real-Ip": {"192.168.1.1"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc:       "closest X-Forwarded-For entry",
+			cfg:        Config{SourceRanges: []string{"10.0.0.0/8"}},
+			headers:    map[string][]string{"X-Forwarded-For": {"10.1.2.3, 192.168.1.1"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc:       "remote address",
+			cfg:        Config{SourceRanges: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.1.2.3:1234",
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "denied range within source ranges",
+			cfg: Config{
+				SourceRanges: []string{"10.0.0.0/8"},
+				DeniedRanges: []string{"10.1.0.0/16"},
+			},
+			headers:    map[string][]string{"X-Real-Ip": {"10.1.2.3"}},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc:       "only denied ranges",
+			cfg:        Config{DeniedRanges: []string{"10.1.0.0/16"}},
+			headers:    map[string][]string{"X-Real-Ip": {"192.168.1.1"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "trusted proxy depth",
+			cfg: Config{
+				SourceRanges:      []string{"10.0.0.0/8"},
+				TrustedProxyDepth: 1,
+			},
+			headers: map[string][]string{
+				"X-Real-Ip":       {"192.168.1.2"},
+				"X-Forwarded-For": {"192.168.1.1, 10.1.2.3", "192.168.1.2"},
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "trusted proxy depth ignores forged entries",
+			cfg: Config{
+				SourceRanges:      []string{"10.0.0.0/8"},
+				TrustedProxyDepth: 1,
+			},
+			headers: map[string][]string{
+				"X-Forwarded-For": {"10.1.2.3, 192.168.1.1, 192.168.1.2"},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc: "not enough X-Forwarded-For entries",
+			cfg: Config{
+				DeniedRanges:      []string{"10.0.0.0/8"},
+				TrustedProxyDepth: 2,
+			},
+			headers: map[string][]string{
+				"X-Forwarded-For": {"192.168.1.1, 192.168.1.2"},
+			},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			h, err := NewHandler(&test.cfg, "my-acp")
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			for name, values := range test.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantStatus, rw.Code)
+		})
+	}
+}
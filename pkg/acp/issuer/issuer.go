@@ -0,0 +1,214 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package issuer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+// Default values of the configuration.
+const (
+	DefaultHeader     = "X-Hub-Identity"
+	DefaultIssuer     = "hub-agent"
+	DefaultTTLSeconds = 60
+)
+
+// Config configures the identity tokens issued to upstream services for the requests granted by an ACP.
+type Config struct {
+	// KeySecret references the Secret holding, under the "key" entry, the PEM encoded private key signing tokens.
+	KeySecret SecretReference `json:"keySecret"`
+	// Header is the header tokens are forwarded in. Defaults to DefaultHeader.
+	Header string `json:"header,omitempty"`
+	// Issuer is the "iss" claim of tokens. Defaults to DefaultIssuer.
+	Issuer   string   `json:"issuer,omitempty"`
+	Audience []string `json:"audience,omitempty"`
+	// TTLSeconds is the lifetime of tokens. Defaults to DefaultTTLSeconds.
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+	// Claims are the names of the verified claims of the request, like the claims of a JWT, copied to tokens.
+	Claims []string `json:"claims,omitempty"`
+
+	// SigningKey holds the PEM encoded private key resolved from KeySecret.
+	SigningKey string `json:"-"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// ApplyDefaultValues applies default values on the given configuration.
+func (cfg *Config) ApplyDefaultValues() {
+	if cfg == nil {
+		return
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = DefaultHeader
+	}
+
+	if cfg.Issuer == "" {
+		cfg.Issuer = DefaultIssuer
+	}
+
+	if cfg.TTLSeconds == 0 {
+		cfg.TTLSeconds = DefaultTTLSeconds
+	}
+}
+
+// registeredClaims are the claims set by the Handler, which can't be copied from the claims of the request.
+var registeredClaims = map[string]struct{}{
+	"iss": {}, "sub": {}, "aud": {}, "exp": {}, "nbf": {}, "iat": {}, "jti": {}, "acp": {}, "groups": {},
+}
+
+// Handler issues an identity token for the requests granted by an ACP handler. The token is a JWT signed by the auth
+// server, holding the subject, groups and selected claims reported by the ACP handler, which upstream services can
+// trust, unlike forwarded headers.
+type Handler struct {
+	next     http.Handler
+	name     string
+	header   string
+	issuer   string
+	audience []string
+	ttl      time.Duration
+	claims   []string
+	signer   jose.Signer
+
+	now func() time.Time
+}
+
+// NewHandler creates a new Handler issuing tokens for the requests granted by the given ACP handler.
+func NewHandler(next http.Handler, cfg *Config, name string) (*Handler, error) {
+	cfg.ApplyDefaultValues()
+
+	if cfg.TTLSeconds < 0 {
+		return nil, errors.New("token TTL must not be negative")
+	}
+
+	key, err := ParseKey(cfg.SigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("new signer: %w", err)
+	}
+
+	return &Handler{
+		next:     next,
+		name:     name,
+		header:   cfg.Header,
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		ttl:      time.Duration(cfg.TTLSeconds) * time.Second,
+		claims:   cfg.Claims,
+		signer:   signer,
+		now:      time.Now,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	req = decision.Track(req)
+
+	err := decision.Decorate(rw, req, h.next, func(header http.Header) error {
+		tok, err := h.issue(decision.IdentityOf(req))
+		if err != nil {
+			return err
+		}
+
+		header.Set(h.header, tok)
+
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("acp_name", h.name).Msg("Unable to issue identity token")
+	}
+}
+
+// issue returns a signed token for the given identity.
+func (h *Handler) issue(id decision.Identity) (string, error) {
+	now := h.now()
+
+	claims := make(map[string]interface{}, len(h.claims)+7)
+	for _, name := range h.claims {
+		if _, ok := registeredClaims[name]; ok {
+			continue
+		}
+
+		if value, ok := id.Claims[name]; ok {
+			claims[name] = value
+		}
+	}
+
+	groups := id.Groups
+	if len(groups) == 0 {
+		groups = claimGroups(id.Claims)
+	}
+	if len(groups) > 0 {
+		claims["groups"] = groups
+	}
+
+	claims["acp"] = h.name
+
+	registered := jwt.Claims{
+		Issuer:    h.issuer,
+		Subject:   id.Subject,
+		Audience:  h.audience,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(h.ttl)),
+	}
+
+	return jwt.Signed(h.signer).Claims(registered).Claims(claims).CompactSerialize()
+}
+
+// claimGroups returns the groups held by the "groups" claim of the given claims, if any.
+func claimGroups(claims map[string]interface{}) []string {
+	switch groups := claims["groups"].(type) {
+	case []string:
+		return groups
+	case []interface{}:
+		var res []string
+		for _, group := range groups {
+			if s, ok := group.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	case string:
+		if groups != "" {
+			return []string{groups}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package issuer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/composite"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	signingKey := generateKey(t)
+
+	tests := []struct {
+		desc       string
+		next       http.HandlerFunc
+		wantStatus int
+		wantClaims map[string]interface{}
+	}{
+		{
+			desc: "granted request",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				decision.SetSubject(req, "alice")
+				decision.SetGroups(req, []string{"dev", "ops"})
+				decision.SetClaims(req, map[string]interface{}{
+					"email": "alice@example.com",
+					"phone": "+33 6 00 00 00 00",
+					"sub":   "spoofed",
+				})
+				rw.Header().Set("Email", "alice@example.com")
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			wantClaims: map[string]interface{}{
+				"iss":    "https://auth.example.com",
+				"sub":    "alice",
+				"aud":    "internal",
+				"iat":    float64(1672531200),
+				"nbf":    float64(1672531200),
+				"exp":    float64(1672531230),
+				"acp":    "my-acp",
+				"groups": []interface{}{"dev", "ops"},
+				"email":  "alice@example.com",
+			},
+		},
+		{
+			desc: "groups from the groups claim",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				decision.SetSubject(req, "alice")
+				decision.SetClaims(req, map[string]interface{}{
+					"groups": []interface{}{"dev"},
+				})
+			},
+			wantStatus: http.StatusOK,
+			wantClaims: map[string]interface{}{
+				"iss":    "https://auth.example.com",
+				"sub":    "alice",
+				"aud":    "internal",
+				"iat":    float64(1672531200),
+				"nbf":    float64(1672531200),
+				"exp":    float64(1672531230),
+				"acp":    "my-acp",
+				"groups": []interface{}{"dev"},
+			},
+		},
+		{
+			desc: "denied request",
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				decision.SetSubject(req, "alice")
+				rw.WriteHeader(http.StatusUnauthorized)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(test.next, &Config{
+				Issuer:     "https://auth.example.com",
+				Audience:   []string{"internal"},
+				TTLSeconds: 30,
+				Claims:     []string{"email", "sub"},
+				SigningKey: signingKey,
+			}, "my-acp")
+			require.NoError(t, err)
+			handler.now = func() time.Time { return time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC) }
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/my-acp", nil))
+
+			assert.Equal(t, test.wantStatus, rw.Code)
+
+			rawToken := rw.Header().Get(DefaultHeader)
+			if test.wantClaims == nil {
+				assert.Empty(t, rawToken)
+				return
+			}
+
+			assert.Equal(t, test.wantClaims, verify(t, signingKey, rawToken))
+		})
+	}
+}
+
+func TestHandler_KeepsResponse(t *testing.T) {
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Location", "https://idp.example.com")
+		rw.WriteHeader(http.StatusFound)
+		_, _ = rw.Write([]byte("redirecting"))
+	})
+
+	handler, err := NewHandler(next, &Config{SigningKey: generateKey(t)}, "my-acp")
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/my-acp", nil))
+
+	assert.Equal(t, http.StatusFound, rw.Code)
+	assert.Equal(t, "https://idp.example.com", rw.Header().Get("Location"))
+	assert.Equal(t, "redirecting", rw.Body.String())
+	assert.Empty(t, rw.Header().Get(DefaultHeader))
+}
+
+func TestHandler_ServeHTTP_ignoresFailedMethodIdentity(t *testing.T) {
+	signingKey := generateKey(t)
+
+	basicAuth, err := basicauth.NewHandler(&basicauth.Config{
+		Users: []string{"admin:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"},
+	}, "my-acp", nil)
+	require.NoError(t, err)
+
+	ipAllowList, err := ipallowlist.NewHandler(&ipallowlist.Config{SourceRanges: []string{"0.0.0.0/0"}}, "my-acp")
+	require.NoError(t, err)
+
+	anyOf, err := composite.NewHandler(composite.AnyOf, []http.Handler{basicAuth, ipAllowList}, "my-acp")
+	require.NoError(t, err)
+
+	handler, err := NewHandler(anyOf, &Config{SigningKey: signingKey}, "my-acp")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/my-acp", nil)
+	req.SetBasicAuth("admin", "wrong-password")
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+
+	claims := verify(t, signingKey, rw.Header().Get(DefaultHeader))
+	assert.NotContains(t, claims, "sub")
+}
+
+func TestNewHandler_InvalidKey(t *testing.T) {
+	_, err := NewHandler(http.NotFoundHandler(), &Config{SigningKey: "not a key"}, "my-acp")
+	assert.EqualError(t, err, "parse signing key: empty or ill-formatted private key")
+}
+
+func TestNewHandler_NegativeTTL(t *testing.T) {
+	_, err := NewHandler(http.NotFoundHandler(), &Config{SigningKey: generateKey(t), TTLSeconds: -1}, "my-acp")
+	assert.EqualError(t, err, "token TTL must not be negative")
+}
+
+func TestJWKSHandler(t *testing.T) {
+	key1, key2 := generateKey(t), generateKey(t)
+
+	handler, err := NewJWKSHandler([]string{key1, key2, key1})
+	require.NoError(t, err)
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var keySet jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &keySet))
+	require.Len(t, keySet.Keys, 2)
+
+	for _, pemKey := range []string{key1, key2} {
+		key, err := ParseKey(pemKey)
+		require.NoError(t, err)
+
+		published := keySet.Key(key.KeyID)
+		require.Len(t, published, 1)
+		assert.True(t, published[0].IsPublic())
+		assert.Equal(t, "ES256", published[0].Algorithm)
+	}
+
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, JWKSPath, nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+}
+
+// verify verifies the given token with the JWKS published for the given key and returns its claims.
+func verify(t *testing.T, pemKey, rawToken string) map[string]interface{} {
+	t.Helper()
+
+	jwks, err := NewJWKSHandler([]string{pemKey})
+	require.NoError(t, err)
+
+	var keySet jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(jwks.body, &keySet))
+
+	tok, err := jwt.ParseSigned(rawToken)
+	require.NoError(t, err)
+	require.Len(t, tok.Headers, 1)
+
+	claims := make(map[string]interface{})
+	require.NoError(t, tok.Claims(keySet, &claims))
+
+	return claims
+}
+
+func generateKey(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
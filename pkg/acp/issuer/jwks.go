@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package issuer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// JWKSPath is the path of the auth server publishing the public keys verifying the tokens it issues.
+const JWKSPath = "/.well-known/jwks.json"
+
+// ParseKey parses the given PEM encoded RSA, ECDSA or Ed25519 private key. The returned key is identified by its
+// SHA-256 thumbprint and holds the algorithm tokens are signed with.
+func ParseKey(pemKey string) (*jose.JSONWebKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("empty or ill-formatted private key")
+	}
+
+	key, err := parsePrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &jose.JSONWebKey{Key: key, Use: "sig"}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		jwk.Algorithm = string(jose.RS256)
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			jwk.Algorithm = string(jose.ES256)
+		case elliptic.P384():
+			jwk.Algorithm = string(jose.ES384)
+		case elliptic.P521():
+			jwk.Algorithm = string(jose.ES512)
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+	case ed25519.PrivateKey:
+		jwk.Algorithm = string(jose.EdDSA)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	public := jwk.Public()
+	thumbprint, err := public.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("compute key thumbprint: %w", err)
+	}
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	return jwk, nil
+}
+
+// parsePrivateKey parses a DER encoded private key, in the PKCS #8, PKCS #1 or SEC 1 format.
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported private key format")
+}
+
+// JWKSHandler publishes the public keys verifying the tokens issued by the auth server.
+type JWKSHandler struct {
+	body []byte
+}
+
+// NewJWKSHandler creates a new JWKSHandler publishing the public part of the given PEM encoded private keys. Keys
+// shared by several ACPs are published once.
+func NewJWKSHandler(pemKeys []string) (*JWKSHandler, error) {
+	keys := make(map[string]jose.JSONWebKey)
+	for _, pemKey := range pemKeys {
+		key, err := ParseKey(pemKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys[key.KeyID] = key.Public()
+	}
+
+	keySet := jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(keys))}
+	for _, key := range keys {
+		keySet.Keys = append(keySet.Keys, key)
+	}
+
+	// Sort keys to get a stable key set.
+	sort.Slice(keySet.Keys, func(i, j int) bool {
+		return keySet.Keys[i].KeyID < keySet.Keys[j].KeyID
+	})
+
+	body, err := json.Marshal(keySet)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key set: %w", err)
+	}
+
+	return &JWKSHandler{body: body}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *JWKSHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "max-age=300")
+
+	if _, err := rw.Write(h.body); err != nil {
+		log.Error().Err(err).Msg("Unable to write JWKS")
+	}
+}
@@ -25,10 +25,13 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-jose/go-jose/v3"
 	"github.com/pquerna/cachecontrol"
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
 	"github.com/traefik/hub-agent-kubernetes/pkg/version"
 )
 
@@ -158,20 +161,35 @@ func (k *FileKeySet) updateKeySet() error {
 }
 
 // RemoteKeySet resolves a key set based on a key set URL, and keeps it up to date.
+// The key set is refreshed in the background before it expires, as long as it is in use. Once expired, the last
+// successfully fetched key set keeps being served while a new one is fetched, for at most maxStale. Unknown key IDs
+// trigger a refetch, at most once every minRefetchInterval, to handle key rotations. Failed fetches are not retried
+// before minRefetchInterval either: until then, the last error is returned if there is no usable key set.
 type RemoteKeySet struct {
-	url string
-
-	mu       sync.RWMutex
-	keys     jose.JSONWebKeySet
-	expiry   time.Time
-	updating *inflight
-	client   *http.Client
+	url     string
+	acpName string
+	client  *http.Client
+
+	minRefetchInterval time.Duration
+	maxStale           time.Duration
+
+	// used reports whether the key set has been used since the last background refresh.
+	used atomic.Bool
+
+	mu        sync.RWMutex
+	keys      jose.JSONWebKeySet
+	expiry    time.Time
+	lastFetch time.Time
+	lastErr   error
+	updating  *inflight
+	timer     *time.Timer
 }
 
-// NewRemoteKeySet returns a RemoteKeySet.
-func NewRemoteKeySet(url string) *RemoteKeySet {
+// NewRemoteKeySet returns a RemoteKeySet. Its fetches are reported in the metrics of the given ACP.
+func NewRemoteKeySet(url, acpName string) *RemoteKeySet {
 	return &RemoteKeySet{
-		url: url,
+		url:     url,
+		acpName: acpName,
 		client: &http.Client{
 			Transport: &http.Transport{
 				DialContext: (&net.Dialer{
@@ -182,61 +200,136 @@ func NewRemoteKeySet(url string) *RemoteKeySet {
 			},
 			Timeout: 5 * time.Second,
 		},
+		minRefetchInterval: 30 * time.Second,
+		maxStale:           24 * time.Hour,
 	}
 }
 
 // Key returns a key for a given key ID.
 func (s *RemoteKeySet) Key(ctx context.Context, keyID string) (*jose.JSONWebKey, error) {
-	if err := s.updateKeySet(ctx); err != nil {
+	s.used.Store(true)
+
+	s.mu.RLock()
+	now := time.Now()
+	// A zero expiry means the key set has never been fetched.
+	usable := !s.expiry.IsZero() && now.Before(s.expiry.Add(s.maxStale))
+	// After a failed fetch, the background refresh is retried by the refresh timer instead.
+	refresh := now.After(s.expiry) && s.lastErr == nil
+	// Failed fetches are not retried on each request, to prevent them from hammering an unavailable key set URL.
+	backoff := s.lastErr != nil && now.Sub(s.lastFetch) < s.minRefetchInterval
+	lastErr := s.lastErr
+	s.mu.RUnlock()
+
+	switch {
+	case !usable && backoff:
+		return nil, lastErr
+	case !usable:
+		if err := s.refresh().Wait(ctx); err != nil {
+			return nil, err
+		}
+	case refresh:
+		// Serve the current key set while the new one is being fetched.
+		s.refresh()
+	}
+
+	if k := s.key(keyID); k != nil {
+		return k, nil
+	}
+
+	// The key set may have been rotated since the last fetch. Refetching is rate limited to prevent tokens with
+	// random key IDs from hammering the key set URL.
+	s.mu.RLock()
+	canRefetch := time.Since(s.lastFetch) >= s.minRefetchInterval
+	s.mu.RUnlock()
+
+	if !canRefetch {
+		return nil, nil
+	}
+
+	if err := s.refresh().Wait(ctx); err != nil {
 		return nil, err
 	}
 
+	return s.key(keyID), nil
+}
+
+func (s *RemoteKeySet) key(keyID string) *jose.JSONWebKey {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	keys := s.keys.Key(keyID)
 	if len(keys) == 0 {
-		return nil, nil
+		return nil
 	}
-	return &keys[0], nil
+	return &keys[0]
 }
 
-func (s *RemoteKeySet) updateKeySet(ctx context.Context) error {
-	if !s.isExpired() {
-		return nil
+// refresh fetches the key set, unless a fetch is already in progress. It returns the in-flight fetch.
+func (s *RemoteKeySet) refresh() *inflight {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.updating != nil {
+		return s.updating
 	}
 
-	s.mu.Lock()
-	if s.updating == nil {
-		s.updating = newInflight()
+	s.updating = newInflight()
 
-		go func() {
-			keySet, expiry, err := fetchKeys(ctx, s.client, s.url)
+	go func() {
+		// The fetch must not be bound to the request which triggered it, as other requests may wait for it.
+		keySet, expiry, err := fetchKeys(context.Background(), s.client, s.url)
+		metrics.ObserveJWKSFetch(s.acpName, err)
 
-			s.mu.Lock()
-			defer s.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
 
-			if err == nil {
-				s.keys = *keySet
-				s.expiry = expiry
-			}
+		s.lastFetch = time.Now()
+		s.lastErr = err
 
-			s.updating.Done(err)
-			s.updating = nil
-		}()
-	}
+		if err == nil {
+			s.keys = *keySet
+			s.expiry = expiry
+		} else if !s.expiry.IsZero() {
+			log.Warn().Err(err).Str("url", s.url).Msg("Unable to refresh JWK set, serving the last known one")
+		}
 
-	updating := s.updating
-	s.mu.Unlock()
+		s.scheduleRefresh(err)
 
-	return updating.Wait(ctx)
+		s.updating.Done(err)
+		s.updating = nil
+	}()
+
+	return s.updating
 }
 
-func (s *RemoteKeySet) isExpired() bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// scheduleRefresh schedules the next background refresh of the key set, ahead of its expiry or, if the last fetch
+// failed, after minRefetchInterval. Key sets which can't be cached are only refreshed on demand.
+// It must be called with the lock held.
+func (s *RemoteKeySet) scheduleRefresh(fetchErr error) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	ttl := s.expiry.Sub(s.lastFetch)
+	if fetchErr == nil && ttl <= 0 {
+		return
+	}
+
+	// Refresh once 80% of the key set lifetime has elapsed.
+	delay := ttl * 4 / 5
+	if fetchErr != nil || delay < s.minRefetchInterval {
+		delay = s.minRefetchInterval
+	}
+
+	s.timer = time.AfterFunc(delay, func() {
+		// Stop refreshing key sets which are not used anymore, typically because their handler has been replaced.
+		if !s.used.Swap(false) {
+			return
+		}
 
-	return time.Now().After(s.expiry)
+		s.refresh()
+	})
 }
 
 func fetchKeys(ctx context.Context, client *http.Client, url string) (*jose.JSONWebKeySet, time.Time, error) {
@@ -20,9 +20,12 @@ package jwt_test
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-jose/go-jose/v3"
 	"github.com/stretchr/testify/assert"
@@ -108,7 +111,7 @@ func TestRemoteKeySet_KeysFetchesKeySet(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(hdlr))
 	defer srv.Close()
 
-	ks := jwt.NewRemoteKeySet(srv.URL)
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
 
 	gotFooKey, err := ks.Key(context.Background(), "foo-key")
 	require.NoError(t, err)
@@ -126,9 +129,9 @@ func TestRemoteKeySet_KeysFetchesKeySetWithoutCache(t *testing.T) {
 	err := json.Unmarshal([]byte(jwkeys), &wantKeys)
 	require.NoError(t, err)
 
-	var hdlrCalled int
+	var hdlrCalled atomic.Int32
 	hdlr := func(rw http.ResponseWriter, req *http.Request) {
-		hdlrCalled++
+		hdlrCalled.Add(1)
 
 		_, _ = rw.Write([]byte(jwkeys))
 	}
@@ -136,15 +139,16 @@ func TestRemoteKeySet_KeysFetchesKeySetWithoutCache(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(hdlr))
 	defer srv.Close()
 
-	ks := jwt.NewRemoteKeySet(srv.URL)
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
 
 	gotFooKey, err := ks.Key(context.Background(), "foo-key")
 	require.NoError(t, err)
 
+	// The key set is already expired: it is served while being refreshed in the background.
 	gotBarKey, err := ks.Key(context.Background(), "bar-key")
 	require.NoError(t, err)
 
-	assert.Equal(t, 2, hdlrCalled)
+	assert.Eventually(t, func() bool { return hdlrCalled.Load() == 2 }, time.Second, 10*time.Millisecond)
 	assert.Equal(t, wantKeys.Key("foo-key")[0], *gotFooKey)
 	assert.Equal(t, wantKeys.Key("bar-key")[0], *gotBarKey)
 }
@@ -158,7 +162,7 @@ func TestRemoteKeySet_KeysReturnsNilWhenKeyIsUnknown(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(hdlr))
 	defer srv.Close()
 
-	ks := jwt.NewRemoteKeySet(srv.URL)
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
 
 	gotKey, err := ks.Key(context.Background(), "meh-key")
 	require.NoError(t, err)
@@ -166,6 +170,89 @@ func TestRemoteKeySet_KeysReturnsNilWhenKeyIsUnknown(t *testing.T) {
 	assert.Nil(t, gotKey)
 }
 
+func TestRemoteKeySet_ServesStaleKeySetWhenRefreshFails(t *testing.T) {
+	var wantKeys jose.JSONWebKeySet
+	err := json.Unmarshal([]byte(jwkeys), &wantKeys)
+	require.NoError(t, err)
+
+	var hdlrCalled atomic.Int32
+	hdlr := func(rw http.ResponseWriter, req *http.Request) {
+		if hdlrCalled.Add(1) > 1 {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = rw.Write([]byte(jwkeys))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(hdlr))
+	defer srv.Close()
+
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
+
+	_, err = ks.Key(context.Background(), "foo-key")
+	require.NoError(t, err)
+
+	// Triggers a refresh, which fails.
+	_, err = ks.Key(context.Background(), "foo-key")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return hdlrCalled.Load() == 2 }, time.Second, 10*time.Millisecond)
+
+	// The last known key set is still served, and the failed refresh is not retried on each request.
+	for i := 0; i < 5; i++ {
+		gotKey, err := ks.Key(context.Background(), "foo-key")
+		require.NoError(t, err)
+		assert.Equal(t, wantKeys.Key("foo-key")[0], *gotKey)
+	}
+	assert.Equal(t, int32(2), hdlrCalled.Load())
+}
+
+func TestRemoteKeySet_BacksOffAfterFailedFetch(t *testing.T) {
+	var hdlrCalled atomic.Int32
+	hdlr := func(rw http.ResponseWriter, req *http.Request) {
+		hdlrCalled.Add(1)
+
+		rw.WriteHeader(http.StatusInternalServerError)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(hdlr))
+	defer srv.Close()
+
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
+
+	// The key set has never been fetched: the last error is returned until the fetch can be retried.
+	for i := 0; i < 5; i++ {
+		gotKey, err := ks.Key(context.Background(), "foo-key")
+		assert.EqualError(t, err, `unexpected status code "500 Internal Server Error"`)
+		assert.Nil(t, gotKey)
+	}
+
+	assert.Equal(t, int32(1), hdlrCalled.Load())
+}
+
+func TestRemoteKeySet_RateLimitsUnknownKeyRefetches(t *testing.T) {
+	var hdlrCalled atomic.Int32
+	hdlr := func(rw http.ResponseWriter, req *http.Request) {
+		hdlrCalled.Add(1)
+
+		rw.Header().Add("Cache-Control", "max-age=600")
+		_, _ = rw.Write([]byte(jwkeys))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(hdlr))
+	defer srv.Close()
+
+	ks := jwt.NewRemoteKeySet(srv.URL, "my-acp")
+
+	for i := 0; i < 5; i++ {
+		gotKey, err := ks.Key(context.Background(), fmt.Sprintf("unknown-key-%d", i))
+		require.NoError(t, err)
+		assert.Nil(t, gotKey)
+	}
+
+	assert.Equal(t, int32(1), hdlrCalled.Load())
+}
+
 const jwkeys = `
 {
   "keys": [
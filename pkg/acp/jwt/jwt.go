@@ -21,6 +21,7 @@ import (
 	"context"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
@@ -28,10 +29,14 @@ import (
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-jose/go-jose/v3"
 	"github.com/golang-jwt/jwt/v4"
 	jwtreq "github.com/golang-jwt/jwt/v4/request"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
 )
 
@@ -46,9 +51,105 @@ type Config struct {
 	ForwardHeaders             map[string]string `json:"forwardHeaders,omitempty"`
 	TokenQueryKey              string            `json:"tokenQueryKey,omitempty"`
 	Claims                     string            `json:"claims,omitempty"`
+	GroupsClaim                string            `json:"groupsClaim,omitempty"`
+	Issuer                     string            `json:"issuer,omitempty"`
+	Audience                   []string          `json:"audience,omitempty"`
+	Algorithms                 []string          `json:"algorithms,omitempty"`
+	LeewaySeconds              int               `json:"leewaySeconds,omitempty"`
+	DecryptionKeySecret        *SecretReference  `json:"decryptionKeySecret,omitempty"`
+
+	// DecryptionKey and DecryptionJWKs hold the decryption keys resolved from DecryptionKeySecret.
+	// DecryptionKey is a PEM encoded private key and DecryptionJWKs a JWK set holding private keys.
+	DecryptionKey  string `json:"-"`
+	DecryptionJWKs string `json:"-"`
 }
 
-func (cfg *Config) keySet() (KeySet, error) {
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// supportedAlgorithms are the signing algorithms a JWT handler is able to verify.
+var supportedAlgorithms = []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// ValidateAlgorithms makes sure all the given algorithms are supported.
+func ValidateAlgorithms(algs []string) error {
+	for _, alg := range algs {
+		var found bool
+		for _, supported := range supportedAlgorithms {
+			if alg == supported {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("unsupported algorithm %q, must be one of %s", alg, strings.Join(supportedAlgorithms, ", "))
+		}
+	}
+
+	return nil
+}
+
+// decryptionKeys returns the private keys used to decrypt JWE tokens.
+func (cfg *Config) decryptionKeys() ([]jose.JSONWebKey, error) {
+	switch {
+	case cfg.DecryptionKey != "":
+		block, _ := pem.Decode([]byte(cfg.DecryptionKey))
+		if block == nil {
+			return nil, errors.New("empty or ill-formatted private key")
+		}
+
+		key, err := parsePrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse private key: %w", err)
+		}
+
+		return []jose.JSONWebKey{{Key: key}}, nil
+
+	case cfg.DecryptionJWKs != "":
+		var keySet jose.JSONWebKeySet
+		if err := json.Unmarshal([]byte(cfg.DecryptionJWKs), &keySet); err != nil {
+			return nil, fmt.Errorf("unable to decode JWK set: %w", err)
+		}
+
+		var keys []jose.JSONWebKey
+		for _, key := range keySet.Keys {
+			if !key.IsPublic() {
+				keys = append(keys, key)
+			}
+		}
+
+		if len(keys) == 0 {
+			return nil, errors.New("no private key found in JWK set")
+		}
+
+		return keys, nil
+	}
+
+	return nil, nil
+}
+
+// parsePrivateKey parses a DER encoded private key, in the PKCS #8, PKCS #1 or SEC 1 format.
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unsupported private key format")
+}
+
+func (cfg *Config) keySet(polName string) (KeySet, error) {
 	if cfg == nil {
 		return nil, nil
 	}
@@ -66,7 +167,7 @@ func (cfg *Config) keySet() (KeySet, error) {
 	}
 
 	if cfg.JWKsURL != "" && !strings.HasPrefix(cfg.JWKsURL, "/") {
-		return NewRemoteKeySet(cfg.JWKsURL), nil
+		return NewRemoteKeySet(cfg.JWKsURL, polName), nil
 	}
 
 	return nil, nil
@@ -91,7 +192,20 @@ type Handler struct {
 	stripAuthorization bool
 	fwdHeaders         map[string]string
 
+	issuer     string
+	audience   []string
+	algorithms []string
+	leeway     time.Duration
+
+	// decryptionKeys are the private keys used to decrypt JWE tokens.
+	decryptionKeys []jose.JSONWebKey
+
 	validateCustomClaims expr.Predicate
+	// groupsClaim is the claim holding the groups of the token bearer, checked against the groups required by the
+	// request. Groups are not checked if it is empty.
+	groupsClaim string
+
+	now func() time.Time
 }
 
 // NewHandler returns a new JWT ACP Handler.
@@ -100,6 +214,14 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		return nil, errors.New("at least a signing secret, public key or a JWKs file or URL is required")
 	}
 
+	if cfg.LeewaySeconds < 0 {
+		return nil, errors.New("leeway must not be negative")
+	}
+
+	if err := ValidateAlgorithms(cfg.Algorithms); err != nil {
+		return nil, err
+	}
+
 	var (
 		pred expr.Predicate
 		err  error
@@ -139,11 +261,16 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		tokenQueryKey = cfg.TokenQueryKey
 	}
 
-	ks, err := cfg.keySet()
+	ks, err := cfg.keySet(polName)
 	if err != nil {
 		return nil, err
 	}
 
+	decryptionKeys, err := cfg.decryptionKeys()
+	if err != nil {
+		return nil, fmt.Errorf("decryption keys: %w", err)
+	}
+
 	return &Handler{
 		name:                 polName,
 		signingSecret:        signingSecret,
@@ -154,16 +281,27 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		stripAuthorization:   cfg.StripAuthorizationHeader,
 		fwdHeaders:           cfg.ForwardHeaders,
 		tokQryKey:            tokenQueryKey,
+		issuer:               cfg.Issuer,
+		audience:             cfg.Audience,
+		algorithms:           cfg.Algorithms,
+		leeway:               time.Duration(cfg.LeewaySeconds) * time.Second,
+		decryptionKeys:       decryptionKeys,
 		validateCustomClaims: pred,
+		groupsClaim:          cfg.GroupsClaim,
+		now:                  time.Now,
 	}, nil
 }
 
 func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	l := log.With().Str("handler_type", "JWT").Str("handler_name", h.name).Logger()
 
-	extractor := jwtExtractor{tokQryKey: h.tokQryKey}
-	p := &jwt.Parser{UseJSONNumber: true}
+	extractor := jwtExtractor{tokQryKey: h.tokQryKey, decrypt: h.decrypt}
+	// Standard claims are validated by the handler itself, to take the issuer, audience and leeway into account.
+	p := &jwt.Parser{UseJSONNumber: true, ValidMethods: h.algorithms, SkipClaimsValidation: true}
 	tok, err := jwtreq.ParseFromRequest(req, extractor, h.keyFunc(req.Context()), jwtreq.WithParser(p))
+	if err == nil {
+		err = h.validateClaims(tok.Claims.(jwt.MapClaims))
+	}
 	if err != nil {
 		var jwtErr *jwt.ValidationError
 		if errors.As(err, &jwtErr) && jwtErr.Errors&jwt.ValidationErrorUnverifiable != 0 {
@@ -172,20 +310,48 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			l.Error().Err(err).Msg("Unable to parse JWT")
 		}
 
+		reason := parseErrorReason(err)
+		decision.SetReason(req, reason)
+		denial.SetBearerChallenge(rw, reason)
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
+	claims := tok.Claims.(jwt.MapClaims)
+
 	if h.validateCustomClaims != nil {
-		if !h.validateCustomClaims(tok.Claims.(jwt.MapClaims)) {
+		if !h.validateCustomClaims(claims, req) {
+			decision.SetReason(req, decision.ReasonClaimsMismatch)
+			denial.SetBearerChallenge(rw, decision.ReasonClaimsMismatch)
 			rw.WriteHeader(http.StatusForbidden)
 			return
 		}
 	}
 
-	hdrs, err := expr.PluckClaims(h.fwdHeaders, tok.Claims.(jwt.MapClaims))
+	var groups []string
+	if h.groupsClaim != "" {
+		var match bool
+		groups, match, err = expr.MatchGroups(req, h.groupsClaim, claims)
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to match groups")
+			decision.SetReason(req, decision.ReasonInternalError)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !match {
+			l.Debug().Strs("user_groups", groups).Msg("User is not in the required groups")
+			decision.SetReason(req, decision.ReasonGroupsMismatch)
+			denial.SetBearerChallenge(rw, decision.ReasonGroupsMismatch)
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	hdrs, err := expr.PluckClaims(h.fwdHeaders, claims)
 	if err != nil {
 		l.Error().Err(err).Msg("Unable to set forwarded header")
+		decision.SetReason(req, decision.ReasonInternalError)
 		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -200,9 +366,80 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		rw.Header().Add("Authorization", "")
 	}
 
+	if sub, ok := claims["sub"].(string); ok {
+		decision.SetSubject(req, sub)
+	}
+	decision.SetGroups(req, groups)
+	decision.SetClaims(req, claims)
+
 	rw.WriteHeader(http.StatusOK)
 }
 
+// validateClaims validates the registered claims of the given JWT: its time bounds, tolerating the configured leeway,
+// and its issuer and audience if configured.
+func (h *Handler) validateClaims(claims jwt.MapClaims) error {
+	now := h.now().Unix()
+	leeway := int64(h.leeway.Seconds())
+
+	var errs uint32
+	var msgs []string
+	if !claims.VerifyExpiresAt(now-leeway, false) {
+		errs |= jwt.ValidationErrorExpired
+		msgs = append(msgs, "token is expired")
+	}
+
+	if !claims.VerifyNotBefore(now+leeway, false) {
+		errs |= jwt.ValidationErrorNotValidYet
+		msgs = append(msgs, "token is not valid yet")
+	}
+
+	if !claims.VerifyIssuedAt(now+leeway, false) {
+		errs |= jwt.ValidationErrorIssuedAt
+		msgs = append(msgs, "token used before issued")
+	}
+
+	if h.issuer != "" && !claims.VerifyIssuer(h.issuer, true) {
+		errs |= jwt.ValidationErrorIssuer
+		msgs = append(msgs, "token has an invalid issuer")
+	}
+
+	if len(h.audience) > 0 && !verifyAudience(claims, h.audience) {
+		errs |= jwt.ValidationErrorAudience
+		msgs = append(msgs, "token has an invalid audience")
+	}
+
+	if errs == 0 {
+		return nil
+	}
+
+	return jwt.NewValidationError(strings.Join(msgs, ", "), errs)
+}
+
+// verifyAudience returns whether the given claims are intended for at least one of the given audiences.
+func verifyAudience(claims jwt.MapClaims, audience []string) bool {
+	for _, aud := range audience {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseErrorReason returns the reason of a denial caused by the given JWT parsing error.
+func parseErrorReason(err error) decision.Reason {
+	if errors.Is(err, errNoJWT) {
+		return decision.ReasonMissingCredentials
+	}
+
+	var jwtErr *jwt.ValidationError
+	if errors.As(err, &jwtErr) && jwtErr.Errors&(jwt.ValidationErrorExpired|jwt.ValidationErrorNotValidYet) != 0 {
+		return decision.ReasonExpiredCredentials
+	}
+
+	return decision.ReasonInvalidCredentials
+}
+
 // keyFunc returns a function to find the correct key to validate its given JWT's signature.
 func (h *Handler) keyFunc(ctx context.Context) jwt.Keyfunc {
 	return func(tok *jwt.Token) (key interface{}, err error) {
@@ -294,7 +531,7 @@ func (h *Handler) remoteKeySet(iss string) (*RemoteKeySet, error) {
 	h.dynKeySetsMu.Lock()
 	rks = h.dynKeySets[ksURL]
 	if rks == nil {
-		rks = NewRemoteKeySet(ksURL)
+		rks = NewRemoteKeySet(ksURL, h.name)
 		h.dynKeySets[ksURL] = rks
 	}
 	h.dynKeySetsMu.Unlock()
@@ -302,9 +539,39 @@ func (h *Handler) remoteKeySet(iss string) (*RemoteKeySet, error) {
 	return rks, nil
 }
 
+// decrypt decrypts the given JWE token in the compact serialization format and returns its payload, which is expected
+// to be a signed JWT.
+func (h *Handler) decrypt(rawJWE string) (string, error) {
+	if len(h.decryptionKeys) == 0 {
+		return "", errors.New("encrypted JWT found but no decryption key configured")
+	}
+
+	enc, err := jose.ParseEncrypted(rawJWE)
+	if err != nil {
+		return "", fmt.Errorf("parse encrypted JWT: %w", err)
+	}
+
+	for _, key := range h.decryptionKeys {
+		if enc.Header.KeyID != "" && key.KeyID != "" && enc.Header.KeyID != key.KeyID {
+			continue
+		}
+
+		payload, decryptErr := enc.Decrypt(key.Key)
+		if decryptErr == nil {
+			return string(payload), nil
+		}
+	}
+
+	return "", errors.New("unable to decrypt JWT with any of the configured keys")
+}
+
+var errNoJWT = errors.New("no JWT found in request")
+
 // jwtExtractor extracts JWTs from HTTP requests.
 type jwtExtractor struct {
 	tokQryKey string
+	// decrypt, if set, is used to decrypt JWE tokens in the compact serialization format.
+	decrypt func(rawJWE string) (string, error)
 }
 
 // ExtractToken extracts a JWT from an HTTP request. It first looks in the "Authorization" header then in a query parameter
@@ -316,7 +583,12 @@ func (j jwtExtractor) ExtractToken(req *http.Request) (string, error) {
 	}
 
 	if rawJWT == "" {
-		return "", errors.New("no JWT found in request")
+		return "", errNoJWT
+	}
+
+	// Compact JWEs have five parts whereas JWSs have three.
+	if j.decrypt != nil && strings.Count(rawJWT, ".") == 4 {
+		return j.decrypt(rawJWT)
 	}
 
 	return rawJWT, nil
@@ -19,11 +19,18 @@ package jwt
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,6 +38,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
 )
 
 const (
@@ -84,6 +92,26 @@ func TestNew(t *testing.T) {
 			jwtCfg:  Config{JWKsURL: "http://example.com"},
 			wantErr: assert.NoError,
 		},
+		{
+			name:    "allowed algorithms",
+			jwtCfg:  Config{SigningSecret: "foobar", Algorithms: []string{"HS256", "RS256"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "unsupported algorithm",
+			jwtCfg:  Config{SigningSecret: "foobar", Algorithms: []string{"none"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "invalid decryption key",
+			jwtCfg:  Config{SigningSecret: "foobar", DecryptionKey: validPubKey},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "negative leeway",
+			jwtCfg:  Config{SigningSecret: "foobar", LeewaySeconds: -1},
+			wantErr: assert.Error,
+		},
 	}
 
 	for _, test := range tests {
@@ -111,6 +139,7 @@ func TestServeHTTP(t *testing.T) {
 			jwtCfg:         Config{SigningSecret: "bibi"},
 			token:          "",
 			wantStatusCode: http.StatusUnauthorized,
+			wantHeader:     http.Header{"Www-Authenticate": []string{"Bearer"}},
 		},
 		{
 			name:           "token is valid",
@@ -123,6 +152,7 @@ func TestServeHTTP(t *testing.T) {
 			jwtCfg:         Config{SigningSecret: "bibi"},
 			token:          expiredJWT,
 			wantStatusCode: http.StatusUnauthorized,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="invalid_token", error_description="The access token is expired or not valid yet"`}},
 		},
 		{
 			name: "token is not for required group",
@@ -132,6 +162,7 @@ func TestServeHTTP(t *testing.T) {
 			},
 			token:          missingGroupJWT,
 			wantStatusCode: http.StatusForbidden,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`}},
 		},
 		{
 			name: "claims not equal",
@@ -150,6 +181,26 @@ func TestServeHTTP(t *testing.T) {
 			},
 			token:          validJWTWithNestedClaim,
 			wantStatusCode: http.StatusForbidden,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`}},
+		},
+		{
+			name: "request does not match the method required for the group",
+			jwtCfg: Config{
+				SigningSecret: "bibi",
+				Claims:        "Method(`GET`) || Equals(`grp`, `admin`)",
+			},
+			token:          missingGroupJWT,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "request matches the path required for the group",
+			jwtCfg: Config{
+				SigningSecret: "bibi",
+				Claims:        "!PathPrefix(`/`) || Equals(`grp`, `admin`)",
+			},
+			token:          missingGroupJWT,
+			wantStatusCode: http.StatusForbidden,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`}},
 		},
 		{
 			name: "group header is forwarded",
@@ -166,6 +217,7 @@ func TestServeHTTP(t *testing.T) {
 			jwtCfg:         Config{JWKsURL: "/.well-known/jwks.json"},
 			token:          validJWT,
 			wantStatusCode: http.StatusUnauthorized,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="invalid_token", error_description="The access token is invalid"`}},
 		},
 		{
 			name: "nested header is forwarded (and header is canonicalized)",
@@ -214,6 +266,349 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+func TestServeHTTP_groups(t *testing.T) {
+	tests := []struct {
+		desc   string
+		groups string
+
+		wantStatusCode int
+		wantHeader     http.Header
+		wantGroups     []string
+	}{
+		{
+			desc:           "no groups required",
+			wantStatusCode: http.StatusOK,
+			wantGroups:     []string{"admin"},
+		},
+		{
+			desc:           "in one of the required groups",
+			groups:         "dev,admin",
+			wantStatusCode: http.StatusOK,
+			wantGroups:     []string{"admin"},
+		},
+		{
+			desc:           "not in the required groups",
+			groups:         "dev",
+			wantStatusCode: http.StatusForbidden,
+			wantHeader:     http.Header{"Www-Authenticate": []string{`Bearer error="insufficient_scope", error_description="The access token does not grant access to this resource"`}},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&Config{SigningSecret: "bibi", GroupsClaim: "grp"}, "acp@my-ns")
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := decision.Track(httptest.NewRequest(http.MethodGet, "/?groups="+url.QueryEscape(test.groups), http.NoBody))
+			req.Header.Set("Authorization", "Bearer "+validJWT)
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Code)
+			assert.Equal(t, len(test.wantHeader), len(rec.Header()))
+			for k := range test.wantHeader {
+				assert.Equal(t, test.wantHeader[k], rec.Header()[k])
+			}
+			assert.Equal(t, test.wantGroups, decision.IdentityOf(req).Groups)
+		})
+	}
+}
+
+func TestServeHTTP_registeredClaims(t *testing.T) {
+	now := time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		desc           string
+		jwtCfg         Config
+		claims         jwt.MapClaims
+		wantStatusCode int
+	}{
+		{
+			desc:           "expired token",
+			jwtCfg:         Config{SigningSecret: "secret"},
+			claims:         jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "expired token within leeway",
+			jwtCfg:         Config{SigningSecret: "secret", LeewaySeconds: 30},
+			claims:         jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "token not valid yet",
+			jwtCfg:         Config{SigningSecret: "secret"},
+			claims:         jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "token not valid yet within leeway",
+			jwtCfg:         Config{SigningSecret: "secret", LeewaySeconds: 30},
+			claims:         jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix(), "iat": now.Add(10 * time.Second).Unix()},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "token issued in the future",
+			jwtCfg:         Config{SigningSecret: "secret"},
+			claims:         jwt.MapClaims{"iat": now.Add(10 * time.Second).Unix()},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "valid issuer",
+			jwtCfg:         Config{SigningSecret: "secret", Issuer: "https://idp.example.com"},
+			claims:         jwt.MapClaims{"iss": "https://idp.example.com"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "invalid issuer",
+			jwtCfg:         Config{SigningSecret: "secret", Issuer: "https://idp.example.com"},
+			claims:         jwt.MapClaims{"iss": "https://evil.example.com"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "missing issuer",
+			jwtCfg:         Config{SigningSecret: "secret", Issuer: "https://idp.example.com"},
+			claims:         jwt.MapClaims{},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "valid audience",
+			jwtCfg:         Config{SigningSecret: "secret", Audience: []string{"api", "admin"}},
+			claims:         jwt.MapClaims{"aud": "admin"},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "valid audience among several",
+			jwtCfg:         Config{SigningSecret: "secret", Audience: []string{"api"}},
+			claims:         jwt.MapClaims{"aud": []string{"other", "api"}},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "invalid audience",
+			jwtCfg:         Config{SigningSecret: "secret", Audience: []string{"api"}},
+			claims:         jwt.MapClaims{"aud": "other"},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "missing audience",
+			jwtCfg:         Config{SigningSecret: "secret", Audience: []string{"api"}},
+			claims:         jwt.MapClaims{},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "allowed algorithm",
+			jwtCfg:         Config{SigningSecret: "secret", Algorithms: []string{"HS256"}},
+			claims:         jwt.MapClaims{},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "disallowed algorithm",
+			jwtCfg:         Config{SigningSecret: "secret", Algorithms: []string{"RS256"}},
+			claims:         jwt.MapClaims{},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "disallowed algorithm with the same family",
+			jwtCfg:         Config{SigningSecret: "secret", Algorithms: []string{"HS512"}},
+			claims:         jwt.MapClaims{},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&test.jwtCfg, "acp@my-ns")
+			require.NoError(t, err)
+			handler.now = func() time.Time { return now }
+
+			tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, test.claims).SignedString([]byte("secret"))
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+tok)
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Code)
+		})
+	}
+}
+
+func TestServeHTTP_keyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var rotated atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		keySet := jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{Key: oldKey.Public(), KeyID: "old", Algorithm: "RS256", Use: "sig"}},
+		}
+		if rotated.Load() {
+			keySet.Keys = append(keySet.Keys, jose.JSONWebKey{Key: newKey.Public(), KeyID: "new", Algorithm: "RS256", Use: "sig"})
+		}
+
+		rw.Header().Set("Cache-Control", "max-age=600")
+		_ = json.NewEncoder(rw).Encode(keySet)
+	}))
+	defer srv.Close()
+
+	handler, err := NewHandler(&Config{JWKsURL: srv.URL}, "acp@my-ns")
+	require.NoError(t, err)
+
+	serve := func(key *rsa.PrivateKey, kid string) int {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "john"})
+		tok.Header["kid"] = kid
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+signed)
+
+		handler.ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(oldKey, "old"))
+
+	rotated.Store(true)
+
+	// The key set has just been fetched: refetching it is rate limited.
+	assert.Equal(t, http.StatusUnauthorized, serve(newKey, "new"))
+
+	handler.keySet.(*RemoteKeySet).minRefetchInterval = 0
+
+	assert.Equal(t, http.StatusOK, serve(newKey, "new"))
+	assert.Equal(t, http.StatusOK, serve(oldKey, "old"))
+}
+
+func TestServeHTTP_encryptedJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	rsaKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+
+	jwks, err := json.Marshal(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{
+			{Key: otherKey, KeyID: "other", Use: "enc"},
+			{Key: ecKey, KeyID: "ec", Use: "enc"},
+		},
+	})
+	require.NoError(t, err)
+
+	publicJWKs, err := json.Marshal(jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{Key: otherKey.Public(), KeyID: "other", Use: "enc"}},
+	})
+	require.NoError(t, err)
+
+	_, err = NewHandler(&Config{SigningSecret: "secret", DecryptionJWKs: string(publicJWKs)}, "acp@my-ns")
+	assert.EqualError(t, err, "decryption keys: no private key found in JWK set")
+
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"grp": "admin"}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	encrypt := func(t *testing.T, alg jose.KeyAlgorithm, key interface{}, kid string) string {
+		t.Helper()
+
+		opts := (&jose.EncrypterOptions{}).WithContentType("JWT")
+		enc, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: key, KeyID: kid}, opts)
+		require.NoError(t, err)
+
+		obj, err := enc.Encrypt([]byte(signed))
+		require.NoError(t, err)
+
+		raw, err := obj.CompactSerialize()
+		require.NoError(t, err)
+
+		return raw
+	}
+
+	tests := []struct {
+		desc           string
+		jwtCfg         Config
+		token          string
+		wantStatusCode int
+		wantHeader     http.Header
+	}{
+		{
+			desc:           "decrypted with a PEM encoded private key",
+			jwtCfg:         Config{SigningSecret: "secret", DecryptionKey: string(rsaKeyPEM)},
+			token:          encrypt(t, jose.RSA_OAEP_256, &rsaKey.PublicKey, ""),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "decrypted with a key of a JWK set",
+			jwtCfg:         Config{SigningSecret: "secret", DecryptionJWKs: string(jwks)},
+			token:          encrypt(t, jose.ECDH_ES_A256KW, &ecKey.PublicKey, "ec"),
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "signed JWT is still accepted",
+			jwtCfg:         Config{SigningSecret: "secret", DecryptionKey: string(rsaKeyPEM)},
+			token:          signed,
+			wantStatusCode: http.StatusOK,
+			wantHeader:     http.Header{"Group": []string{"admin"}},
+		},
+		{
+			desc:           "encrypted with an unknown key",
+			jwtCfg:         Config{SigningSecret: "secret", DecryptionJWKs: string(jwks)},
+			token:          encrypt(t, jose.RSA_OAEP_256, &rsaKey.PublicKey, ""),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "no decryption key configured",
+			jwtCfg:         Config{SigningSecret: "secret"},
+			token:          encrypt(t, jose.RSA_OAEP_256, &rsaKey.PublicKey, ""),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			desc:           "invalid inner signature",
+			jwtCfg:         Config{SigningSecret: "other-secret", DecryptionKey: string(rsaKeyPEM)},
+			token:          encrypt(t, jose.RSA_OAEP_256, &rsaKey.PublicKey, ""),
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			test.jwtCfg.ForwardHeaders = map[string]string{"Group": "grp"}
+			handler, err := NewHandler(&test.jwtCfg, "acp@my-ns")
+			require.NoError(t, err)
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Code)
+			for k := range test.wantHeader {
+				assert.Equal(t, test.wantHeader[k], rec.Header()[k])
+			}
+		})
+	}
+}
+
 func TestExtractJWT(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -335,7 +730,9 @@ func TestKeyFunc(t *testing.T) {
 			name: "jwks key not found",
 			handler: &Handler{
 				keySet: &RemoteKeySet{
-					expiry: time.Now().Add(60 * time.Second),
+					expiry:             time.Now().Add(60 * time.Second),
+					lastFetch:          time.Now(),
+					minRefetchInterval: time.Minute,
 					keys: jose.JSONWebKeySet{
 						Keys: []jose.JSONWebKey{},
 					},
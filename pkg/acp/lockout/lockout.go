@@ -0,0 +1,205 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package lockout protects ACPs against brute-force attacks by temporarily locking out clients and users after too
+// many failed authentication attempts.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+)
+
+// Default configuration values.
+const (
+	DefaultWindowSeconds   = 60
+	DefaultLockoutSeconds  = 300
+	keyPrefixIP            = "ip:"
+	keyPrefixUser          = "user:"
+	storeOperationsTimeout = time.Second
+)
+
+// Config configures the lockout of an ACP.
+type Config struct {
+	// MaxFailuresPerIP is the number of failed attempts from a client IP after which it is locked out.
+	// Zero disables the lockout of client IPs.
+	MaxFailuresPerIP int `json:"maxFailuresPerIP,omitempty"`
+	// MaxFailuresPerUser is the number of failed attempts for a user after which it is locked out.
+	// Zero disables the lockout of users.
+	MaxFailuresPerUser int `json:"maxFailuresPerUser,omitempty"`
+	// WindowSeconds is the period over which failed attempts are counted. Defaults to DefaultWindowSeconds.
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+	// LockoutSeconds is the duration of lockouts. Defaults to DefaultLockoutSeconds.
+	LockoutSeconds int `json:"lockoutSeconds,omitempty"`
+}
+
+// Store stores failure counters and lockouts. Implementations must be safe for concurrent use.
+type Store interface {
+	// Fail increments the failure counter of the given key and returns its new value. Counters are reset once the
+	// given window has elapsed since their first failure.
+	Fail(ctx context.Context, key string, window time.Duration) (int, error)
+	// Lock locks the given key out for the given duration and resets its failure counter.
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockedFor returns the remaining lockout duration of the given key, zero if it is not locked out.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+	// Reset resets the failure counter of the given key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Limiter locks out client IPs and users of an ACP after too many failed attempts.
+type Limiter struct {
+	store  Store
+	policy string
+
+	maxFailuresPerIP   int
+	maxFailuresPerUser int
+	window             time.Duration
+	lockout            time.Duration
+}
+
+// NewLimiter returns a Limiter for the given ACP.
+func NewLimiter(cfg *Config, store Store, policy string) (*Limiter, error) {
+	if cfg.MaxFailuresPerIP < 0 || cfg.MaxFailuresPerUser < 0 || cfg.WindowSeconds < 0 || cfg.LockoutSeconds < 0 {
+		return nil, errors.New("lockout values must not be negative")
+	}
+
+	window := DefaultWindowSeconds
+	if cfg.WindowSeconds > 0 {
+		window = cfg.WindowSeconds
+	}
+
+	lockout := DefaultLockoutSeconds
+	if cfg.LockoutSeconds > 0 {
+		lockout = cfg.LockoutSeconds
+	}
+
+	return &Limiter{
+		store:              store,
+		policy:             policy,
+		maxFailuresPerIP:   cfg.MaxFailuresPerIP,
+		maxFailuresPerUser: cfg.MaxFailuresPerUser,
+		window:             time.Duration(window) * time.Second,
+		lockout:            time.Duration(lockout) * time.Second,
+	}, nil
+}
+
+// LockedFor returns for how long the client of the given request, or the given user if any, is locked out.
+// Store errors are logged and do not lock anyone out.
+func (l *Limiter) LockedFor(req *http.Request, user string) time.Duration {
+	ctx, cancel := context.WithTimeout(req.Context(), storeOperationsTimeout)
+	defer cancel()
+
+	var lockedFor time.Duration
+	for _, key := range l.keys(req, user) {
+		d, err := l.store.LockedFor(ctx, key.name)
+		if err != nil {
+			log.Error().Err(err).Str("acp_name", l.policy).Msg("Unable to get lockout")
+			continue
+		}
+
+		if d > lockedFor {
+			lockedFor = d
+		}
+	}
+
+	return lockedFor
+}
+
+// Fail records a failed attempt from the client of the given request, for the given user if any, and locks them out
+// if they reached their maximum number of failures.
+func (l *Limiter) Fail(req *http.Request, user string) {
+	ctx, cancel := context.WithTimeout(req.Context(), storeOperationsTimeout)
+	defer cancel()
+
+	for _, key := range l.keys(req, user) {
+		failures, err := l.store.Fail(ctx, key.name, l.window)
+		if err != nil {
+			log.Error().Err(err).Str("acp_name", l.policy).Msg("Unable to record failed attempt")
+			continue
+		}
+
+		if failures < key.maxFailures {
+			continue
+		}
+
+		log.Warn().
+			Str("acp_name", l.policy).
+			Str("key", key.name).
+			Int("failures", failures).
+			Dur("lockout", l.lockout).
+			Msg("Too many failed attempts, locking out")
+
+		if err = l.store.Lock(ctx, key.name, l.lockout); err != nil {
+			log.Error().Err(err).Str("acp_name", l.policy).Msg("Unable to lock out")
+		}
+	}
+}
+
+// Succeed resets the failure counter of the given user after a successful attempt. Failure counters of client IPs
+// are not reset, as a client could otherwise interleave attempts on several users with a valid one.
+func (l *Limiter) Succeed(req *http.Request, user string) {
+	if l.maxFailuresPerUser == 0 || user == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), storeOperationsTimeout)
+	defer cancel()
+
+	if err := l.store.Reset(ctx, l.key(keyPrefixUser, user)); err != nil {
+		log.Error().Err(err).Str("acp_name", l.policy).Msg("Unable to reset failed attempts")
+	}
+}
+
+type limitedKey struct {
+	name        string
+	maxFailures int
+}
+
+func (l *Limiter) keys(req *http.Request, user string) []limitedKey {
+	var keys []limitedKey
+	if l.maxFailuresPerIP > 0 {
+		if ip := expr.SourceIP(req); ip != nil {
+			keys = append(keys, limitedKey{name: l.key(keyPrefixIP, ip.String()), maxFailures: l.maxFailuresPerIP})
+		}
+	}
+
+	if l.maxFailuresPerUser > 0 && user != "" {
+		keys = append(keys, limitedKey{name: l.key(keyPrefixUser, user), maxFailures: l.maxFailuresPerUser})
+	}
+
+	return keys
+}
+
+func (l *Limiter) key(prefix, value string) string {
+	return l.policy + ":" + prefix + value
+}
+
+// Deny denies the given request because its client or user is locked out for the given duration.
+func Deny(rw http.ResponseWriter, req *http.Request, lockedFor time.Duration) {
+	decision.SetReason(req, decision.ReasonLockedOut)
+
+	rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedFor.Seconds()))))
+	rw.WriteHeader(http.StatusTooManyRequests)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lockout
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis/redistest"
+)
+
+func TestNewLimiter(t *testing.T) {
+	_, err := NewLimiter(&Config{MaxFailuresPerIP: -1}, NewMemoryStore(), "acp")
+	assert.EqualError(t, err, "lockout values must not be negative")
+
+	l, err := NewLimiter(&Config{MaxFailuresPerIP: 1}, NewMemoryStore(), "acp")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultWindowSeconds*time.Second, l.window)
+	assert.Equal(t, DefaultLockoutSeconds*time.Second, l.lockout)
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	l, err := NewLimiter(&Config{
+		MaxFailuresPerIP:   5,
+		MaxFailuresPerUser: 2,
+		WindowSeconds:      60,
+		LockoutSeconds:     300,
+	}, store, "acp")
+	require.NoError(t, err)
+
+	req := newRequest("10.0.0.1")
+
+	// Failures are counted per user.
+	l.Fail(req, "alice")
+	assert.Zero(t, l.LockedFor(req, "alice"))
+	l.Fail(req, "alice")
+	assert.Equal(t, 300*time.Second, l.LockedFor(req, "alice"))
+
+	// Other users are not locked out, and neither is the client IP.
+	assert.Zero(t, l.LockedFor(req, "bob"))
+
+	// Successful attempts reset user failure counters.
+	l.Fail(req, "bob")
+	l.Succeed(req, "bob")
+	l.Fail(req, "bob")
+	assert.Zero(t, l.LockedFor(req, "bob"))
+
+	// The client IP is locked out after its 5th failure, whatever the user.
+	l.Fail(req, "")
+	assert.Equal(t, 300*time.Second, l.LockedFor(req, "carol"))
+	assert.Zero(t, l.LockedFor(newRequest("10.0.0.2"), "carol"))
+
+	// Lockouts expire.
+	now = now.Add(299 * time.Second)
+	assert.Equal(t, time.Second, l.LockedFor(req, "carol"))
+
+	now = now.Add(time.Second)
+	assert.Zero(t, l.LockedFor(req, "carol"))
+	assert.Zero(t, l.LockedFor(req, "alice"))
+}
+
+func TestMemoryStore_Fail(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	failures, err := store.Fail(ctx, "key", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	now = now.Add(59 * time.Second)
+	failures, err = store.Fail(ctx, "key", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failures)
+
+	// The window started with the first failure.
+	now = now.Add(time.Second)
+	failures, err = store.Fail(ctx, "key", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	// Expired entries are swept.
+	now = now.Add(2 * time.Minute)
+	_, err = store.Fail(ctx, "other", time.Minute)
+	require.NoError(t, err)
+	assert.NotContains(t, store.failures, "key")
+}
+
+func TestRedisStore(t *testing.T) {
+	srv := redistest.NewServer(t)
+
+	client := redis.NewClient(redis.Config{Address: srv.Addr})
+	defer func() { _ = client.Close() }()
+
+	store := NewRedisStore(client)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		failures, err := store.Fail(ctx, "acp:user:alice", time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, i, failures)
+	}
+
+	lockedFor, err := store.LockedFor(ctx, "acp:user:alice")
+	require.NoError(t, err)
+	assert.Zero(t, lockedFor)
+
+	err = store.Lock(ctx, "acp:user:alice", time.Minute)
+	require.NoError(t, err)
+
+	lockedFor, err = store.LockedFor(ctx, "acp:user:alice")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, lockedFor, float64(time.Second))
+
+	// Locking out resets the failure counter.
+	failures, err := store.Fail(ctx, "acp:user:alice", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	err = store.Reset(ctx, "acp:user:alice")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"hub:lockout:lock:acp:user:alice"}, srv.Keys())
+}
+
+func TestDeny(t *testing.T) {
+	rw := httptest.NewRecorder()
+
+	Deny(rw, newRequest("10.0.0.1"), 1500*time.Millisecond)
+
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "2", rw.Header().Get("Retry-After"))
+}
+
+func newRequest(ip string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.RemoteAddr = ip + ":1234"
+
+	return req
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval at which expired entries are removed from a MemoryStore.
+const sweepInterval = time.Minute
+
+// MemoryStore is a Store keeping failure counters and lockouts in memory. It is not shared between replicas.
+type MemoryStore struct {
+	mu        sync.Mutex
+	failures  map[string]counter
+	lockouts  map[string]time.Time
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type counter struct {
+	value   int
+	resetAt time.Time
+}
+
+// NewMemoryStore returns a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		failures: make(map[string]counter),
+		lockouts: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Fail implements Store.
+func (s *MemoryStore) Fail(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	c, ok := s.failures[key]
+	if !ok || !now.Before(c.resetAt) {
+		c = counter{resetAt: now.Add(window)}
+	}
+	c.value++
+	s.failures[key] = c
+
+	return c.value, nil
+}
+
+// Lock implements Store.
+func (s *MemoryStore) Lock(_ context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lockouts[key] = s.now().Add(d)
+	delete(s.failures, key)
+
+	return nil
+}
+
+// LockedFor implements Store.
+func (s *MemoryStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.lockouts[key]
+	if !ok {
+		return 0, nil
+	}
+
+	d := until.Sub(s.now())
+	if d <= 0 {
+		delete(s.lockouts, key)
+		return 0, nil
+	}
+
+	return d, nil
+}
+
+// Reset implements Store.
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+
+	return nil
+}
+
+// sweep removes expired entries, at most once every sweepInterval. It must be called with the lock held.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, c := range s.failures {
+		if !now.Before(c.resetAt) {
+			delete(s.failures, key)
+		}
+	}
+
+	for key, until := range s.lockouts {
+		if !now.Before(until) {
+			delete(s.lockouts, key)
+		}
+	}
+}
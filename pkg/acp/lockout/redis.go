@@ -0,0 +1,87 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lockout
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
+)
+
+const redisKeyPrefix = "hub:lockout:"
+
+// RedisStore is a Store keeping failure counters and lockouts in a Redis server, shared between replicas.
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a new RedisStore.
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Fail implements Store.
+func (s *RedisStore) Fail(ctx context.Context, key string, window time.Duration) (int, error) {
+	failuresKey := redisKeyPrefix + "failures:" + key
+
+	failures, err := s.client.Int(ctx, "INCR", failuresKey)
+	if err != nil {
+		return 0, err
+	}
+
+	// The window starts with the first failure.
+	if failures == 1 {
+		if _, err = s.client.Int(ctx, "PEXPIRE", failuresKey, strconv.FormatInt(window.Milliseconds(), 10)); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(failures), nil
+}
+
+// Lock implements Store.
+func (s *RedisStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if _, err := s.client.Do(ctx, "SET", redisKeyPrefix+"lock:"+key, "1", "PX", strconv.FormatInt(d.Milliseconds(), 10)); err != nil {
+		return err
+	}
+
+	return s.Reset(ctx, key)
+}
+
+// LockedFor implements Store.
+func (s *RedisStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.Int(ctx, "PTTL", redisKeyPrefix+"lock:"+key)
+	if err != nil {
+		return 0, err
+	}
+
+	// Negative values mean the key does not exist or has no expiry.
+	if ttl <= 0 {
+		return 0, nil
+	}
+
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// Reset implements Store.
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	_, err := s.client.Do(ctx, "DEL", redisKeyPrefix+"failures:"+key)
+	return err
+}
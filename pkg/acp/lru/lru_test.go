@@ -0,0 +1,74 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package lru
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache_Expiration(t *testing.T) {
+	now := time.Now()
+
+	c := New[string](10)
+	c.Set("key", "value", now.Add(time.Minute))
+
+	value, ok := c.Get("key", now.Add(59*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+
+	_, ok = c.Get("key", now.Add(time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_Set_updatesExistingEntry(t *testing.T) {
+	now := time.Now()
+
+	c := New[string](10)
+	c.Set("key", "old", now.Add(time.Second))
+	c.Set("key", "new", now.Add(time.Minute))
+
+	value, ok := c.Get("key", now.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, "new", value)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Minute)
+
+	c := New[int](2)
+	c.Set("key-1", 1, expiresAt)
+	c.Set("key-2", 2, expiresAt)
+
+	_, ok := c.Get("key-1", now)
+	assert.True(t, ok)
+
+	c.Set("key-3", 3, expiresAt)
+
+	_, ok = c.Get("key-2", now)
+	assert.False(t, ok)
+	_, ok = c.Get("key-1", now)
+	assert.True(t, ok)
+	_, ok = c.Get("key-3", now)
+	assert.True(t, ok)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+// Results of the operations reported by metrics.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Metrics of the auth server.
+var (
+	// Decisions counts the decisions made by ACP handlers.
+	Decisions = NewCounterVec(
+		"hub_agent_auth_decisions_total",
+		"Number of decisions made by ACP handlers.",
+		"acp", "handler_type", "enforcement_mode", "decision", "status",
+	)
+	// HandlerDuration observes the time taken by ACP handlers to make a decision.
+	HandlerDuration = NewHistogramVec(
+		"hub_agent_auth_handler_duration_seconds",
+		"Time taken by ACP handlers to make a decision.",
+		DefaultBuckets,
+		"acp", "handler_type",
+	)
+	// JWKSFetches counts the fetches of remote JWK sets. They are labeled by ACP rather than by URL, as the URL of
+	// the JWK sets discovered from the token issuer is controlled by clients.
+	JWKSFetches = NewCounterVec(
+		"hub_agent_auth_jwks_fetches_total",
+		"Number of fetches of remote JWK sets.",
+		"acp", "result",
+	)
+	// IntrospectionDuration observes the time taken by OAuth 2.0 token introspection calls.
+	IntrospectionDuration = NewHistogramVec(
+		"hub_agent_auth_introspection_duration_seconds",
+		"Time taken by OAuth 2.0 token introspection calls.",
+		DefaultBuckets,
+		"acp", "result",
+	)
+	// IntrospectionCacheRequests counts the lookups in the OAuth 2.0 token introspection caches.
+	IntrospectionCacheRequests = NewCounterVec(
+		"hub_agent_auth_introspection_cache_requests_total",
+		"Number of lookups in the OAuth 2.0 token introspection caches.",
+		"acp", "result",
+	)
+	// OIDCSessionRefreshes counts the refreshes of OIDC sessions.
+	OIDCSessionRefreshes = NewCounterVec(
+		"hub_agent_auth_oidc_session_refreshes_total",
+		"Number of refreshes of OIDC sessions.",
+		"acp", "result",
+	)
+	// InvalidKeySecrets counts the invalid API key secrets matched by a selector, skipped when building ACP handlers.
+	InvalidKeySecrets = NewCounterVec(
+		"hub_agent_auth_invalid_key_secrets_total",
+		"Number of invalid API key secrets matched by a selector, skipped when building ACP handlers.",
+		"acp",
+	)
+	// JWKSCacheAge reports the time elapsed since a remote JWK set of ACPs has last been fetched successfully.
+	JWKSCacheAge = NewGaugeFunc(
+		"hub_agent_auth_jwks_cache_age_seconds",
+		"Time elapsed since a remote JWK set of ACPs has last been fetched successfully.",
+		jwksCacheAge,
+		"acp",
+	)
+)
+
+// NewAuthServerRegistry returns a new Registry holding the metrics of the auth server.
+func NewAuthServerRegistry() *Registry {
+	return NewRegistry(
+		Decisions,
+		HandlerDuration,
+		JWKSFetches,
+		JWKSCacheAge,
+		IntrospectionDuration,
+		IntrospectionCacheRequests,
+		OIDCSessionRefreshes,
+		InvalidKeySecrets,
+	)
+}
+
+var (
+	jwksFetchedMu sync.RWMutex
+	jwksFetched   = make(map[string]time.Time)
+)
+
+// ObserveJWKSFetch reports a fetch of a remote JWK set of the given ACP.
+func ObserveJWKSFetch(acp string, err error) {
+	if err != nil {
+		JWKSFetches.Inc(acp, ResultFailure)
+		return
+	}
+
+	JWKSFetches.Inc(acp, ResultSuccess)
+
+	jwksFetchedMu.Lock()
+	jwksFetched[acp] = time.Now()
+	jwksFetchedMu.Unlock()
+}
+
+func jwksCacheAge() []GaugeSample {
+	jwksFetchedMu.RLock()
+	defer jwksFetchedMu.RUnlock()
+
+	samples := make([]GaugeSample, 0, len(jwksFetched))
+	for acp, fetched := range jwksFetched {
+		samples = append(samples, GaugeSample{
+			LabelValues: []string{acp},
+			Value:       time.Since(fetched).Seconds(),
+		})
+	}
+
+	return samples
+}
+
+// DeleteStaleSeries deletes the series of the ACPs which are no longer in use, so they are not exported forever. It is
+// meant to be called whenever ACP handlers are rebuilt.
+func DeleteStaleSeries(acps map[string]struct{}) {
+	isACP := func(name string) bool {
+		_, ok := acps[name]
+		return ok
+	}
+
+	Decisions.DeleteUnless("acp", isACP)
+	HandlerDuration.DeleteUnless("acp", isACP)
+	JWKSFetches.DeleteUnless("acp", isACP)
+	IntrospectionDuration.DeleteUnless("acp", isACP)
+	IntrospectionCacheRequests.DeleteUnless("acp", isACP)
+	OIDCSessionRefreshes.DeleteUnless("acp", isACP)
+	InvalidKeySecrets.DeleteUnless("acp", isACP)
+
+	jwksFetchedMu.Lock()
+	for acp := range jwksFetched {
+		if !isACP(acp) {
+			delete(jwksFetched, acp)
+		}
+	}
+	jwksFetchedMu.Unlock()
+}
+
+// Result returns the result of an operation which returned the given error.
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+
+	return ResultSuccess
+}
+
+// DecisionSink is a decision.Sink counting decisions and observing their latency.
+type DecisionSink struct{}
+
+// Write implements decision.Sink.
+func (DecisionSink) Write(entry decision.Entry) error {
+	Decisions.Inc(entry.Policy, entry.HandlerType, entry.EnforcementMode, string(entry.Decision), strconv.Itoa(entry.Status))
+	HandlerDuration.Observe(entry.LatencyMS/1000, entry.Policy, entry.HandlerType)
+
+	return nil
+}
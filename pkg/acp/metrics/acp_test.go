@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+)
+
+func TestDecisionSink_Write(t *testing.T) {
+	resetSeries()
+
+	err := DecisionSink{}.Write(decision.Entry{
+		Policy:          "decision-sink-acp",
+		HandlerType:     "JWT",
+		EnforcementMode: "enforce",
+		Decision:        decision.Deny,
+		Status:          401,
+		LatencyMS:       20,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, counterValue(t, Decisions, "decision-sink-acp", "JWT", "enforce", "deny", "401"))
+
+	var found bool
+	for _, m := range HandlerDuration.Collect().Metric {
+		if m.Label[0].GetValue() != "decision-sink-acp" {
+			continue
+		}
+
+		found = true
+		assert.Equal(t, uint64(1), m.Histogram.GetSampleCount())
+		assert.Equal(t, 0.02, m.Histogram.GetSampleSum())
+	}
+	assert.True(t, found)
+}
+
+func TestObserveJWKSFetch(t *testing.T) {
+	resetSeries()
+
+	ObserveJWKSFetch("my-acp", errors.New("boom"))
+	assert.Equal(t, 1.0, counterValue(t, JWKSFetches, "my-acp", ResultFailure))
+	assert.Empty(t, jwksCacheAgeOf("my-acp"))
+
+	ObserveJWKSFetch("my-acp", nil)
+	assert.Equal(t, 1.0, counterValue(t, JWKSFetches, "my-acp", ResultSuccess))
+
+	ages := jwksCacheAgeOf("my-acp")
+	require.Len(t, ages, 1)
+	assert.Less(t, ages[0], 1.0)
+}
+
+func TestDeleteStaleSeries(t *testing.T) {
+	resetSeries()
+
+	Decisions.Inc("kept-acp", "JWT", "enforce", "allow", "200")
+	Decisions.Inc("stale-acp", "JWT", "enforce", "allow", "200")
+	HandlerDuration.Observe(0.01, "stale-acp", "JWT")
+	ObserveJWKSFetch("kept-acp", nil)
+	ObserveJWKSFetch("stale-acp", nil)
+
+	DeleteStaleSeries(map[string]struct{}{"kept-acp": {}})
+
+	assert.Equal(t, 1.0, counterValue(t, Decisions, "kept-acp", "JWT", "enforce", "allow", "200"))
+	assert.Equal(t, 0.0, counterValue(t, Decisions, "stale-acp", "JWT", "enforce", "allow", "200"))
+	for _, m := range HandlerDuration.Collect().Metric {
+		assert.NotEqual(t, "stale-acp", m.Label[0].GetValue())
+	}
+
+	assert.Equal(t, 1.0, counterValue(t, JWKSFetches, "kept-acp", ResultSuccess))
+	assert.Equal(t, 0.0, counterValue(t, JWKSFetches, "stale-acp", ResultSuccess))
+	assert.Len(t, jwksCacheAgeOf("kept-acp"), 1)
+	assert.Empty(t, jwksCacheAgeOf("stale-acp"))
+}
+
+// resetSeries deletes the series of all ACPs, as the metrics are package globals shared by all tests.
+func resetSeries() {
+	DeleteStaleSeries(nil)
+}
+
+func jwksCacheAgeOf(acp string) []float64 {
+	var ages []float64
+	for _, m := range JWKSCacheAge.Collect().Metric {
+		if m.Label[0].GetValue() == acp {
+			ages = append(ages, m.Gauge.GetValue())
+		}
+	}
+
+	return ages
+}
+
+func counterValue(t *testing.T, c *CounterVec, labelValues ...string) float64 {
+	t.Helper()
+
+	for _, m := range c.Collect().Metric {
+		if len(m.Label) != len(labelValues) {
+			continue
+		}
+
+		match := true
+		for i, l := range m.Label {
+			if l.GetValue() != labelValues[i] {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return m.Counter.GetValue()
+		}
+	}
+
+	return 0
+}
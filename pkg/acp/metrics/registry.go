@@ -0,0 +1,348 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// Collector collects a metric family.
+type Collector interface {
+	Collect() *dto.MetricFamily
+}
+
+// Registry exposes metrics in the Prometheus text format.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+// NewRegistry creates a new Registry.
+func NewRegistry(collectors ...Collector) *Registry {
+	return &Registry{collectors: collectors}
+}
+
+// Register registers the given collectors.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Gather returns the metric families of all registered collectors, sorted by name. Families without metrics are
+// omitted.
+func (r *Registry) Gather() []*dto.MetricFamily {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	families := make([]*dto.MetricFamily, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		if mf := c.Collect(); len(mf.Metric) > 0 {
+			families = append(families, mf)
+		}
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+
+	return families
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", string(expfmt.FmtText))
+
+	enc := expfmt.NewEncoder(rw, expfmt.FmtText)
+	for _, mf := range r.Gather() {
+		if err := enc.Encode(mf); err != nil {
+			log.Error().Err(err).Str("metric", mf.GetName()).Msg("Unable to encode metric")
+			return
+		}
+	}
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+// NewCounterVec creates a new CounterVec.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		desc:   desc{name: name, help: help, labels: labels},
+		values: make(map[string]*sample),
+	}
+}
+
+// Inc increments the counter with the given label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds the given value to the counter with the given label values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := getSample(c.values, labelValues)
+	s.value += v
+}
+
+// DeleteUnless deletes the series whose value of the given label is not kept.
+func (c *CounterVec) DeleteUnless(label string, keep func(value string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleteSamples(c.values, c.labelIndex(label), keep)
+}
+
+// Collect implements Collector.
+func (c *CounterVec) Collect() *dto.MetricFamily {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	mf := c.family(dto.MetricType_COUNTER)
+	for _, s := range sortedSamples(c.values) {
+		mf.Metric = append(mf.Metric, &dto.Metric{
+			Label:   c.labelPairs(s.labelValues),
+			Counter: &dto.Counter{Value: proto.Float64(s.value)},
+		})
+	}
+
+	return mf
+}
+
+// DefaultBuckets are the default histogram buckets, in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+// NewHistogramVec creates a new HistogramVec with the given upper bounds, sorted in increasing order.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		desc:    desc{name: name, help: help, labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*sample),
+	}
+}
+
+// Observe adds an observation to the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := getSample(h.values, labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+
+	for i, upperBound := range h.buckets {
+		if v <= upperBound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// DeleteUnless deletes the series whose value of the given label is not kept.
+func (h *HistogramVec) DeleteUnless(label string, keep func(value string) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	deleteSamples(h.values, h.labelIndex(label), keep)
+}
+
+// Collect implements Collector.
+func (h *HistogramVec) Collect() *dto.MetricFamily {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	mf := h.family(dto.MetricType_HISTOGRAM)
+	for _, s := range sortedSamples(h.values) {
+		buckets := make([]*dto.Bucket, 0, len(h.buckets))
+		for i, upperBound := range h.buckets {
+			buckets = append(buckets, &dto.Bucket{
+				UpperBound:      proto.Float64(upperBound),
+				CumulativeCount: proto.Uint64(s.counts[i]),
+			})
+		}
+
+		mf.Metric = append(mf.Metric, &dto.Metric{
+			Label: h.labelPairs(s.labelValues),
+			Histogram: &dto.Histogram{
+				SampleCount: proto.Uint64(s.count),
+				SampleSum:   proto.Float64(s.value),
+				Bucket:      buckets,
+			},
+		})
+	}
+
+	return mf
+}
+
+// GaugeSample is a sample of a GaugeFunc.
+type GaugeSample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose samples are computed when collected.
+type GaugeFunc struct {
+	desc
+
+	fn func() []GaugeSample
+}
+
+// NewGaugeFunc creates a new GaugeFunc.
+func NewGaugeFunc(name, help string, fn func() []GaugeSample, labels ...string) *GaugeFunc {
+	return &GaugeFunc{
+		desc: desc{name: name, help: help, labels: labels},
+		fn:   fn,
+	}
+}
+
+// Collect implements Collector.
+func (g *GaugeFunc) Collect() *dto.MetricFamily {
+	samples := g.fn()
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].LabelValues, labelSeparator) < strings.Join(samples[j].LabelValues, labelSeparator)
+	})
+
+	mf := g.family(dto.MetricType_GAUGE)
+	for _, s := range samples {
+		mf.Metric = append(mf.Metric, &dto.Metric{
+			Label: g.labelPairs(s.LabelValues),
+			Gauge: &dto.Gauge{Value: proto.Float64(s.Value)},
+		})
+	}
+
+	return mf
+}
+
+const labelSeparator = "\xff"
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) family(typ dto.MetricType) *dto.MetricFamily {
+	return &dto.MetricFamily{
+		Name: proto.String(d.name),
+		Help: proto.String(d.help),
+		Type: typ.Enum(),
+	}
+}
+
+// labelIndex returns the index of the given label, or -1 if the metric has no such label.
+func (d desc) labelIndex(label string) int {
+	for i, name := range d.labels {
+		if name == label {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (d desc) labelPairs(values []string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(d.labels))
+	for i, name := range d.labels {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+
+		pairs = append(pairs, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+
+	return pairs
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+
+	// Histograms only.
+	count  uint64
+	counts []uint64
+}
+
+func getSample(values map[string]*sample, labelValues []string) *sample {
+	key := strings.Join(labelValues, labelSeparator)
+
+	s, ok := values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		values[key] = s
+	}
+
+	return s
+}
+
+func deleteSamples(values map[string]*sample, labelIndex int, keep func(string) bool) {
+	if labelIndex < 0 {
+		return
+	}
+
+	for key, s := range values {
+		if labelIndex < len(s.labelValues) && !keep(s.labelValues[labelIndex]) {
+			delete(values, key)
+		}
+	}
+}
+
+func sortedSamples(values map[string]*sample) []*sample {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]*sample, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, values[key])
+	}
+
+	return samples
+}
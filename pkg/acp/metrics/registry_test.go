@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Number of requests.", "acp", "result")
+	counter.Inc("my-acp", "success")
+	counter.Add(2, "my-acp", "failure")
+	counter.Inc("my-acp", "success")
+
+	histogram := NewHistogramVec("test_duration_seconds", "Duration of requests.", []float64{0.1, 1}, "acp")
+	histogram.Observe(0.05, "my-acp")
+	histogram.Observe(0.5, "my-acp")
+	histogram.Observe(3, "my-acp")
+
+	gauge := NewGaugeFunc("test_handlers", "Number of handlers.", func() []GaugeSample {
+		return []GaugeSample{{Value: 3}}
+	})
+
+	empty := NewCounterVec("test_empty_total", "Never incremented.")
+
+	registry := NewRegistry(gauge, counter, empty)
+	registry.Register(histogram)
+
+	rw := httptest.NewRecorder()
+	registry.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rw.Header().Get("Content-Type"))
+
+	want := `# HELP test_duration_seconds Duration of requests.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{acp="my-acp",le="0.1"} 1
+test_duration_seconds_bucket{acp="my-acp",le="1"} 2
+test_duration_seconds_bucket{acp="my-acp",le="+Inf"} 3
+test_duration_seconds_sum{acp="my-acp"} 3.55
+test_duration_seconds_count{acp="my-acp"} 3
+# HELP test_handlers Number of handlers.
+# TYPE test_handlers gauge
+test_handlers 3
+# HELP test_requests_total Number of requests.
+# TYPE test_requests_total counter
+test_requests_total{acp="my-acp",result="failure"} 2
+test_requests_total{acp="my-acp",result="success"} 2
+`
+	assert.Equal(t, want, rw.Body.String())
+}
+
+func TestCounterVec_DeleteUnless(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Number of requests.", "acp", "result")
+	counter.Inc("kept-acp", "success")
+	counter.Inc("stale-acp", "success")
+	counter.Inc("stale-acp", "failure")
+
+	counter.DeleteUnless("acp", func(value string) bool { return value == "kept-acp" })
+	counter.DeleteUnless("unknown", func(string) bool { return false })
+
+	mf := counter.Collect()
+	require.Len(t, mf.Metric, 1)
+	assert.Equal(t, "kept-acp", mf.Metric[0].Label[0].GetValue())
+}
+
+func TestHistogramVec_DeleteUnless(t *testing.T) {
+	histogram := NewHistogramVec("test_duration_seconds", "Duration of requests.", []float64{0.1, 1}, "acp")
+	histogram.Observe(0.05, "kept-acp")
+	histogram.Observe(0.05, "stale-acp")
+
+	histogram.DeleteUnless("acp", func(value string) bool { return value == "kept-acp" })
+
+	mf := histogram.Collect()
+	require.Len(t, mf.Metric, 1)
+	assert.Equal(t, "kept-acp", mf.Metric[0].Label[0].GetValue())
+}
@@ -0,0 +1,271 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mtls
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+)
+
+// Headers in which ingress controllers forward the client certificate.
+const (
+	// HeaderTraefik is set by the Traefik passTLSClientCert middleware when `pem` is enabled. It holds the URL-escaped
+	// and comma-separated list of certificates of the chain, in the PEM format without delimiters.
+	HeaderTraefik = "X-Forwarded-Tls-Client-Cert"
+	// HeaderNginx is set by Nginx from the $ssl_client_escaped_cert variable. It holds the URL-escaped client
+	// certificate in the PEM format.
+	HeaderNginx = "Ssl-Client-Cert"
+)
+
+// Query parameter of auth requests naming the ingress controller forwarding the client certificate. It is set by the
+// admission webhook in the auth URL of Nginx ingresses, which clients cannot change. Traefik is assumed if it is not set.
+const (
+	ControllerParam = "controller"
+	ControllerNginx = "nginx"
+)
+
+// Config configures an mTLS ACP handler.
+type Config struct {
+	CASecret       SecretReference   `json:"caSecret"`
+	CABundle       string            `json:"-"`
+	Claims         string            `json:"claims,omitempty"`
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Handler is an mTLS ACP Handler.
+type Handler struct {
+	name string
+
+	roots *x509.CertPool
+
+	fwdHeaders           map[string]string
+	validateCustomClaims expr.Predicate
+}
+
+// NewHandler creates a new mTLS ACP Handler.
+func NewHandler(cfg *Config, name string) (*Handler, error) {
+	if cfg.CABundle == "" {
+		return nil, errors.New("empty CA bundle")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(cfg.CABundle)) {
+		return nil, errors.New("no valid certificate found in CA bundle")
+	}
+
+	var (
+		pred expr.Predicate
+		err  error
+	)
+	if cfg.Claims != "" {
+		pred, err = expr.Parse(cfg.Claims)
+		if err != nil {
+			return nil, fmt.Errorf("make predicate: %w", err)
+		}
+	}
+
+	return &Handler{
+		name:                 name,
+		roots:                roots,
+		fwdHeaders:           cfg.ForwardHeaders,
+		validateCustomClaims: pred,
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	l := log.With().Str("handler_type", "MTLS").Str("handler_name", h.name).Logger()
+
+	certs, err := clientCertificates(req)
+	if err != nil {
+		l.Debug().Err(err).Msg("Unable to get client certificate")
+		if errors.Is(err, errNoCertificate) {
+			decision.SetReason(req, decision.ReasonMissingCredentials)
+		} else {
+			decision.SetReason(req, decision.ReasonInvalidCredentials)
+		}
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = certs[0].Verify(x509.VerifyOptions{
+		Roots:         h.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		l.Debug().Err(err).Msg("Unable to verify client certificate")
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	claims := certificateClaims(certs[0])
+
+	if h.validateCustomClaims != nil && !h.validateCustomClaims(claims, req) {
+		decision.SetReason(req, decision.ReasonClaimsMismatch)
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	hdrs, err := expr.PluckClaims(h.fwdHeaders, claims)
+	if err != nil {
+		l.Error().Err(err).Msg("Unable to set forwarded header")
+		decision.SetReason(req, decision.ReasonInternalError)
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	for name, vals := range hdrs {
+		for _, val := range vals {
+			rw.Header().Add(name, val)
+		}
+	}
+
+	decision.SetSubject(req, certs[0].Subject.CommonName)
+	decision.SetClaims(req, claims)
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+var errNoCertificate = errors.New("no client certificate found in request")
+
+// clientCertificates returns the client certificate chain forwarded by the ingress controller, leaf first.
+// Only the header set by the ingress controller the request comes from is read, as the other one may have been
+// forwarded as-is from the client.
+func clientCertificates(req *http.Request) ([]*x509.Certificate, error) {
+	name := HeaderTraefik
+	if req.URL.Query().Get(ControllerParam) == ControllerNginx {
+		name = HeaderNginx
+	}
+
+	raw := req.Header.Get(name)
+	if raw == "" {
+		return nil, errNoCertificate
+	}
+
+	unescaped, err := url.QueryUnescape(raw)
+	if err != nil {
+		return nil, fmt.Errorf("unescape client certificate: %w", err)
+	}
+
+	var ders [][]byte
+	if strings.Contains(unescaped, "-----BEGIN") {
+		rest := []byte(unescaped)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+
+			if block.Type == "CERTIFICATE" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+	} else {
+		for _, data := range strings.Split(unescaped, ",") {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data), ""))
+			if err != nil {
+				return nil, fmt.Errorf("decode client certificate: %w", err)
+			}
+
+			ders = append(ders, der)
+		}
+	}
+
+	if len(ders) == 0 {
+		return nil, errNoCertificate
+	}
+
+	certs := make([]*x509.Certificate, 0, len(ders))
+	for _, der := range ders {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse client certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// certificateClaims returns the claims of the given certificate, so they can be evaluated with expressions and
+// forwarded as headers.
+func certificateClaims(cert *x509.Certificate) map[string]interface{} {
+	ips := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	uris := make([]string, 0, len(cert.URIs))
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+
+	return map[string]interface{}{
+		"subject": map[string]interface{}{
+			"commonName":         cert.Subject.CommonName,
+			"organization":       toList(cert.Subject.Organization),
+			"organizationalUnit": toList(cert.Subject.OrganizationalUnit),
+			"country":            toList(cert.Subject.Country),
+			"locality":           toList(cert.Subject.Locality),
+			"province":           toList(cert.Subject.Province),
+		},
+		"issuer": map[string]interface{}{
+			"commonName": cert.Issuer.CommonName,
+		},
+		"serialNumber":   cert.SerialNumber.String(),
+		"dnsNames":       toList(cert.DNSNames),
+		"emailAddresses": toList(cert.EmailAddresses),
+		"ipAddresses":    toList(ips),
+		"uris":           toList(uris),
+	}
+}
+
+func toList(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		list = append(list, v)
+	}
+
+	return list
+}
@@ -0,0 +1,249 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	ca := newCertificate(t, nil, "ca", true, nil)
+
+	tests := []struct {
+		desc    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			desc: "valid configuration",
+			cfg: Config{
+				CABundle: ca.pem,
+				Claims:   "Equals(`subject.commonName`, `bob`)",
+			},
+		},
+		{
+			desc:    "missing CA bundle",
+			cfg:     Config{},
+			wantErr: true,
+		},
+		{
+			desc: "invalid CA bundle",
+			cfg: Config{
+				CABundle: "not a certificate",
+			},
+			wantErr: true,
+		},
+		{
+			desc: "invalid claims expression",
+			cfg: Config{
+				CABundle: ca.pem,
+				Claims:   "Equals(`subject.commonName`",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewHandler(&test.cfg, "mtls")
+
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	ca := newCertificate(t, nil, "ca", true, nil)
+	otherCA := newCertificate(t, nil, "other-ca", true, nil)
+
+	bob := newCertificate(t, ca, "bob", false, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	alice := newCertificate(t, ca, "alice", false, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	server := newCertificate(t, ca, "server", false, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	mallory := newCertificate(t, otherCA, "bob", false, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+
+	tests := []struct {
+		desc        string
+		target      string
+		headers     map[string]string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			desc:        "valid certificate forwarded by Traefik",
+			headers:     map[string]string{HeaderTraefik: traefikValue(bob)},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Client-Cn": "bob", "Client-Org": "acme"},
+		},
+		{
+			desc:        "valid certificate forwarded by Nginx",
+			target:      "/?controller=nginx",
+			headers:     map[string]string{HeaderNginx: url.QueryEscape(bob.pem)},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Client-Cn": "bob", "Client-Org": "acme"},
+		},
+		{
+			desc:       "Traefik header sent through Nginx",
+			target:     "/?controller=nginx",
+			headers:    map[string]string{HeaderTraefik: traefikValue(bob)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "Nginx header and From header sent through Traefik",
+			headers:    map[string]string{"From": "nginx", HeaderNginx: url.QueryEscape(bob.pem)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "Nginx header sent through Traefik",
+			headers:    map[string]string{HeaderNginx: url.QueryEscape(bob.pem)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "missing certificate",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "malformed certificate",
+			headers:    map[string]string{HeaderTraefik: "not-a-certificate"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "certificate signed by an unknown authority",
+			headers:    map[string]string{HeaderTraefik: traefikValue(mallory)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "certificate not valid for client authentication",
+			headers:    map[string]string{HeaderTraefik: traefikValue(server)},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "claims not matching",
+			headers:    map[string]string{HeaderTraefik: traefikValue(alice)},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewHandler(&Config{
+				CABundle: ca.pem,
+				Claims:   "Equals(`subject.commonName`, `bob`) && Contains(`subject.organization`, `acme`)",
+				ForwardHeaders: map[string]string{
+					"Client-Cn":  "subject.commonName",
+					"Client-Org": "subject.organization",
+				},
+			}, "mtls")
+			require.NoError(t, err)
+
+			target := test.target
+			if target == "" {
+				target = "/"
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			for name, value := range test.wantHeaders {
+				assert.Equal(t, value, rec.Header().Get(name))
+			}
+		})
+	}
+}
+
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newCertificate(t *testing.T, parent *certificate, cn string, isCA bool, usages []x509.ExtKeyUsage) *certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject: pkix.Name{
+			CommonName:   cn,
+			Organization: []string{"acme"},
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           usages,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &certificate{
+		cert: cert,
+		key:  key,
+		pem:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+}
+
+// traefikValue returns the given certificate as forwarded by the Traefik passTLSClientCert middleware.
+func traefikValue(c *certificate) string {
+	return url.QueryEscape(base64.StdEncoding.EncodeToString(c.cert.Raw))
+}
@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oauthintro
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lru"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+)
+
+// Default values of the cache configuration.
+const (
+	DefaultCacheMaxSize            = 1000
+	DefaultCacheMaxTTLSeconds      = 300
+	DefaultCacheNegativeTTLSeconds = 10
+)
+
+// CacheConfig configures the cache of token introspection results.
+type CacheConfig struct {
+	MaxSize            *optional.Int `json:"maxSize,omitempty"`
+	MaxTTLSeconds      *optional.Int `json:"maxTtlSeconds,omitempty"`
+	NegativeTTLSeconds *optional.Int `json:"negativeTtlSeconds,omitempty"`
+}
+
+// cache is a bounded LRU cache of token introspection results, keyed by the hash of their cache key.
+type cache struct {
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	entries *lru.Cache[map[string]interface{}]
+}
+
+func newCache(cfg CacheConfig) *cache {
+	return &cache{
+		maxTTL:      time.Duration(cfg.MaxTTLSeconds.IntOrDefault(DefaultCacheMaxTTLSeconds)) * time.Second,
+		negativeTTL: time.Duration(cfg.NegativeTTLSeconds.IntOrDefault(DefaultCacheNegativeTTLSeconds)) * time.Second,
+		now:         time.Now,
+		entries:     lru.New[map[string]interface{}](cfg.MaxSize.IntOrDefault(DefaultCacheMaxSize)),
+	}
+}
+
+// Get returns the cached introspection result of the given key, if any.
+func (c *cache) Get(k string) (map[string]interface{}, bool) {
+	return c.entries.Get(hashKey(k), c.now())
+}
+
+// Set caches the introspection result of the given key. Active tokens are cached at most until they expire,
+// inactive ones for the negative TTL.
+func (c *cache) Set(k string, claims map[string]interface{}) {
+	ttl := c.ttl(claims)
+	if ttl <= 0 {
+		return
+	}
+
+	c.entries.Set(hashKey(k), claims, c.now().Add(ttl))
+}
+
+func (c *cache) ttl(claims map[string]interface{}) time.Duration {
+	if active, ok := claims["active"].(bool); !ok || !active {
+		return c.negativeTTL
+	}
+
+	ttl := c.maxTTL
+
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return ttl
+	}
+
+	expSeconds, err := exp.Int64()
+	if err != nil {
+		return ttl
+	}
+
+	if untilExp := time.Unix(expSeconds, 0).Sub(c.now()); untilExp < ttl {
+		return untilExp
+	}
+
+	return ttl
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oauthintro
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+)
+
+func TestCache_TTL(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		desc     string
+		claims   map[string]interface{}
+		advance  time.Duration
+		wantHit  bool
+		wantSkip bool
+	}{
+		{
+			desc:    "active token without expiration is cached for the max TTL",
+			claims:  map[string]interface{}{"active": true},
+			advance: 59 * time.Second,
+			wantHit: true,
+		},
+		{
+			desc:    "active token without expiration expires after the max TTL",
+			claims:  map[string]interface{}{"active": true},
+			advance: 60 * time.Second,
+		},
+		{
+			desc:    "active token is not cached beyond its expiration",
+			claims:  map[string]interface{}{"active": true, "exp": unixNumber(now.Add(10 * time.Second))},
+			advance: 10 * time.Second,
+		},
+		{
+			desc:    "active token is cached until its expiration",
+			claims:  map[string]interface{}{"active": true, "exp": unixNumber(now.Add(10 * time.Second))},
+			advance: 9 * time.Second,
+			wantHit: true,
+		},
+		{
+			desc:     "expired active token is not cached",
+			claims:   map[string]interface{}{"active": true, "exp": unixNumber(now.Add(-time.Second))},
+			wantSkip: true,
+		},
+		{
+			desc:    "inactive token is cached for the negative TTL",
+			claims:  map[string]interface{}{"active": false},
+			advance: 4 * time.Second,
+			wantHit: true,
+		},
+		{
+			desc:    "inactive token expires after the negative TTL",
+			claims:  map[string]interface{}{"active": false},
+			advance: 5 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			c := newCache(CacheConfig{
+				MaxTTLSeconds:      optional.NewInt(60),
+				NegativeTTLSeconds: optional.NewInt(5),
+			})
+			c.now = func() time.Time { return now }
+
+			c.Set("token", test.claims)
+			if test.wantSkip {
+				assert.Equal(t, 0, c.entries.Len())
+				return
+			}
+
+			c.now = func() time.Time { return now.Add(test.advance) }
+
+			claims, ok := c.Get("token")
+			assert.Equal(t, test.wantHit, ok)
+			if test.wantHit {
+				assert.Equal(t, test.claims, claims)
+			}
+		})
+	}
+}
+
+func TestCache_NegativeCachingDisabled(t *testing.T) {
+	c := newCache(CacheConfig{NegativeTTLSeconds: optional.NewInt(0)})
+
+	c.Set("token", map[string]interface{}{"active": false})
+
+	_, ok := c.Get("token")
+	assert.False(t, ok)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newCache(CacheConfig{MaxSize: optional.NewInt(2)})
+
+	c.Set("token-1", map[string]interface{}{"active": true})
+	c.Set("token-2", map[string]interface{}{"active": true})
+
+	_, ok := c.Get("token-1")
+	assert.True(t, ok)
+
+	c.Set("token-3", map[string]interface{}{"active": true})
+
+	_, ok = c.Get("token-2")
+	assert.False(t, ok)
+	_, ok = c.Get("token-1")
+	assert.True(t, ok)
+	_, ok = c.Get("token-3")
+	assert.True(t, ok)
+}
+
+func unixNumber(t time.Time) json.Number {
+	return json.Number(strconv.FormatInt(t.Unix(), 10))
+}
@@ -26,9 +26,13 @@ import (
 	"net/url"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
 	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
 )
@@ -38,7 +42,9 @@ type Config struct {
 	ClientConfig   ClientConfig      `json:"clientConfig,omitempty"`
 	TokenSource    token.Source      `json:"tokenSource,omitempty"`
 	Claims         string            `json:"claims,omitempty"`
+	GroupsClaim    string            `json:"groupsClaim,omitempty"`
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	Cache          *CacheConfig      `json:"cache,omitempty"`
 }
 
 // ClientConfig configures the HTTP client of the OAuth 2.0 Token Introspection ACP handler.
@@ -81,6 +87,11 @@ type Handler struct {
 	tokenSrc             token.Source
 	fwdHeaders           map[string]string
 	validateCustomClaims expr.Predicate
+	// groupsClaim is the claim holding the groups of the token owner, checked against the groups required by the
+	// request. Groups are not checked if it is empty.
+	groupsClaim string
+
+	cache *cache
 }
 
 // NewHandler creates a new OAuth 2.0 Token Introspection ACP Handler.
@@ -125,6 +136,11 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		}
 	}
 
+	var c *cache
+	if cfg.Cache != nil {
+		c = newCache(*cfg.Cache)
+	}
+
 	return &Handler{
 		name:                 polName,
 		url:                  cfg.ClientConfig.URL,
@@ -135,6 +151,8 @@ func NewHandler(cfg *Config, polName string) (*Handler, error) {
 		auth:                 cfg.ClientConfig.Auth,
 		fwdHeaders:           cfg.ForwardHeaders,
 		validateCustomClaims: pred,
+		groupsClaim:          cfg.GroupsClaim,
+		cache:                c,
 	}, nil
 }
 
@@ -145,25 +163,52 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	tok, err := token.Extract(req, h.tokenSrc)
 	if tok == "" {
 		l.Debug().Err(err).Msg("No token found in request")
+		decision.SetReason(req, decision.ReasonMissingCredentials)
+		denial.SetBearerChallenge(rw, decision.ReasonMissingCredentials)
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	claims, err := h.introspectToken(req, tok)
+	claims, err := h.introspect(req, tok)
 	if err != nil {
 		l.Error().Err(err).Msg("Unable to introspect token")
+		decision.SetReason(req, decision.ReasonInternalError)
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 	active, ok := claims["active"].(bool)
 	if !ok || !active {
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
+		denial.SetBearerChallenge(rw, decision.ReasonInvalidCredentials)
 		rw.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
 	if h.validateCustomClaims != nil {
-		if !h.validateCustomClaims(claims) {
+		if !h.validateCustomClaims(claims, req) {
+			decision.SetReason(req, decision.ReasonClaimsMismatch)
+			denial.SetBearerChallenge(rw, decision.ReasonClaimsMismatch)
+			rw.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	var groups []string
+	if h.groupsClaim != "" {
+		var match bool
+		groups, match, err = expr.MatchGroups(req, h.groupsClaim, claims)
+		if err != nil {
+			l.Error().Err(err).Msg("Unable to match groups")
+			decision.SetReason(req, decision.ReasonInternalError)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		if !match {
+			l.Debug().Strs("user_groups", groups).Msg("User is not in the required groups")
+			decision.SetReason(req, decision.ReasonGroupsMismatch)
+			denial.SetBearerChallenge(rw, decision.ReasonGroupsMismatch)
 			rw.WriteHeader(http.StatusForbidden)
 			return
 		}
@@ -172,6 +217,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	hdrs, err := expr.PluckClaims(h.fwdHeaders, claims)
 	if err != nil {
 		l.Error().Err(err).Msg("Unable to set forwarded header")
+		decision.SetReason(req, decision.ReasonInternalError)
 		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -182,10 +228,93 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	if sub, ok := claims["sub"].(string); ok {
+		decision.SetSubject(req, sub)
+	}
+	decision.SetGroups(req, groups)
+	decision.SetClaims(req, claims)
+
 	rw.WriteHeader(http.StatusOK)
 }
 
-func (h *Handler) introspectToken(originalReq *http.Request, tok string) (map[string]interface{}, error) {
+// introspect returns the introspection result of the given token, from the cache if possible.
+func (h *Handler) introspect(req *http.Request, tok string) (map[string]interface{}, error) {
+	hdrs, err := h.renderHeaders(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.cache == nil {
+		return h.observedIntrospectToken(req, tok, hdrs)
+	}
+
+	// The headers sent to the Authorization Server may depend on the request, and so may its response.
+	key, err := cacheKey(tok, hdrs)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := h.cache.Get(key); ok {
+		metrics.IntrospectionCacheRequests.Inc(h.name, "hit")
+		return claims, nil
+	}
+	metrics.IntrospectionCacheRequests.Inc(h.name, "miss")
+
+	claims, err := h.observedIntrospectToken(req, tok, hdrs)
+	if err != nil {
+		return nil, err
+	}
+
+	h.cache.Set(key, claims)
+
+	return claims, nil
+}
+
+func (h *Handler) observedIntrospectToken(req *http.Request, tok string, hdrs map[string]string) (map[string]interface{}, error) {
+	start := time.Now()
+	claims, err := h.introspectToken(req, tok, hdrs)
+	metrics.IntrospectionDuration.Observe(time.Since(start).Seconds(), h.name, metrics.Result(err))
+
+	return claims, err
+}
+
+// renderHeaders renders the headers to send to the Authorization Server for the given request.
+func (h *Handler) renderHeaders(req *http.Request) (map[string]string, error) {
+	data := struct {
+		Request *http.Request
+	}{
+		Request: req,
+	}
+
+	hdrs := make(map[string]string)
+	for _, tmpl := range h.headers.Templates() {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("executing template for header %q: %w", tmpl.Name(), err)
+		}
+		hdrs[tmpl.Name()] = buf.String()
+	}
+
+	return hdrs, nil
+}
+
+// cacheKey returns the cache key of the introspection of the given token with the given headers.
+func cacheKey(tok string, hdrs map[string]string) (string, error) {
+	key, err := json.Marshal(struct {
+		Token   string            `json:"token"`
+		Headers map[string]string `json:"headers"`
+	}{
+		Token:   tok,
+		Headers: hdrs,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal cache key: %w", err)
+	}
+
+	return string(key), nil
+}
+
+func (h *Handler) introspectToken(originalReq *http.Request, tok string, hdrs map[string]string) (map[string]interface{}, error) {
 	form := url.Values{"token": []string{tok}}
 	form.Set("token", tok)
 	if h.tokenTypeHint != "" {
@@ -199,19 +328,8 @@ func (h *Handler) introspectToken(originalReq *http.Request, tok string) (map[st
 	req = req.WithContext(originalReq.Context())
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
-	data := struct {
-		Request *http.Request
-	}{
-		Request: originalReq,
-	}
-	if len(h.headers.Templates()) > 0 {
-		for _, tmpl := range h.headers.Templates() {
-			var buf bytes.Buffer
-			if err = tmpl.Execute(&buf, data); err != nil {
-				return nil, fmt.Errorf("executing template for header %q: %w", tmpl.Name(), err)
-			}
-			req.Header.Set(tmpl.Name(), buf.String())
-		}
+	for name, val := range hdrs {
+		req.Header.Set(name, val)
 	}
 
 	switch h.auth.Kind {
@@ -20,10 +20,12 @@ package oauthintro
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/token"
 	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
 	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
@@ -587,6 +589,76 @@ func TestOAuthIntro_HandlesWrongClaims(t *testing.T) {
 	assert.Equal(t, 1, callCount)
 }
 
+func TestOAuthIntro_MatchesGroups(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"active": true, "groups": ["dev", "ops"]}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		ClientConfig: ClientConfig{
+			URL: srv.URL,
+			Config: httpclient.Config{
+				MaxRetries: optional.NewInt(0),
+			},
+			Auth: ClientConfigAuth{
+				Kind: "Bearer",
+				Secret: SecretReference{
+					Name:      "name",
+					Namespace: "namespace",
+				},
+				Key:   "Authorization",
+				Value: "Bearer token",
+			},
+		},
+		TokenSource: token.Source{
+			Header:           "Authorization",
+			HeaderAuthScheme: "Bearer",
+		},
+		GroupsClaim: "groups",
+	}
+	handler, err := NewHandler(&cfg, "oauth-intro")
+	require.NoError(t, err)
+
+	tests := []struct {
+		desc           string
+		groups         string
+		wantStatusCode int
+		wantGroups     []string
+	}{
+		{
+			desc:           "no groups required",
+			wantStatusCode: http.StatusOK,
+			wantGroups:     []string{"dev", "ops"},
+		},
+		{
+			desc:           "in one of the required groups",
+			groups:         "admin,ops",
+			wantStatusCode: http.StatusOK,
+			wantGroups:     []string{"dev", "ops"},
+		},
+		{
+			desc:           "not in the required groups",
+			groups:         "admin",
+			wantStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := decision.Track(httptest.NewRequest(http.MethodGet, "/?groups="+url.QueryEscape(test.groups), http.NoBody))
+			req.Header.Set("Authorization", "Bearer abc")
+
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatusCode, rec.Result().StatusCode)
+			assert.Equal(t, test.wantGroups, decision.IdentityOf(req).Groups)
+		})
+	}
+}
+
 func TestOAuthIntro_ForwardsClaimsHeaders(t *testing.T) {
 	var callCount int
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -632,3 +704,130 @@ func TestOAuthIntro_ForwardsClaimsHeaders(t *testing.T) {
 	assert.Equal(t, "test", rec.Header().Get("Group"))
 	assert.Equal(t, 1, callCount)
 }
+
+func TestOAuthIntro_CachesIntrospectionResults(t *testing.T) {
+	var callCount int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("token") == "inactive" {
+			_, _ = w.Write([]byte(`{"active": false}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"active": true}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		ClientConfig: ClientConfig{
+			URL: srv.URL,
+			Config: httpclient.Config{
+				MaxRetries: optional.NewInt(0),
+			},
+			Auth: ClientConfigAuth{
+				Kind: "Bearer",
+				Secret: SecretReference{
+					Name:      "name",
+					Namespace: "namespace",
+				},
+				Key:   "Authorization",
+				Value: "Bearer token",
+			},
+		},
+		TokenSource: token.Source{
+			Header:           "Authorization",
+			HeaderAuthScheme: "Bearer",
+		},
+		Cache: &CacheConfig{},
+	}
+	handler, err := NewHandler(&cfg, "oauth-intro")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		token      string
+		wantStatus int
+	}{
+		{token: "active", wantStatus: http.StatusOK},
+		{token: "active", wantStatus: http.StatusOK},
+		{token: "inactive", wantStatus: http.StatusUnauthorized},
+		{token: "inactive", wantStatus: http.StatusUnauthorized},
+	} {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, test.wantStatus, rec.Result().StatusCode)
+	}
+
+	assert.Equal(t, 2, callCount)
+}
+
+func TestOAuthIntro_CachesIntrospectionResultsPerRenderedHeaders(t *testing.T) {
+	var callCount int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callCount++
+
+		if r.Header.Get("Request-Tenant") != "acme" {
+			_, _ = w.Write([]byte(`{"active": false}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"active": true}`))
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		ClientConfig: ClientConfig{
+			URL: srv.URL,
+			Config: httpclient.Config{
+				MaxRetries: optional.NewInt(0),
+			},
+			Auth: ClientConfigAuth{
+				Kind: "Bearer",
+				Secret: SecretReference{
+					Name:      "name",
+					Namespace: "namespace",
+				},
+				Key:   "Authorization",
+				Value: "Bearer token",
+			},
+			Headers: map[string]string{
+				"Request-Tenant": `{{ .Request.Header.Get "Tenant" }}`,
+			},
+		},
+		TokenSource: token.Source{
+			Header:           "Authorization",
+			HeaderAuthScheme: "Bearer",
+		},
+		Cache: &CacheConfig{},
+	}
+	handler, err := NewHandler(&cfg, "oauth-intro")
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		tenant     string
+		wantStatus int
+	}{
+		{tenant: "acme", wantStatus: http.StatusOK},
+		{tenant: "other", wantStatus: http.StatusUnauthorized},
+		{tenant: "acme", wantStatus: http.StatusOK},
+		{tenant: "other", wantStatus: http.StatusUnauthorized},
+	} {
+		rec := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Tenant", test.tenant)
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, test.wantStatus, rec.Result().StatusCode)
+	}
+
+	assert.Equal(t, 2, callCount)
+}
@@ -19,6 +19,7 @@ package oidc
 
 import (
 	"errors"
+	"fmt"
 )
 
 // Config holds the configuration for the OIDC middleware.
@@ -28,19 +29,41 @@ type Config struct {
 	ClientSecret string           `json:"-"`
 	Secret       *SecretReference `json:"secret,omitempty"`
 
-	RedirectURL string            `json:"redirectUrl,omitempty"`
-	LogoutURL   string            `json:"logoutUrl,omitempty"`
-	Scopes      []string          `json:"scopes,omitempty"`
-	AuthParams  map[string]string `json:"authParams,omitempty"`
-	StateCookie *AuthStateCookie  `json:"stateCookie,omitempty"`
-	Session     *AuthSession      `json:"session,omitempty"`
-	SessionKey  string            `json:"-"`
+	RedirectURL string `json:"redirectUrl,omitempty"`
+	LogoutURL   string `json:"logoutUrl,omitempty"`
+	// ProviderLogout ends the session at the provider when users log out with a GET request on LogoutURL, by
+	// redirecting them to the end_session_endpoint of the provider (RP-initiated logout).
+	ProviderLogout bool `json:"providerLogout,omitempty"`
+	// PostLogoutRedirectURL is the URL the provider redirects users to after RP-initiated logout.
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl,omitempty"`
+	// FrontChannelLogoutURL is the URL the provider loads to end the session of users logging out from the provider
+	// (front-channel logout).
+	FrontChannelLogoutURL string            `json:"frontChannelLogoutUrl,omitempty"`
+	Scopes                []string          `json:"scopes,omitempty"`
+	AuthParams            map[string]string `json:"authParams,omitempty"`
+	StateCookie           *AuthStateCookie  `json:"stateCookie,omitempty"`
+	Session               *AuthSession      `json:"session,omitempty"`
+	SessionKey            string            `json:"-"`
+	// SessionKeys holds the session keys resolved from Session.KeysSecret, indexed by ID. They replace SessionKey.
+	SessionKeys map[string]string `json:"-"`
 
 	// ForwardHeaders defines headers that should be added to the request and populated with values extracted from the ID token.
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	// ForwardAccessToken defines whether the access token is forwarded to upstream services. Defaults to true.
+	ForwardAccessToken *bool `json:"forwardAccessToken,omitempty"`
+	// AccessTokenHeader is the header the access token is forwarded in. Defaults to Authorization, in which case the
+	// access token is forwarded as a bearer token.
+	AccessTokenHeader string `json:"accessTokenHeader,omitempty"`
+	// FetchUserInfo defines whether the claims of the userinfo endpoint of the provider complete the ID token claims
+	// used by Claims and ForwardHeaders. Userinfo claims are fetched on login and session refresh, and kept in the
+	// session.
+	FetchUserInfo bool `json:"fetchUserInfo,omitempty"`
 	// Claims defines an expression to perform validation on the ID token. For example:
 	//     Equals(`grp`, `admin`) && Equals(`scope`, `deploy`)
 	Claims string `json:"claims,omitempty"`
+	// GroupsClaim is the claim holding the groups of the user. If set, users must be in one of the groups required
+	// by the "groups" query parameter of requests, if any.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
 }
 
 // ApplyDefaultValues applies default values on the given dynamic configuration.
@@ -81,6 +104,10 @@ func (cfg *Config) ApplyDefaultValues() {
 		cfg.Session.Refresh = ptrBool(true)
 	}
 
+	if cfg.Session.KeyGracePeriodSeconds == 0 {
+		cfg.Session.KeyGracePeriodSeconds = sessionMaxAge
+	}
+
 	if cfg.RedirectURL == "" {
 		cfg.RedirectURL = "/callback"
 	}
@@ -106,24 +133,53 @@ func (cfg *Config) Validate() error {
 		return errors.New("missing client secret")
 	}
 
-	if cfg.SessionKey == "" {
-		return errors.New("missing session key")
+	if len(cfg.SessionKeys) == 0 {
+		if cfg.SessionKey == "" {
+			return errors.New("missing session key")
+		}
+
+		if !validSessionKey(cfg.SessionKey) {
+			return errors.New("session key must be 16, 24 or 32 characters long")
+		}
 	}
 
-	switch len(cfg.SessionKey) {
-	case 16, 24, 32:
-		break
-	default:
-		return errors.New("session key must be 16, 24 or 32 characters long")
+	for id, key := range cfg.SessionKeys {
+		if !validSessionKey(key) {
+			return fmt.Errorf("session key %q must be 16, 24 or 32 characters long", id)
+		}
+	}
+
+	if cfg.Session.KeyGracePeriodSeconds < 0 {
+		return errors.New("session key grace period must not be negative")
 	}
 
 	if cfg.RedirectURL == "" {
 		return errors.New("missing redirect URL")
 	}
 
+	if cfg.ProviderLogout && cfg.LogoutURL == "" {
+		return errors.New("provider logout requires a logout URL")
+	}
+
+	switch cfg.Session.Store {
+	case "", SessionStoreCookie, SessionStoreMemory, SessionStoreRedis:
+	default:
+		return fmt.Errorf("unsupported session store %q, must be one of %q, %q or %q", cfg.Session.Store, SessionStoreCookie, SessionStoreMemory, SessionStoreRedis)
+	}
+
 	return nil
 }
 
+// validSessionKey returns whether the given session key has a valid AES key size.
+func validSessionKey(key string) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	default:
+		return false
+	}
+}
+
 // SecretReference represents a Secret Reference.
 // It has enough information to retrieve secret in any namespace.
 type SecretReference struct {
@@ -146,6 +202,15 @@ type AuthSession struct {
 	SameSite string `json:"sameSite,omitempty"`
 	Secure   bool   `json:"secure,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	// Store is where sessions are stored, one of SessionStoreCookie (the default), SessionStoreMemory or
+	// SessionStoreRedis.
+	Store string `json:"store,omitempty"`
+	// KeysSecret references a Secret holding the keys encrypting sessions, indexed by ID. The key with the greatest ID
+	// encrypts sessions, the others only decrypt sessions they encrypted, until they are refreshed.
+	KeysSecret *SecretReference `json:"keysSecret,omitempty"`
+	// KeyGracePeriodSeconds is how long sessions encrypted with a previous key stay valid. Defaults to the session
+	// lifetime.
+	KeyGracePeriodSeconds int `json:"keyGracePeriodSeconds,omitempty"`
 }
 
 // ptrBool returns a pointer to boolean.
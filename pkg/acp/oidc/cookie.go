@@ -22,12 +22,17 @@ import (
 	"crypto/cipher"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 )
 
-const maxCookies = 180
+const (
+	maxCookies = 180
+	// sessionMaxAge is the lifetime, in seconds, of session cookies and server-side sessions.
+	sessionMaxAge = 86400
+)
 
 // Randr represents an object that can return random bytes.
 type Randr interface {
@@ -40,17 +45,17 @@ type CookieSessionStore struct {
 	cfg     *AuthSession
 	maxSize int
 
-	block cipher.Block
-	rand  Randr
+	keys *KeyRing
+	rand Randr
 }
 
 // NewCookieSessionStore creates a cookie session store.
-func NewCookieSessionStore(name string, block cipher.Block, cfg *AuthSession, rand Randr, maxSize int) *CookieSessionStore {
+func NewCookieSessionStore(name string, keys *KeyRing, cfg *AuthSession, rand Randr, maxSize int) *CookieSessionStore {
 	return &CookieSessionStore{
 		name:    name,
 		cfg:     cfg,
 		maxSize: maxSize,
-		block:   block,
+		keys:    keys,
 		rand:    rand,
 	}
 }
@@ -68,7 +73,7 @@ func (s *CookieSessionStore) Create(w http.ResponseWriter, data SessionData) err
 			Value:    string(value),
 			Path:     s.cfg.Path,
 			Domain:   s.cfg.Domain,
-			MaxAge:   86400,
+			MaxAge:   sessionMaxAge,
 			HttpOnly: true,
 			SameSite: parseSameSite(s.cfg.SameSite),
 			Secure:   s.cfg.Secure,
@@ -84,7 +89,7 @@ func (s *CookieSessionStore) Create(w http.ResponseWriter, data SessionData) err
 			Value:    string(val),
 			Path:     s.cfg.Path,
 			Domain:   s.cfg.Domain,
-			MaxAge:   86400,
+			MaxAge:   sessionMaxAge,
 			HttpOnly: true,
 			SameSite: parseSameSite(s.cfg.SameSite),
 			Secure:   s.cfg.Secure,
@@ -153,11 +158,17 @@ func (s *CookieSessionStore) getCookiesBytes(r *http.Request) []byte {
 
 // RemoveCookie removes the session cookie from the request.
 func (s *CookieSessionStore) RemoveCookie(rw http.ResponseWriter, r *http.Request) {
+	removeCookies(rw, r, s.name)
+}
+
+// removeCookies sets the Cookie header of the response to the cookies of the request, except those whose name has
+// the given prefix.
+func removeCookies(rw http.ResponseWriter, r *http.Request, prefix string) {
 	cs := r.Cookies()
 
 	res := make([]*http.Cookie, 0, len(cs))
 	for _, c := range cs {
-		if !strings.HasPrefix(c.Name, s.name) {
+		if !strings.HasPrefix(c.Name, prefix) {
 			res = append(res, c)
 		}
 	}
@@ -169,44 +180,64 @@ func (s *CookieSessionStore) RemoveCookie(rw http.ResponseWriter, r *http.Reques
 }
 
 func (s *CookieSessionStore) encode(session SessionData) ([]byte, error) {
-	blockSize := s.block.BlockSize()
-
 	ser, err := json.Marshal(session)
 	if err != nil {
 		return nil, fmt.Errorf("unable to serialize session: %w", err)
 	}
 
-	encrypted := make([]byte, blockSize+len(ser))
-	iv := s.rand.Bytes(blockSize)
+	return s.keys.Encrypt(s.rand, ser), nil
+}
+
+func (s *CookieSessionStore) decode(p []byte) (SessionData, error) {
+	decrypted, rotated, err := s.keys.Decrypt(p)
+	if err != nil {
+		return SessionData{}, fmt.Errorf("unable to decode session: %w", err)
+	}
+
+	var sess SessionData
+	if err = json.Unmarshal(decrypted, &sess); err != nil {
+		return SessionData{}, fmt.Errorf("unable to deserialize session: %w", err)
+	}
+	sess.rotated = rotated
+
+	return sess, nil
+}
+
+// encrypt encrypts the given payload using AES-CTR with a random IV, and returns it base64 encoded.
+func encrypt(block cipher.Block, rand Randr, payload []byte) []byte {
+	blockSize := block.BlockSize()
+
+	encrypted := make([]byte, blockSize+len(payload))
+	iv := rand.Bytes(blockSize)
 	copy(encrypted[:blockSize], iv)
-	stream := cipher.NewCTR(s.block, iv)
-	stream.XORKeyStream(encrypted[blockSize:], ser)
+	stream := cipher.NewCTR(block, iv)
+	stream.XORKeyStream(encrypted[blockSize:], payload)
 
 	encoded := make([]byte, base64.RawURLEncoding.EncodedLen(len(encrypted)))
 	base64.RawURLEncoding.Encode(encoded, encrypted)
 
-	return encoded, nil
+	return encoded
 }
 
-func (s *CookieSessionStore) decode(p []byte) (SessionData, error) {
-	blockSize := s.block.BlockSize()
+// decrypt decrypts the given payload, encrypted by encrypt.
+func decrypt(block cipher.Block, p []byte) ([]byte, error) {
+	blockSize := block.BlockSize()
 
 	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(len(p)))
 	if _, err := base64.RawURLEncoding.Decode(decoded, p); err != nil {
-		return SessionData{}, fmt.Errorf("unable to decode session: %w", err)
+		return nil, err
+	}
+
+	if len(decoded) < blockSize {
+		return nil, errors.New("payload too short")
 	}
 
 	decrypted := make([]byte, len(decoded)-blockSize)
 	iv := decoded[:blockSize]
-	stream := cipher.NewCTR(s.block, iv)
+	stream := cipher.NewCTR(block, iv)
 	stream.XORKeyStream(decrypted, decoded[blockSize:])
 
-	var sess SessionData
-	if err := json.Unmarshal(decrypted, &sess); err != nil {
-		return SessionData{}, fmt.Errorf("unable to deserialize session: %w", err)
-	}
-
-	return sess, nil
+	return decrypted, nil
 }
 
 func chunkBytes(b []byte, lim int) [][]byte {
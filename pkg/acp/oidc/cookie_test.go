@@ -18,7 +18,6 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package oidc
 
 import (
-	"crypto/aes"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -28,10 +27,10 @@ import (
 )
 
 func TestCookieSessionStore_Delete(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -70,10 +69,10 @@ func TestCookieSessionStore_Delete(t *testing.T) {
 }
 
 func TestCookieSessionStore_RemoveCookieOnlyRemovesOurCookie(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -105,10 +104,10 @@ func TestCookieSessionStore_RemoveCookieOnlyRemovesOurCookie(t *testing.T) {
 }
 
 func TestCookieSessionStore_Create(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -129,10 +128,10 @@ func TestCookieSessionStore_Create(t *testing.T) {
 }
 
 func TestCookieSessionStore_CreateCanChunkCookies(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -167,10 +166,10 @@ func TestCookieSessionStore_CreateCanChunkCookies(t *testing.T) {
 }
 
 func TestCookieSessionStore_Update(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{
 		Path:     "/",
 		Domain:   "example.com",
 		SameSite: "lax",
@@ -191,10 +190,10 @@ func TestCookieSessionStore_Update(t *testing.T) {
 }
 
 func TestCookieSessionStore_Get(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 	req.AddCookie(&http.Cookie{
@@ -210,10 +209,10 @@ func TestCookieSessionStore_Get(t *testing.T) {
 }
 
 func TestCookieSessionStore_GetHandlesChunkedCookies(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 	req.AddCookie(&http.Cookie{
@@ -237,10 +236,10 @@ func TestCookieSessionStore_GetHandlesChunkedCookies(t *testing.T) {
 }
 
 func TestCookieSessionStore_GetReturnsNilIfNoSessionExists(t *testing.T) {
-	block, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
-	store := NewCookieSessionStore("test-name", block, &AuthSession{}, RandrMock{}, 200)
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{}, RandrMock{}, 200)
 
 	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
 
@@ -0,0 +1,191 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// keyIDSeparator separates the key ID from the encrypted payload. It can't be part of Secret keys nor of base64url
+// encoded data.
+const keyIDSeparator = '~'
+
+// KeyRing encrypts and decrypts sessions. The current key encrypts payloads, previous keys still decrypt payloads
+// they encrypted within the grace period, so keys can be rotated without logging users out.
+//
+// Payloads are encrypted with AES-GCM, authenticating the time they were encrypted at along with the ID of their key.
+// A key ring built from a single key with an empty ID uses the historical unversioned format, without grace period.
+type KeyRing struct {
+	current ringKey
+	keys    map[string]ringKey
+	grace   time.Duration
+
+	now func() time.Time
+}
+
+type ringKey struct {
+	id string
+	// block encrypts payloads in the unversioned format, aead in the versioned one.
+	block cipher.Block
+	aead  cipher.AEAD
+}
+
+// NewKeyRing creates a key ring from the given keys, indexed by ID. The key with the greatest ID is the current one:
+// IDs are compared as numbers if they all are integers, like 9 and 10, and in lexical order otherwise, like dates.
+// Payloads encrypted with other keys are accepted for the given grace period after they were encrypted.
+func NewKeyRing(keys map[string]string, grace time.Duration) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("missing session key")
+	}
+
+	ids := make([]string, 0, len(keys))
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	sortKeyIDs(ids)
+
+	if _, ok := keys[""]; ok && len(ids) > 1 {
+		return nil, errors.New("empty session key ID")
+	}
+
+	ring := &KeyRing{
+		keys:  make(map[string]ringKey, len(keys)),
+		grace: grace,
+		now:   time.Now,
+	}
+	for _, id := range ids {
+		block, err := aes.NewCipher([]byte(keys[id]))
+		if err != nil {
+			return nil, fmt.Errorf("new cipher for session key %q: %w", id, err)
+		}
+
+		key := ringKey{id: id, block: block}
+		if id != "" {
+			key.aead, err = cipher.NewGCM(block)
+			if err != nil {
+				return nil, fmt.Errorf("new AEAD for session key %q: %w", id, err)
+			}
+		}
+
+		ring.keys[id] = key
+	}
+	ring.current = ring.keys[ids[len(ids)-1]]
+
+	return ring, nil
+}
+
+// sortKeyIDs sorts the given key IDs numerically if they all are integers, lexically otherwise.
+func sortKeyIDs(ids []string) {
+	nums := make(map[string]uint64, len(ids))
+	for _, id := range ids {
+		num, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			sort.Strings(ids)
+			return
+		}
+
+		nums[id] = num
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return nums[ids[i]] < nums[ids[j]]
+	})
+}
+
+// Encrypt encrypts the given payload with the current key.
+func (r *KeyRing) Encrypt(rand Randr, payload []byte) []byte {
+	if r.current.id == "" {
+		return encrypt(r.current.block, rand, payload)
+	}
+
+	stamped := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint64(stamped, uint64(r.now().Unix()))
+	copy(stamped[8:], payload)
+
+	nonce := rand.Bytes(r.current.aead.NonceSize())
+	sealed := r.current.aead.Seal(nonce, nonce, stamped, []byte(r.current.id))
+
+	p := make([]byte, len(r.current.id)+1+base64.RawURLEncoding.EncodedLen(len(sealed)))
+	n := copy(p, r.current.id)
+	p[n] = keyIDSeparator
+	base64.RawURLEncoding.Encode(p[n+1:], sealed)
+
+	return p
+}
+
+// Decrypt decrypts the given payload, encrypted by Encrypt. It also returns whether the payload was encrypted with a
+// previous key, in which case it should be encrypted again with the current one.
+func (r *KeyRing) Decrypt(p []byte) ([]byte, bool, error) {
+	var id string
+	if i := bytes.IndexByte(p, keyIDSeparator); i >= 0 {
+		id, p = string(p[:i]), p[i+1:]
+	}
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown session key %q", id)
+	}
+
+	if id == "" {
+		decrypted, err := decrypt(key.block, p)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return decrypted, false, nil
+	}
+
+	sealed := make([]byte, base64.RawURLEncoding.DecodedLen(len(p)))
+	if _, err := base64.RawURLEncoding.Decode(sealed, p); err != nil {
+		return nil, false, err
+	}
+
+	nonceSize := key.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, false, errors.New("payload too short")
+	}
+
+	decrypted, err := key.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(id))
+	if err != nil {
+		return nil, false, fmt.Errorf("open payload: %w", err)
+	}
+
+	if len(decrypted) < 8 {
+		return nil, false, errors.New("payload too short")
+	}
+
+	if key.id == r.current.id {
+		return decrypted[8:], false, nil
+	}
+
+	encryptedAt := time.Unix(int64(binary.BigEndian.Uint64(decrypted)), 0)
+	if r.now().Sub(encryptedAt) > r.grace {
+		return nil, false, fmt.Errorf("session key %q is past its grace period", id)
+	}
+
+	return decrypted[8:], true, nil
+}
@@ -0,0 +1,264 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyRing(t *testing.T) {
+	tests := []struct {
+		desc    string
+		keys    map[string]string
+		wantErr string
+	}{
+		{
+			desc:    "no keys",
+			wantErr: "missing session key",
+		},
+		{
+			desc:    "empty ID among other keys",
+			keys:    map[string]string{"": "secret1234567890", "2023-01": "secret0987654321"},
+			wantErr: "empty session key ID",
+		},
+		{
+			desc:    "invalid key size",
+			keys:    map[string]string{"2023-01": "secret"},
+			wantErr: `new cipher for session key "2023-01": crypto/aes: invalid key size 6`,
+		},
+		{
+			desc: "valid keys",
+			keys: map[string]string{"2023-01": "secret1234567890", "2023-02": "secret0987654321"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewKeyRing(test.keys, time.Hour)
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNewKeyRing_currentKey(t *testing.T) {
+	tests := []struct {
+		desc        string
+		ids         []string
+		wantCurrent string
+	}{
+		{
+			desc:        "integer IDs are compared as numbers",
+			ids:         []string{"9", "10", "2"},
+			wantCurrent: "10",
+		},
+		{
+			desc:        "other IDs are compared lexically",
+			ids:         []string{"2023-02", "2023-10", "2023-09"},
+			wantCurrent: "2023-10",
+		},
+		{
+			desc:        "integer and other IDs are compared lexically",
+			ids:         []string{"9", "10", "1a"},
+			wantCurrent: "9",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			keys := make(map[string]string)
+			for _, id := range test.ids {
+				keys[id] = "secret1234567890"
+			}
+
+			ring, err := NewKeyRing(keys, time.Hour)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantCurrent, ring.current.id)
+		})
+	}
+}
+
+func TestKeyRing_RejectsTamperedPayloads(t *testing.T) {
+	now := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	oldRing, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890"}, time.Hour)
+	require.NoError(t, err)
+	oldRing.now = func() time.Time { return now }
+
+	encrypted := oldRing.Encrypt(RandrMock{}, []byte("payload"))
+
+	ring, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890", "2023-02": "secret0987654321"}, time.Hour)
+	require.NoError(t, err)
+	ring.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	// Flip the bits of the encryption time so the payload would look encrypted within the grace period.
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(string(encrypted), "2023-01~"))
+	require.NoError(t, err)
+
+	nonceSize := ring.keys["2023-01"].aead.NonceSize()
+	var stamp, target [8]byte
+	binary.BigEndian.PutUint64(stamp[:], uint64(now.Unix()))
+	binary.BigEndian.PutUint64(target[:], uint64(now.Add(2*time.Hour).Unix()))
+	for i := range stamp {
+		sealed[nonceSize+i] ^= stamp[i] ^ target[i]
+	}
+
+	tampered := "2023-01~" + base64.RawURLEncoding.EncodeToString(sealed)
+
+	_, _, err = ring.Decrypt([]byte(tampered))
+	assert.Error(t, err)
+
+	// A payload can't be moved to another key either.
+	_, _, err = ring.Decrypt([]byte("2023-02~" + strings.TrimPrefix(string(encrypted), "2023-01~")))
+	assert.Error(t, err)
+}
+
+func TestKeyRing_Rotation(t *testing.T) {
+	now := time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	oldRing, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890"}, time.Hour)
+	require.NoError(t, err)
+	oldRing.now = func() time.Time { return now }
+
+	encrypted := oldRing.Encrypt(RandrMock{}, []byte("payload"))
+	assert.True(t, strings.HasPrefix(string(encrypted), "2023-01~"))
+
+	payload, rotated, err := oldRing.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Equal(t, "payload", string(payload))
+
+	// The key is rotated, payloads encrypted with the previous key are still accepted for the grace period.
+	ring, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890", "2023-02": "secret0987654321"}, time.Hour)
+	require.NoError(t, err)
+	ring.now = func() time.Time { return now.Add(30 * time.Minute) }
+
+	payload, rotated, err = ring.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, "payload", string(payload))
+
+	reencrypted := ring.Encrypt(RandrMock{}, payload)
+	assert.True(t, strings.HasPrefix(string(reencrypted), "2023-02~"))
+
+	payload, rotated, err = ring.Decrypt(reencrypted)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Equal(t, "payload", string(payload))
+
+	ring.now = func() time.Time { return now.Add(2 * time.Hour) }
+
+	_, _, err = ring.Decrypt(encrypted)
+	assert.EqualError(t, err, `session key "2023-01" is past its grace period`)
+
+	// The current key is not subject to the grace period.
+	_, _, err = ring.Decrypt(reencrypted)
+	assert.NoError(t, err)
+
+	// Once removed, the previous key can't decrypt anymore.
+	newRing, err := NewKeyRing(map[string]string{"2023-02": "secret0987654321"}, time.Hour)
+	require.NoError(t, err)
+
+	_, _, err = newRing.Decrypt(encrypted)
+	assert.EqualError(t, err, `unknown session key "2023-01"`)
+}
+
+func TestKeyRing_UnversionedKey(t *testing.T) {
+	legacy, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
+	require.NoError(t, err)
+
+	encrypted := legacy.Encrypt(RandrMock{}, []byte("payload"))
+	assert.Equal(t, encrypt(legacy.current.block, RandrMock{}, []byte("payload")), encrypted)
+
+	payload, rotated, err := legacy.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Equal(t, "payload", string(payload))
+
+	ring, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890"}, time.Hour)
+	require.NoError(t, err)
+
+	_, _, err = ring.Decrypt(encrypted)
+	assert.EqualError(t, err, `unknown session key ""`)
+}
+
+func TestCookieSessionStore_GetRotatedSession(t *testing.T) {
+	oldKeys, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890"}, time.Hour)
+	require.NoError(t, err)
+
+	oldStore := NewCookieSessionStore("test-name", oldKeys, &AuthSession{}, RandrMock{}, 2000)
+
+	rec := httptest.NewRecorder()
+	err = oldStore.Create(rec, SessionData{AccessToken: "test1", IDToken: "test2"})
+	require.NoError(t, err)
+
+	keys, err := NewKeyRing(map[string]string{"2023-01": "secret1234567890", "2023-02": "secret0987654321"}, time.Hour)
+	require.NoError(t, err)
+
+	store := NewCookieSessionStore("test-name", keys, &AuthSession{}, RandrMock{}, 2000)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.True(t, sess.rotated)
+	assert.Equal(t, "test1", sess.AccessToken)
+
+	rec = httptest.NewRecorder()
+	err = store.Update(rec, req, *sess)
+	require.NoError(t, err)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge > 0 {
+			assert.True(t, strings.HasPrefix(c.Value, "2023-02~"))
+			req.AddCookie(c)
+		}
+	}
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.False(t, sess.rotated)
+}
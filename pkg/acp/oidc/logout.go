@@ -0,0 +1,243 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BackChannelLogoutPath is the path, relative to the path of the handler on the auth server, of the back-channel
+	// logout endpoint.
+	BackChannelLogoutPath = "/backchannel-logout"
+
+	backChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+)
+
+// Routes returns the additional endpoints served by the handler, indexed by their path relative to the path of the
+// handler. The back-channel logout endpoint is only served when sessions are stored server-side, as cookie sessions
+// cannot be ended without a request from the user.
+func (h *Handler) Routes() map[string]http.Handler {
+	if _, ok := h.session.(*ServerSessionStore); !ok {
+		return nil
+	}
+
+	return map[string]http.Handler{
+		BackChannelLogoutPath: http.HandlerFunc(h.handleBackChannelLogout),
+	}
+}
+
+// logoutFromProvider deletes the session and redirects the user to the end_session_endpoint of the provider.
+// spec: https://openid.net/specs/openid-connect-rpinitiated-1_0.html#RPLogout
+func (h *Handler) logoutFromProvider(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "OIDC").Str("handler_name", h.name).Logger()
+
+	sess, err := h.session.Get(req)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Unable to get the session")
+	}
+
+	if err = h.session.Delete(rw, req); err != nil {
+		logger.Debug().Err(err).Msg("Unable to delete the session")
+	}
+
+	endSessionURL, err := url.Parse(h.endSessionURL)
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid end session endpoint")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	query := endSessionURL.Query()
+	query.Set("client_id", h.cfg.ClientID)
+	if sess != nil && sess.IDToken != "" {
+		query.Set("id_token_hint", sess.IDToken)
+	}
+	if h.cfg.PostLogoutRedirectURL != "" {
+		query.Set("post_logout_redirect_uri", resolveURL(req, h.cfg.PostLogoutRedirectURL))
+	}
+	endSessionURL.RawQuery = query.Encode()
+
+	if req.Header.Get("From") == "nginx" {
+		rw.Header().Add("url_redirect", endSessionURL.String())
+		rw.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	http.Redirect(rw, req, endSessionURL.String(), http.StatusFound)
+}
+
+// handleFrontChannelLogout ends the session of a user who logged out from the provider.
+// spec: https://openid.net/specs/openid-connect-frontchannel-1_0.html#RPLogout
+func (h *Handler) handleFrontChannelLogout(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "OIDC").Str("handler_name", h.name).Logger()
+
+	u, err := url.Parse(req.Header.Get("X-Forwarded-Uri"))
+	if err != nil {
+		logger.Debug().Err(err).Msg("Malformed front-channel logout request")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	if iss := u.Query().Get("iss"); iss != "" && iss != h.cfg.Issuer {
+		logger.Debug().Str("iss", iss).Msg("Front-channel logout request from an unknown issuer")
+		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-cache, no-store")
+
+	// Third-party cookies may be blocked in the frame loading this URL. Sessions stored server-side can be ended from
+	// their provider session ID anyway.
+	sid := u.Query().Get("sid")
+	if store, ok := h.session.(*ServerSessionStore); ok && sid != "" {
+		if _, err = store.RevokeSID(req.Context(), sid); err != nil {
+			logger.Error().Err(err).Msg("Unable to revoke sessions")
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	sess, err := h.session.Get(req)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Unable to get the session")
+	}
+
+	if sess != nil && (sid == "" || sess.SID == "" || sess.SID == sid) {
+		if err = h.session.Delete(rw, req); err != nil {
+			logger.Debug().Err(err).Msg("Unable to delete the session")
+		}
+	}
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// handleBackChannelLogout ends the sessions designated by the logout token sent by the provider.
+// spec: https://openid.net/specs/openid-connect-backchannel-1_0.html#BCRequest
+func (h *Handler) handleBackChannelLogout(rw http.ResponseWriter, req *http.Request) {
+	logger := log.With().Str("handler_type", "OIDC").Str("handler_name", h.name).Logger()
+
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	rw.Header().Set("Cache-Control", "no-store")
+
+	sub, sid, err := h.verifyLogoutToken(req)
+	if err != nil {
+		logger.Debug().Err(err).Msg("Invalid logout token")
+		writeLogoutError(rw, err)
+
+		return
+	}
+
+	store, ok := h.session.(*ServerSessionStore)
+	if !ok {
+		http.Error(rw, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
+
+		return
+	}
+
+	var revoked int
+	if sid != "" {
+		revoked, err = store.RevokeSID(req.Context(), sid)
+	} else {
+		revoked, err = store.RevokeSubject(req.Context(), sub)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Unable to revoke sessions")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	logger.Debug().Str("sub", sub).Str("sid", sid).Int("revoked", revoked).Msg("Sessions ended by back-channel logout")
+
+	rw.WriteHeader(http.StatusOK)
+}
+
+// verifyLogoutToken validates the logout token of the given back-channel logout request and returns its subject and
+// session ID, one of which may be empty.
+// spec: https://openid.net/specs/openid-connect-backchannel-1_0.html#Validation
+func (h *Handler) verifyLogoutToken(req *http.Request) (sub, sid string, err error) {
+	rawToken := req.PostFormValue("logout_token")
+	if rawToken == "" {
+		return "", "", errors.New("missing logout token")
+	}
+
+	token, err := h.logoutVerifier.Verify(req.Context(), rawToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	var claims struct {
+		SID    string                     `json:"sid"`
+		Nonce  *string                    `json:"nonce"`
+		Events map[string]json.RawMessage `json:"events"`
+	}
+	if err = token.Claims(&claims); err != nil {
+		return "", "", fmt.Errorf("unable to unmarshal claims: %w", err)
+	}
+
+	if _, ok := claims.Events[backChannelLogoutEvent]; !ok {
+		return "", "", errors.New("missing back-channel logout event")
+	}
+
+	if claims.Nonce != nil {
+		return "", "", errors.New("logout tokens must not have a nonce")
+	}
+
+	if token.IssuedAt.IsZero() {
+		return "", "", errors.New("missing issued at")
+	}
+
+	if !token.Expiry.IsZero() && time.Now().After(token.Expiry) {
+		return "", "", fmt.Errorf("token expired at %s", token.Expiry)
+	}
+
+	if token.Subject == "" && claims.SID == "" {
+		return "", "", errors.New("missing subject and session ID")
+	}
+
+	return token.Subject, claims.SID, nil
+}
+
+func writeLogoutError(rw http.ResponseWriter, err error) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusBadRequest)
+
+	_ = json.NewEncoder(rw).Encode(map[string]string{
+		"error":             "invalid_request",
+		"error_description": err.Error(),
+	})
+}
@@ -0,0 +1,308 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware_LogsOutFromProvider(t *testing.T) {
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(&SessionData{IDToken: "id-token"}, nil).Once().
+		OnDeleteRaw(mock.Anything, mock.Anything).TypedReturns(nil).Once().
+		Parent
+
+	handler := buildHandler(t)
+	handler.session = session
+	handler.endSessionURL = "https://idp.example.com/logout?tenant=acme"
+	handler.cfg = &Config{
+		Issuer:                "https://idp.example.com",
+		ClientID:              "client-12345",
+		LogoutURL:             "/logout",
+		ProviderLogout:        true,
+		PostLogoutRedirectURL: "/bye",
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/logout", nil)
+	req.Header.Add("X-Forwarded-Method", req.Method)
+	req.Header.Add("X-Forwarded-Proto", "https")
+	req.Header.Add("X-Forwarded-Host", req.Host)
+	req.Header.Add("X-Forwarded-URI", req.URL.RequestURI())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", location.Host)
+	assert.Equal(t, "/logout", location.Path)
+	assert.Equal(t, url.Values{
+		"tenant":                   {"acme"},
+		"client_id":                {"client-12345"},
+		"id_token_hint":            {"id-token"},
+		"post_logout_redirect_uri": {"https://example.com/bye"},
+	}, location.Query())
+}
+
+func TestHandler_Routes(t *testing.T) {
+	handler := buildHandler(t)
+	handler.session = newSessionStoreMock(t)
+
+	assert.Empty(t, handler.Routes())
+
+	handler.session = &ServerSessionStore{}
+
+	assert.Contains(t, handler.Routes(), BackChannelLogoutPath)
+}
+
+func TestHandler_BackChannelLogout(t *testing.T) {
+	event := map[string]interface{}{backChannelLogoutEvent: map[string]interface{}{}}
+	now := time.Now().Unix()
+
+	tests := []struct {
+		desc         string
+		method       string
+		claims       map[string]interface{}
+		wantStatus   int
+		wantSessions []string
+	}{
+		{
+			desc:   "ends the sessions with the session ID",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "events": event, "sub": "alice", "sid": "sid-1",
+			},
+			wantStatus:   http.StatusOK,
+			wantSessions: []string{"session-2", "session-3"},
+		},
+		{
+			desc:   "ends the sessions of the subject",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "events": event, "sub": "alice",
+			},
+			wantStatus:   http.StatusOK,
+			wantSessions: []string{"session-3"},
+		},
+		{
+			desc:   "unsupported method",
+			method: http.MethodGet,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "events": event, "sub": "alice",
+			},
+			wantStatus:   http.StatusMethodNotAllowed,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			desc:   "invalid audience",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "other", "iat": now, "jti": "1", "events": event, "sub": "alice",
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			desc:   "missing event",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "sub": "alice",
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			desc:   "nonce",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "events": event, "sub": "alice", "nonce": "nonce",
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			desc:   "expired",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now - 120, "exp": now - 60, "jti": "1", "events": event, "sub": "alice",
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+		{
+			desc:   "missing subject and session ID",
+			method: http.MethodPost,
+			claims: map[string]interface{}{
+				"aud": "client-12345", "iat": now, "jti": "1", "events": event,
+			},
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2", "session-3"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			store, backend := newTestServerSessionStore(t)
+
+			ctx := context.Background()
+			sessions := map[string]StoredSession{
+				"session-1": {Subject: "alice", SID: "sid-1"},
+				"session-2": {Subject: "alice", SID: "sid-2"},
+				"session-3": {Subject: "bob", SID: "sid-3"},
+			}
+			for id, sess := range sessions {
+				err := backend.Save(ctx, store.key(id), sess, time.Minute)
+				require.NoError(t, err)
+			}
+
+			handler := buildHandler(t)
+			handler.logoutVerifier = handler.verifier
+			handler.session = store
+
+			form := url.Values{"logout_token": {newUnsignedToken(t, test.claims)}}
+			req := httptest.NewRequest(test.method, "/my-acp/backchannel-logout", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rec := httptest.NewRecorder()
+			handler.Routes()[BackChannelLogoutPath].ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+
+			var remaining []string
+			for id := range sessions {
+				sess, err := backend.Load(ctx, store.key(id))
+				require.NoError(t, err)
+
+				if sess != nil {
+					remaining = append(remaining, id)
+				}
+			}
+			assert.ElementsMatch(t, test.wantSessions, remaining)
+		})
+	}
+}
+
+func TestMiddleware_FrontChannelLogout(t *testing.T) {
+	tests := []struct {
+		desc         string
+		query        string
+		wantStatus   int
+		wantSessions []string
+	}{
+		{
+			desc:         "ends the sessions with the session ID",
+			query:        "?iss=https%3A%2F%2Fidp.example.com&sid=sid-1",
+			wantStatus:   http.StatusOK,
+			wantSessions: []string{"session-2"},
+		},
+		{
+			desc:         "unknown issuer",
+			query:        "?iss=https%3A%2F%2Fother.example.com&sid=sid-1",
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: []string{"session-1", "session-2"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			store, backend := newTestServerSessionStore(t)
+
+			ctx := context.Background()
+			sessions := map[string]StoredSession{
+				"session-1": {Subject: "alice", SID: "sid-1"},
+				"session-2": {Subject: "alice", SID: "sid-2"},
+			}
+			for id, sess := range sessions {
+				err := backend.Save(ctx, store.key(id), sess, time.Minute)
+				require.NoError(t, err)
+			}
+
+			handler := buildHandler(t)
+			handler.session = store
+			handler.cfg = &Config{
+				Issuer:                "https://idp.example.com",
+				FrontChannelLogoutURL: "/frontchannel-logout",
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "https://example.com/frontchannel-logout"+test.query, nil)
+			req.Header.Add("X-Forwarded-Method", req.Method)
+			req.Header.Add("X-Forwarded-Proto", "https")
+			req.Header.Add("X-Forwarded-Host", req.Host)
+			req.Header.Add("X-Forwarded-URI", req.URL.RequestURI())
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+
+			var remaining []string
+			for id := range sessions {
+				sess, err := backend.Load(ctx, store.key(id))
+				require.NoError(t, err)
+
+				if sess != nil {
+					remaining = append(remaining, id)
+				}
+			}
+			assert.ElementsMatch(t, test.wantSessions, remaining)
+		})
+	}
+}
+
+func newTestServerSessionStore(t *testing.T) (*ServerSessionStore, *MemorySessionBackend) {
+	t.Helper()
+
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
+	require.NoError(t, err)
+
+	backend := NewMemorySessionBackend()
+
+	return NewServerSessionStore("test-session", "my-acp", backend, keys, &AuthSession{}, RandrMock{}), backend
+}
+
+// newUnsignedToken returns a JWT holding the given claims, with a fake signature.
+func newUnsignedToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","typ":"logout+jwt"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte("signature"))
+}
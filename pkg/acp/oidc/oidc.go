@@ -19,10 +19,7 @@ package oidc
 
 import (
 	"context"
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,7 +31,9 @@ import (
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
 	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/metrics"
 	"golang.org/x/oauth2"
 )
 
@@ -72,9 +71,18 @@ type SessionData struct {
 	TokenType    string `json:"tokenType"`
 	RefreshToken string `json:"refreshToken"`
 	IDToken      string `json:"idToken"`
+	// Subject is the subject of the ID token.
+	Subject string `json:"subject,omitempty"`
+	// SID is the session ID of the provider, from the "sid" claim of the ID token, if any.
+	SID string `json:"sid,omitempty"`
+	// UserInfo holds the claims returned by the userinfo endpoint of the provider, if fetched.
+	UserInfo map[string]interface{} `json:"userInfo,omitempty"`
 
 	// Expiry is the expiration time of the access token.
 	Expiry time.Time `json:"expiry"`
+
+	// rotated is set when the session was encrypted with a previous session key and must be encrypted again.
+	rotated bool
 }
 
 // IsExpired determines if the current access token is expired.
@@ -109,7 +117,14 @@ type Handler struct {
 	verifier IDTokenVerifier
 	oauth    OAuthProvider
 	session  SessionStore
-	block    cipher.Block
+	keys     *KeyRing
+
+	// logoutVerifier verifies back-channel logout tokens.
+	logoutVerifier IDTokenVerifier
+	// endSessionURL is the end_session_endpoint of the provider, used for RP-initiated logout.
+	endSessionURL string
+	// userInfo fetches the claims of the userinfo endpoint of the provider with the given token.
+	userInfo func(ctx context.Context, tok *oauth2.Token) (map[string]interface{}, error)
 
 	validateClaims expr.Predicate
 
@@ -118,8 +133,9 @@ type Handler struct {
 	cfg *Config
 }
 
-// NewHandler creates a new instance of a Handler from an auth source.
-func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error) {
+// NewHandler creates a new instance of a Handler from an auth source. Server-side sessions are stored in the given
+// backends.
+func NewHandler(ctx context.Context, cfg *Config, name string, backends SessionBackends) (*Handler, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validate configuration: %w", err)
 	}
@@ -139,15 +155,57 @@ func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error)
 		}
 	}
 
-	block, err := aes.NewCipher([]byte(cfg.SessionKey))
+	sessionKeys := cfg.SessionKeys
+	if len(sessionKeys) == 0 {
+		sessionKeys = map[string]string{"": cfg.SessionKey}
+	}
+
+	keys, err := NewKeyRing(sessionKeys, time.Duration(cfg.Session.KeyGracePeriodSeconds)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("new key ring: %w", err)
+	}
+
+	session, err := newSessionStore(cfg.Session, name, keys, backends)
 	if err != nil {
-		return nil, fmt.Errorf("new cipher: %w", err)
+		return nil, err
+	}
+
+	var metadata struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+		UserInfoEndpoint   string `json:"userinfo_endpoint"`
+	}
+	if err = provider.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("unable to read provider metadata: %w", err)
+	}
+
+	if cfg.ProviderLogout && metadata.EndSessionEndpoint == "" {
+		return nil, errors.New("provider does not support RP-initiated logout")
+	}
+
+	if cfg.FetchUserInfo && metadata.UserInfoEndpoint == "" {
+		return nil, errors.New("provider does not have a userinfo endpoint")
 	}
 
 	return &Handler{
 		name:     name,
 		cfg:      cfg,
 		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		// Logout tokens may not have an expiry, it is checked when validating the token.
+		logoutVerifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID, SkipExpiryCheck: true}),
+		endSessionURL:  metadata.EndSessionEndpoint,
+		userInfo: func(ctx context.Context, tok *oauth2.Token) (map[string]interface{}, error) {
+			info, err := provider.UserInfo(oidc.ClientContext(ctx, client), oauth2.StaticTokenSource(tok))
+			if err != nil {
+				return nil, err
+			}
+
+			claims := make(map[string]interface{})
+			if err = info.Claims(&claims); err != nil {
+				return nil, fmt.Errorf("unmarshal claims: %w", err)
+			}
+
+			return claims, nil
+		},
 		oauth: &oauth2.Config{
 			ClientID:     cfg.ClientID,
 			ClientSecret: cfg.ClientSecret,
@@ -155,13 +213,34 @@ func NewHandler(ctx context.Context, cfg *Config, name string) (*Handler, error)
 			Scopes:       cfg.Scopes,
 		},
 		rand:           newRandom(),
-		session:        NewCookieSessionStore(name+"-session", block, cfg.Session, newRandom(), maxCookieSize),
-		block:          block,
+		session:        session,
+		keys:           keys,
 		validateClaims: pred,
 		client:         client,
 	}, nil
 }
 
+func newSessionStore(cfg *AuthSession, name string, keys *KeyRing, backends SessionBackends) (SessionStore, error) {
+	cookieName := name + "-session"
+
+	switch cfg.Store {
+	case "", SessionStoreCookie:
+		return NewCookieSessionStore(cookieName, keys, cfg, newRandom(), maxCookieSize), nil
+	case SessionStoreMemory:
+		if backends.Memory == nil {
+			return nil, errors.New("memory session store is not available")
+		}
+		return NewServerSessionStore(cookieName, name, backends.Memory, keys, cfg, newRandom()), nil
+	case SessionStoreRedis:
+		if backends.Redis == nil {
+			return nil, errors.New("redis session store is not configured on the auth server")
+		}
+		return NewServerSessionStore(cookieName, name, backends.Redis, keys, cfg, newRandom()), nil
+	default:
+		return nil, fmt.Errorf("unsupported session store %q", cfg.Store)
+	}
+}
+
 // The implementation below should be compliant with the Authorization Code Flow
 // of the specification at
 // https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth , which is
@@ -193,9 +272,22 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if h.cfg.ProviderLogout && equalURL(forwardedURL, logoutURL) && forwardedMethod == http.MethodGet {
+		h.logoutFromProvider(rw, req)
+
+		return
+	}
+
+	if h.cfg.FrontChannelLogoutURL != "" && equalURL(forwardedURL, resolveURL(req, h.cfg.FrontChannelLogoutURL)) {
+		h.handleFrontChannelLogout(rw, req)
+
+		return
+	}
+
 	sess, err := h.session.Get(req)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Unable to get the session")
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
 		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 
 		return
@@ -220,6 +312,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 		if !h.shouldRedirect(req) {
 			logger.Debug().Msg("Received a request that should not be redirected")
+			decision.SetReason(req, decision.ReasonAuthenticationRequired)
 			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 
 			return
@@ -235,6 +328,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	sess, refreshSession, err = h.maybeRefreshSession(req.Context(), sess)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Unable to refresh the session")
+		decision.SetReason(req, decision.ReasonExpiredCredentials)
 
 		if err = h.session.Delete(rw, req); err != nil {
 			logger.Debug().Err(err).Msg("Unable to delete the session")
@@ -281,6 +375,7 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	idToken, err = h.verifier.Verify(req.Context(), sess.IDToken)
 	if err != nil {
 		logger.Debug().Err(err).Msg("Invalid ID token")
+		decision.SetReason(req, decision.ReasonInvalidCredentials)
 		http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 
 		return
@@ -294,13 +389,42 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if h.validateClaims != nil && !h.validateClaims(claims) {
+	// Userinfo claims complete the ID token claims, which take precedence as they are signed by the provider.
+	for name, value := range sess.UserInfo {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+
+	if h.validateClaims != nil && !h.validateClaims(claims, req) {
 		logger.Debug().Err(err).Msg("Unauthorized claim")
+		decision.SetReason(req, decision.ReasonClaimsMismatch)
 		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 
 		return
 	}
 
+	var groups []string
+	if h.cfg.GroupsClaim != "" {
+		var match bool
+		groups, match, err = expr.MatchGroups(req, h.cfg.GroupsClaim, claims)
+		if err != nil {
+			logger.Error().Err(err).Msg("Unable to match groups")
+			decision.SetReason(req, decision.ReasonInternalError)
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+			return
+		}
+
+		if !match {
+			logger.Debug().Strs("user_groups", groups).Msg("User is not in the required groups")
+			decision.SetReason(req, decision.ReasonGroupsMismatch)
+			http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+
+			return
+		}
+	}
+
 	if err = h.forwardHeader(rw, claims); err != nil {
 		logger.Error().Err(err).Msg("Unable to set forwarded header")
 		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -309,9 +433,13 @@ func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	}
 
 	// 10th step of diagram.
-	rw.Header().Set("Authorization", "Bearer "+sess.AccessToken)
+	h.forwardAccessToken(rw, sess.AccessToken)
 	h.session.RemoveCookie(rw, req)
 
+	decision.SetSubject(req, idToken.Subject)
+	decision.SetGroups(req, groups)
+	decision.SetClaims(req, claims)
+
 	rw.WriteHeader(http.StatusOK)
 }
 
@@ -331,33 +459,103 @@ func (h *Handler) forwardHeader(rw http.ResponseWriter, claims map[string]interf
 	return nil
 }
 
-func (h *Handler) maybeRefreshSession(ctx context.Context, sess *SessionData) (s *SessionData, refresh bool, err error) {
-	if !(*h.cfg.Session.Refresh) || !sess.IsExpired() {
-		return sess, false, nil
+func (h *Handler) forwardAccessToken(rw http.ResponseWriter, accessToken string) {
+	if h.cfg.ForwardAccessToken != nil && !*h.cfg.ForwardAccessToken {
+		return
 	}
 
+	switch header := h.cfg.AccessTokenHeader; header {
+	case "", "Authorization":
+		rw.Header().Set("Authorization", "Bearer "+accessToken)
+	default:
+		rw.Header().Set(header, accessToken)
+	}
+}
+
+func (h *Handler) maybeRefreshSession(ctx context.Context, sess *SessionData) (*SessionData, bool, error) {
+	if *h.cfg.Session.Refresh && sess.IsExpired() {
+		refreshed, err := h.refreshSession(ctx, sess)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return refreshed, true, nil
+	}
+
+	// Sessions encrypted with a previous session key are encrypted again with the current one.
+	if sess.rotated && !sess.IsExpired() {
+		return sess, true, nil
+	}
+
+	// Sessions created before userinfo claims were fetched are completed.
+	if h.cfg.FetchUserInfo && sess.UserInfo == nil && !sess.IsExpired() {
+		userInfo, err := h.fetchUserInfo(ctx, sess.ToToken(), sess.Subject)
+		if err != nil {
+			return nil, false, err
+		}
+
+		completed := *sess
+		completed.UserInfo = userInfo
+
+		return &completed, true, nil
+	}
+
+	return sess, false, nil
+}
+
+func (h *Handler) refreshSession(ctx context.Context, sess *SessionData) (s *SessionData, err error) {
+	defer func() {
+		metrics.OIDCSessionRefreshes.Inc(h.name, metrics.Result(err))
+	}()
+
 	// We are in refresh mode and have and expired token, exchange for a new one.
 	// (not shown on diagram).
 	// spec: section 12.
 	ts := h.oauth.TokenSource(ctx, sess.ToToken())
 	tok, err := ts.Token()
 	if err != nil {
-		return nil, false, err
+		return nil, err
 	}
 
 	rawIDToken, ok := tok.Extra("id_token").(string)
 	if !ok {
-		return nil, false, errors.New("ID token not found")
+		return nil, errors.New("ID token not found")
 	}
 
-	sess = &SessionData{
+	var userInfo map[string]interface{}
+	if h.cfg.FetchUserInfo {
+		userInfo, err = h.fetchUserInfo(ctx, tok, sess.Subject)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &SessionData{
 		AccessToken:  tok.AccessToken,
 		TokenType:    tok.TokenType,
 		RefreshToken: tok.RefreshToken,
 		IDToken:      rawIDToken,
+		Subject:      sess.Subject,
+		SID:          sess.SID,
+		UserInfo:     userInfo,
 		Expiry:       tok.Expiry,
+	}, nil
+}
+
+// fetchUserInfo fetches the claims of the userinfo endpoint of the provider, which must be about the given subject.
+// spec: section 5.3.
+func (h *Handler) fetchUserInfo(ctx context.Context, tok *oauth2.Token, subject string) (map[string]interface{}, error) {
+	claims, err := h.userInfo(ctx, tok)
+	if err != nil {
+		return nil, fmt.Errorf("fetch userinfo: %w", err)
+	}
+
+	// spec: section 5.3.2, the sub claim must match the one of the ID token.
+	if sub, _ := claims["sub"].(string); subject != "" && sub != subject {
+		return nil, fmt.Errorf("userinfo subject %q does not match ID token subject %q", sub, subject)
 	}
-	return sess, true, nil
+
+	return claims, nil
 }
 
 func (h *Handler) redirectToProvider(rw http.ResponseWriter, req *http.Request, redirectURL string) {
@@ -487,12 +685,34 @@ func (h *Handler) handleProviderCallback(rw http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	var claims struct {
+		SID string `json:"sid"`
+	}
+	if err = idToken.Claims(&claims); err != nil {
+		logger.Debug().Err(err).Msg("Unable to unmarshal claims")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var userInfo map[string]interface{}
+	if h.cfg.FetchUserInfo {
+		userInfo, err = h.fetchUserInfo(req.Context(), oauth2Token, idToken.Subject)
+		if err != nil {
+			logger.Debug().Err(err).Msg("Unable to fetch userinfo")
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// 8th step of diagram.
 	sess := &SessionData{
 		AccessToken:  oauth2Token.AccessToken,
 		TokenType:    oauth2Token.TokenType,
 		RefreshToken: oauth2Token.RefreshToken,
 		IDToken:      rawIDToken,
+		Subject:      idToken.Subject,
+		SID:          claims.SID,
+		UserInfo:     userInfo,
 		Expiry:       oauth2Token.Expiry,
 	}
 	if err = h.session.Create(rw, *sess); err != nil {
@@ -512,19 +732,13 @@ func (h *Handler) getStateCookie(r *http.Request) (*StateData, error) {
 		return nil, nil
 	}
 
-	var state StateData
-	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(len(stateCookie)))
-	if _, err := base64.RawURLEncoding.Decode(decoded, stateCookie); err != nil {
+	decrypted, _, err := h.keys.Decrypt(stateCookie)
+	if err != nil {
 		return nil, fmt.Errorf("decode state: %w", err)
 	}
 
-	blockSize := h.block.BlockSize()
-	decrypted := make([]byte, len(decoded)-blockSize)
-	iv := decoded[:blockSize]
-	stream := cipher.NewCTR(h.block, iv)
-	stream.XORKeyStream(decrypted, decoded[blockSize:])
-
-	if err := json.Unmarshal(decrypted, &state); err != nil {
+	var state StateData
+	if err = json.Unmarshal(decrypted, &state); err != nil {
 		return nil, fmt.Errorf("deserialize state: %w", err)
 	}
 	return &state, nil
@@ -536,16 +750,9 @@ func (h *Handler) newStateCookie(state StateData) (*http.Cookie, error) {
 		return nil, fmt.Errorf("serialize state: %w", err)
 	}
 
-	blockSize := h.block.BlockSize()
-	encrypted := make([]byte, blockSize+len(statePayload))
-	iv := h.rand.Bytes(blockSize)
-	copy(encrypted[:blockSize], iv)
-	stream := cipher.NewCTR(h.block, iv)
-	stream.XORKeyStream(encrypted[blockSize:], statePayload)
-
 	return &http.Cookie{
 		Name:     h.name + "-state",
-		Value:    base64.RawURLEncoding.EncodeToString(encrypted),
+		Value:    string(h.keys.Encrypt(h.rand, statePayload)),
 		Path:     h.cfg.StateCookie.Path,
 		MaxAge:   600,
 		HttpOnly: true,
@@ -19,7 +19,6 @@ package oidc
 
 import (
 	"context"
-	"crypto/aes"
 	"encoding/base64"
 	"net/http"
 	"net/http/httptest"
@@ -62,13 +61,36 @@ func TestNewMiddlewareFromSource_ValidatesConfiguration(t *testing.T) {
 			},
 			wantErr: "validate configuration: missing client ID",
 		},
+		{
+			desc: "unsupported session store",
+			cfg: &Config{
+				Issuer:       "foo",
+				ClientID:     "bar",
+				ClientSecret: "bat",
+				SessionKey:   "secret1234567890",
+				RedirectURL:  "test",
+				Session:      &AuthSession{Store: "disk"},
+			},
+			wantErr: `validate configuration: unsupported session store "disk", must be one of "cookie", "memory" or "redis"`,
+		},
+		{
+			desc: "invalid rotated session key",
+			cfg: &Config{
+				Issuer:       "foo",
+				ClientID:     "bar",
+				ClientSecret: "bat",
+				SessionKeys:  map[string]string{"2023-01": "secret1234567890", "2023-02": "secret"},
+				RedirectURL:  "test",
+			},
+			wantErr: `validate configuration: session key "2023-02" must be 16, 24 or 32 characters long`,
+		},
 	}
 
 	for _, test := range tests {
 		test := test
 		t.Run(test.desc, func(t *testing.T) {
 			test.cfg.ApplyDefaultValues()
-			_, err := NewHandler(context.Background(), test.cfg, test.desc)
+			_, err := NewHandler(context.Background(), test.cfg, test.desc, SessionBackends{})
 
 			if test.wantErr != "" {
 				assert.Error(t, err)
@@ -302,6 +324,7 @@ func TestMiddleware_ExchangesTokenOnCallback(t *testing.T) {
 		AccessToken: oauth2tok.AccessToken,
 		IDToken:     jwtToken,
 		TokenType:   oauth2tok.TokenType,
+		Subject:     "alice",
 	}
 
 	session := newSessionStoreMock(t).
@@ -346,6 +369,7 @@ func TestMiddleware_ForwardsCorrectly(t *testing.T) {
 		expiry  time.Time
 		idToken string
 		headers map[string]string
+		groups  string
 
 		wantStatus              int
 		wantNextCalled          bool
@@ -375,6 +399,33 @@ func TestMiddleware_ForwardsCorrectly(t *testing.T) {
 			idToken:    jwtToken,
 			wantStatus: http.StatusForbidden,
 		},
+		{
+			desc: "returns forbidden if the user is not in the required groups",
+			cfg: &Config{
+				Issuer:       "http://foo.com",
+				ClientID:     "clientID",
+				ClientSecret: "secret1234567890",
+				RedirectURL:  "http://foo.com",
+				GroupsClaim:  "group",
+			},
+			idToken:    jwtToken,
+			groups:     "dev,ops",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			desc: "forwards call if the user is in one of the required groups",
+			cfg: &Config{
+				Issuer:       "http://foo.com",
+				ClientID:     "clientID",
+				ClientSecret: "secret1234567890",
+				RedirectURL:  "http://foo.com",
+				GroupsClaim:  "group",
+			},
+			idToken:        jwtToken,
+			groups:         "dev,admin",
+			wantStatus:     http.StatusOK,
+			wantNextCalled: true,
+		},
 		{
 			desc: "refreshes token if expired",
 			cfg: &Config{
@@ -490,7 +541,12 @@ func TestMiddleware_ForwardsCorrectly(t *testing.T) {
 			handler.validateClaims = pred
 			handler.cfg = test.cfg
 
-			r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			target := "/foo"
+			if test.groups != "" {
+				target += "?groups=" + url.QueryEscape(test.groups)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, target, nil)
 			for k, v := range test.headers {
 				r.Header.Add(k, v)
 			}
@@ -506,6 +562,170 @@ func TestMiddleware_ForwardsCorrectly(t *testing.T) {
 	}
 }
 
+func TestMiddleware_ForwardsUserInfoAndAccessToken(t *testing.T) {
+	forwardAccessToken := false
+
+	tests := []struct {
+		desc     string
+		cfg      *Config
+		userInfo map[string]interface{}
+
+		wantStatus              int
+		wantUpdateSessionCalled bool
+		wantForwardedHeaders    map[string]string
+	}{
+		{
+			desc: "completes ID token claims with userinfo claims",
+			cfg: &Config{
+				Claims:        "Equals(`group`,`admin`) && Equals(`department`,`sales`)",
+				FetchUserInfo: true,
+				ForwardHeaders: map[string]string{
+					"Group":      "group",
+					"Department": "department",
+				},
+			},
+			userInfo: map[string]interface{}{"sub": "alice", "group": "dev", "department": "sales"},
+			wantForwardedHeaders: map[string]string{
+				"Group":         "admin",
+				"Department":    "sales",
+				"Authorization": "Bearer test",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "forwards the access token in a custom header",
+			cfg: &Config{
+				AccessTokenHeader: "X-Access-Token",
+			},
+			wantForwardedHeaders: map[string]string{
+				"X-Access-Token": "test",
+				"Authorization":  "",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "does not forward the access token",
+			cfg: &Config{
+				ForwardAccessToken: &forwardAccessToken,
+			},
+			wantForwardedHeaders: map[string]string{
+				"Authorization": "",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc: "fetches userinfo claims missing from the session",
+			cfg: &Config{
+				FetchUserInfo: true,
+			},
+			wantUpdateSessionCalled: true,
+			wantStatus:              http.StatusFound,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			test.cfg.ApplyDefaultValues()
+
+			session := newSessionStoreMock(t).
+				OnGetRaw(mock.Anything).TypedReturns(&SessionData{
+				AccessToken: "test",
+				IDToken:     jwtToken,
+				Subject:     "alice",
+				UserInfo:    test.userInfo,
+				Expiry:      time.Now().Add(time.Minute),
+			}, nil).Once().
+				Parent
+
+			if test.wantUpdateSessionCalled {
+				session.OnUpdateRaw(mock.Anything, mock.Anything, mock.Anything).
+					Call.Run(func(args mock.Arguments) {
+					sess := args.Get(2).(SessionData)
+					assert.Equal(t, map[string]interface{}{"sub": "alice", "group": "dev"}, sess.UserInfo)
+				}).Return(nil).Once()
+			}
+
+			if test.wantStatus == http.StatusOK {
+				session.OnRemoveCookieRaw(mock.Anything, mock.Anything).Once()
+			}
+
+			pred, err := expr.Parse(test.cfg.Claims)
+			if test.cfg.Claims == "" {
+				pred = nil
+			} else {
+				require.NoError(t, err)
+			}
+
+			handler := buildHandler(t)
+			handler.session = session
+			handler.validateClaims = pred
+			handler.cfg = test.cfg
+			handler.userInfo = func(_ context.Context, tok *oauth2.Token) (map[string]interface{}, error) {
+				assert.Equal(t, "test", tok.AccessToken)
+
+				return map[string]interface{}{"sub": "alice", "group": "dev"}, nil
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, test.wantStatus, w.Code)
+			for name, value := range test.wantForwardedHeaders {
+				assert.Equal(t, value, w.Header().Get(name), name)
+			}
+		})
+	}
+}
+
+func TestMiddleware_ReencryptsRotatedSessions(t *testing.T) {
+	cfg := &Config{}
+	cfg.ApplyDefaultValues()
+
+	session := newSessionStoreMock(t).
+		OnGetRaw(mock.Anything).TypedReturns(&SessionData{
+		AccessToken: "test",
+		IDToken:     jwtToken,
+		Subject:     "alice",
+		Expiry:      time.Now().Add(time.Minute),
+		rotated:     true,
+	}, nil).Once().
+		Parent
+
+	session.OnUpdateRaw(mock.Anything, mock.Anything, mock.Anything).
+		Call.Run(func(args mock.Arguments) {
+		sess := args.Get(2).(SessionData)
+		assert.Equal(t, "test", sess.AccessToken)
+	}).Return(nil).Once()
+
+	handler := buildHandler(t)
+	handler.session = session
+	handler.cfg = cfg
+
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusFound, w.Code)
+}
+
+func TestHandler_fetchUserInfo(t *testing.T) {
+	handler := buildHandler(t)
+	handler.userInfo = func(context.Context, *oauth2.Token) (map[string]interface{}, error) {
+		return map[string]interface{}{"sub": "bob", "group": "dev"}, nil
+	}
+
+	_, err := handler.fetchUserInfo(context.Background(), &oauth2.Token{}, "alice")
+	assert.EqualError(t, err, `userinfo subject "bob" does not match ID token subject "alice"`)
+
+	claims, err := handler.fetchUserInfo(context.Background(), &oauth2.Token{}, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"sub": "bob", "group": "dev"}, claims)
+}
+
 func TestMiddleware_LogsOutCorrectly(t *testing.T) {
 	tests := []struct {
 		desc      string
@@ -569,7 +789,7 @@ func buildHandler(t *testing.T) *Handler {
 		},
 	)
 
-	stateBlock, err := aes.NewCipher([]byte("secret1234567890"))
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
 	require.NoError(t, err)
 
 	client := newHTTPClient()
@@ -577,7 +797,7 @@ func buildHandler(t *testing.T) *Handler {
 
 	return &Handler{
 		name:     "test",
-		block:    stateBlock,
+		keys:     keys,
 		rand:     newRandom(),
 		client:   client,
 		verifier: verifier,
@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RevocationHandler is an admin endpoint revoking the server-side sessions of a user. It expects POST requests with a
+// "subject" parameter, and optionally an "acp" parameter restricting the revocation to the sessions of a policy.
+// Requests must be authenticated with the admin token as a bearer token.
+type RevocationHandler struct {
+	backends SessionBackends
+	token    string
+}
+
+// NewRevocationHandler returns a new RevocationHandler. Every request is rejected if the given token is empty.
+func NewRevocationHandler(backends SessionBackends, token string) *RevocationHandler {
+	return &RevocationHandler{
+		backends: backends,
+		token:    token,
+	}
+}
+
+func (h *RevocationHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if h.token == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	subject := req.FormValue("subject")
+	if subject == "" {
+		http.Error(rw, `missing "subject" parameter`, http.StatusBadRequest)
+		return
+	}
+
+	var prefix string
+	if policy := req.FormValue("acp"); policy != "" {
+		prefix = policy + ":"
+	}
+
+	var revoked int
+	for _, backend := range h.backends.All() {
+		n, err := backend.RevokeSubject(req.Context(), subject, prefix)
+		revoked += n
+		if err != nil {
+			log.Error().Err(err).Str("subject", subject).Msg("Unable to revoke sessions")
+			http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Info().Str("subject", subject).Int("revoked", revoked).Msg("Sessions revoked")
+
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(map[string]int{"revoked": revoked}); err != nil {
+		log.Error().Err(err).Msg("Unable to write revocation response")
+	}
+}
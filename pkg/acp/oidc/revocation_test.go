@@ -0,0 +1,152 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationHandler(t *testing.T) {
+	tests := []struct {
+		desc        string
+		method      string
+		token       string
+		params      url.Values
+		wantStatus  int
+		wantBody    string
+		wantRevoked []string
+	}{
+		{
+			desc:       "unsupported method",
+			method:     http.MethodGet,
+			token:      "admin-token",
+			params:     url.Values{"subject": {"alice"}},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			desc:       "missing token",
+			method:     http.MethodPost,
+			params:     url.Values{"subject": {"alice"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "invalid token",
+			method:     http.MethodPost,
+			token:      "invalid",
+			params:     url.Values{"subject": {"alice"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "missing subject",
+			method:     http.MethodPost,
+			token:      "admin-token",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			desc:        "revoke all sessions of a subject",
+			method:      http.MethodPost,
+			token:       "admin-token",
+			params:      url.Values{"subject": {"alice"}},
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"revoked":2}`,
+			wantRevoked: []string{"acp-1:session-1", "acp-2:session-2"},
+		},
+		{
+			desc:        "revoke sessions of a subject on a policy",
+			method:      http.MethodPost,
+			token:       "admin-token",
+			params:      url.Values{"subject": {"alice"}, "acp": {"acp-1"}},
+			wantStatus:  http.StatusOK,
+			wantBody:    `{"revoked":1}`,
+			wantRevoked: []string{"acp-1:session-1"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			backend := NewMemorySessionBackend()
+			keys := []string{"acp-1:session-1", "acp-2:session-2"}
+			for _, key := range keys {
+				err := backend.Save(ctx, key, StoredSession{Subject: "alice"}, time.Minute)
+				require.NoError(t, err)
+			}
+
+			handler := NewRevocationHandler(SessionBackends{Memory: backend}, "admin-token")
+
+			req := httptest.NewRequest(test.method, "/_sessions/revoke", strings.NewReader(test.params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			if test.wantBody != "" {
+				assert.JSONEq(t, test.wantBody, rec.Body.String())
+			}
+
+			for _, key := range keys {
+				sess, err := backend.Load(ctx, key)
+				require.NoError(t, err)
+
+				if contains(test.wantRevoked, key) {
+					assert.Nil(t, sess, key)
+				} else {
+					assert.NotNil(t, sess, key)
+				}
+			}
+		})
+	}
+}
+
+func TestRevocationHandler_disabledWithoutToken(t *testing.T) {
+	handler := NewRevocationHandler(SessionBackends{Memory: NewMemorySessionBackend()}, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/_sessions/revoke?subject=alice", http.NoBody)
+	req.Header.Set("Authorization", "Bearer ")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// backendOperationsTimeout bounds the duration of session backend operations not tied to a request.
+const backendOperationsTimeout = 5 * time.Second
+
+// ServerSessionStore stores sessions server-side, in a SessionBackend, and only keeps an opaque session ID in the
+// session cookie. Unlike CookieSessionStore, it is not limited by the size of cookies and sessions can be revoked.
+type ServerSessionStore struct {
+	name    string
+	policy  string
+	cfg     *AuthSession
+	backend SessionBackend
+
+	keys *KeyRing
+	rand Randr
+}
+
+// NewServerSessionStore creates a server-side session store. Sessions are stored encrypted in the given backend, under
+// keys prefixed by the given policy name.
+func NewServerSessionStore(name, policy string, backend SessionBackend, keys *KeyRing, cfg *AuthSession, rand Randr) *ServerSessionStore {
+	return &ServerSessionStore{
+		name:    name,
+		policy:  policy,
+		cfg:     cfg,
+		backend: backend,
+		keys:    keys,
+		rand:    rand,
+	}
+}
+
+// Create stores the session data in the backend under a new session ID, and sets the session cookie.
+func (s *ServerSessionStore) Create(w http.ResponseWriter, data SessionData) error {
+	id, err := newSessionID()
+	if err != nil {
+		return fmt.Errorf("unable to generate session ID: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backendOperationsTimeout)
+	defer cancel()
+
+	if err = s.save(ctx, id, data); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    id,
+		Path:     s.cfg.Path,
+		Domain:   s.cfg.Domain,
+		MaxAge:   sessionMaxAge,
+		HttpOnly: true,
+		SameSite: parseSameSite(s.cfg.SameSite),
+		Secure:   s.cfg.Secure,
+	})
+
+	return nil
+}
+
+// Update replaces the data of the session of the given request, keeping its session ID.
+func (s *ServerSessionStore) Update(w http.ResponseWriter, r *http.Request, data SessionData) error {
+	id, ok := getCookie(r, s.name)
+	if !ok {
+		return s.Create(w, data)
+	}
+
+	return s.save(r.Context(), string(id), data)
+}
+
+// Delete deletes the session of the given request from the backend and expires the session cookie.
+func (s *ServerSessionStore) Delete(w http.ResponseWriter, r *http.Request) error {
+	id, ok := getCookie(r, s.name)
+	if !ok {
+		return nil
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   s.name,
+		Path:   s.cfg.Path,
+		Domain: s.cfg.Domain,
+		MaxAge: -1, // Invalidates the cookie.
+	})
+
+	if err := s.backend.Delete(r.Context(), s.key(string(id))); err != nil {
+		return fmt.Errorf("unable to delete session: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the session of the given request from the backend. It returns nil if the request has no session, or
+// if its session expired or was revoked.
+func (s *ServerSessionStore) Get(r *http.Request) (*SessionData, error) {
+	id, ok := getCookie(r, s.name)
+	if !ok {
+		return nil, nil
+	}
+
+	stored, err := s.backend.Load(r.Context(), s.key(string(id)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load session: %w", err)
+	}
+	if stored == nil {
+		return nil, nil
+	}
+
+	decrypted, rotated, err := s.keys.Decrypt(stored.Data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode session: %w", err)
+	}
+
+	var sess SessionData
+	if err = json.Unmarshal(decrypted, &sess); err != nil {
+		return nil, fmt.Errorf("unable to deserialize session: %w", err)
+	}
+	sess.rotated = rotated
+
+	return &sess, nil
+}
+
+// RemoveCookie removes the session cookie from the request.
+func (s *ServerSessionStore) RemoveCookie(rw http.ResponseWriter, r *http.Request) {
+	removeCookies(rw, r, s.name)
+}
+
+// RevokeSID deletes the sessions with the given provider session ID and returns the number of deleted sessions.
+func (s *ServerSessionStore) RevokeSID(ctx context.Context, sid string) (int, error) {
+	return s.backend.RevokeSID(ctx, sid, s.policy+":")
+}
+
+// RevokeSubject deletes the sessions of the given subject and returns the number of deleted sessions.
+func (s *ServerSessionStore) RevokeSubject(ctx context.Context, subject string) (int, error) {
+	return s.backend.RevokeSubject(ctx, subject, s.policy+":")
+}
+
+func (s *ServerSessionStore) save(ctx context.Context, id string, data SessionData) error {
+	ser, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to serialize session: %w", err)
+	}
+
+	stored := StoredSession{
+		Subject: data.Subject,
+		SID:     data.SID,
+		Data:    s.keys.Encrypt(s.rand, ser),
+	}
+	if err = s.backend.Save(ctx, s.key(id), stored, sessionMaxAge*time.Second); err != nil {
+		return fmt.Errorf("unable to save session: %w", err)
+	}
+
+	return nil
+}
+
+// key returns the backend key of the given session ID. Session IDs are hashed so that they cannot be recovered from the
+// backend.
+func (s *ServerSessionStore) key(id string) string {
+	hash := sha256.Sum256([]byte(id))
+
+	return s.policy + ":" + hex.EncodeToString(hash[:])
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSessionStore(t *testing.T) {
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
+	require.NoError(t, err)
+
+	backend := NewMemorySessionBackend()
+	store := NewServerSessionStore("test-name", "my-acp", backend, keys, &AuthSession{
+		Path:     "/",
+		Domain:   "example.com",
+		SameSite: "lax",
+		Secure:   true,
+	}, RandrMock{})
+
+	rec := httptest.NewRecorder()
+	err = store.Create(rec, SessionData{AccessToken: "test1", IDToken: "test2", Subject: "user"})
+	require.NoError(t, err)
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "test-name", cookies[0].Name)
+	assert.Equal(t, 86400, cookies[0].MaxAge)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	// The cookie only holds the session ID.
+	assert.Len(t, cookies[0].Value, 43)
+
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	req.AddCookie(cookies[0])
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.Equal(t, "test1", sess.AccessToken)
+	assert.Equal(t, "test2", sess.IDToken)
+
+	// Updates keep the session ID.
+	rec = httptest.NewRecorder()
+	err = store.Update(rec, req, SessionData{AccessToken: "test3", IDToken: "test4", Subject: "user"})
+	require.NoError(t, err)
+	assert.Empty(t, rec.Header().Values("Set-Cookie"))
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	require.NotNil(t, sess)
+	assert.Equal(t, "test3", sess.AccessToken)
+
+	rec = httptest.NewRecorder()
+	err = store.Delete(rec, req)
+	require.NoError(t, err)
+	assert.Equal(t, "test-name=; Path=/; Domain=example.com; Max-Age=0", rec.Header().Get("Set-Cookie"))
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestServerSessionStore_GetReturnsNilIfNoSessionExists(t *testing.T) {
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
+	require.NoError(t, err)
+
+	store := NewServerSessionStore("test-name", "my-acp", NewMemorySessionBackend(), keys, &AuthSession{}, RandrMock{})
+
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+
+	sess, err := store.Get(req)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+
+	req.AddCookie(&http.Cookie{Name: "test-name", Value: "unknown"})
+
+	sess, err = store.Get(req)
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+}
+
+func TestServerSessionStore_RemoveCookieOnlyRemovesOurCookie(t *testing.T) {
+	keys, err := NewKeyRing(map[string]string{"": "secret1234567890"}, 0)
+	require.NoError(t, err)
+
+	store := NewServerSessionStore("test-name", "my-acp", NewMemorySessionBackend(), keys, &AuthSession{}, RandrMock{})
+
+	req := httptest.NewRequest(http.MethodGet, "http://foo.bar", nil)
+	req.AddCookie(&http.Cookie{Name: "test-name", Value: "session-id"})
+	req.AddCookie(&http.Cookie{Name: "custom-name", Value: "value2"})
+
+	w := httptest.NewRecorder()
+	store.RemoveCookie(w, req)
+
+	assert.Equal(t, "custom-name=value2", w.Header().Get("Cookie"))
+}
@@ -0,0 +1,235 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session stores.
+const (
+	// SessionStoreCookie stores sessions in encrypted, possibly chunked, cookies.
+	SessionStoreCookie = "cookie"
+	// SessionStoreMemory stores sessions in the memory of the auth server. Sessions are not shared between replicas.
+	SessionStoreMemory = "memory"
+	// SessionStoreRedis stores sessions in the Redis compatible server configured on the auth server.
+	SessionStoreRedis = "redis"
+)
+
+// StoredSession is a session stored server-side.
+type StoredSession struct {
+	// Subject is the subject of the ID token of the session, used to revoke all the sessions of a user.
+	Subject string `json:"subject,omitempty"`
+	// SID is the session ID of the provider, used to revoke the sessions ended at the provider.
+	SID string `json:"sid,omitempty"`
+	// Data holds the encrypted SessionData.
+	Data []byte `json:"data"`
+}
+
+// SessionBackend stores server-side sessions. Implementations must be safe for concurrent use.
+type SessionBackend interface {
+	// Save stores the given session under the given key for the given duration.
+	Save(ctx context.Context, key string, sess StoredSession, ttl time.Duration) error
+	// Load returns the session stored under the given key, nil if there is none.
+	Load(ctx context.Context, key string) (*StoredSession, error)
+	// Delete deletes the session stored under the given key.
+	Delete(ctx context.Context, key string) error
+	// RevokeSubject deletes the sessions of the given subject whose key has the given prefix, and returns the number of
+	// deleted sessions.
+	RevokeSubject(ctx context.Context, subject, prefix string) (int, error)
+	// RevokeSID deletes the sessions with the given provider session ID whose key has the given prefix, and returns the
+	// number of deleted sessions.
+	RevokeSID(ctx context.Context, sid, prefix string) (int, error)
+}
+
+// SessionBackends holds the server-side session backends available to OIDC handlers. Backends outlive handlers, which
+// are rebuilt whenever their policy changes.
+type SessionBackends struct {
+	Memory SessionBackend
+	// Redis is nil if no Redis compatible server is configured.
+	Redis SessionBackend
+}
+
+// All returns the configured backends.
+func (b SessionBackends) All() []SessionBackend {
+	var backends []SessionBackend
+	for _, backend := range []SessionBackend{b.Memory, b.Redis} {
+		if backend != nil {
+			backends = append(backends, backend)
+		}
+	}
+
+	return backends
+}
+
+// sessionIndexes returns the indexes the given session must be listed in to be revoked.
+func sessionIndexes(sess StoredSession) []string {
+	var indexes []string
+	if sess.Subject != "" {
+		indexes = append(indexes, subjectIndex(sess.Subject))
+	}
+	if sess.SID != "" {
+		indexes = append(indexes, sidIndex(sess.SID))
+	}
+
+	return indexes
+}
+
+func subjectIndex(subject string) string {
+	return "subject:" + subject
+}
+
+func sidIndex(sid string) string {
+	return "sid:" + sid
+}
+
+// sessionSweepInterval is the interval at which expired sessions are removed from a MemorySessionBackend.
+const sessionSweepInterval = time.Minute
+
+// MemorySessionBackend is a SessionBackend keeping sessions in memory.
+type MemorySessionBackend struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	// indexes holds the keys of the sessions of each subject and provider session ID.
+	indexes   map[string]map[string]struct{}
+	lastSweep time.Time
+
+	now func() time.Time
+}
+
+type memorySession struct {
+	StoredSession
+
+	expiresAt time.Time
+}
+
+// NewMemorySessionBackend returns a new MemorySessionBackend.
+func NewMemorySessionBackend() *MemorySessionBackend {
+	return &MemorySessionBackend{
+		sessions: make(map[string]memorySession),
+		indexes:  make(map[string]map[string]struct{}),
+		now:      time.Now,
+	}
+}
+
+// Save implements SessionBackend.
+func (b *MemorySessionBackend) Save(_ context.Context, key string, sess StoredSession, ttl time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	b.deleteLocked(key)
+	b.sessions[key] = memorySession{StoredSession: sess, expiresAt: now.Add(ttl)}
+
+	for _, index := range sessionIndexes(sess) {
+		if b.indexes[index] == nil {
+			b.indexes[index] = make(map[string]struct{})
+		}
+		b.indexes[index][key] = struct{}{}
+	}
+
+	return nil
+}
+
+// Load implements SessionBackend.
+func (b *MemorySessionBackend) Load(_ context.Context, key string) (*StoredSession, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sess, ok := b.sessions[key]
+	if !ok || !b.now().Before(sess.expiresAt) {
+		return nil, nil
+	}
+
+	return &sess.StoredSession, nil
+}
+
+// Delete implements SessionBackend.
+func (b *MemorySessionBackend) Delete(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deleteLocked(key)
+
+	return nil
+}
+
+// RevokeSubject implements SessionBackend.
+func (b *MemorySessionBackend) RevokeSubject(_ context.Context, subject, prefix string) (int, error) {
+	return b.revoke(subjectIndex(subject), prefix), nil
+}
+
+// RevokeSID implements SessionBackend.
+func (b *MemorySessionBackend) RevokeSID(_ context.Context, sid, prefix string) (int, error) {
+	return b.revoke(sidIndex(sid), prefix), nil
+}
+
+func (b *MemorySessionBackend) revoke(index, prefix string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var revoked int
+	for key := range b.indexes[index] {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		b.deleteLocked(key)
+		revoked++
+	}
+
+	return revoked
+}
+
+// deleteLocked deletes the session stored under the given key. It must be called with the lock held.
+func (b *MemorySessionBackend) deleteLocked(key string) {
+	sess, ok := b.sessions[key]
+	if !ok {
+		return
+	}
+
+	delete(b.sessions, key)
+
+	for _, index := range sessionIndexes(sess.StoredSession) {
+		if keys := b.indexes[index]; keys != nil {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(b.indexes, index)
+			}
+		}
+	}
+}
+
+// sweep removes expired sessions, at most once every sessionSweepInterval. It must be called with the lock held.
+func (b *MemorySessionBackend) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sessionSweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, sess := range b.sessions {
+		if !now.Before(sess.expiresAt) {
+			b.deleteLocked(key)
+		}
+	}
+}
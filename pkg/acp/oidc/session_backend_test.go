@@ -0,0 +1,121 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis/redistest"
+)
+
+func TestSessionBackends(t *testing.T) {
+	srv := redistest.NewServer(t)
+
+	client := redis.NewClient(redis.Config{Address: srv.Addr})
+	t.Cleanup(func() { _ = client.Close() })
+
+	backends := map[string]SessionBackend{
+		"memory": NewMemorySessionBackend(),
+		"redis":  NewRedisSessionBackend(client),
+	}
+
+	for name, backend := range backends {
+		backend := backend
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			sess, err := backend.Load(ctx, "acp-1:session-1")
+			require.NoError(t, err)
+			assert.Nil(t, sess)
+
+			for _, key := range []string{"acp-1:session-1", "acp-1:session-2", "acp-2:session-3"} {
+				err = backend.Save(ctx, key, StoredSession{Subject: "alice", Data: []byte(key)}, time.Minute)
+				require.NoError(t, err)
+			}
+			err = backend.Save(ctx, "acp-1:session-4", StoredSession{Subject: "bob", SID: "sid-1", Data: []byte("bob")}, time.Minute)
+			require.NoError(t, err)
+
+			sess, err = backend.Load(ctx, "acp-1:session-1")
+			require.NoError(t, err)
+			assert.Equal(t, &StoredSession{Subject: "alice", Data: []byte("acp-1:session-1")}, sess)
+
+			err = backend.Delete(ctx, "acp-1:session-1")
+			require.NoError(t, err)
+
+			sess, err = backend.Load(ctx, "acp-1:session-1")
+			require.NoError(t, err)
+			assert.Nil(t, sess)
+
+			// Revocations can be restricted to a policy.
+			revoked, err := backend.RevokeSubject(ctx, "alice", "acp-2:")
+			require.NoError(t, err)
+			assert.Equal(t, 1, revoked)
+
+			revoked, err = backend.RevokeSubject(ctx, "alice", "")
+			require.NoError(t, err)
+			assert.Equal(t, 1, revoked)
+
+			for _, key := range []string{"acp-1:session-2", "acp-2:session-3"} {
+				sess, err = backend.Load(ctx, key)
+				require.NoError(t, err)
+				assert.Nil(t, sess, key)
+			}
+
+			// Sessions of other subjects are kept.
+			sess, err = backend.Load(ctx, "acp-1:session-4")
+			require.NoError(t, err)
+			assert.NotNil(t, sess)
+
+			revoked, err = backend.RevokeSID(ctx, "sid-1", "acp-1:")
+			require.NoError(t, err)
+			assert.Equal(t, 1, revoked)
+
+			sess, err = backend.Load(ctx, "acp-1:session-4")
+			require.NoError(t, err)
+			assert.Nil(t, sess)
+		})
+	}
+}
+
+func TestMemorySessionBackend_expiry(t *testing.T) {
+	now := time.Now()
+	backend := NewMemorySessionBackend()
+	backend.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	err := backend.Save(ctx, "acp:session", StoredSession{Subject: "alice"}, time.Minute)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+
+	sess, err := backend.Load(ctx, "acp:session")
+	require.NoError(t, err)
+	assert.Nil(t, sess)
+
+	// Expired sessions are swept.
+	err = backend.Save(ctx, "acp:other", StoredSession{}, time.Minute)
+	require.NoError(t, err)
+	assert.NotContains(t, backend.sessions, "acp:session")
+	assert.NotContains(t, backend.indexes, subjectIndex("alice"))
+}
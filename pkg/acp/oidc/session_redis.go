@@ -0,0 +1,133 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
+)
+
+const (
+	redisSessionKeyPrefix = "hub:oidc:session:"
+	redisIndexKeyPrefix   = "hub:oidc:"
+)
+
+// RedisSessionBackend is a SessionBackend keeping sessions in a Redis compatible server, shared between replicas.
+type RedisSessionBackend struct {
+	client *redis.Client
+}
+
+// NewRedisSessionBackend returns a new RedisSessionBackend.
+func NewRedisSessionBackend(client *redis.Client) *RedisSessionBackend {
+	return &RedisSessionBackend{client: client}
+}
+
+// Save implements SessionBackend.
+func (b *RedisSessionBackend) Save(ctx context.Context, key string, sess StoredSession, ttl time.Duration) error {
+	ser, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("serialize session: %w", err)
+	}
+
+	ttlMillis := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if _, err = b.client.Do(ctx, "SET", redisSessionKeyPrefix+key, string(ser), "PX", ttlMillis); err != nil {
+		return err
+	}
+
+	// Indexes live as long as their most recent session. Entries of expired sessions are removed on revocation.
+	for _, index := range sessionIndexes(sess) {
+		indexKey := redisIndexKeyPrefix + index
+		if _, err = b.client.Do(ctx, "SADD", indexKey, key); err != nil {
+			return err
+		}
+		if _, err = b.client.Do(ctx, "PEXPIRE", indexKey, ttlMillis); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load implements SessionBackend.
+func (b *RedisSessionBackend) Load(ctx context.Context, key string) (*StoredSession, error) {
+	ser, err := b.client.String(ctx, "GET", redisSessionKeyPrefix+key)
+	if errors.Is(err, redis.ErrNil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess StoredSession
+	if err = json.Unmarshal([]byte(ser), &sess); err != nil {
+		return nil, fmt.Errorf("deserialize session: %w", err)
+	}
+
+	return &sess, nil
+}
+
+// Delete implements SessionBackend.
+func (b *RedisSessionBackend) Delete(ctx context.Context, key string) error {
+	_, err := b.client.Do(ctx, "DEL", redisSessionKeyPrefix+key)
+	return err
+}
+
+// RevokeSubject implements SessionBackend.
+func (b *RedisSessionBackend) RevokeSubject(ctx context.Context, subject, prefix string) (int, error) {
+	return b.revoke(ctx, subjectIndex(subject), prefix)
+}
+
+// RevokeSID implements SessionBackend.
+func (b *RedisSessionBackend) RevokeSID(ctx context.Context, sid, prefix string) (int, error) {
+	return b.revoke(ctx, sidIndex(sid), prefix)
+}
+
+func (b *RedisSessionBackend) revoke(ctx context.Context, index, prefix string) (int, error) {
+	indexKey := redisIndexKeyPrefix + index
+
+	keys, err := b.client.Strings(ctx, "SMEMBERS", indexKey)
+	if err != nil {
+		return 0, err
+	}
+
+	var revoked int
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		deleted, err := b.client.Int(ctx, "DEL", redisSessionKeyPrefix+key)
+		if err != nil {
+			return revoked, err
+		}
+		if _, err = b.client.Do(ctx, "SREM", indexKey, key); err != nil {
+			return revoked, err
+		}
+
+		revoked += int(deleted)
+	}
+
+	return revoked, nil
+}
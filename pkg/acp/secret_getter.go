@@ -18,20 +18,33 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package acp
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	corev1lister "k8s.io/client-go/listers/core/v1"
 )
 
-// KubeSecretGetter allows getting Kubernetes secrets.
+// configMapGetTimeout is the maximum duration of a ConfigMap request.
+const configMapGetTimeout = 5 * time.Second
+
+// KubeSecretGetter allows getting Kubernetes secrets and ConfigMaps.
+// Unlike secrets, ConfigMaps are requested one at a time, so only the ones referenced by ACPs are read.
 type KubeSecretGetter struct {
-	secrets corev1lister.SecretLister
+	secrets    corev1lister.SecretLister
+	configMaps corev1client.ConfigMapsGetter
 }
 
 // NewKubeSecretValueGetter creates a KubeSecretGetter instance.
-func NewKubeSecretValueGetter(secrets corev1lister.SecretLister) *KubeSecretGetter {
-	return &KubeSecretGetter{secrets: secrets}
+func NewKubeSecretValueGetter(secrets corev1lister.SecretLister, configMaps corev1client.ConfigMapsGetter) *KubeSecretGetter {
+	return &KubeSecretGetter{
+		secrets:    secrets,
+		configMaps: configMaps,
+	}
 }
 
 // GetValue returns the value of the given key in the given Kubernetes secret.
@@ -49,8 +62,58 @@ func (g KubeSecretGetter) GetValue(secret *corev1.SecretReference, key string) (
 	return value, nil
 }
 
+// GetSecret returns the given Kubernetes secret.
+func (g KubeSecretGetter) GetSecret(secret *corev1.SecretReference) (*corev1.Secret, error) {
+	s, err := g.secrets.Secrets(secret.Namespace).Get(secret.Name)
+	if err != nil {
+		return nil, fmt.Errorf("getting secret %q in namespace %q: %w", secret.Name, secret.Namespace, err)
+	}
+
+	return s, nil
+}
+
+// ListSecrets returns the Kubernetes secrets of the given namespace matching the given selector.
+func (g KubeSecretGetter) ListSecrets(namespace string, selector labels.Selector) ([]*corev1.Secret, error) {
+	secrets, err := g.secrets.Secrets(namespace).List(selector)
+	if err != nil {
+		return nil, fmt.Errorf("listing secrets in namespace %q: %w", namespace, err)
+	}
+
+	return secrets, nil
+}
+
+// GetConfigMapValue returns the value of the given key in the given Kubernetes ConfigMap.
+func (g KubeSecretGetter) GetConfigMapValue(namespace, name, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), configMapGetTimeout)
+	defer cancel()
+
+	cm, err := g.configMaps.ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting ConfigMap %q in namespace %q: %w", name, namespace, err)
+	}
+
+	value, ok := cm.Data[key]
+	if !ok {
+		return "", fmt.Errorf("no key %q in ConfigMap %q in namespace %q", key, name, namespace)
+	}
+
+	return value, nil
+}
+
 type emptySecretGetter struct{}
 
 func (g emptySecretGetter) GetValue(*corev1.SecretReference, string) ([]byte, error) {
 	return nil, nil
 }
+
+func (g emptySecretGetter) GetSecret(*corev1.SecretReference) (*corev1.Secret, error) {
+	return nil, nil
+}
+
+func (g emptySecretGetter) ListSecrets(string, labels.Selector) ([]*corev1.Secret, error) {
+	return nil, nil
+}
+
+func (g emptySecretGetter) GetConfigMapValue(string, string, string) (string, error) {
+	return "", nil
+}
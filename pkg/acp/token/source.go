@@ -78,7 +78,7 @@ func getTokenFromHeader(header http.Header, headerName, headerAuthScheme string)
 }
 
 func getTokenFromQuery(header http.Header, key string) (string, error) {
-	if uri := originalURI(header); uri != "" {
+	if uri := OriginalURI(header); uri != "" {
 		parsedURI, err := url.Parse(uri)
 		if err != nil {
 			return "", err
@@ -92,9 +92,9 @@ func getTokenFromQuery(header http.Header, key string) (string, error) {
 	return "", nil
 }
 
-// originalURI gets the original URI that was sent to the ingress controller, regardless of its type.
+// OriginalURI gets the original URI that was sent to the ingress controller, regardless of its type.
 // It currently supports Traefik (X-Forwarded-Uri) and Nginx Community (X-Original-Url).
-func originalURI(hdr http.Header) string {
+func OriginalURI(hdr http.Header) string {
 	if xfu := hdr.Get("X-Forwarded-Uri"); xfu != "" {
 		return xfu
 	}
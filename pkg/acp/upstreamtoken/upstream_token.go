@@ -0,0 +1,120 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package upstreamtoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/decision"
+	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Config configures the access tokens obtained with the OAuth 2.0 client credentials grant and forwarded to upstream
+// services for the requests granted by an ACP.
+type Config struct {
+	httpclient.Config
+
+	// TokenURL is the token endpoint of the Authorization Server.
+	TokenURL string `json:"tokenUrl"`
+	// Secret references the Secret holding the client credentials under the "clientId" and "clientSecret" entries.
+	Secret         SecretReference   `json:"secret"`
+	Scopes         []string          `json:"scopes,omitempty"`
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+
+	// ClientID and ClientSecret hold the client credentials resolved from Secret.
+	ClientID     string `json:"-"`
+	ClientSecret string `json:"-"`
+}
+
+// SecretReference represents a Secret Reference.
+// It has enough information to retrieve secret in any namespace.
+type SecretReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Handler sets an access token, obtained with the OAuth 2.0 client credentials grant, as the upstream
+// "Authorization" header of the requests granted by an ACP handler. Tokens are cached until shortly before they
+// expire.
+type Handler struct {
+	next   http.Handler
+	name   string
+	tokens oauth2.TokenSource
+}
+
+// NewHandler creates a new Handler forwarding tokens for the requests granted by the given ACP handler.
+func NewHandler(next http.Handler, cfg *Config, name string) (*Handler, error) {
+	if cfg.TokenURL == "" {
+		return nil, errors.New("empty token URL")
+	}
+
+	if cfg.ClientID == "" {
+		return nil, errors.New("empty client ID")
+	}
+
+	httpClient, err := httpclient.New(cfg.Config)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+
+	params := make(url.Values, len(cfg.EndpointParams))
+	for key, value := range cfg.EndpointParams {
+		params.Set(key, value)
+	}
+
+	oauthCfg := &clientcredentials.Config{
+		ClientID:       cfg.ClientID,
+		ClientSecret:   cfg.ClientSecret,
+		TokenURL:       cfg.TokenURL,
+		Scopes:         cfg.Scopes,
+		EndpointParams: params,
+	}
+
+	// The token source reuses tokens until shortly before they expire.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
+
+	return &Handler{
+		next:   next,
+		name:   name,
+		tokens: oauthCfg.TokenSource(ctx),
+	}, nil
+}
+
+// ServeHTTP serves an HTTP request.
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	err := decision.Decorate(rw, req, h.next, func(header http.Header) error {
+		tok, err := h.tokens.Token()
+		if err != nil {
+			return err
+		}
+
+		header.Set("Authorization", tok.Type()+" "+tok.AccessToken)
+
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("acp_name", h.name).Msg("Unable to get upstream access token")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package upstreamtoken
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		desc       string
+		expiresIn  int
+		next       http.HandlerFunc
+		wantStatus int
+		wantAuth   string
+		wantCalls  int32
+	}{
+		{
+			desc:      "granted requests reuse the token",
+			expiresIn: 3600,
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Set("Authorization", "Bearer user-token")
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			wantAuth:   "Bearer service-token-1",
+			wantCalls:  1,
+		},
+		{
+			desc:      "tokens about to expire are renewed",
+			expiresIn: 5,
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			},
+			wantStatus: http.StatusOK,
+			wantAuth:   "Bearer service-token-2",
+			wantCalls:  2,
+		},
+		{
+			desc:      "denied requests",
+			expiresIn: 3600,
+			next: func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusUnauthorized)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				if err := req.ParseForm(); err != nil {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				clientID, clientSecret, ok := req.BasicAuth()
+				if !ok || clientID != "client-id" || clientSecret != "client-secret" {
+					rw.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+
+				if req.Form.Get("grant_type") != "client_credentials" || req.Form.Get("scope") != "read write" ||
+					req.Form.Get("audience") != "legacy" {
+					rw.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				n := atomic.AddInt32(&calls, 1)
+
+				rw.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(rw, `{"access_token":"service-token-%d","token_type":"bearer","expires_in":%d}`, n, test.expiresIn)
+			}))
+			t.Cleanup(srv.Close)
+
+			h, err := NewHandler(test.next, &Config{
+				TokenURL:       srv.URL,
+				Scopes:         []string{"read", "write"},
+				EndpointParams: map[string]string{"audience": "legacy"},
+				ClientID:       "client-id",
+				ClientSecret:   "client-secret",
+			}, "my-acp")
+			require.NoError(t, err)
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				rec = httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+
+			assert.Equal(t, test.wantStatus, rec.Code)
+			assert.Equal(t, test.wantAuth, rec.Header().Get("Authorization"))
+			assert.Equal(t, test.wantCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestHandler_ServeHTTP_tokenEndpointError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(srv.Close)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("User", "alice")
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	h, err := NewHandler(next, &Config{
+		TokenURL:     srv.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+	}, "my-acp")
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Authorization"))
+}
+
+func TestNewHandler(t *testing.T) {
+	_, err := NewHandler(http.NotFoundHandler(), &Config{ClientID: "client-id"}, "my-acp")
+	assert.EqualError(t, err, "empty token URL")
+
+	_, err = NewHandler(http.NotFoundHandler(), &Config{TokenURL: "https://idp.example.com/token"}, "my-acp")
+	assert.EqualError(t, err, "empty client ID")
+}
@@ -0,0 +1,196 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package acp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/expr"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidatePolicy makes sure the given policy is well-formed. It does not resolve secret references.
+func ValidatePolicy(policy *hubv1alpha1.AccessControlPolicy) error {
+	spec := policy.Spec
+
+	var count int
+	for _, set := range []bool{
+		spec.JWT != nil,
+		spec.BasicAuth != nil,
+		spec.APIKey != nil,
+		spec.OIDC != nil,
+		spec.OIDCGoogle != nil,
+		spec.OAuthIntro != nil,
+		spec.MTLS != nil,
+		spec.IPAllowList != nil,
+		spec.ExternalAuthz != nil,
+		len(spec.AnyOf) > 0,
+		len(spec.AllOf) > 0,
+	} {
+		if set {
+			count++
+		}
+	}
+
+	if count != 1 {
+		return errors.New(`exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle", "oAuthIntro", "mtls", "ipAllowList", "externalAuthz", "anyOf" or "allOf" must be set`)
+	}
+
+	switch spec.EnforcementMode {
+	case "", EnforcementModeEnforce, EnforcementModeAudit:
+	default:
+		return fmt.Errorf(`enforcementMode must be one of %q or %q`, EnforcementModeEnforce, EnforcementModeAudit)
+	}
+
+	if spec.IssueToken != nil && spec.IssueToken.KeySecret.Name == "" {
+		return errors.New("issueToken: missing key secret")
+	}
+
+	if spec.UpstreamToken != nil {
+		if spec.UpstreamToken.TokenURL == "" {
+			return errors.New("upstreamToken: missing token URL")
+		}
+		if spec.UpstreamToken.Secret.Name == "" {
+			return errors.New("upstreamToken: missing secret")
+		}
+	}
+
+	if spec.DenialResponse != nil && spec.DenialResponse.HTMLTemplate != nil {
+		if spec.DenialResponse.HTMLTemplate.Name == "" || spec.DenialResponse.HTMLTemplate.Namespace == "" {
+			return errors.New("denialResponse: missing HTML template ConfigMap name or namespace")
+		}
+	}
+
+	if spec.OIDC != nil {
+		if err := validateClaims(spec.OIDC.Claims); err != nil {
+			return fmt.Errorf("oidc: %w", err)
+		}
+	}
+
+	if err := validateMethodClaims(hubv1alpha1.AccessControlPolicyMethod{
+		JWT:           spec.JWT,
+		APIKey:        spec.APIKey,
+		OAuthIntro:    spec.OAuthIntro,
+		MTLS:          spec.MTLS,
+		IPAllowList:   spec.IPAllowList,
+		ExternalAuthz: spec.ExternalAuthz,
+	}); err != nil {
+		return err
+	}
+
+	for i, method := range spec.AnyOf {
+		if err := validateMethod(method); err != nil {
+			return fmt.Errorf("anyOf: method %d: %w", i, err)
+		}
+	}
+
+	for i, method := range spec.AllOf {
+		if err := validateMethod(method); err != nil {
+			return fmt.Errorf("allOf: method %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func validateMethod(method hubv1alpha1.AccessControlPolicyMethod) error {
+	var count int
+	for _, set := range []bool{
+		method.JWT != nil,
+		method.BasicAuth != nil,
+		method.APIKey != nil,
+		method.OAuthIntro != nil,
+		method.MTLS != nil,
+		method.IPAllowList != nil,
+		method.ExternalAuthz != nil,
+	} {
+		if set {
+			count++
+		}
+	}
+
+	if count != 1 {
+		return errors.New(`exactly one of "jwt", "basicAuth", "apiKey", "oAuthIntro", "mtls", "ipAllowList" or "externalAuthz" must be set`)
+	}
+
+	return validateMethodClaims(method)
+}
+
+// validateMethodClaims makes sure the claims expression of the given method, if any, is valid, as well as its JWT
+// algorithms, its API key Secret selector, its IP ranges and its external authorization service URL.
+func validateMethodClaims(method hubv1alpha1.AccessControlPolicyMethod) error {
+	switch {
+	case method.JWT != nil:
+		if err := validateClaims(method.JWT.Claims); err != nil {
+			return fmt.Errorf("jwt: %w", err)
+		}
+		if err := jwt.ValidateAlgorithms(method.JWT.Algorithms); err != nil {
+			return fmt.Errorf("jwt: %w", err)
+		}
+
+	case method.APIKey != nil:
+		if method.APIKey.KeySecretSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(&method.APIKey.KeySecretSelector.Selector); err != nil {
+				return fmt.Errorf("apiKey: invalid key secret selector: %w", err)
+			}
+		}
+
+	case method.OAuthIntro != nil:
+		if err := validateClaims(method.OAuthIntro.Claims); err != nil {
+			return fmt.Errorf("oAuthIntro: %w", err)
+		}
+
+	case method.MTLS != nil:
+		if err := validateClaims(method.MTLS.Claims); err != nil {
+			return fmt.Errorf("mtls: %w", err)
+		}
+
+	case method.IPAllowList != nil:
+		if len(method.IPAllowList.SourceRanges) == 0 && len(method.IPAllowList.DeniedRanges) == 0 {
+			return errors.New(`ipAllowList: at least one of "sourceRanges" or "deniedRanges" must be set`)
+		}
+		if _, err := expr.ParseIPRanges(method.IPAllowList.SourceRanges); err != nil {
+			return fmt.Errorf("ipAllowList: invalid source ranges: %w", err)
+		}
+		if _, err := expr.ParseIPRanges(method.IPAllowList.DeniedRanges); err != nil {
+			return fmt.Errorf("ipAllowList: invalid denied ranges: %w", err)
+		}
+
+	case method.ExternalAuthz != nil:
+		if method.ExternalAuthz.URL == "" {
+			return errors.New("externalAuthz: missing URL")
+		}
+	}
+
+	return nil
+}
+
+func validateClaims(claims string) error {
+	if claims == "" {
+		return nil
+	}
+
+	if _, err := expr.Parse(claims); err != nil {
+		return fmt.Errorf("invalid claims: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package acp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		desc    string
+		spec    hubv1alpha1.AccessControlPolicySpec
+		wantErr string
+	}{
+		{
+			desc: "single method",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+			},
+		},
+		{
+			desc:    "no method",
+			spec:    hubv1alpha1.AccessControlPolicySpec{},
+			wantErr: `exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle", "oAuthIntro", "mtls", "ipAllowList", "externalAuthz", "anyOf" or "allOf" must be set`,
+		},
+		{
+			desc: "several methods",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT:       &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				BasicAuth: &hubv1alpha1.AccessControlPolicyBasicAuth{Users: []string{"user:password"}},
+			},
+			wantErr: `exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle", "oAuthIntro", "mtls", "ipAllowList", "externalAuthz", "anyOf" or "allOf" must be set`,
+		},
+		{
+			desc: "anyOf",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AnyOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"}},
+					{APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{KeySource: hubv1alpha1.TokenSource{Header: "Api-Key"}}},
+				},
+			},
+		},
+		{
+			desc: "anyOf and jwt",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				AnyOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"}},
+				},
+			},
+			wantErr: `exactly one of "jwt", "basicAuth", "apiKey", "oidc", "oidcGoogle", "oAuthIntro", "mtls", "ipAllowList", "externalAuthz", "anyOf" or "allOf" must be set`,
+		},
+		{
+			desc: "allOf with an empty method",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AllOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"}},
+					{},
+				},
+			},
+			wantErr: `allOf: method 1: exactly one of "jwt", "basicAuth", "apiKey", "oAuthIntro", "mtls", "ipAllowList" or "externalAuthz" must be set`,
+		},
+		{
+			desc: "allOf with a method setting several types",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AllOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{
+						JWT:       &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+						BasicAuth: &hubv1alpha1.AccessControlPolicyBasicAuth{Users: []string{"user:password"}},
+					},
+				},
+			},
+			wantErr: `allOf: method 0: exactly one of "jwt", "basicAuth", "apiKey", "oAuthIntro", "mtls", "ipAllowList" or "externalAuthz" must be set`,
+		},
+		{
+			desc: "audit enforcement mode",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT:             &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				EnforcementMode: "audit",
+			},
+		},
+		{
+			desc: "unknown enforcement mode",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT:             &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				EnforcementMode: "dry-run",
+			},
+			wantErr: `enforcementMode must be one of "enforce" or "audit"`,
+		},
+		{
+			desc: "issue token",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				IssueToken: &hubv1alpha1.AccessControlPolicyIssueToken{
+					KeySecret: corev1.SecretReference{Name: "signing-key", Namespace: "default"},
+				},
+			},
+		},
+		{
+			desc: "issue token without key secret",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT:        &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				IssueToken: &hubv1alpha1.AccessControlPolicyIssueToken{},
+			},
+			wantErr: "issueToken: missing key secret",
+		},
+		{
+			desc: "ip allow list",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AllOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{IPAllowList: &hubv1alpha1.AccessControlPolicyIPAllowList{SourceRanges: []string{"10.0.0.0/8"}}},
+					{JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"}},
+				},
+			},
+		},
+		{
+			desc: "ip allow list without ranges",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				IPAllowList: &hubv1alpha1.AccessControlPolicyIPAllowList{TrustedProxyDepth: 1},
+			},
+			wantErr: `ipAllowList: at least one of "sourceRanges" or "deniedRanges" must be set`,
+		},
+		{
+			desc: "ip allow list with invalid ranges",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AnyOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{IPAllowList: &hubv1alpha1.AccessControlPolicyIPAllowList{DeniedRanges: []string{"10.0.0"}}},
+				},
+			},
+			wantErr: `anyOf: method 0: ipAllowList: invalid denied ranges: invalid IP "10.0.0"`,
+		},
+		{
+			desc: "external authz after jwt",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AllOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"}},
+					{ExternalAuthz: &hubv1alpha1.AccessControlPolicyExternalAuthz{URL: "https://authz.example.com"}},
+				},
+			},
+		},
+		{
+			desc: "external authz without URL",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				ExternalAuthz: &hubv1alpha1.AccessControlPolicyExternalAuthz{ForwardHeaders: []string{"X-Entitlements"}},
+			},
+			wantErr: "externalAuthz: missing URL",
+		},
+		{
+			desc: "upstream token without token URL",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				UpstreamToken: &hubv1alpha1.AccessControlPolicyUpstreamToken{
+					Secret: corev1.SecretReference{Name: "my-client", Namespace: "default"},
+				},
+			},
+			wantErr: "upstreamToken: missing token URL",
+		},
+		{
+			desc: "upstream token without secret",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				UpstreamToken: &hubv1alpha1.AccessControlPolicyUpstreamToken{
+					TokenURL: "https://idp.example.com/token",
+				},
+			},
+			wantErr: "upstreamToken: missing secret",
+		},
+		{
+			desc: "denial response with HTML template",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				DenialResponse: &hubv1alpha1.AccessControlPolicyDenialResponse{
+					ProblemDetails: true,
+					HTMLTemplate:   &hubv1alpha1.ConfigMapKeyReference{Name: "my-templates", Namespace: "default"},
+				},
+			},
+		},
+		{
+			desc: "denial response with HTML template without namespace",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key"},
+				DenialResponse: &hubv1alpha1.AccessControlPolicyDenialResponse{
+					HTMLTemplate: &hubv1alpha1.ConfigMapKeyReference{Name: "my-templates"},
+				},
+			},
+			wantErr: "denialResponse: missing HTML template ConfigMap name or namespace",
+		},
+		{
+			desc: "jwt with valid claims",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key", Claims: "GreaterThan(`level`, `3`)"},
+			},
+		},
+		{
+			desc: "jwt with invalid claims",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key", Claims: "Foo(`grp`)"},
+			},
+			wantErr: "jwt: invalid claims: unable to parse expression \"Foo(`grp`)\": unsupported function: Foo",
+		},
+		{
+			desc: "jwt with unsupported algorithm",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				JWT: &hubv1alpha1.AccessControlPolicyJWT{PublicKey: "key", Algorithms: []string{"RS256", "none"}},
+			},
+			wantErr: `jwt: unsupported algorithm "none", must be one of HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512`,
+		},
+		{
+			desc: "api key with key secret selector",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{
+					KeySecretSelector: &hubv1alpha1.AccessControlPolicyAPIKeySecretSelector{
+						Namespace: "default",
+						Selector:  metav1.LabelSelector{MatchLabels: map[string]string{"app": "api-keys"}},
+					},
+				},
+			},
+		},
+		{
+			desc: "api key with invalid key secret selector",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{
+					KeySecretSelector: &hubv1alpha1.AccessControlPolicyAPIKeySecretSelector{
+						Namespace: "default",
+						Selector: metav1.LabelSelector{
+							MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Unknown"}},
+						},
+					},
+				},
+			},
+			wantErr: `apiKey: invalid key secret selector: "Unknown" is not a valid label selector operator`,
+		},
+		{
+			desc: "oidc with invalid claims",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				OIDC: &hubv1alpha1.AccessControlPolicyOIDC{Claims: "Matches(`email`, `(`)"},
+			},
+			wantErr: "oidc: invalid claims: unable to parse expression \"Matches(`email`, `(`)\": invalid regular expression \"(\" given to Matches: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			desc: "anyOf with a method with invalid claims",
+			spec: hubv1alpha1.AccessControlPolicySpec{
+				AnyOf: []hubv1alpha1.AccessControlPolicyMethod{
+					{APIKey: &hubv1alpha1.AccessControlPolicyAPIKey{KeySource: hubv1alpha1.TokenSource{Header: "Api-Key"}}},
+					{OAuthIntro: &hubv1alpha1.AccessControlOAuthIntro{Claims: "After(`auth_time`, `yesterday`)"}},
+				},
+			},
+			wantErr: "anyOf: method 1: oAuthIntro: invalid claims: unable to parse expression \"After(`auth_time`, `yesterday`)\": invalid time \"yesterday\" given to After: must be an RFC 3339 timestamp or a duration",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidatePolicy(&hubv1alpha1.AccessControlPolicy{Spec: test.spec})
+			if test.wantErr != "" {
+				assert.EqualError(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -24,6 +24,17 @@ import (
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/apikey"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/basicauth"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/denial"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/externalauthz"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/ipallowlist"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/issuer"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/jwt"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/lockout"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/mtls"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/oauthintro"
+	"github.com/traefik/hub-agent-kubernetes/pkg/acp/upstreamtoken"
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 	hubclientset "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/clientset/versioned"
 	hubinformers "github.com/traefik/hub-agent-kubernetes/pkg/crd/generated/client/hub/informers/externalversions"
@@ -191,46 +202,13 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 	spec := hubv1alpha1.AccessControlPolicySpec{}
 	switch {
 	case a.JWT != nil:
-		spec.JWT = &hubv1alpha1.AccessControlPolicyJWT{
-			SigningSecret:              a.JWT.SigningSecret,
-			SigningSecretBase64Encoded: a.JWT.SigningSecretBase64Encoded,
-			PublicKey:                  a.JWT.PublicKey,
-			JWKsFile:                   a.JWT.JWKsFile.String(),
-			JWKsURL:                    a.JWT.JWKsURL,
-			StripAuthorizationHeader:   a.JWT.StripAuthorizationHeader,
-			ForwardHeaders:             a.JWT.ForwardHeaders,
-			TokenQueryKey:              a.JWT.TokenQueryKey,
-			Claims:                     a.JWT.Claims,
-		}
+		spec.JWT = buildAccessControlPolicyJWT(a.JWT)
 
 	case a.BasicAuth != nil:
-		spec.BasicAuth = &hubv1alpha1.AccessControlPolicyBasicAuth{
-			Users:                    a.BasicAuth.Users,
-			Realm:                    a.BasicAuth.Realm,
-			StripAuthorizationHeader: a.BasicAuth.StripAuthorizationHeader,
-			ForwardUsernameHeader:    a.BasicAuth.ForwardUsernameHeader,
-		}
+		spec.BasicAuth = buildAccessControlPolicyBasicAuth(a.BasicAuth)
 
 	case a.APIKey != nil:
-		keys := make([]hubv1alpha1.AccessControlPolicyAPIKeyKey, 0, len(a.APIKey.Keys))
-		for _, k := range a.APIKey.Keys {
-			keys = append(keys, hubv1alpha1.AccessControlPolicyAPIKeyKey{
-				ID:       k.ID,
-				Metadata: k.Metadata,
-				Value:    k.Value,
-			})
-		}
-
-		spec.APIKey = &hubv1alpha1.AccessControlPolicyAPIKey{
-			KeySource: hubv1alpha1.TokenSource{
-				Header:           a.APIKey.KeySource.Header,
-				HeaderAuthScheme: a.APIKey.KeySource.HeaderAuthScheme,
-				Query:            a.APIKey.KeySource.Query,
-				Cookie:           a.APIKey.KeySource.Cookie,
-			},
-			Keys:           keys,
-			ForwardHeaders: a.APIKey.ForwardHeaders,
-		}
+		spec.APIKey = buildAccessControlPolicyAPIKey(a.APIKey)
 
 	case a.OIDC != nil:
 		spec.OIDC = &hubv1alpha1.AccessControlPolicyOIDC{
@@ -242,6 +220,14 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 			Scopes:         a.OIDC.Scopes,
 			ForwardHeaders: a.OIDC.ForwardHeaders,
 			Claims:         a.OIDC.Claims,
+			GroupsClaim:    a.OIDC.GroupsClaim,
+
+			ProviderLogout:        a.OIDC.ProviderLogout,
+			PostLogoutRedirectURL: a.OIDC.PostLogoutRedirectURL,
+			FrontChannelLogoutURL: a.OIDC.FrontChannelLogoutURL,
+			ForwardAccessToken:    a.OIDC.ForwardAccessToken,
+			AccessTokenHeader:     a.OIDC.AccessTokenHeader,
+			FetchUserInfo:         a.OIDC.FetchUserInfo,
 		}
 
 		if a.OIDC.Secret != nil {
@@ -267,6 +253,16 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 				Domain:   a.OIDC.Session.Domain,
 				Path:     a.OIDC.Session.Path,
 				Refresh:  a.OIDC.Session.Refresh,
+				Store:    a.OIDC.Session.Store,
+
+				KeyGracePeriodSeconds: a.OIDC.Session.KeyGracePeriodSeconds,
+			}
+
+			if a.OIDC.Session.KeysSecret != nil {
+				spec.OIDC.Session.KeysSecret = &corev1.SecretReference{
+					Name:      a.OIDC.Session.KeysSecret.Name,
+					Namespace: a.OIDC.Session.KeysSecret.Namespace,
+				}
 			}
 		}
 
@@ -303,48 +299,356 @@ func buildAccessControlPolicySpec(a ACP) hubv1alpha1.AccessControlPolicySpec {
 				Domain:   a.OIDCGoogle.Session.Domain,
 				Path:     a.OIDCGoogle.Session.Path,
 				Refresh:  a.OIDCGoogle.Session.Refresh,
+				Store:    a.OIDCGoogle.Session.Store,
+
+				KeyGracePeriodSeconds: a.OIDCGoogle.Session.KeyGracePeriodSeconds,
+			}
+
+			if a.OIDCGoogle.Session.KeysSecret != nil {
+				spec.OIDCGoogle.Session.KeysSecret = &corev1.SecretReference{
+					Name:      a.OIDCGoogle.Session.KeysSecret.Name,
+					Namespace: a.OIDCGoogle.Session.KeysSecret.Namespace,
+				}
 			}
 		}
 
 	case a.OAuthIntro != nil:
-		spec.OAuthIntro = &hubv1alpha1.AccessControlOAuthIntro{
-			Claims:         a.OAuthIntro.Claims,
-			ForwardHeaders: a.OAuthIntro.ForwardHeaders,
+		spec.OAuthIntro = buildAccessControlOAuthIntro(a.OAuthIntro)
+
+	case a.MTLS != nil:
+		spec.MTLS = buildAccessControlPolicyMTLS(a.MTLS)
+
+	case a.IPAllowList != nil:
+		spec.IPAllowList = buildAccessControlPolicyIPAllowList(a.IPAllowList)
+
+	case a.ExternalAuthz != nil:
+		spec.ExternalAuthz = buildAccessControlPolicyExternalAuthz(a.ExternalAuthz)
+
+	case len(a.AnyOf) > 0:
+		spec.AnyOf = buildAccessControlPolicyMethods(a.AnyOf)
+
+	case len(a.AllOf) > 0:
+		spec.AllOf = buildAccessControlPolicyMethods(a.AllOf)
+	}
+
+	spec.EnforcementMode = a.EnforcementMode
+
+	if a.IssueToken != nil {
+		spec.IssueToken = buildAccessControlPolicyIssueToken(a.IssueToken)
+	}
+
+	if a.UpstreamToken != nil {
+		spec.UpstreamToken = buildAccessControlPolicyUpstreamToken(a.UpstreamToken)
+	}
+
+	if a.DenialResponse != nil {
+		spec.DenialResponse = buildAccessControlPolicyDenialResponse(a.DenialResponse)
+	}
+
+	return spec
+}
+
+func buildAccessControlPolicyIssueToken(cfg *issuer.Config) *hubv1alpha1.AccessControlPolicyIssueToken {
+	return &hubv1alpha1.AccessControlPolicyIssueToken{
+		KeySecret: corev1.SecretReference{
+			Name:      cfg.KeySecret.Name,
+			Namespace: cfg.KeySecret.Namespace,
+		},
+		Header:     cfg.Header,
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		TTLSeconds: cfg.TTLSeconds,
+		Claims:     cfg.Claims,
+	}
+}
+
+func buildAccessControlPolicyUpstreamToken(cfg *upstreamtoken.Config) *hubv1alpha1.AccessControlPolicyUpstreamToken {
+	policy := &hubv1alpha1.AccessControlPolicyUpstreamToken{
+		HTTPClientConfig: hubv1alpha1.HTTPClientConfig{
+			TimeoutSeconds: cfg.TimeoutSeconds.Int(),
+			MaxRetries:     cfg.MaxRetries.Int(),
+		},
+		TokenURL: cfg.TokenURL,
+		Secret: corev1.SecretReference{
+			Name:      cfg.Secret.Name,
+			Namespace: cfg.Secret.Namespace,
+		},
+		Scopes:         cfg.Scopes,
+		EndpointParams: cfg.EndpointParams,
+	}
+
+	if cfg.TLS != nil {
+		policy.TLS = &hubv1alpha1.HTTPClientConfigTLS{
+			CABundle:           cfg.TLS.CABundle,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+	}
+
+	return policy
+}
+
+func buildAccessControlPolicyDenialResponse(cfg *denial.Config) *hubv1alpha1.AccessControlPolicyDenialResponse {
+	policy := &hubv1alpha1.AccessControlPolicyDenialResponse{
+		ProblemDetails: cfg.ProblemDetails,
+	}
+
+	if cfg.HTMLTemplate != nil {
+		policy.HTMLTemplate = &hubv1alpha1.ConfigMapKeyReference{
+			Name:      cfg.HTMLTemplate.Name,
+			Namespace: cfg.HTMLTemplate.Namespace,
+			Key:       cfg.HTMLTemplate.Key,
+		}
+	}
+
+	return policy
+}
+
+func buildAccessControlPolicyMethods(cfgs []Config) []hubv1alpha1.AccessControlPolicyMethod {
+	methods := make([]hubv1alpha1.AccessControlPolicyMethod, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		var method hubv1alpha1.AccessControlPolicyMethod
+		switch {
+		case cfg.JWT != nil:
+			method.JWT = buildAccessControlPolicyJWT(cfg.JWT)
+
+		case cfg.BasicAuth != nil:
+			method.BasicAuth = buildAccessControlPolicyBasicAuth(cfg.BasicAuth)
+
+		case cfg.APIKey != nil:
+			method.APIKey = buildAccessControlPolicyAPIKey(cfg.APIKey)
+
+		case cfg.OAuthIntro != nil:
+			method.OAuthIntro = buildAccessControlOAuthIntro(cfg.OAuthIntro)
+
+		case cfg.MTLS != nil:
+			method.MTLS = buildAccessControlPolicyMTLS(cfg.MTLS)
+
+		case cfg.IPAllowList != nil:
+			method.IPAllowList = buildAccessControlPolicyIPAllowList(cfg.IPAllowList)
+
+		case cfg.ExternalAuthz != nil:
+			method.ExternalAuthz = buildAccessControlPolicyExternalAuthz(cfg.ExternalAuthz)
+		}
+
+		methods = append(methods, method)
+	}
+
+	return methods
+}
+
+func buildAccessControlPolicyJWT(cfg *jwt.Config) *hubv1alpha1.AccessControlPolicyJWT {
+	policy := &hubv1alpha1.AccessControlPolicyJWT{
+		SigningSecret:              cfg.SigningSecret,
+		SigningSecretBase64Encoded: cfg.SigningSecretBase64Encoded,
+		PublicKey:                  cfg.PublicKey,
+		JWKsFile:                   cfg.JWKsFile.String(),
+		JWKsURL:                    cfg.JWKsURL,
+		StripAuthorizationHeader:   cfg.StripAuthorizationHeader,
+		ForwardHeaders:             cfg.ForwardHeaders,
+		TokenQueryKey:              cfg.TokenQueryKey,
+		Claims:                     cfg.Claims,
+		GroupsClaim:                cfg.GroupsClaim,
+		Issuer:                     cfg.Issuer,
+		Audience:                   cfg.Audience,
+		Algorithms:                 cfg.Algorithms,
+		LeewaySeconds:              cfg.LeewaySeconds,
+	}
+
+	if cfg.DecryptionKeySecret != nil {
+		policy.DecryptionKeySecret = &corev1.SecretReference{
+			Name:      cfg.DecryptionKeySecret.Name,
+			Namespace: cfg.DecryptionKeySecret.Namespace,
+		}
+	}
+
+	return policy
+}
+
+func buildAccessControlPolicyBasicAuth(cfg *basicauth.Config) *hubv1alpha1.AccessControlPolicyBasicAuth {
+	policy := &hubv1alpha1.AccessControlPolicyBasicAuth{
+		Users:                    cfg.Users,
+		Realm:                    cfg.Realm,
+		StripAuthorizationHeader: cfg.StripAuthorizationHeader,
+		ForwardUsernameHeader:    cfg.ForwardUsernameHeader,
+		Lockout:                  buildAccessControlPolicyLockout(cfg.Lockout),
+	}
+
+	if cfg.UsersSecret != nil {
+		policy.UsersSecret = &corev1.SecretReference{
+			Name:      cfg.UsersSecret.Name,
+			Namespace: cfg.UsersSecret.Namespace,
 		}
+	}
+
+	return policy
+}
 
-		spec.OAuthIntro.TokenSource = hubv1alpha1.TokenSource{
-			Header:           a.OAuthIntro.TokenSource.Header,
-			HeaderAuthScheme: a.OAuthIntro.TokenSource.HeaderAuthScheme,
-			Query:            a.OAuthIntro.TokenSource.Query,
-			Cookie:           a.OAuthIntro.TokenSource.Cookie,
+func buildAccessControlPolicyAPIKey(cfg *apikey.Config) *hubv1alpha1.AccessControlPolicyAPIKey {
+	keys := make([]hubv1alpha1.AccessControlPolicyAPIKeyKey, 0, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		key := hubv1alpha1.AccessControlPolicyAPIKeyKey{
+			ID:       k.ID,
+			Metadata: k.Metadata,
+			Value:    k.Value,
+		}
+		if k.NotBefore != nil {
+			notBefore := metav1.NewTime(*k.NotBefore)
+			key.NotBefore = &notBefore
 		}
+		if k.ExpiresAt != nil {
+			expiresAt := metav1.NewTime(*k.ExpiresAt)
+			key.ExpiresAt = &expiresAt
+		}
+
+		keys = append(keys, key)
+	}
+
+	policy := &hubv1alpha1.AccessControlPolicyAPIKey{
+		KeySource: hubv1alpha1.TokenSource{
+			Header:           cfg.KeySource.Header,
+			HeaderAuthScheme: cfg.KeySource.HeaderAuthScheme,
+			Query:            cfg.KeySource.Query,
+			Cookie:           cfg.KeySource.Cookie,
+		},
+		Keys:           keys,
+		ForwardHeaders: cfg.ForwardHeaders,
+		Lockout:        buildAccessControlPolicyLockout(cfg.Lockout),
+	}
 
-		spec.OAuthIntro.ClientConfig = hubv1alpha1.AccessControlOAuthIntroClientConfig{
-			HTTPClientConfig: hubv1alpha1.HTTPClientConfig{
-				TimeoutSeconds: a.OAuthIntro.ClientConfig.TimeoutSeconds.Int(),
-				MaxRetries:     a.OAuthIntro.ClientConfig.MaxRetries.Int(),
+	for _, ref := range cfg.KeySecrets {
+		policy.KeySecrets = append(policy.KeySecrets, corev1.SecretReference{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+	}
+
+	if cfg.KeySecretSelector != nil {
+		policy.KeySecretSelector = &hubv1alpha1.AccessControlPolicyAPIKeySecretSelector{
+			Namespace: cfg.KeySecretSelector.Namespace,
+			Selector: metav1.LabelSelector{
+				MatchLabels: cfg.KeySecretSelector.MatchLabels,
 			},
-			URL:           a.OAuthIntro.ClientConfig.URL,
-			Headers:       a.OAuthIntro.ClientConfig.Headers,
-			TokenTypeHint: a.OAuthIntro.ClientConfig.TokenTypeHint,
 		}
 
-		if a.OAuthIntro.ClientConfig.TLS != nil {
-			spec.OAuthIntro.ClientConfig.HTTPClientConfig.TLS = &hubv1alpha1.HTTPClientConfigTLS{
-				CABundle:           a.OAuthIntro.ClientConfig.TLS.CABundle,
-				InsecureSkipVerify: a.OAuthIntro.ClientConfig.TLS.InsecureSkipVerify,
-			}
+		for _, expr := range cfg.KeySecretSelector.MatchExpressions {
+			policy.KeySecretSelector.Selector.MatchExpressions = append(policy.KeySecretSelector.Selector.MatchExpressions, metav1.LabelSelectorRequirement{
+				Key:      expr.Key,
+				Operator: metav1.LabelSelectorOperator(expr.Operator),
+				Values:   expr.Values,
+			})
 		}
+	}
+
+	return policy
+}
+
+func buildAccessControlPolicyLockout(cfg *lockout.Config) *hubv1alpha1.AccessControlPolicyLockout {
+	if cfg == nil {
+		return nil
+	}
+
+	return &hubv1alpha1.AccessControlPolicyLockout{
+		MaxFailuresPerIP:   cfg.MaxFailuresPerIP,
+		MaxFailuresPerUser: cfg.MaxFailuresPerUser,
+		WindowSeconds:      cfg.WindowSeconds,
+		LockoutSeconds:     cfg.LockoutSeconds,
+	}
+}
 
-		spec.OAuthIntro.ClientConfig.Auth = hubv1alpha1.AccessControlOAuthIntroClientConfigAuth{
-			Kind: a.OAuthIntro.ClientConfig.Auth.Kind,
+func buildAccessControlPolicyIPAllowList(cfg *ipallowlist.Config) *hubv1alpha1.AccessControlPolicyIPAllowList {
+	return &hubv1alpha1.AccessControlPolicyIPAllowList{
+		SourceRanges:      cfg.SourceRanges,
+		DeniedRanges:      cfg.DeniedRanges,
+		TrustedProxyDepth: cfg.TrustedProxyDepth,
+	}
+}
+
+func buildAccessControlPolicyExternalAuthz(cfg *externalauthz.Config) *hubv1alpha1.AccessControlPolicyExternalAuthz {
+	policy := &hubv1alpha1.AccessControlPolicyExternalAuthz{
+		HTTPClientConfig: hubv1alpha1.HTTPClientConfig{
+			TimeoutSeconds: cfg.TimeoutSeconds.Int(),
+			MaxRetries:     cfg.MaxRetries.Int(),
+		},
+		URL:            cfg.URL,
+		RequestHeaders: cfg.RequestHeaders,
+		ForwardHeaders: cfg.ForwardHeaders,
+	}
+
+	if cfg.TLS != nil {
+		policy.TLS = &hubv1alpha1.HTTPClientConfigTLS{
+			CABundle:           cfg.TLS.CABundle,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
 		}
+	}
 
-		spec.OAuthIntro.ClientConfig.Auth.Secret = corev1.SecretReference{
-			Name:      a.OAuthIntro.ClientConfig.Auth.Secret.Name,
-			Namespace: a.OAuthIntro.ClientConfig.Auth.Secret.Namespace,
+	if cfg.Cache != nil {
+		policy.Cache = &hubv1alpha1.AccessControlPolicyExternalAuthzCache{
+			MaxSize:    cfg.Cache.MaxSize.Int(),
+			TTLSeconds: cfg.Cache.TTLSeconds.Int(),
 		}
 	}
 
-	return spec
+	return policy
+}
+
+func buildAccessControlPolicyMTLS(cfg *mtls.Config) *hubv1alpha1.AccessControlPolicyMTLS {
+	return &hubv1alpha1.AccessControlPolicyMTLS{
+		CASecret: corev1.SecretReference{
+			Name:      cfg.CASecret.Name,
+			Namespace: cfg.CASecret.Namespace,
+		},
+		Claims:         cfg.Claims,
+		ForwardHeaders: cfg.ForwardHeaders,
+	}
+}
+
+func buildAccessControlOAuthIntro(cfg *oauthintro.Config) *hubv1alpha1.AccessControlOAuthIntro {
+	policy := &hubv1alpha1.AccessControlOAuthIntro{
+		Claims:         cfg.Claims,
+		GroupsClaim:    cfg.GroupsClaim,
+		ForwardHeaders: cfg.ForwardHeaders,
+	}
+
+	if cfg.Cache != nil {
+		policy.Cache = &hubv1alpha1.AccessControlOAuthIntroCache{
+			MaxSize:            cfg.Cache.MaxSize.IntOrDefault(oauthintro.DefaultCacheMaxSize),
+			MaxTTLSeconds:      cfg.Cache.MaxTTLSeconds.IntOrDefault(oauthintro.DefaultCacheMaxTTLSeconds),
+			NegativeTTLSeconds: cfg.Cache.NegativeTTLSeconds.IntOrDefault(oauthintro.DefaultCacheNegativeTTLSeconds),
+		}
+	}
+
+	policy.TokenSource = hubv1alpha1.TokenSource{
+		Header:           cfg.TokenSource.Header,
+		HeaderAuthScheme: cfg.TokenSource.HeaderAuthScheme,
+		Query:            cfg.TokenSource.Query,
+		Cookie:           cfg.TokenSource.Cookie,
+	}
+
+	policy.ClientConfig = hubv1alpha1.AccessControlOAuthIntroClientConfig{
+		HTTPClientConfig: hubv1alpha1.HTTPClientConfig{
+			TimeoutSeconds: cfg.ClientConfig.TimeoutSeconds.Int(),
+			MaxRetries:     cfg.ClientConfig.MaxRetries.Int(),
+		},
+		URL:           cfg.ClientConfig.URL,
+		Headers:       cfg.ClientConfig.Headers,
+		TokenTypeHint: cfg.ClientConfig.TokenTypeHint,
+	}
+
+	if cfg.ClientConfig.TLS != nil {
+		policy.ClientConfig.HTTPClientConfig.TLS = &hubv1alpha1.HTTPClientConfigTLS{
+			CABundle:           cfg.ClientConfig.TLS.CABundle,
+			InsecureSkipVerify: cfg.ClientConfig.TLS.InsecureSkipVerify,
+		}
+	}
+
+	policy.ClientConfig.Auth = hubv1alpha1.AccessControlOAuthIntroClientConfigAuth{
+		Kind: cfg.ClientConfig.Auth.Kind,
+	}
+
+	policy.ClientConfig.Auth.Secret = corev1.SecretReference{
+		Name:      cfg.ClientConfig.Auth.Secret.Name,
+		Namespace: cfg.ClientConfig.Auth.Secret.Namespace,
+	}
+
+	return policy
 }
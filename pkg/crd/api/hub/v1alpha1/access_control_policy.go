@@ -47,12 +47,128 @@ type AccessControlPolicy struct {
 
 // AccessControlPolicySpec configures an access control policy.
 type AccessControlPolicySpec struct {
-	JWT        *AccessControlPolicyJWT        `json:"jwt,omitempty"`
-	BasicAuth  *AccessControlPolicyBasicAuth  `json:"basicAuth,omitempty"`
-	APIKey     *AccessControlPolicyAPIKey     `json:"apiKey,omitempty"`
-	OIDC       *AccessControlPolicyOIDC       `json:"oidc,omitempty"`
-	OIDCGoogle *AccessControlPolicyOIDCGoogle `json:"oidcGoogle,omitempty"`
-	OAuthIntro *AccessControlOAuthIntro       `json:"oAuthIntro,omitempty"`
+	JWT           *AccessControlPolicyJWT           `json:"jwt,omitempty"`
+	BasicAuth     *AccessControlPolicyBasicAuth     `json:"basicAuth,omitempty"`
+	APIKey        *AccessControlPolicyAPIKey        `json:"apiKey,omitempty"`
+	OIDC          *AccessControlPolicyOIDC          `json:"oidc,omitempty"`
+	OIDCGoogle    *AccessControlPolicyOIDCGoogle    `json:"oidcGoogle,omitempty"`
+	OAuthIntro    *AccessControlOAuthIntro          `json:"oAuthIntro,omitempty"`
+	MTLS          *AccessControlPolicyMTLS          `json:"mtls,omitempty"`
+	IPAllowList   *AccessControlPolicyIPAllowList   `json:"ipAllowList,omitempty"`
+	ExternalAuthz *AccessControlPolicyExternalAuthz `json:"externalAuthz,omitempty"`
+
+	// AnyOf grants access to requests accepted by at least one of the given methods.
+	// Methods are evaluated in order and the headers of the first method granting access are forwarded.
+	// +kubebuilder:validation:MinItems:=1
+	AnyOf []AccessControlPolicyMethod `json:"anyOf,omitempty"`
+	// AllOf grants access to requests accepted by all the given methods.
+	// Headers of all methods are forwarded. When several methods set the same header, the first one wins.
+	// +kubebuilder:validation:MinItems:=1
+	AllOf []AccessControlPolicyMethod `json:"allOf,omitempty"`
+
+	// EnforcementMode defines whether the decisions of the policy are enforced. In "audit" mode, requests are
+	// evaluated and the decisions are logged and counted, but access is always granted.
+	// +optional
+	// +kubebuilder:validation:Enum=enforce;audit
+	EnforcementMode string `json:"enforcementMode,omitempty"`
+
+	// IssueToken makes the auth server issue a short-lived JWT for the requests granted by the policy, holding the
+	// verified subject, groups and selected claims of the request. Upstream services can verify it with the public
+	// keys published by the auth server on "/.well-known/jwks.json".
+	// +optional
+	IssueToken *AccessControlPolicyIssueToken `json:"issueToken,omitempty"`
+
+	// UpstreamToken makes the auth server obtain an access token from an OAuth 2.0 Authorization Server with the
+	// client credentials grant, and forward it as the "Authorization" header of the requests granted by the policy.
+	// It allows upstream services expecting a service token, rather than the credentials of the user, to be protected.
+	// +optional
+	UpstreamToken *AccessControlPolicyUpstreamToken `json:"upstreamToken,omitempty"`
+
+	// DenialResponse customizes the responses to the requests denied by the policy.
+	// +optional
+	DenialResponse *AccessControlPolicyDenialResponse `json:"denialResponse,omitempty"`
+}
+
+// AccessControlPolicyIssueToken configures the identity tokens issued for the requests granted by an access control
+// policy.
+type AccessControlPolicyIssueToken struct {
+	// KeySecret references the Kubernetes secret holding, under the "key" entry, the PEM encoded RSA, ECDSA or
+	// Ed25519 private key signing tokens.
+	// +kubebuilder:validation:Required
+	KeySecret corev1.SecretReference `json:"keySecret"`
+	// Header is the header tokens are forwarded in. Defaults to "X-Hub-Identity".
+	// +optional
+	Header string `json:"header,omitempty"`
+	// Issuer is the "iss" claim of tokens. Defaults to "hub-agent".
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+	// Audience is the "aud" claim of tokens.
+	// +optional
+	Audience []string `json:"audience,omitempty"`
+	// TTLSeconds is the lifetime of tokens. Defaults to 60.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
+	// Claims are the names of the verified claims of the request, like the claims of a JWT or the metadata of an API
+	// key, copied to tokens.
+	// +optional
+	Claims []string `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyUpstreamToken configures the access tokens obtained with the OAuth 2.0 client credentials grant
+// for the requests granted by an access control policy. Tokens are cached until shortly before they expire.
+type AccessControlPolicyUpstreamToken struct {
+	HTTPClientConfig `json:",inline"`
+
+	// TokenURL is the token endpoint of the Authorization Server.
+	// +kubebuilder:validation:Required
+	TokenURL string `json:"tokenUrl"`
+	// Secret references the Kubernetes secret holding the client credentials, under the "clientId" and
+	// "clientSecret" entries.
+	// +kubebuilder:validation:Required
+	Secret corev1.SecretReference `json:"secret"`
+	// Scopes are the scopes requested for tokens.
+	// +optional
+	Scopes []string `json:"scopes,omitempty"`
+	// EndpointParams are additional parameters sent to the token endpoint, like "audience".
+	// +optional
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
+// AccessControlPolicyDenialResponse configures the responses to the requests denied by an access control policy.
+type AccessControlPolicyDenialResponse struct {
+	// ProblemDetails makes denied requests answered with a JSON problem details body, as defined by RFC 7807.
+	// +optional
+	ProblemDetails bool `json:"problemDetails,omitempty"`
+	// HTMLTemplate references the ConfigMap entry holding a Go HTML template, rendered instead of the problem
+	// details body for denied browser requests.
+	// +optional
+	HTMLTemplate *ConfigMapKeyReference `json:"htmlTemplate,omitempty"`
+}
+
+// ConfigMapKeyReference references an entry of a ConfigMap in any namespace.
+type ConfigMapKeyReference struct {
+	// Name is the name of the ConfigMap.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Namespace is the namespace of the ConfigMap.
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+	// Key is the entry of the ConfigMap holding the value. Defaults to "template.html".
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// AccessControlPolicyMethod is an authentication method of a composite access control policy.
+// Exactly one method must be set.
+type AccessControlPolicyMethod struct {
+	JWT           *AccessControlPolicyJWT           `json:"jwt,omitempty"`
+	BasicAuth     *AccessControlPolicyBasicAuth     `json:"basicAuth,omitempty"`
+	APIKey        *AccessControlPolicyAPIKey        `json:"apiKey,omitempty"`
+	OAuthIntro    *AccessControlOAuthIntro          `json:"oAuthIntro,omitempty"`
+	MTLS          *AccessControlPolicyMTLS          `json:"mtls,omitempty"`
+	IPAllowList   *AccessControlPolicyIPAllowList   `json:"ipAllowList,omitempty"`
+	ExternalAuthz *AccessControlPolicyExternalAuthz `json:"externalAuthz,omitempty"`
 }
 
 // Hash return AccessControlPolicySpec hash.
@@ -79,6 +195,24 @@ type AccessControlPolicyJWT struct {
 	ForwardHeaders             map[string]string `json:"forwardHeaders,omitempty"`
 	TokenQueryKey              string            `json:"tokenQueryKey,omitempty"`
 	Claims                     string            `json:"claims,omitempty"`
+	// GroupsClaim is the claim holding the groups of the token bearer. When set, requests requiring groups, through the
+	// "groups" query parameter set by API portals and gateways, are only allowed if it holds one of them.
+	// +optional
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+
+	// Issuer is the expected value of the "iss" claim.
+	Issuer string `json:"issuer,omitempty"`
+	// Audience holds the accepted values of the "aud" claim. Tokens must be intended for at least one of them.
+	Audience []string `json:"audience,omitempty"`
+	// Algorithms holds the accepted signing algorithms, e.g. "RS256". All supported algorithms are accepted by default.
+	Algorithms []string `json:"algorithms,omitempty"`
+	// LeewaySeconds is the clock skew tolerated when validating the "exp", "nbf" and "iat" claims.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	LeewaySeconds int `json:"leewaySeconds,omitempty"`
+	// DecryptionKeySecret references the Kubernetes secret holding the private keys used to decrypt JWE tokens, either
+	// as a PEM encoded private key under the "key" key or as a JWK set under the "jwks" key.
+	DecryptionKeySecret *corev1.SecretReference `json:"decryptionKeySecret,omitempty"`
 }
 
 // AccessControlPolicyBasicAuth holds the HTTP basic authentication configuration.
@@ -87,6 +221,11 @@ type AccessControlPolicyBasicAuth struct {
 	Realm                    string   `json:"realm,omitempty"`
 	StripAuthorizationHeader bool     `json:"stripAuthorizationHeader,omitempty"`
 	ForwardUsernameHeader    string   `json:"forwardUsernameHeader,omitempty"`
+	// UsersSecret references the Kubernetes secret holding, under the "users" key, an htpasswd file listing additional
+	// users. Passwords must be hashed using bcrypt, SHA1 or APR1. Users must not be listed both here and in Users.
+	UsersSecret *corev1.SecretReference `json:"usersSecret,omitempty"`
+	// Lockout locks out clients and users after too many failed attempts.
+	Lockout *AccessControlPolicyLockout `json:"lockout,omitempty"`
 }
 
 // AccessControlPolicyAPIKey configure an APIKey control policy.
@@ -96,8 +235,50 @@ type AccessControlPolicyAPIKey struct {
 	KeySource TokenSource `json:"keySource"`
 	// Keys define the set of authorized keys to access a protected resource.
 	Keys []AccessControlPolicyAPIKeyKey `json:"keys,omitempty"`
+	// KeySecrets references Secrets holding one API key each, in addition to Keys.
+	// A Secret must have either a "key" entry with the API key, or a "value" entry with its SHAKE-256 hash (using 64 bytes).
+	// It may also have an "id" entry (defaults to the Secret name), a "metadata" entry holding a JSON object of strings,
+	// and "notBefore" and "expiresAt" entries holding RFC 3339 timestamps.
+	// Changes made to these Secrets are applied without updating the policy.
+	KeySecrets []corev1.SecretReference `json:"keySecrets,omitempty"`
+	// KeySecretSelector selects Secrets holding one API key each, in addition to Keys.
+	// Selected Secrets must follow the same format as KeySecrets. Invalid ones are skipped with a warning.
+	KeySecretSelector *AccessControlPolicyAPIKeySecretSelector `json:"keySecretSelector,omitempty"`
 	// ForwardHeaders instructs the middleware to forward key metadata as header values upon successful authentication.
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	// Lockout locks out clients and keys after too many failed attempts.
+	Lockout *AccessControlPolicyLockout `json:"lockout,omitempty"`
+}
+
+// AccessControlPolicyLockout configures the lockout of clients and users after too many failed attempts.
+// Locked out requests are denied with a 429 status code and a Retry-After header.
+type AccessControlPolicyLockout struct {
+	// MaxFailuresPerIP is the number of failed attempts from a client IP after which it is locked out.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxFailuresPerIP int `json:"maxFailuresPerIP,omitempty"`
+	// MaxFailuresPerUser is the number of failed attempts for a user, or an API key ID, after which it is locked out.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxFailuresPerUser int `json:"maxFailuresPerUser,omitempty"`
+	// WindowSeconds is the period over which failed attempts are counted. Defaults to 60.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	WindowSeconds int `json:"windowSeconds,omitempty"`
+	// LockoutSeconds is the duration of lockouts. Defaults to 300.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	LockoutSeconds int `json:"lockoutSeconds,omitempty"`
+}
+
+// AccessControlPolicyAPIKeySecretSelector selects Secrets within a namespace.
+type AccessControlPolicyAPIKeySecretSelector struct {
+	// Namespace is the namespace of the selected Secrets.
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+	// Selector is the label selector of the Secrets.
+	// +kubebuilder:validation:Required
+	Selector metav1.LabelSelector `json:"selector"`
 }
 
 // AccessControlPolicyAPIKeyKey defines an API key.
@@ -110,6 +291,10 @@ type AccessControlPolicyAPIKeyKey struct {
 	Value string `json:"value"`
 	// Metadata holds arbitrary metadata for this key, can be used by ForwardHeaders.
 	Metadata map[string]string `json:"metadata,omitempty"`
+	// NotBefore is the time before which the key is rejected.
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+	// ExpiresAt is the time from which the key is rejected.
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
 }
 
 // AccessControlPolicyOIDC holds the OIDC authentication configuration.
@@ -123,12 +308,41 @@ type AccessControlPolicyOIDC struct {
 	LogoutURL   string            `json:"logoutUrl,omitempty"`
 	AuthParams  map[string]string `json:"authParams,omitempty"`
 
+	// ProviderLogout ends the session at the provider when users log out with a GET request on the logout URL, by
+	// redirecting them to the end_session_endpoint of the provider.
+	// +optional
+	ProviderLogout bool `json:"providerLogout,omitempty"`
+	// PostLogoutRedirectURL is the URL the provider redirects users to once the session at the provider is ended.
+	// +optional
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl,omitempty"`
+	// FrontChannelLogoutURL is the URL the provider loads to end the session of users logging out from the provider.
+	// Back-channel logout is available on the auth server, under /<policy name>/backchannel-logout, when sessions
+	// are stored server-side.
+	// +optional
+	FrontChannelLogoutURL string `json:"frontChannelLogoutUrl,omitempty"`
+
 	StateCookie *StateCookie `json:"stateCookie,omitempty"`
 	Session     *Session     `json:"session,omitempty"`
 
 	Scopes         []string          `json:"scopes,omitempty"`
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 	Claims         string            `json:"claims,omitempty"`
+	// GroupsClaim is the claim holding the groups of the user. When set, requests requiring groups, through the
+	// "groups" query parameter set by API portals and gateways, are only allowed if it holds one of them.
+	// +optional
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+
+	// ForwardAccessToken defines whether the access token is forwarded to upstream services. Defaults to true.
+	// +optional
+	ForwardAccessToken *bool `json:"forwardAccessToken,omitempty"`
+	// AccessTokenHeader is the header the access token is forwarded in. Defaults to Authorization, in which case the
+	// access token is forwarded as a bearer token.
+	// +optional
+	AccessTokenHeader string `json:"accessTokenHeader,omitempty"`
+	// FetchUserInfo defines whether the claims of the userinfo endpoint of the provider complete the ID token claims
+	// used by claims and forwardHeaders.
+	// +optional
+	FetchUserInfo bool `json:"fetchUserInfo,omitempty"`
 }
 
 // AccessControlPolicyOIDCGoogle holds the Google OIDC authentication configuration.
@@ -165,6 +379,94 @@ type Session struct {
 	Domain   string `json:"domain,omitempty"`
 	Path     string `json:"path,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	// Store is where sessions are stored. With "cookie", the default, sessions are stored in encrypted cookies.
+	// With "memory" or "redis", sessions are stored by the auth server, in memory or in the Redis server it is
+	// configured with, and session cookies only hold an opaque session ID. Server-side sessions can be revoked.
+	// +optional
+	// +kubebuilder:validation:Enum=cookie;memory;redis
+	Store string `json:"store,omitempty"`
+	// KeysSecret references a Secret holding the keys encrypting sessions, one per entry, the entry name being the key
+	// ID. The key with the greatest ID encrypts sessions: IDs are compared as numbers if they all are integers, like 9
+	// and 10, and in lexical order otherwise, like dates. The other keys still decrypt the sessions they encrypted,
+	// which are encrypted again with the current key when refreshed. To rotate keys, add a key with a greater ID and
+	// remove previous keys once the grace period is over. Defaults to the agent key.
+	// +optional
+	KeysSecret *corev1.SecretReference `json:"keysSecret,omitempty"`
+	// KeyGracePeriodSeconds is how long sessions encrypted with a previous key stay valid. Defaults to the session
+	// lifetime, one day.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	KeyGracePeriodSeconds int `json:"keyGracePeriodSeconds,omitempty"`
+}
+
+// AccessControlPolicyMTLS configures a mutual TLS client certificate access control policy.
+// The client certificate is read from the headers set by the ingress controller: X-Forwarded-Tls-Client-Cert for
+// Traefik, set by a passTLSClientCert middleware the admission webhook adds before the ACP middleware, and
+// ssl-client-cert for Nginx.
+type AccessControlPolicyMTLS struct {
+	// CASecret references the Kubernetes secret holding, under the "ca.crt" key, the PEM encoded CA bundle used to
+	// verify client certificates.
+	// +kubebuilder:validation:Required
+	CASecret corev1.SecretReference `json:"caSecret"`
+	// Claims defines an expression to validate the client certificate. Available claims are "subject.commonName",
+	// "subject.organization", "subject.organizationalUnit", "subject.country", "subject.locality", "subject.province",
+	// "issuer.commonName", "serialNumber", "dnsNames", "emailAddresses", "ipAddresses" and "uris".
+	Claims string `json:"claims,omitempty"`
+	// ForwardHeaders instructs the middleware to forward certificate claims as header values upon successful authentication.
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+}
+
+// AccessControlPolicyIPAllowList configures an access control policy granting access according to the client IP.
+// Combined with other methods in an "allOf" policy, it restricts the networks identities can authenticate from.
+type AccessControlPolicyIPAllowList struct {
+	// SourceRanges are the IPs and CIDRs allowed to access resources. All IPs are allowed if empty.
+	// +optional
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+	// DeniedRanges are the IPs and CIDRs denied access to resources, even if they are in SourceRanges.
+	// +optional
+	DeniedRanges []string `json:"deniedRanges,omitempty"`
+	// TrustedProxyDepth is the number of trusted proxies in front of the ingress controller. When set, the client IP
+	// is read from the X-Forwarded-For header, skipping the entries added by these proxies. Otherwise, it is read from
+	// the X-Real-Ip header or from the closest entry of the X-Forwarded-For header.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	TrustedProxyDepth int `json:"trustedProxyDepth,omitempty"`
+}
+
+// AccessControlPolicyExternalAuthz configures an access control policy delegating authorization decisions to an
+// external HTTP service. The description of requests is POSTed as JSON to the service, with the claims reported by the
+// methods evaluated before in an "allOf" policy. Access is granted when the service answers with a 2xx status code,
+// and denied when it answers with a 401 or 403 status code.
+type AccessControlPolicyExternalAuthz struct {
+	HTTPClientConfig `json:",inline"`
+
+	// URL is the endpoint of the external authorization service.
+	// +kubebuilder:validation:Required
+	URL string `json:"url"`
+	// RequestHeaders are the headers of the request sent to the service. No headers are sent if empty.
+	// +optional
+	RequestHeaders []string `json:"requestHeaders,omitempty"`
+	// ForwardHeaders are the headers returned by the service forwarded to upstream services when access is granted.
+	// +optional
+	ForwardHeaders []string `json:"forwardHeaders,omitempty"`
+	// Cache configures the caching of authorization decisions.
+	// Decisions are not cached when it is not set.
+	// +optional
+	Cache *AccessControlPolicyExternalAuthzCache `json:"cache,omitempty"`
+}
+
+// AccessControlPolicyExternalAuthzCache configures the in-memory cache of authorization decisions. Decisions are
+// cached per original method, host and path, request headers and identity reported by the methods evaluated before.
+type AccessControlPolicyExternalAuthzCache struct {
+	// MaxSize is the maximum number of decisions kept in memory.
+	// The least recently used decisions are evicted first.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:default:=1000
+	MaxSize int `json:"maxSize,omitempty"`
+	// TTLSeconds is the amount of seconds a decision is cached.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:default:=60
+	TTLSeconds int `json:"ttlSeconds,omitempty"`
 }
 
 // AccessControlOAuthIntro configures an OAuth 2.0 Token Introspection access control policy.
@@ -175,6 +477,33 @@ type AccessControlOAuthIntro struct {
 	TokenSource    TokenSource       `json:"tokenSource"`
 	Claims         string            `json:"claims,omitempty"`
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	// GroupsClaim is the claim holding the groups of the token owner. When set, requests requiring groups, through the
+	// "groups" query parameter set by API portals and gateways, are only allowed if it holds one of them.
+	// +optional
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// Cache configures the caching of token introspection results.
+	// Results are not cached when it is not set.
+	Cache *AccessControlOAuthIntroCache `json:"cache,omitempty"`
+}
+
+// AccessControlOAuthIntroCache configures the in-memory cache of token introspection results.
+// Tokens are never stored as is, only their hash is kept.
+type AccessControlOAuthIntroCache struct {
+	// MaxSize is the maximum number of introspection results kept in memory.
+	// The least recently used results are evicted first.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:default:=1000
+	MaxSize int `json:"maxSize,omitempty"`
+	// MaxTTLSeconds is the maximum amount of seconds an active token introspection result is cached.
+	// Results are never cached beyond the expiration time of the token given by the "exp" claim.
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:default:=300
+	MaxTTLSeconds int `json:"maxTtlSeconds,omitempty"`
+	// NegativeTTLSeconds is the amount of seconds an inactive token introspection result is cached.
+	// Setting it to 0 disables the caching of inactive tokens.
+	// +kubebuilder:validation:Minimum:=0
+	// +kubebuilder:default:=10
+	NegativeTTLSeconds int `json:"negativeTtlSeconds,omitempty"`
 }
 
 // AccessControlOAuthIntroClientConfig configures the OAuth 2.0 client for issuing token introspection requests.
@@ -585,6 +585,11 @@ func (in *AccessControlOAuthIntro) DeepCopyInto(out *AccessControlOAuthIntro) {
 			(*out)[key] = val
 		}
 	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(AccessControlOAuthIntroCache)
+		**out = **in
+	}
 	return
 }
 
@@ -598,6 +603,22 @@ func (in *AccessControlOAuthIntro) DeepCopy() *AccessControlOAuthIntro {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlOAuthIntroCache) DeepCopyInto(out *AccessControlOAuthIntroCache) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlOAuthIntroCache.
+func (in *AccessControlOAuthIntroCache) DeepCopy() *AccessControlOAuthIntroCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlOAuthIntroCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlOAuthIntroClientConfig) DeepCopyInto(out *AccessControlOAuthIntroClientConfig) {
 	*out = *in
@@ -679,6 +700,16 @@ func (in *AccessControlPolicyAPIKey) DeepCopyInto(out *AccessControlPolicyAPIKey
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.KeySecrets != nil {
+		in, out := &in.KeySecrets, &out.KeySecrets
+		*out = make([]corev1.SecretReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeySecretSelector != nil {
+		in, out := &in.KeySecretSelector, &out.KeySecretSelector
+		*out = new(AccessControlPolicyAPIKeySecretSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ForwardHeaders != nil {
 		in, out := &in.ForwardHeaders, &out.ForwardHeaders
 		*out = make(map[string]string, len(*in))
@@ -686,6 +717,11 @@ func (in *AccessControlPolicyAPIKey) DeepCopyInto(out *AccessControlPolicyAPIKey
 			(*out)[key] = val
 		}
 	}
+	if in.Lockout != nil {
+		in, out := &in.Lockout, &out.Lockout
+		*out = new(AccessControlPolicyLockout)
+		**out = **in
+	}
 	return
 }
 
@@ -709,6 +745,14 @@ func (in *AccessControlPolicyAPIKeyKey) DeepCopyInto(out *AccessControlPolicyAPI
 			(*out)[key] = val
 		}
 	}
+	if in.NotBefore != nil {
+		in, out := &in.NotBefore, &out.NotBefore
+		*out = (*in).DeepCopy()
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
 	return
 }
 
@@ -722,6 +766,23 @@ func (in *AccessControlPolicyAPIKeyKey) DeepCopy() *AccessControlPolicyAPIKeyKey
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyAPIKeySecretSelector) DeepCopyInto(out *AccessControlPolicyAPIKeySecretSelector) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyAPIKeySecretSelector.
+func (in *AccessControlPolicyAPIKeySecretSelector) DeepCopy() *AccessControlPolicyAPIKeySecretSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyAPIKeySecretSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicyBasicAuth) DeepCopyInto(out *AccessControlPolicyBasicAuth) {
 	*out = *in
@@ -730,6 +791,16 @@ func (in *AccessControlPolicyBasicAuth) DeepCopyInto(out *AccessControlPolicyBas
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.UsersSecret != nil {
+		in, out := &in.UsersSecret, &out.UsersSecret
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+	if in.Lockout != nil {
+		in, out := &in.Lockout, &out.Lockout
+		*out = new(AccessControlPolicyLockout)
+		**out = **in
+	}
 	return
 }
 
@@ -743,6 +814,128 @@ func (in *AccessControlPolicyBasicAuth) DeepCopy() *AccessControlPolicyBasicAuth
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyDenialResponse) DeepCopyInto(out *AccessControlPolicyDenialResponse) {
+	*out = *in
+	if in.HTMLTemplate != nil {
+		in, out := &in.HTMLTemplate, &out.HTMLTemplate
+		*out = new(ConfigMapKeyReference)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyDenialResponse.
+func (in *AccessControlPolicyDenialResponse) DeepCopy() *AccessControlPolicyDenialResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyDenialResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyExternalAuthz) DeepCopyInto(out *AccessControlPolicyExternalAuthz) {
+	*out = *in
+	in.HTTPClientConfig.DeepCopyInto(&out.HTTPClientConfig)
+	if in.RequestHeaders != nil {
+		in, out := &in.RequestHeaders, &out.RequestHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(AccessControlPolicyExternalAuthzCache)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyExternalAuthz.
+func (in *AccessControlPolicyExternalAuthz) DeepCopy() *AccessControlPolicyExternalAuthz {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyExternalAuthz)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyExternalAuthzCache) DeepCopyInto(out *AccessControlPolicyExternalAuthzCache) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyExternalAuthzCache.
+func (in *AccessControlPolicyExternalAuthzCache) DeepCopy() *AccessControlPolicyExternalAuthzCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyExternalAuthzCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyIPAllowList) DeepCopyInto(out *AccessControlPolicyIPAllowList) {
+	*out = *in
+	if in.SourceRanges != nil {
+		in, out := &in.SourceRanges, &out.SourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeniedRanges != nil {
+		in, out := &in.DeniedRanges, &out.DeniedRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyIPAllowList.
+func (in *AccessControlPolicyIPAllowList) DeepCopy() *AccessControlPolicyIPAllowList {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyIPAllowList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyIssueToken) DeepCopyInto(out *AccessControlPolicyIssueToken) {
+	*out = *in
+	out.KeySecret = in.KeySecret
+	if in.Audience != nil {
+		in, out := &in.Audience, &out.Audience
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Claims != nil {
+		in, out := &in.Claims, &out.Claims
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyIssueToken.
+func (in *AccessControlPolicyIssueToken) DeepCopy() *AccessControlPolicyIssueToken {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyIssueToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicyJWT) DeepCopyInto(out *AccessControlPolicyJWT) {
 	*out = *in
@@ -753,6 +946,21 @@ func (in *AccessControlPolicyJWT) DeepCopyInto(out *AccessControlPolicyJWT) {
 			(*out)[key] = val
 		}
 	}
+	if in.Audience != nil {
+		in, out := &in.Audience, &out.Audience
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Algorithms != nil {
+		in, out := &in.Algorithms, &out.Algorithms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DecryptionKeySecret != nil {
+		in, out := &in.DecryptionKeySecret, &out.DecryptionKeySecret
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
 	return
 }
 
@@ -799,6 +1007,97 @@ func (in *AccessControlPolicyList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyLockout) DeepCopyInto(out *AccessControlPolicyLockout) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyLockout.
+func (in *AccessControlPolicyLockout) DeepCopy() *AccessControlPolicyLockout {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyLockout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyMTLS) DeepCopyInto(out *AccessControlPolicyMTLS) {
+	*out = *in
+	out.CASecret = in.CASecret
+	if in.ForwardHeaders != nil {
+		in, out := &in.ForwardHeaders, &out.ForwardHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyMTLS.
+func (in *AccessControlPolicyMTLS) DeepCopy() *AccessControlPolicyMTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyMTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyMethod) DeepCopyInto(out *AccessControlPolicyMethod) {
+	*out = *in
+	if in.JWT != nil {
+		in, out := &in.JWT, &out.JWT
+		*out = new(AccessControlPolicyJWT)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(AccessControlPolicyBasicAuth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(AccessControlPolicyAPIKey)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OAuthIntro != nil {
+		in, out := &in.OAuthIntro, &out.OAuthIntro
+		*out = new(AccessControlOAuthIntro)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MTLS != nil {
+		in, out := &in.MTLS, &out.MTLS
+		*out = new(AccessControlPolicyMTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPAllowList != nil {
+		in, out := &in.IPAllowList, &out.IPAllowList
+		*out = new(AccessControlPolicyIPAllowList)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalAuthz != nil {
+		in, out := &in.ExternalAuthz, &out.ExternalAuthz
+		*out = new(AccessControlPolicyExternalAuthz)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyMethod.
+func (in *AccessControlPolicyMethod) DeepCopy() *AccessControlPolicyMethod {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyMethod)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AccessControlPolicyOIDC) DeepCopyInto(out *AccessControlPolicyOIDC) {
 	*out = *in
@@ -836,6 +1135,11 @@ func (in *AccessControlPolicyOIDC) DeepCopyInto(out *AccessControlPolicyOIDC) {
 			(*out)[key] = val
 		}
 	}
+	if in.ForwardAccessToken != nil {
+		in, out := &in.ForwardAccessToken, &out.ForwardAccessToken
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -932,6 +1236,50 @@ func (in *AccessControlPolicySpec) DeepCopyInto(out *AccessControlPolicySpec) {
 		*out = new(AccessControlOAuthIntro)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.MTLS != nil {
+		in, out := &in.MTLS, &out.MTLS
+		*out = new(AccessControlPolicyMTLS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IPAllowList != nil {
+		in, out := &in.IPAllowList, &out.IPAllowList
+		*out = new(AccessControlPolicyIPAllowList)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExternalAuthz != nil {
+		in, out := &in.ExternalAuthz, &out.ExternalAuthz
+		*out = new(AccessControlPolicyExternalAuthz)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AnyOf != nil {
+		in, out := &in.AnyOf, &out.AnyOf
+		*out = make([]AccessControlPolicyMethod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllOf != nil {
+		in, out := &in.AllOf, &out.AllOf
+		*out = make([]AccessControlPolicyMethod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IssueToken != nil {
+		in, out := &in.IssueToken, &out.IssueToken
+		*out = new(AccessControlPolicyIssueToken)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpstreamToken != nil {
+		in, out := &in.UpstreamToken, &out.UpstreamToken
+		*out = new(AccessControlPolicyUpstreamToken)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DenialResponse != nil {
+		in, out := &in.DenialResponse, &out.DenialResponse
+		*out = new(AccessControlPolicyDenialResponse)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -962,6 +1310,52 @@ func (in *AccessControlPolicyStatus) DeepCopy() *AccessControlPolicyStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessControlPolicyUpstreamToken) DeepCopyInto(out *AccessControlPolicyUpstreamToken) {
+	*out = *in
+	in.HTTPClientConfig.DeepCopyInto(&out.HTTPClientConfig)
+	out.Secret = in.Secret
+	if in.Scopes != nil {
+		in, out := &in.Scopes, &out.Scopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EndpointParams != nil {
+		in, out := &in.EndpointParams, &out.EndpointParams
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessControlPolicyUpstreamToken.
+func (in *AccessControlPolicyUpstreamToken) DeepCopy() *AccessControlPolicyUpstreamToken {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessControlPolicyUpstreamToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapKeyReference) DeepCopyInto(out *ConfigMapKeyReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapKeyReference.
+func (in *ConfigMapKeyReference) DeepCopy() *ConfigMapKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EdgeIngress) DeepCopyInto(out *EdgeIngress) {
 	*out = *in
@@ -1246,6 +1640,11 @@ func (in *Session) DeepCopyInto(out *Session) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.KeysSecret != nil {
+		in, out := &in.KeysSecret, &out.KeysSecret
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
 	return
 }
 
@@ -36,11 +36,28 @@ type Middleware struct {
 
 // MiddlewareSpec holds the Middleware configuration.
 type MiddlewareSpec struct {
-	ForwardAuth      *ForwardAuth      `json:"forwardAuth,omitempty"`
-	StripPrefix      *StripPrefix      `json:"stripPrefix,omitempty"`
-	StripPrefixRegex *StripPrefixRegex `json:"stripPrefixRegex,omitempty"`
-	AddPrefix        *AddPrefix        `json:"addPrefix,omitempty"`
-	Headers          *Headers          `json:"headers,omitempty"`
+	ForwardAuth       *ForwardAuth       `json:"forwardAuth,omitempty"`
+	StripPrefix       *StripPrefix       `json:"stripPrefix,omitempty"`
+	StripPrefixRegex  *StripPrefixRegex  `json:"stripPrefixRegex,omitempty"`
+	AddPrefix         *AddPrefix         `json:"addPrefix,omitempty"`
+	Headers           *Headers           `json:"headers,omitempty"`
+	PassTLSClientCert *PassTLSClientCert `json:"passTLSClientCert,omitempty"`
+	Chain             *Chain             `json:"chain,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// PassTLSClientCert holds the PassTLSClientCert configuration.
+type PassTLSClientCert struct {
+	// PEM sets the X-Forwarded-Tls-Client-Cert header with the client certificate chain.
+	PEM bool `json:"pem,omitempty"`
+}
+
+// +k8s:deepcopy-gen=true
+
+// Chain holds the Chain configuration.
+type Chain struct {
+	Middlewares []MiddlewareRef `json:"middlewares,omitempty"`
 }
 
 // +k8s:deepcopy-gen=true
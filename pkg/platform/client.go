@@ -735,9 +735,14 @@ func (c *Client) DeleteEdgeIngress(ctx context.Context, namespace, name, lastKno
 
 // CreateACP creates an AccessControlPolicy.
 func (c *Client) CreateACP(ctx context.Context, policy *hubv1alpha1.AccessControlPolicy) (*acp.ACP, error) {
+	cfg, err := acp.ConfigFromPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("make ACP config: %w", err)
+	}
+
 	acpReq := acp.ACP{
 		Name:   policy.Name,
-		Config: *acp.ConfigFromPolicy(policy),
+		Config: *cfg,
 	}
 	body, err := json.Marshal(acpReq)
 	if err != nil {
@@ -764,9 +769,14 @@ func (c *Client) GetACPs(ctx context.Context) ([]acp.ACP, error) {
 
 // UpdateACP updates an AccessControlPolicy.
 func (c *Client) UpdateACP(ctx context.Context, oldVersion string, policy *hubv1alpha1.AccessControlPolicy) (*acp.ACP, error) {
+	cfg, err := acp.ConfigFromPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("make ACP config: %w", err)
+	}
+
 	acpReq := acp.ACP{
 		Name:   policy.Name,
-		Config: *acp.ConfigFromPolicy(policy),
+		Config: *cfg,
 	}
 	body, err := json.Marshal(acpReq)
 	if err != nil {
@@ -0,0 +1,337 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package redis implements a minimal client of the Redis serialization protocol (RESP), used to share state between
+// replicas of the auth server. It is compatible with Redis and its protocol-compatible alternatives.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default configuration values.
+const (
+	DefaultTimeout  = 5 * time.Second
+	DefaultPoolSize = 10
+)
+
+// Config configures a Client.
+type Config struct {
+	// Address is the "host:port" address of the server.
+	Address  string
+	Username string
+	Password string
+	DB       int
+	TLS      bool
+	// Timeout bounds the duration of connections and commands. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// PoolSize is the maximum number of idle connections kept open. Defaults to DefaultPoolSize.
+	PoolSize int
+}
+
+// Error is an error returned by the server.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+// ErrNil is returned when the server replies with a nil value, for instance when getting a key which does not exist.
+var ErrNil = errors.New("redis: nil")
+
+// Client is a client of a Redis server. It is safe for concurrent use.
+type Client struct {
+	cfg  Config
+	pool chan *conn
+}
+
+// NewClient returns a new Client. Connections are opened lazily.
+func NewClient(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = DefaultPoolSize
+	}
+
+	return &Client{
+		cfg:  cfg,
+		pool: make(chan *conn, cfg.PoolSize),
+	}
+}
+
+// Do sends the given command to the server and returns its reply, which is either an int64, a string, a nil
+// interface for nil replies or a []interface{} holding such values or Errors.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.cfg.Timeout, args)
+
+	// Server errors leave the connection in a usable state, contrary to network and protocol errors.
+	var srvErr Error
+	if err != nil && !errors.As(err, &srvErr) {
+		_ = cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+
+	return reply, err
+}
+
+// Int sends the given command to the server and returns its integer reply.
+func (c *Client) Int(ctx context.Context, args ...string) (int64, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	switch r := reply.(type) {
+	case int64:
+		return r, nil
+	case nil:
+		return 0, ErrNil
+	default:
+		return 0, fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+}
+
+// String sends the given command to the server and returns its string reply.
+func (c *Client) String(ctx context.Context, args ...string) (string, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+
+	switch r := reply.(type) {
+	case string:
+		return r, nil
+	case nil:
+		return "", ErrNil
+	default:
+		return "", fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+}
+
+// Strings sends the given command to the server and returns its array of strings reply.
+func (c *Client) Strings(ctx context.Context, args ...string) ([]string, error) {
+	reply, err := c.Do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := reply.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(r))
+		for _, v := range r {
+			value, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("redis: unexpected array value type %T", v)
+			}
+			values = append(values, value)
+		}
+
+		return values, nil
+	case nil:
+		return nil, ErrNil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply type %T", reply)
+	}
+}
+
+// Close closes the idle connections of the client.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.pool <- cn:
+	default:
+		_ = cn.Close()
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout}
+
+	var (
+		nc  net.Conn
+		err error
+	)
+	if c.cfg.TLS {
+		host, _, _ := net.SplitHostPort(c.cfg.Address)
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}}
+		nc, err = tlsDialer.DialContext(ctx, "tcp", c.cfg.Address)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", c.cfg.Address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %q: %w", c.cfg.Address, err)
+	}
+
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	switch {
+	case c.cfg.Username != "":
+		setup = append(setup, []string{"AUTH", c.cfg.Username, c.cfg.Password})
+	case c.cfg.Password != "":
+		setup = append(setup, []string{"AUTH", c.cfg.Password})
+	}
+	if c.cfg.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.cfg.DB)})
+	}
+
+	for _, args := range setup {
+		if _, err = cn.do(ctx, c.cfg.Timeout, args); err != nil {
+			_ = cn.Close()
+			return nil, fmt.Errorf("redis: %s: %w", strings.ToLower(args[0]), err)
+		}
+	}
+
+	return cn, nil
+}
+
+type conn struct {
+	net.Conn
+
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := cn.write(args); err != nil {
+		return nil, err
+	}
+
+	return cn.read()
+}
+
+func (cn *conn) write(args []string) error {
+	_, _ = fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	return cn.w.Flush()
+}
+
+func (cn *conn) read() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+
+	case '-':
+		return nil, Error(line[1:])
+
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk string length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(cn.r, buf); err != nil {
+			return nil, err
+		}
+
+		return string(buf[:n]), nil
+
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		values := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			value, err := cn.read()
+			if err != nil {
+				var srvErr Error
+				if !errors.As(err, &srvErr) {
+					return nil, err
+				}
+
+				// Errors nested in arrays are returned as values.
+				value = srvErr
+			}
+
+			values = append(values, value)
+		}
+
+		return values, nil
+
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis"
+	"github.com/traefik/hub-agent-kubernetes/pkg/redis/redistest"
+)
+
+func TestClient(t *testing.T) {
+	srv := redistest.NewServer(t)
+
+	client := redis.NewClient(redis.Config{Address: srv.Addr, Password: "password", DB: 1})
+	defer func() { _ = client.Close() }()
+
+	ctx := context.Background()
+
+	_, err := client.String(ctx, "GET", "key")
+	assert.ErrorIs(t, err, redis.ErrNil)
+
+	reply, err := client.String(ctx, "SET", "key", "value\r\nwith line breaks", "PX", "60000")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+
+	got, err := client.String(ctx, "GET", "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value\r\nwith line breaks", got)
+
+	ttl, err := client.Int(ctx, "PTTL", "key")
+	require.NoError(t, err)
+	assert.InDelta(t, 60000, ttl, 1000)
+
+	n, err := client.Int(ctx, "INCR", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	n, err = client.Int(ctx, "INCR", "counter")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	_, err = client.Int(ctx, "INCR", "key")
+	assert.EqualError(t, err, "redis: ERR value is not an integer")
+
+	_, err = client.Int(ctx, "SADD", "set", "a", "b")
+	require.NoError(t, err)
+
+	members, err := client.Do(ctx, "SMEMBERS", "set")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, members)
+
+	strs, err := client.Strings(ctx, "SMEMBERS", "set")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, strs)
+
+	n, err = client.Int(ctx, "DEL", "key", "counter", "set")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+}
+
+func TestClient_unreachableServer(t *testing.T) {
+	client := redis.NewClient(redis.Config{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond})
+
+	_, err := client.Do(context.Background(), "PING")
+	assert.Error(t, err)
+}
@@ -0,0 +1,330 @@
+/*
+Copyright (C) 2022-2023 Traefik Labs
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as published
+by the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Package redistest provides an in-memory Redis server for tests. It supports a small subset of the Redis commands.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server is an in-memory Redis server.
+type Server struct {
+	// Addr is the address the server listens on.
+	Addr string
+
+	ln net.Listener
+
+	mu      sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]struct{}
+	expiry  map[string]time.Time
+}
+
+// NewServer starts a new Server which is stopped at the end of the test.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := &Server{
+		Addr:    ln.Addr().String(),
+		ln:      ln,
+		strings: make(map[string]string),
+		sets:    make(map[string]map[string]struct{}),
+		expiry:  make(map[string]time.Time),
+	}
+
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+
+	return s
+}
+
+// Keys returns the keys currently stored by the server, sorted.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for k := range s.strings {
+		if s.alive(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range s.sets {
+		if s.alive(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() { _ = c.Close() }()
+
+	r := bufio.NewReader(c)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		if _, err = io.WriteString(c, s.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
+
+func (s *Server) exec(args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.expiry {
+		if !s.alive(k) {
+			s.del(k)
+		}
+	}
+
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "PING" || cmd == "AUTH" || cmd == "SELECT":
+		return "+OK\r\n"
+
+	case cmd == "GET" && len(args) == 2:
+		v, ok := s.strings[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+
+	case cmd == "SET" && len(args) >= 3:
+		return s.set(args[1], args[2], args[3:])
+
+	case cmd == "DEL" && len(args) >= 2:
+		var n int
+		for _, k := range args[1:] {
+			if s.exists(k) {
+				n++
+			}
+			s.del(k)
+		}
+		return integer(n)
+
+	case cmd == "INCR" && len(args) == 2:
+		v, err := strconv.Atoi(s.strings[args[1]])
+		if err != nil && s.strings[args[1]] != "" {
+			return "-ERR value is not an integer\r\n"
+		}
+		s.strings[args[1]] = strconv.Itoa(v + 1)
+		return integer(v + 1)
+
+	case (cmd == "PEXPIRE" || cmd == "EXPIRE") && len(args) == 3:
+		ttl, err := strconv.Atoi(args[2])
+		if err != nil {
+			return "-ERR value is not an integer\r\n"
+		}
+		if !s.exists(args[1]) {
+			return integer(0)
+		}
+		unit := time.Millisecond
+		if cmd == "EXPIRE" {
+			unit = time.Second
+		}
+		s.expiry[args[1]] = time.Now().Add(time.Duration(ttl) * unit)
+		return integer(1)
+
+	case cmd == "PTTL" && len(args) == 2:
+		if !s.exists(args[1]) {
+			return integer(-2)
+		}
+		exp, ok := s.expiry[args[1]]
+		if !ok {
+			return integer(-1)
+		}
+		return integer(int(time.Until(exp).Milliseconds()))
+
+	case cmd == "SADD" && len(args) >= 3:
+		set, ok := s.sets[args[1]]
+		if !ok {
+			set = make(map[string]struct{})
+			s.sets[args[1]] = set
+		}
+		var n int
+		for _, m := range args[2:] {
+			if _, ok = set[m]; !ok {
+				n++
+			}
+			set[m] = struct{}{}
+		}
+		return integer(n)
+
+	case cmd == "SREM" && len(args) >= 3:
+		var n int
+		for _, m := range args[2:] {
+			if _, ok := s.sets[args[1]][m]; ok {
+				n++
+				delete(s.sets[args[1]], m)
+			}
+		}
+		return integer(n)
+
+	case cmd == "SMEMBERS" && len(args) == 2:
+		members := make([]string, 0, len(s.sets[args[1]]))
+		for m := range s.sets[args[1]] {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+
+		reply := fmt.Sprintf("*%d\r\n", len(members))
+		for _, m := range members {
+			reply += bulk(m)
+		}
+		return reply
+
+	default:
+		return fmt.Sprintf("-ERR unsupported command %q\r\n", args[0])
+	}
+}
+
+func (s *Server) set(key, value string, opts []string) string {
+	var (
+		ttl time.Duration
+		nx  bool
+	)
+	for i := 0; i < len(opts); i++ {
+		switch strings.ToUpper(opts[i]) {
+		case "NX":
+			nx = true
+		case "PX", "EX":
+			if i+1 >= len(opts) {
+				return "-ERR syntax error\r\n"
+			}
+			n, err := strconv.Atoi(opts[i+1])
+			if err != nil {
+				return "-ERR value is not an integer\r\n"
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(opts[i]) == "EX" {
+				unit = time.Second
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	if nx && s.exists(key) {
+		return "$-1\r\n"
+	}
+
+	s.del(key)
+	s.strings[key] = value
+	if ttl > 0 {
+		s.expiry[key] = time.Now().Add(ttl)
+	}
+
+	return "+OK\r\n"
+}
+
+func (s *Server) alive(key string) bool {
+	exp, ok := s.expiry[key]
+	return !ok || time.Now().Before(exp)
+}
+
+func (s *Server) exists(key string) bool {
+	_, isString := s.strings[key]
+	_, isSet := s.sets[key]
+	return (isString || isSet) && s.alive(key)
+}
+
+func (s *Server) del(key string) {
+	delete(s.strings, key)
+	delete(s.sets, key)
+	delete(s.expiry, key)
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+func integer(n int) string {
+	return fmt.Sprintf(":%d\r\n", n)
+}
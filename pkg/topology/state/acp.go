@@ -23,6 +23,7 @@ import (
 	hubv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/hub/v1alpha1"
 	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
 	"github.com/traefik/hub-agent-kubernetes/pkg/optional"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
@@ -37,7 +38,8 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 	result := make(map[string]*AccessControlPolicy)
 	for _, policy := range policies {
 		acp := &AccessControlPolicy{
-			Name: policy.Name,
+			Name:            policy.Name,
+			EnforcementMode: policy.Spec.EnforcementMode,
 		}
 
 		switch {
@@ -51,16 +53,7 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 
 		case policy.Spec.APIKey != nil:
 			acp.Method = "apiKey"
-			acp.APIKey = &AccessControlPolicyAPIKey{
-				KeySource: TokenSource{
-					Header:           policy.Spec.APIKey.KeySource.Header,
-					HeaderAuthScheme: policy.Spec.APIKey.KeySource.HeaderAuthScheme,
-					Query:            policy.Spec.APIKey.KeySource.Query,
-					Cookie:           policy.Spec.APIKey.KeySource.Cookie,
-				},
-				Keys:           redactKeys(policy.Spec.APIKey.Keys),
-				ForwardHeaders: policy.Spec.APIKey.ForwardHeaders,
-			}
+			acp.APIKey = makeAccessControlPolicyAPIKey(policy.Spec.APIKey)
 
 		case policy.Spec.OIDC != nil:
 			acp.Method = "oidc"
@@ -74,23 +67,108 @@ func (f *Fetcher) getAccessControlPolicies() (map[string]*AccessControlPolicy, e
 			acp.Method = "oAuthIntro"
 			acp.OAuthIntro = makeAccessControlPolicyOAuthIntro(policy.Spec.OAuthIntro)
 
+		case policy.Spec.MTLS != nil:
+			acp.Method = "mtls"
+			acp.MTLS = makeAccessControlPolicyMTLS(policy.Spec.MTLS)
+
+		case policy.Spec.IPAllowList != nil:
+			acp.Method = "ipAllowList"
+			acp.IPAllowList = makeAccessControlPolicyIPAllowList(policy.Spec.IPAllowList)
+
+		case policy.Spec.ExternalAuthz != nil:
+			acp.Method = "externalAuthz"
+			acp.ExternalAuthz = makeAccessControlPolicyExternalAuthz(policy.Spec.ExternalAuthz)
+
+		case len(policy.Spec.AnyOf) > 0:
+			acp.Method = "anyOf"
+			acp.AnyOf = makeAccessControlPolicyMethods(policy.Spec.AnyOf)
+
+		case len(policy.Spec.AllOf) > 0:
+			acp.Method = "allOf"
+			acp.AllOf = makeAccessControlPolicyMethods(policy.Spec.AllOf)
+
 		default:
 			continue
 		}
 
+		if policy.Spec.IssueToken != nil {
+			acp.IssueToken = makeAccessControlPolicyIssueToken(policy.Spec.IssueToken)
+		}
+
+		if policy.Spec.UpstreamToken != nil {
+			acp.UpstreamToken = makeAccessControlPolicyUpstreamToken(policy.Spec.UpstreamToken)
+		}
+
+		if policy.Spec.DenialResponse != nil {
+			acp.DenialResponse = makeAccessControlPolicyDenialResponse(policy.Spec.DenialResponse)
+		}
+
 		result[policy.Name] = acp
 	}
 
 	return result, nil
 }
 
+func makeAccessControlPolicyMethods(methods []hubv1alpha1.AccessControlPolicyMethod) []AccessControlPolicyMethod {
+	result := make([]AccessControlPolicyMethod, 0, len(methods))
+	for _, method := range methods {
+		var m AccessControlPolicyMethod
+		switch {
+		case method.BasicAuth != nil:
+			m.Method = "basicAuth"
+			m.BasicAuth = makeAccessControlBasicAuth(method.BasicAuth)
+
+		case method.JWT != nil:
+			m.Method = "jwt"
+			m.JWT = makeAccessControlPolicyJWT(method.JWT)
+
+		case method.APIKey != nil:
+			m.Method = "apiKey"
+			m.APIKey = makeAccessControlPolicyAPIKey(method.APIKey)
+
+		case method.OAuthIntro != nil:
+			m.Method = "oAuthIntro"
+			m.OAuthIntro = makeAccessControlPolicyOAuthIntro(method.OAuthIntro)
+
+		case method.MTLS != nil:
+			m.Method = "mtls"
+			m.MTLS = makeAccessControlPolicyMTLS(method.MTLS)
+
+		case method.IPAllowList != nil:
+			m.Method = "ipAllowList"
+			m.IPAllowList = makeAccessControlPolicyIPAllowList(method.IPAllowList)
+
+		case method.ExternalAuthz != nil:
+			m.Method = "externalAuthz"
+			m.ExternalAuthz = makeAccessControlPolicyExternalAuthz(method.ExternalAuthz)
+
+		default:
+			continue
+		}
+
+		result = append(result, m)
+	}
+
+	return result
+}
+
 func makeAccessControlBasicAuth(cfg *hubv1alpha1.AccessControlPolicyBasicAuth) *AccessControlPolicyBasicAuth {
-	return &AccessControlPolicyBasicAuth{
+	policy := &AccessControlPolicyBasicAuth{
 		Users:                    redactPasswords(cfg.Users),
 		Realm:                    cfg.Realm,
 		StripAuthorizationHeader: cfg.StripAuthorizationHeader,
 		ForwardUsernameHeader:    cfg.ForwardUsernameHeader,
+		Lockout:                  makeAccessControlPolicyLockout(cfg.Lockout),
+	}
+
+	if cfg.UsersSecret != nil {
+		policy.UsersSecret = &SecretReference{
+			Name:      cfg.UsersSecret.Name,
+			Namespace: cfg.UsersSecret.Namespace,
+		}
 	}
+
+	return policy
 }
 
 func makeAccessControlPolicyJWT(cfg *hubv1alpha1.AccessControlPolicyJWT) *AccessControlPolicyJWT {
@@ -103,12 +181,54 @@ func makeAccessControlPolicyJWT(cfg *hubv1alpha1.AccessControlPolicyJWT) *Access
 		ForwardHeaders:             cfg.ForwardHeaders,
 		TokenQueryKey:              cfg.TokenQueryKey,
 		Claims:                     cfg.Claims,
+		GroupsClaim:                cfg.GroupsClaim,
+		Issuer:                     cfg.Issuer,
+		Audience:                   cfg.Audience,
+		Algorithms:                 cfg.Algorithms,
+		LeewaySeconds:              cfg.LeewaySeconds,
 	}
 
 	if cfg.SigningSecret != "" {
 		policy.SigningSecret = redactedValue
 	}
 
+	if cfg.DecryptionKeySecret != nil {
+		policy.DecryptionKeySecret = &SecretReference{
+			Name:      cfg.DecryptionKeySecret.Name,
+			Namespace: cfg.DecryptionKeySecret.Namespace,
+		}
+	}
+
+	return policy
+}
+
+func makeAccessControlPolicyAPIKey(cfg *hubv1alpha1.AccessControlPolicyAPIKey) *AccessControlPolicyAPIKey {
+	policy := &AccessControlPolicyAPIKey{
+		KeySource: TokenSource{
+			Header:           cfg.KeySource.Header,
+			HeaderAuthScheme: cfg.KeySource.HeaderAuthScheme,
+			Query:            cfg.KeySource.Query,
+			Cookie:           cfg.KeySource.Cookie,
+		},
+		Keys:           redactKeys(cfg.Keys),
+		ForwardHeaders: cfg.ForwardHeaders,
+		Lockout:        makeAccessControlPolicyLockout(cfg.Lockout),
+	}
+
+	for _, ref := range cfg.KeySecrets {
+		policy.KeySecrets = append(policy.KeySecrets, SecretReference{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+	}
+
+	if cfg.KeySecretSelector != nil {
+		policy.KeySecretSelector = &SecretSelector{
+			Namespace: cfg.KeySecretSelector.Namespace,
+			Selector:  metav1.FormatLabelSelector(&cfg.KeySecretSelector.Selector),
+		}
+	}
+
 	return policy
 }
 
@@ -122,6 +242,14 @@ func makeAccessControlOIDC(cfg *hubv1alpha1.AccessControlPolicyOIDC) *AccessCont
 		AuthParams:     cfg.AuthParams,
 		ForwardHeaders: cfg.ForwardHeaders,
 		Claims:         cfg.Claims,
+		GroupsClaim:    cfg.GroupsClaim,
+
+		ProviderLogout:        cfg.ProviderLogout,
+		PostLogoutRedirectURL: cfg.PostLogoutRedirectURL,
+		FrontChannelLogoutURL: cfg.FrontChannelLogoutURL,
+		ForwardAccessToken:    cfg.ForwardAccessToken,
+		AccessTokenHeader:     cfg.AccessTokenHeader,
+		FetchUserInfo:         cfg.FetchUserInfo,
 	}
 
 	if cfg.Secret != nil {
@@ -147,6 +275,71 @@ func makeAccessControlOIDC(cfg *hubv1alpha1.AccessControlPolicyOIDC) *AccessCont
 			SameSite: cfg.Session.SameSite,
 			Secure:   cfg.Session.Secure,
 			Refresh:  cfg.Session.Refresh,
+			Store:    cfg.Session.Store,
+
+			KeyGracePeriodSeconds: cfg.Session.KeyGracePeriodSeconds,
+		}
+
+		if cfg.Session.KeysSecret != nil {
+			policy.Session.KeysSecret = &SecretReference{
+				Name:      cfg.Session.KeysSecret.Name,
+				Namespace: cfg.Session.KeysSecret.Namespace,
+			}
+		}
+	}
+
+	return policy
+}
+
+func makeAccessControlPolicyIssueToken(cfg *hubv1alpha1.AccessControlPolicyIssueToken) *AccessControlPolicyIssueToken {
+	return &AccessControlPolicyIssueToken{
+		KeySecret: SecretReference{
+			Name:      cfg.KeySecret.Name,
+			Namespace: cfg.KeySecret.Namespace,
+		},
+		Header:     cfg.Header,
+		Issuer:     cfg.Issuer,
+		Audience:   cfg.Audience,
+		TTLSeconds: cfg.TTLSeconds,
+		Claims:     cfg.Claims,
+	}
+}
+
+func makeAccessControlPolicyUpstreamToken(cfg *hubv1alpha1.AccessControlPolicyUpstreamToken) *AccessControlPolicyUpstreamToken {
+	policy := &AccessControlPolicyUpstreamToken{
+		Config: httpclient.Config{
+			TimeoutSeconds: optional.NewInt(cfg.TimeoutSeconds),
+			MaxRetries:     optional.NewInt(cfg.MaxRetries),
+		},
+		TokenURL: cfg.TokenURL,
+		Secret: SecretReference{
+			Name:      cfg.Secret.Name,
+			Namespace: cfg.Secret.Namespace,
+		},
+		Scopes:         cfg.Scopes,
+		EndpointParams: cfg.EndpointParams,
+	}
+
+	if cfg.TLS != nil {
+		policy.TLS = &httpclient.ConfigTLS{
+			CABundle:           cfg.TLS.CABundle,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+	}
+
+	return policy
+}
+
+func makeAccessControlPolicyDenialResponse(cfg *hubv1alpha1.AccessControlPolicyDenialResponse) *AccessControlPolicyDenialResponse {
+	policy := &AccessControlPolicyDenialResponse{
+		ProblemDetails: cfg.ProblemDetails,
+	}
+
+	if cfg.HTMLTemplate != nil {
+		policy.HTMLTemplate = &ConfigMapKeyReference{
+			Name:      cfg.HTMLTemplate.Name,
+			Namespace: cfg.HTMLTemplate.Namespace,
+			Key:       cfg.HTMLTemplate.Key,
 		}
 	}
 
@@ -186,6 +379,16 @@ func makeAccessControlOIDCGoogle(cfg *hubv1alpha1.AccessControlPolicyOIDCGoogle)
 			SameSite: cfg.Session.SameSite,
 			Secure:   cfg.Session.Secure,
 			Refresh:  cfg.Session.Refresh,
+			Store:    cfg.Session.Store,
+
+			KeyGracePeriodSeconds: cfg.Session.KeyGracePeriodSeconds,
+		}
+
+		if cfg.Session.KeysSecret != nil {
+			policy.Session.KeysSecret = &SecretReference{
+				Name:      cfg.Session.KeysSecret.Name,
+				Namespace: cfg.Session.KeysSecret.Namespace,
+			}
 		}
 	}
 
@@ -195,9 +398,18 @@ func makeAccessControlOIDCGoogle(cfg *hubv1alpha1.AccessControlPolicyOIDCGoogle)
 func makeAccessControlPolicyOAuthIntro(cfg *hubv1alpha1.AccessControlOAuthIntro) *AccessControlPolicyOAuthIntro {
 	policy := &AccessControlPolicyOAuthIntro{
 		Claims:         cfg.Claims,
+		GroupsClaim:    cfg.GroupsClaim,
 		ForwardHeaders: cfg.ForwardHeaders,
 	}
 
+	if cfg.Cache != nil {
+		policy.Cache = &OAuthIntroCache{
+			MaxSize:            cfg.Cache.MaxSize,
+			MaxTTLSeconds:      cfg.Cache.MaxTTLSeconds,
+			NegativeTTLSeconds: cfg.Cache.NegativeTTLSeconds,
+		}
+	}
+
 	policy.ClientConfig = ClientConfig{
 		Config: httpclient.Config{
 			TimeoutSeconds: optional.NewInt(cfg.ClientConfig.TimeoutSeconds),
@@ -234,6 +446,66 @@ func makeAccessControlPolicyOAuthIntro(cfg *hubv1alpha1.AccessControlOAuthIntro)
 	return policy
 }
 
+func makeAccessControlPolicyIPAllowList(cfg *hubv1alpha1.AccessControlPolicyIPAllowList) *AccessControlPolicyIPAllowList {
+	return &AccessControlPolicyIPAllowList{
+		SourceRanges:      cfg.SourceRanges,
+		DeniedRanges:      cfg.DeniedRanges,
+		TrustedProxyDepth: cfg.TrustedProxyDepth,
+	}
+}
+
+func makeAccessControlPolicyExternalAuthz(cfg *hubv1alpha1.AccessControlPolicyExternalAuthz) *AccessControlPolicyExternalAuthz {
+	policy := &AccessControlPolicyExternalAuthz{
+		Config: httpclient.Config{
+			TimeoutSeconds: optional.NewInt(cfg.TimeoutSeconds),
+			MaxRetries:     optional.NewInt(cfg.MaxRetries),
+		},
+		URL:            cfg.URL,
+		RequestHeaders: cfg.RequestHeaders,
+		ForwardHeaders: cfg.ForwardHeaders,
+	}
+
+	if cfg.TLS != nil {
+		policy.TLS = &httpclient.ConfigTLS{
+			CABundle:           cfg.TLS.CABundle,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		}
+	}
+
+	if cfg.Cache != nil {
+		policy.Cache = &ExternalAuthzCache{
+			MaxSize:    cfg.Cache.MaxSize,
+			TTLSeconds: cfg.Cache.TTLSeconds,
+		}
+	}
+
+	return policy
+}
+
+func makeAccessControlPolicyMTLS(cfg *hubv1alpha1.AccessControlPolicyMTLS) *AccessControlPolicyMTLS {
+	return &AccessControlPolicyMTLS{
+		CASecret: SecretReference{
+			Name:      cfg.CASecret.Name,
+			Namespace: cfg.CASecret.Namespace,
+		},
+		Claims:         cfg.Claims,
+		ForwardHeaders: cfg.ForwardHeaders,
+	}
+}
+
+func makeAccessControlPolicyLockout(cfg *hubv1alpha1.AccessControlPolicyLockout) *AccessControlPolicyLockout {
+	if cfg == nil {
+		return nil
+	}
+
+	return &AccessControlPolicyLockout{
+		MaxFailuresPerIP:   cfg.MaxFailuresPerIP,
+		MaxFailuresPerUser: cfg.MaxFailuresPerUser,
+		WindowSeconds:      cfg.WindowSeconds,
+		LockoutSeconds:     cfg.LockoutSeconds,
+	}
+}
+
 func redactPasswords(rawUsers []string) string {
 	var users []string
 
@@ -252,11 +524,19 @@ func redactPasswords(rawUsers []string) string {
 func redactKeys(keys []hubv1alpha1.AccessControlPolicyAPIKeyKey) []AccessControlPolicyAPIKeyKey {
 	out := make([]AccessControlPolicyAPIKeyKey, 0, len(keys))
 	for _, key := range keys {
-		out = append(out, AccessControlPolicyAPIKeyKey{
+		k := AccessControlPolicyAPIKeyKey{
 			ID:       key.ID,
 			Metadata: key.Metadata,
 			Value:    "redacted",
-		})
+		}
+		if key.NotBefore != nil {
+			k.NotBefore = &key.NotBefore.Time
+		}
+		if key.ExpiresAt != nil {
+			k.ExpiresAt = &key.ExpiresAt.Time
+		}
+
+		out = append(out, k)
 	}
 	return out
 }
@@ -20,6 +20,7 @@ package state
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,6 +35,10 @@ import (
 )
 
 func TestFetcher_GetAccessControlPolicies(t *testing.T) {
+	// Kubernetes times are parsed in the local time zone.
+	expiresAt := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC).Local()
+	forwardAccessToken := true
+
 	tests := []struct {
 		desc    string
 		fixture string
@@ -50,6 +55,16 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 						Users:                    "user:redacted",
 						StripAuthorizationHeader: true,
 						ForwardUsernameHeader:    "Username",
+						UsersSecret: &SecretReference{
+							Name:      "my-users",
+							Namespace: "default",
+						},
+						Lockout: &AccessControlPolicyLockout{
+							MaxFailuresPerIP:   20,
+							MaxFailuresPerUser: 5,
+							WindowSeconds:      60,
+							LockoutSeconds:     300,
+						},
 					},
 				},
 			},
@@ -68,7 +83,14 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 						},
 						Keys: []AccessControlPolicyAPIKeyKey{
 							{ID: "user-1", Value: "redacted"},
-							{ID: "user-2", Value: "redacted"},
+							{ID: "user-2", Value: "redacted", ExpiresAt: &expiresAt},
+						},
+						KeySecrets: []SecretReference{
+							{Name: "user-3", Namespace: "default"},
+						},
+						KeySecretSelector: &SecretSelector{
+							Namespace: "default",
+							Selector:  "app=my-app",
 						},
 						ForwardHeaders: map[string]string{
 							"Id":    "_id",
@@ -92,6 +114,27 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 						StripAuthorizationHeader:   true,
 						TokenQueryKey:              "token",
 						Claims:                     "Equals(`group`,`dev`)",
+						GroupsClaim:                "groups",
+						Issuer:                     "https://idp.example.com",
+						Audience:                   []string{"api"},
+						Algorithms:                 []string{"RS256"},
+						LeewaySeconds:              30,
+						DecryptionKeySecret: &SecretReference{
+							Name:      "my-decryption-key",
+							Namespace: "default",
+						},
+					},
+					EnforcementMode: "audit",
+					IssueToken: &AccessControlPolicyIssueToken{
+						KeySecret: SecretReference{
+							Name:      "my-signing-key",
+							Namespace: "default",
+						},
+						Header:     "X-Identity",
+						Issuer:     "https://auth.example.com",
+						Audience:   []string{"internal"},
+						TTLSeconds: 30,
+						Claims:     []string{"email"},
 					},
 				},
 			},
@@ -110,9 +153,15 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 							Name:      "my-secret",
 							Namespace: "default",
 						},
-						RedirectURL: "https://foobar.com/callback",
-						LogoutURL:   "https://foobar.com/logout",
-						Scopes:      []string{"scope"},
+						RedirectURL:           "https://foobar.com/callback",
+						LogoutURL:             "https://foobar.com/logout",
+						Scopes:                []string{"scope"},
+						ProviderLogout:        true,
+						PostLogoutRedirectURL: "https://foobar.com/",
+						FrontChannelLogoutURL: "https://foobar.com/frontchannel-logout",
+						ForwardAccessToken:    &forwardAccessToken,
+						AccessTokenHeader:     "X-Access-Token",
+						FetchUserInfo:         true,
 						AuthParams: map[string]string{
 							"hd": "example.com",
 						},
@@ -122,7 +171,16 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 							SameSite: "lax",
 							Secure:   true,
 						},
-						Claims: "Equals(`group`,`dev`)",
+						Session: &AuthSession{
+							Store: "redis",
+							KeysSecret: &SecretReference{
+								Name:      "my-session-keys",
+								Namespace: "default",
+							},
+							KeyGracePeriodSeconds: 3600,
+						},
+						Claims:      "Equals(`group`,`dev`)",
+						GroupsClaim: "groups",
 					},
 				},
 			},
@@ -192,10 +250,140 @@ func TestFetcher_GetAccessControlPolicies(t *testing.T) {
 							Query:            "token",
 							Cookie:           "token",
 						},
-						Claims: "Equals(`group`, `dev`)",
+						Claims:      "Equals(`group`, `dev`)",
+						GroupsClaim: "groups",
 						ForwardHeaders: map[string]string{
 							"Group": "group",
 						},
+						Cache: &OAuthIntroCache{
+							MaxSize:            500,
+							MaxTTLSeconds:      60,
+							NegativeTTLSeconds: 5,
+						},
+					},
+					DenialResponse: &AccessControlPolicyDenialResponse{
+						ProblemDetails: true,
+						HTMLTemplate: &ConfigMapKeyReference{
+							Name:      "my-denial-templates",
+							Namespace: "default",
+							Key:       "denied.html",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:    "mTLS",
+			fixture: "fixtures/acp/mtls.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "mtls",
+					MTLS: &AccessControlPolicyMTLS{
+						CASecret: SecretReference{
+							Name:      "my-ca",
+							Namespace: "default",
+						},
+						Claims: "Equals(`subject.organization`, `acme`)",
+						ForwardHeaders: map[string]string{
+							"Client-Cn": "subject.commonName",
+						},
+					},
+					UpstreamToken: &AccessControlPolicyUpstreamToken{
+						Config: httpclient.Config{
+							TLS: &httpclient.ConfigTLS{
+								CABundle: "<bundle>",
+							},
+							TimeoutSeconds: optional.NewInt(10),
+							MaxRetries:     optional.NewInt(2),
+						},
+						TokenURL: "https://idp.auth.svc.cluster.local/oauth2/token",
+						Secret: SecretReference{
+							Name:      "my-client",
+							Namespace: "default",
+						},
+						Scopes: []string{"legacy"},
+						EndpointParams: map[string]string{
+							"audience": "legacy-api",
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:    "IP allow list",
+			fixture: "fixtures/acp/ip-allow-list.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "ipAllowList",
+					IPAllowList: &AccessControlPolicyIPAllowList{
+						SourceRanges:      []string{"10.0.0.0/8", "192.168.1.1"},
+						DeniedRanges:      []string{"10.1.0.0/16"},
+						TrustedProxyDepth: 1,
+					},
+				},
+			},
+		},
+		{
+			desc:    "external authz",
+			fixture: "fixtures/acp/external-authz.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "externalAuthz",
+					ExternalAuthz: &AccessControlPolicyExternalAuthz{
+						Config: httpclient.Config{
+							TLS: &httpclient.ConfigTLS{
+								CABundle: "<bundle>",
+							},
+							TimeoutSeconds: optional.NewInt(2),
+							MaxRetries:     optional.NewInt(1),
+						},
+						URL:            "https://entitlements.default.svc.cluster.local/authorize",
+						RequestHeaders: []string{"Authorization", "X-Tenant"},
+						ForwardHeaders: []string{"X-Entitlements"},
+						Cache: &ExternalAuthzCache{
+							MaxSize:    100,
+							TTLSeconds: 30,
+						},
+					},
+				},
+			},
+		},
+		{
+			desc:    "any of",
+			fixture: "fixtures/acp/any-of.yml",
+			want: map[string]*AccessControlPolicy{
+				"my-acp": {
+					Name:   "my-acp",
+					Method: "anyOf",
+					AnyOf: []AccessControlPolicyMethod{
+						{
+							Method: "jwt",
+							JWT: &AccessControlPolicyJWT{
+								SigningSecret:  "redacted",
+								ForwardHeaders: map[string]string{"Sub": "sub"},
+							},
+						},
+						{
+							Method: "apiKey",
+							APIKey: &AccessControlPolicyAPIKey{
+								KeySource: TokenSource{
+									Header: "Api-Key",
+								},
+								Keys: []AccessControlPolicyAPIKeyKey{
+									{ID: "user-1", Value: "redacted"},
+								},
+								ForwardHeaders: map[string]string{"Sub": "_id"},
+							},
+						},
+						{
+							Method: "ipAllowList",
+							IPAllowList: &AccessControlPolicyIPAllowList{
+								SourceRanges: []string{"10.0.0.0/8"},
+							},
+						},
 					},
 				},
 			},
@@ -18,6 +18,8 @@ along with this program. If not, see <https://www.gnu.org/licenses/>.
 package state
 
 import (
+	"time"
+
 	traefikv1alpha1 "github.com/traefik/hub-agent-kubernetes/pkg/crd/api/traefik/v1alpha1"
 	"github.com/traefik/hub-agent-kubernetes/pkg/httpclient"
 	corev1 "k8s.io/api/core/v1"
@@ -121,14 +123,72 @@ type RouteService struct {
 
 // AccessControlPolicy describes an Access Control Policy configured within a cluster.
 type AccessControlPolicy struct {
-	Name       string                         `json:"name"`
-	Method     string                         `json:"method"`
-	JWT        *AccessControlPolicyJWT        `json:"jwt,omitempty"`
-	APIKey     *AccessControlPolicyAPIKey     `json:"apiKey,omitempty"`
-	BasicAuth  *AccessControlPolicyBasicAuth  `json:"basicAuth,omitempty"`
-	OIDC       *AccessControlPolicyOIDC       `json:"oidc,omitempty"`
-	OIDCGoogle *AccessControlPolicyOIDCGoogle `json:"oidcGoogle,omitempty"`
-	OAuthIntro *AccessControlPolicyOAuthIntro `json:"oAuthIntro,omitempty"`
+	Name          string                            `json:"name"`
+	Method        string                            `json:"method"`
+	JWT           *AccessControlPolicyJWT           `json:"jwt,omitempty"`
+	APIKey        *AccessControlPolicyAPIKey        `json:"apiKey,omitempty"`
+	BasicAuth     *AccessControlPolicyBasicAuth     `json:"basicAuth,omitempty"`
+	OIDC          *AccessControlPolicyOIDC          `json:"oidc,omitempty"`
+	OIDCGoogle    *AccessControlPolicyOIDCGoogle    `json:"oidcGoogle,omitempty"`
+	OAuthIntro    *AccessControlPolicyOAuthIntro    `json:"oAuthIntro,omitempty"`
+	MTLS          *AccessControlPolicyMTLS          `json:"mtls,omitempty"`
+	IPAllowList   *AccessControlPolicyIPAllowList   `json:"ipAllowList,omitempty"`
+	ExternalAuthz *AccessControlPolicyExternalAuthz `json:"externalAuthz,omitempty"`
+	AnyOf         []AccessControlPolicyMethod       `json:"anyOf,omitempty"`
+	AllOf         []AccessControlPolicyMethod       `json:"allOf,omitempty"`
+
+	EnforcementMode string                             `json:"enforcementMode,omitempty"`
+	IssueToken      *AccessControlPolicyIssueToken     `json:"issueToken,omitempty"`
+	UpstreamToken   *AccessControlPolicyUpstreamToken  `json:"upstreamToken,omitempty"`
+	DenialResponse  *AccessControlPolicyDenialResponse `json:"denialResponse,omitempty"`
+}
+
+// AccessControlPolicyIssueToken holds the configuration of the identity tokens issued for the requests granted by an
+// access control policy.
+type AccessControlPolicyIssueToken struct {
+	KeySecret  SecretReference `json:"keySecret"`
+	Header     string          `json:"header,omitempty"`
+	Issuer     string          `json:"issuer,omitempty"`
+	Audience   []string        `json:"audience,omitempty"`
+	TTLSeconds int             `json:"ttlSeconds,omitempty"`
+	Claims     []string        `json:"claims,omitempty"`
+}
+
+// AccessControlPolicyUpstreamToken holds the configuration of the access tokens forwarded to upstream services for the
+// requests granted by an access control policy.
+type AccessControlPolicyUpstreamToken struct {
+	httpclient.Config
+
+	TokenURL       string            `json:"tokenUrl"`
+	Secret         SecretReference   `json:"secret"`
+	Scopes         []string          `json:"scopes,omitempty"`
+	EndpointParams map[string]string `json:"endpointParams,omitempty"`
+}
+
+// AccessControlPolicyDenialResponse holds the configuration of the responses to the requests denied by an access
+// control policy.
+type AccessControlPolicyDenialResponse struct {
+	ProblemDetails bool                   `json:"problemDetails,omitempty"`
+	HTMLTemplate   *ConfigMapKeyReference `json:"htmlTemplate,omitempty"`
+}
+
+// ConfigMapKeyReference references an entry of a ConfigMap.
+type ConfigMapKeyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Key       string `json:"key,omitempty"`
+}
+
+// AccessControlPolicyMethod describes an authentication method of a composite access control policy.
+type AccessControlPolicyMethod struct {
+	Method        string                            `json:"method"`
+	JWT           *AccessControlPolicyJWT           `json:"jwt,omitempty"`
+	APIKey        *AccessControlPolicyAPIKey        `json:"apiKey,omitempty"`
+	BasicAuth     *AccessControlPolicyBasicAuth     `json:"basicAuth,omitempty"`
+	OAuthIntro    *AccessControlPolicyOAuthIntro    `json:"oAuthIntro,omitempty"`
+	MTLS          *AccessControlPolicyMTLS          `json:"mtls,omitempty"`
+	IPAllowList   *AccessControlPolicyIPAllowList   `json:"ipAllowList,omitempty"`
+	ExternalAuthz *AccessControlPolicyExternalAuthz `json:"externalAuthz,omitempty"`
 }
 
 // AccessControlPolicyJWT describes the settings for JWT authentication within an access control policy.
@@ -142,28 +202,55 @@ type AccessControlPolicyJWT struct {
 	ForwardHeaders             map[string]string `json:"forwardHeaders,omitempty"`
 	TokenQueryKey              string            `json:"tokenQueryKey,omitempty"`
 	Claims                     string            `json:"claims,omitempty"`
+	GroupsClaim                string            `json:"groupsClaim,omitempty"`
+	Issuer                     string            `json:"issuer,omitempty"`
+	Audience                   []string          `json:"audience,omitempty"`
+	Algorithms                 []string          `json:"algorithms,omitempty"`
+	LeewaySeconds              int               `json:"leewaySeconds,omitempty"`
+	DecryptionKeySecret        *SecretReference  `json:"decryptionKeySecret,omitempty"`
 }
 
 // AccessControlPolicyBasicAuth holds the HTTP basic authentication configuration.
 type AccessControlPolicyBasicAuth struct {
-	Users                    string `json:"users,omitempty"` // Redacted.
-	Realm                    string `json:"realm,omitempty"`
-	StripAuthorizationHeader bool   `json:"stripAuthorizationHeader,omitempty"`
-	ForwardUsernameHeader    string `json:"forwardUsernameHeader,omitempty"`
+	Users                    string                      `json:"users,omitempty"` // Redacted.
+	Realm                    string                      `json:"realm,omitempty"`
+	StripAuthorizationHeader bool                        `json:"stripAuthorizationHeader,omitempty"`
+	ForwardUsernameHeader    string                      `json:"forwardUsernameHeader,omitempty"`
+	UsersSecret              *SecretReference            `json:"usersSecret,omitempty"`
+	Lockout                  *AccessControlPolicyLockout `json:"lockout,omitempty"`
+}
+
+// AccessControlPolicyLockout holds the lockout configuration of an access control policy.
+type AccessControlPolicyLockout struct {
+	MaxFailuresPerIP   int `json:"maxFailuresPerIP,omitempty"`
+	MaxFailuresPerUser int `json:"maxFailuresPerUser,omitempty"`
+	WindowSeconds      int `json:"windowSeconds,omitempty"`
+	LockoutSeconds     int `json:"lockoutSeconds,omitempty"`
 }
 
 // AccessControlPolicyAPIKey describes the settings for APIKey authentication within an access control policy.
 type AccessControlPolicyAPIKey struct {
-	KeySource      TokenSource                    `json:"keySource,omitempty"`
-	Keys           []AccessControlPolicyAPIKeyKey `json:"keys,omitempty"`
-	ForwardHeaders map[string]string              `json:"forwardHeaders,omitempty"`
+	KeySource         TokenSource                    `json:"keySource,omitempty"`
+	Keys              []AccessControlPolicyAPIKeyKey `json:"keys,omitempty"`
+	KeySecrets        []SecretReference              `json:"keySecrets,omitempty"`
+	KeySecretSelector *SecretSelector                `json:"keySecretSelector,omitempty"`
+	ForwardHeaders    map[string]string              `json:"forwardHeaders,omitempty"`
+	Lockout           *AccessControlPolicyLockout    `json:"lockout,omitempty"`
 }
 
 // AccessControlPolicyAPIKeyKey defines an API key.
 type AccessControlPolicyAPIKeyKey struct {
-	ID       string            `json:"id"`
-	Metadata map[string]string `json:"metadata"`
-	Value    string            `json:"value"` // Redacted.
+	ID        string            `json:"id"`
+	Metadata  map[string]string `json:"metadata"`
+	Value     string            `json:"value"` // Redacted.
+	NotBefore *time.Time        `json:"notBefore,omitempty"`
+	ExpiresAt *time.Time        `json:"expiresAt,omitempty"`
+}
+
+// SecretSelector selects Secrets within a namespace.
+type SecretSelector struct {
+	Namespace string `json:"namespace"`
+	Selector  string `json:"selector"`
 }
 
 // AccessControlPolicyOIDC holds the OIDC configuration.
@@ -179,8 +266,16 @@ type AccessControlPolicyOIDC struct {
 	StateCookie *AuthStateCookie  `json:"stateCookie,omitempty"`
 	Session     *AuthSession      `json:"session,omitempty"`
 
-	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
-	Claims         string            `json:"claims,omitempty"`
+	ProviderLogout        bool   `json:"providerLogout,omitempty"`
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl,omitempty"`
+	FrontChannelLogoutURL string `json:"frontChannelLogoutUrl,omitempty"`
+
+	ForwardHeaders     map[string]string `json:"forwardHeaders,omitempty"`
+	Claims             string            `json:"claims,omitempty"`
+	GroupsClaim        string            `json:"groupsClaim,omitempty"`
+	ForwardAccessToken *bool             `json:"forwardAccessToken,omitempty"`
+	AccessTokenHeader  string            `json:"accessTokenHeader,omitempty"`
+	FetchUserInfo      bool              `json:"fetchUserInfo,omitempty"`
 }
 
 // AccessControlPolicyOIDCGoogle holds the Google OIDC configuration.
@@ -220,6 +315,10 @@ type AuthSession struct {
 	SameSite string `json:"sameSite,omitempty"`
 	Secure   bool   `json:"secure,omitempty"`
 	Refresh  *bool  `json:"refresh,omitempty"`
+	Store    string `json:"store,omitempty"`
+
+	KeysSecret            *SecretReference `json:"keysSecret,omitempty"`
+	KeyGracePeriodSeconds int              `json:"keyGracePeriodSeconds,omitempty"`
 }
 
 // AccessControlPolicyOAuthIntro holds the OAuth 2.0 token introspection configuration.
@@ -227,9 +326,48 @@ type AccessControlPolicyOAuthIntro struct {
 	ClientConfig   ClientConfig      `json:"clientConfig,omitempty"`
 	TokenSource    TokenSource       `json:"tokenSource,omitempty"`
 	Claims         string            `json:"claims,omitempty"`
+	GroupsClaim    string            `json:"groupsClaim,omitempty"`
+	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
+	Cache          *OAuthIntroCache  `json:"cache,omitempty"`
+}
+
+// OAuthIntroCache configures the cache of OAuth 2.0 token introspection results.
+type OAuthIntroCache struct {
+	MaxSize            int `json:"maxSize"`
+	MaxTTLSeconds      int `json:"maxTtlSeconds"`
+	NegativeTTLSeconds int `json:"negativeTtlSeconds"`
+}
+
+// AccessControlPolicyMTLS holds the mutual TLS configuration.
+type AccessControlPolicyMTLS struct {
+	CASecret       SecretReference   `json:"caSecret"`
+	Claims         string            `json:"claims,omitempty"`
 	ForwardHeaders map[string]string `json:"forwardHeaders,omitempty"`
 }
 
+// AccessControlPolicyIPAllowList holds the IP allow list configuration.
+type AccessControlPolicyIPAllowList struct {
+	SourceRanges      []string `json:"sourceRanges,omitempty"`
+	DeniedRanges      []string `json:"deniedRanges,omitempty"`
+	TrustedProxyDepth int      `json:"trustedProxyDepth,omitempty"`
+}
+
+// AccessControlPolicyExternalAuthz holds the external authorization configuration.
+type AccessControlPolicyExternalAuthz struct {
+	httpclient.Config
+
+	URL            string              `json:"url"`
+	RequestHeaders []string            `json:"requestHeaders,omitempty"`
+	ForwardHeaders []string            `json:"forwardHeaders,omitempty"`
+	Cache          *ExternalAuthzCache `json:"cache,omitempty"`
+}
+
+// ExternalAuthzCache configures the cache of external authorization decisions.
+type ExternalAuthzCache struct {
+	MaxSize    int `json:"maxSize"`
+	TTLSeconds int `json:"ttlSeconds"`
+}
+
 // ClientConfig configures the HTTP client of the OAuth 2.0 Token Introspection ACP handler.
 type ClientConfig struct {
 	httpclient.Config